@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+)
+
+// chuckbot doctor [-config path] checks the config, secrets, token, network, Store and file
+// permissions, and exits with 1 if anything would keep the bot from going live
+func doctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a JSON config file")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("FAIL %-22s %s\n", "config", err.Error())
+		os.Exit(1)
+	}
+
+	checks := twitchbot.Doctor(context.Background(), config)
+	printpretty.Flush()
+
+	failures, warnings := 0, 0
+	for _, check := range checks {
+		status := "ok"
+		switch {
+		case !check.Passed:
+			status = "FAIL"
+			failures++
+		case check.Warning:
+			status = "warn"
+			warnings++
+		}
+
+		fmt.Printf("%-4s %-22s %s\n", status, check.Name, check.Detail)
+	}
+
+	switch {
+	case failures > 0:
+		fmt.Printf("\n%d problem(s) to fix before the bot can go live\n", failures)
+		os.Exit(1)
+	case warnings > 0:
+		fmt.Printf("\nReady to go live, with %d warning(s)\n", warnings)
+	default:
+		fmt.Println("\nReady to go live")
+	}
+}
@@ -1,17 +1,233 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
 	"github.com/mike1104/chuckbot/pkg/twitchbot"
 )
 
 func main() {
-	bot := twitchbot.Bot{
-		BotName:          "carlosray__norris",
-		ChannelName:      "mikkeever",
-		Server:           "irc.chat.twitch.tv",
-		Port:             "6697",
-		SecretsPath:      "./secrets.json",
-		WhispersDisabled: true,
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "login":
+			login(os.Args[2:])
+			return
+		case "encrypt-secrets":
+			encryptSecrets(os.Args[2:])
+			return
+		case "tail":
+			tail(os.Args[2:])
+			return
+		case "doctor":
+			doctor(os.Args[2:])
+			return
+		case "migrate":
+			migrate(os.Args[2:])
+			return
+		case "template":
+			template(os.Args[2:])
+			return
+		}
+	}
+
+	configPath := flag.String("config", "", "path to a JSON config file")
+	flag.String("bot-name", "", "the bot's Twitch username")
+	flag.String("channel", "", "channel to join")
+	flag.String("server", "", "Twitch IRC server")
+	flag.String("port", "", "Twitch IRC port")
+	flag.String("secrets", "", "path to the secrets.json holding the OAuth token")
+	flag.String("store", "", "path of a JSON file to keep data in")
+	flag.String("health-address", "", "address to serve health checks on, e.g. :8080")
+	flag.String("timezone", "", "IANA timezone for times typed in chat")
+	flag.Bool("whispers-disabled", false, "never send whispers")
+	flag.Bool("anonymous", false, "read chat as a justinfan login, without a token")
+	flag.Parse()
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err.Error())
 	}
+
+	if needsLogin(config) {
+		err = deviceLogin(config)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	bot, err := twitchbot.NewBot(config)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// SIGHUP, or a change to the file, reloads the same layers
+	bot.ConfigPath = *configPath
+	bot.ConfigLoader = func() (*twitchbot.Config, error) { return loadConfig(*configPath) }
+
 	bot.Start()
 }
+
+// Settings are layered: built in defaults, then the config file, then CHUCKBOT_ environment
+// variables, then flags
+func loadConfig(path string) (*twitchbot.Config, error) {
+	config := twitchbot.DefaultConfig()
+	config.BotName = "carlosray__norris"
+	config.Channel = "mikkeever"
+	config.WhispersDisabled = true
+
+	if path != "" {
+		err := twitchbot.LoadConfigFile(path, &config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := config.ApplyEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	flag.Visit(func(set *flag.Flag) {
+		value := set.Value.String()
+
+		switch set.Name {
+		case "bot-name":
+			config.BotName = value
+		case "channel":
+			config.Channel = value
+		case "server":
+			config.Server = value
+		case "port":
+			config.Port = value
+		case "secrets":
+			config.SecretsPath = value
+		case "store":
+			config.StorePath = value
+		case "health-address":
+			config.HealthAddress = value
+		case "timezone":
+			config.Timezone = value
+		case "whispers-disabled":
+			config.WhispersDisabled, _ = strconv.ParseBool(value)
+		case "anonymous":
+			config.Anonymous, _ = strconv.ParseBool(value)
+		}
+	})
+
+	return &config, nil
+}
+
+// chuckbot login [-config path] [-client-id id] [-secrets path] gets a token for the bot's account
+// and writes it to the secrets file
+func login(args []string) {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a JSON config file")
+	clientID := flags.String("client-id", "", "client ID of a Twitch app registered as a public client")
+	secretsPath := flags.String("secrets", "", "path of the secrets.json to write the token to")
+	flags.Parse(args)
+
+	config := twitchbot.DefaultConfig()
+	config.BotName = "carlosray__norris"
+	if *configPath != "" {
+		err := twitchbot.LoadConfigFile(*configPath, &config)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	err := config.ApplyEnvironment()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *clientID != "" {
+		config.ClientID = *clientID
+	}
+	if *secretsPath != "" {
+		config.SecretsPath = *secretsPath
+	}
+
+	err = deviceLogin(&config)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// On first run, with a client_id but no token or secrets file, offer to log in instead of failing
+func needsLogin(config *twitchbot.Config) bool {
+	if config.Anonymous || config.Token != "" || config.ClientID == "" || (config.SecretsProvider != "" && config.SecretsProvider != "file") {
+		return false
+	}
+	if _, err := os.Stat(config.SecretsPath); !os.IsNotExist(err) {
+		return false
+	}
+
+	// Only when someone is there to enter the code
+	stdin, err := os.Stdin.Stat()
+	return err == nil && stdin.Mode()&os.ModeCharDevice != 0
+}
+
+func deviceLogin(config *twitchbot.Config) error {
+	info, err := twitchbot.DeviceLogin(context.Background(), config, twitchbot.LoginScopes(), func(userCode, verificationURI string) {
+		fmt.Printf("Open %s and enter the code %s, signed in to Twitch as %s\n", verificationURI, userCode, config.BotName)
+	})
+	if err != nil {
+		return err
+	}
+
+	saved := config.SecretsPath
+	switch strings.ToLower(config.SecretsProvider) {
+	case "keychain":
+		saved = "the keychain"
+	case "vault", "aws", "gcp":
+		saved = config.CloudSecrets.Name
+	}
+	fmt.Printf("Logged in as %s, token saved to %s\n", info.Login, saved)
+	if !strings.EqualFold(info.Login, config.BotName) {
+		fmt.Printf("bot_name is %s, set it to %s to use this token\n", config.BotName, info.Login)
+	}
+
+	return nil
+}
+
+// chuckbot encrypt-secrets [-secrets path] [-key-file path] [-decrypt] encrypts a secrets file in
+// place. Without a key file the passphrase is read from CHUCKBOT_SECRETS_PASSPHRASE, or asked for.
+func encryptSecrets(args []string) {
+	flags := flag.NewFlagSet("encrypt-secrets", flag.ExitOnError)
+	secretsPath := flags.String("secrets", "./secrets.json", "path of the secrets file")
+	keyFile := flags.String("key-file", "", "encrypt with the contents of this file instead of a passphrase")
+	decrypt := flags.Bool("decrypt", false, "turn an encrypted file back into plaintext")
+	flags.Parse(args)
+
+	passphrase := os.Getenv("CHUCKBOT_SECRETS_PASSPHRASE")
+	if *keyFile == "" && passphrase == "" {
+		fmt.Fprint(os.Stderr, "Passphrase (shown as you type): ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		passphrase = strings.TrimSpace(line)
+	}
+	if *keyFile != "" {
+		passphrase = ""
+	}
+
+	var err error
+	if *decrypt {
+		err = twitchbot.DecryptSecretsFile(*secretsPath, passphrase, *keyFile)
+	} else {
+		err = twitchbot.EncryptSecretsFile(*secretsPath, passphrase, *keyFile)
+	}
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if *decrypt {
+		fmt.Printf("Decrypted %s\n", *secretsPath)
+		return
+	}
+	fmt.Printf("Encrypted %s, set secrets_passphrase or secrets_key_file so the bot can read it\n", *secretsPath)
+}
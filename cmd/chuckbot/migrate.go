@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+)
+
+// chuckbot migrate [-config path] [-to version] [-status] moves the Store to the latest schema,
+// or back to an older one before downgrading the bot
+func migrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a JSON config file")
+	target := flags.Int("to", twitchbot.LatestStoreVersion(), "the version to migrate up or down to")
+	status := flags.Bool("status", false, "list the migrations and which are applied, without changing anything")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if config.StorePath == "" {
+		log.Fatal("store_path isn't set, so there is no Store to migrate")
+	}
+
+	if *status {
+		version, err := twitchbot.StoreVersion(config)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		fmt.Printf("%s is at version %d\n", config.StorePath, version)
+		for _, migration := range twitchbot.StoreMigrations() {
+			applied := " "
+			if migration.Version <= version {
+				applied = "x"
+			}
+			fmt.Printf("[%s] %3d %s\n", applied, migration.Version, migration.Name)
+		}
+		return
+	}
+
+	from, err := twitchbot.MigrateStore(config, *target)
+	// Let the Store's log of each step out first
+	printpretty.Flush()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if from == *target {
+		fmt.Printf("%s is already at version %d\n", config.StorePath, from)
+		return
+	}
+	fmt.Printf("Migrated %s from version %d to %d\n", config.StorePath, from, *target)
+}
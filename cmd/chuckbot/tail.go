@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+	"github.com/mike1104/chuckbot/pkg/websocket"
+)
+
+// How long to wait before reconnecting to a bot that went away
+const tailRetryWait = 5 * time.Second
+
+// chuckbot tail [-url ws://host:port/events] [-channel name] [-events commands,filters] [-token t]
+// prints a bot's live event feed
+func tail(args []string) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	address := flags.String("url", "ws://localhost:8080/events", "the bot's /events address, on its health_address")
+	channel := flags.String("channel", "", "only events from this channel")
+	events := flags.String("events", "", "comma separated kinds: chat, commands, filters, moderation, raids, subs, follows, redemptions, connection")
+	token := flags.String("token", os.Getenv("CHUCKBOT_ADMIN_TOKEN"), "the bot's admin_token, if it has one")
+	flags.Parse(args)
+
+	feedURL, err := url.Parse(*address)
+	if err != nil {
+		printpretty.Error("%s", err.Error())
+		os.Exit(1)
+	}
+	query := feedURL.Query()
+	if *channel != "" {
+		query.Set("channel", *channel)
+	}
+	if *events != "" {
+		query.Set("events", *events)
+	}
+	feedURL.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if *token != "" {
+		header.Set("Authorization", "Bearer "+*token)
+	}
+
+	for {
+		err = followFeed(feedURL.String(), header)
+		printpretty.Warn("%s, reconnecting in %s", err.Error(), tailRetryWait)
+		time.Sleep(tailRetryWait)
+	}
+}
+
+// Prints events until the connection drops
+func followFeed(address string, header http.Header) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	conn, err := websocket.Dial(ctx, address, header)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	printpretty.Success("Following %s", address)
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		event := twitchbot.FeedEvent{}
+		if json.Unmarshal(data, &event) != nil {
+			continue
+		}
+		printFeedEvent(event)
+	}
+}
+
+func printFeedEvent(event twitchbot.FeedEvent) {
+	where := ""
+	if event.Channel != "" {
+		where = "#" + event.Channel + " "
+	}
+	who := ""
+	if event.User != "" {
+		who = "@" + event.User + ": "
+	}
+	line := fmt.Sprintf("%-10s %s%s%s", event.Kind, where, who, event.Text)
+
+	switch event.Kind {
+	case twitchbot.FeedCommands:
+		// Highlight reads the line as a format
+		printpretty.Highlight(strings.ReplaceAll(line, "%", "%%"), strings.ReplaceAll(event.Text, "%", "%%"))
+	case twitchbot.FeedFilters:
+		printpretty.Warn("%s", line)
+	case twitchbot.FeedModeration:
+		printpretty.Notice("%s", line)
+	case twitchbot.FeedRaids, twitchbot.FeedSubs, twitchbot.FeedFollows, twitchbot.FeedBits, twitchbot.FeedStreams:
+		printpretty.Success("%s", line)
+	case twitchbot.FeedConnection:
+		printpretty.Quiet("%s", line)
+	default:
+		printpretty.Info("%s", line)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+)
+
+// chuckbot template export -channel name [-o file] writes a channel's custom commands and
+// timers as JSON. chuckbot template import -channel name [-replace] file adds them to another.
+func template(args []string) {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		log.Fatal("usage: chuckbot template export|import -config path -channel name ...")
+	}
+
+	flags := flag.NewFlagSet("template "+args[0], flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a JSON config file")
+	channel := flags.String("channel", "", "the channel to export from or import into")
+	output := flags.String("o", "", "file to export to, instead of standard output")
+	replace := flags.Bool("replace", false, "overwrite commands the channel already has")
+	flags.Parse(args[1:])
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *channel == "" {
+		*channel = config.Channel
+	}
+
+	if args[0] == "export" {
+		exported, err := twitchbot.ExportChannelTemplate(config, *channel)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		data, err := json.MarshalIndent(exported, "", "  ")
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		data = append(data, '\n')
+
+		if *output == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		err = ioutil.WriteFile(*output, data, 0644)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("Exported %d commands and %d timers from #%s to %s\n", len(exported.Commands), len(exported.Timers), *channel, *output)
+		return
+	}
+
+	if flags.NArg() != 1 {
+		log.Fatal("usage: chuckbot template import -config path -channel name [-replace] file.json")
+	}
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	imported, err := twitchbot.ParseChannelTemplate(data)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	result, err := twitchbot.ImportChannelTemplate(config, *channel, imported, *replace)
+	printpretty.Flush()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	fmt.Printf("Imported into #%s: %s\n", *channel, result)
+}
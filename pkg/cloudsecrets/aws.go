@@ -0,0 +1,147 @@
+package cloudsecrets
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AWSSecretsManager keeps the secret as a secret string in AWS Secrets Manager
+type AWSSecretsManager struct {
+	// The secret's name or ARN
+	SecretID string
+
+	// Defaults to AWS_REGION, then AWS_DEFAULT_REGION
+	Region string
+
+	// Default to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Defaults to https://secretsmanager.<region>.amazonaws.com
+	Endpoint string
+
+	HTTPClient *http.Client
+}
+
+// Get reads the current version
+func (manager *AWSSecretsManager) Get(ctx context.Context) ([]byte, error) {
+	data, err := manager.call(ctx, "GetSecretValue", map[string]string{"SecretId": manager.SecretID})
+	if err != nil {
+		return nil, wrap("AWSSecretsManager.Get", err)
+	}
+
+	response := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, wrap("AWSSecretsManager.Get", err)
+	}
+
+	return []byte(response.SecretString), nil
+}
+
+// Put stores a new current version. The secret has to exist already.
+func (manager *AWSSecretsManager) Put(ctx context.Context, data []byte) error {
+	_, err := manager.call(ctx, "PutSecretValue", map[string]string{"SecretId": manager.SecretID, "SecretString": string(data)})
+	if err != nil {
+		return wrap("AWSSecretsManager.Put", err)
+	}
+
+	return nil
+}
+
+// Calls an action of the JSON API, signed with Signature Version 4
+func (manager *AWSSecretsManager) call(ctx context.Context, action string, input interface{}) ([]byte, error) {
+	region := firstSet(manager.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	accessKeyID := firstSet(manager.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretAccessKey := firstSet(manager.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	sessionToken := manager.SessionToken
+	if manager.AccessKeyID == "" {
+		sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if region == "" || accessKeyID == "" || secretAccessKey == "" {
+		return nil, errors.New("a region and credentials are required, e.g. from AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	endpoint := manager.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+
+	body := marshal(input)
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	target := "secretsmanager." + action
+
+	header := http.Header{
+		"Content-Type": {"application/x-amz-json-1.1"},
+		"X-Amz-Target": {target},
+		"X-Amz-Date":   {amzDate},
+	}
+
+	canonicalHeaders := "content-type:application/x-amz-json-1.1\nhost:" + host + "\nx-amz-date:" + amzDate + "\n"
+	signedHeaders := "content-type;host;x-amz-date"
+	if sessionToken != "" {
+		header.Set("X-Amz-Security-Token", sessionToken)
+		canonicalHeaders += "x-amz-security-token:" + sessionToken + "\n"
+		signedHeaders += ";x-amz-security-token"
+	}
+	canonicalHeaders += "x-amz-target:" + target + "\n"
+	signedHeaders += ";x-amz-target"
+
+	canonicalRequest := strings.Join([]string{http.MethodPost, "/", "", canonicalHeaders, signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + region + "/secretsmanager/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+
+	data, err := send(ctx, manager.HTTPClient, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", header, body)
+	if failure, ok := err.(*Error); ok && strings.Contains(failure.Message, "ResourceNotFoundException") {
+		failure.StatusCode = http.StatusNotFound
+	}
+
+	return data, err
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
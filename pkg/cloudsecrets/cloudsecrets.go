@@ -0,0 +1,81 @@
+// Package cloudsecrets reads and writes one secret in HashiCorp Vault, AWS Secrets Manager or
+// Google Cloud Secret Manager, using only their HTTP APIs
+package cloudsecrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backend holds one secret, a JSON document
+type Backend interface {
+	Get(ctx context.Context) ([]byte, error)
+	Put(ctx context.Context, data []byte) error
+}
+
+// Sends a request with a JSON body and returns the response body, or an error for a non-2xx status
+func send(ctx context.Context, client *http.Client, method, address string, header http.Header, body []byte) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, address, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	return data, nil
+}
+
+// Error is a request the secret manager refused
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.StatusCode, http.StatusText(err.StatusCode), err.Message)
+}
+
+// NotFound reports whether an error means the secret doesn't exist
+func NotFound(err error) bool {
+	failure, ok := err.(*Error)
+	return ok && failure.StatusCode == http.StatusNotFound
+}
+
+func wrap(function string, err error) error {
+	if failure, ok := err.(*Error); ok {
+		return &Error{StatusCode: failure.StatusCode, Message: function + ": " + failure.Message}
+	}
+
+	return fmt.Errorf("%s: %s", function, err.Error())
+}
+
+func marshal(value interface{}) []byte {
+	data, _ := json.Marshal(value)
+	return data
+}
@@ -0,0 +1,100 @@
+package cloudsecrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// Where GCE, GKE and Cloud Run hand out the attached service account's tokens
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPSecretManager keeps the secret in Google Cloud Secret Manager, adding a version on every Put
+type GCPSecretManager struct {
+	Project string
+	Secret  string
+
+	// An OAuth access token. Defaults to GOOGLE_OAUTH_ACCESS_TOKEN, then the metadata server of
+	// the machine the bot runs on.
+	AccessToken string
+
+	// Defaults to https://secretmanager.googleapis.com
+	Endpoint string
+
+	HTTPClient *http.Client
+}
+
+// Get reads the latest version
+func (manager *GCPSecretManager) Get(ctx context.Context) ([]byte, error) {
+	header, err := manager.header(ctx)
+	if err != nil {
+		return nil, wrap("GCPSecretManager.Get", err)
+	}
+
+	data, err := send(ctx, manager.HTTPClient, http.MethodGet, manager.url()+"/versions/latest:access", header, nil)
+	if err != nil {
+		return nil, wrap("GCPSecretManager.Get", err)
+	}
+
+	response := struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}{}
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, wrap("GCPSecretManager.Get", err)
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+	if err != nil {
+		return nil, wrap("GCPSecretManager.Get", err)
+	}
+
+	return secret, nil
+}
+
+// Put adds a new version. The secret has to exist already.
+func (manager *GCPSecretManager) Put(ctx context.Context, data []byte) error {
+	header, err := manager.header(ctx)
+	if err != nil {
+		return wrap("GCPSecretManager.Put", err)
+	}
+
+	body := marshal(map[string]map[string]string{"payload": {"data": base64.StdEncoding.EncodeToString(data)}})
+	_, err = send(ctx, manager.HTTPClient, http.MethodPost, manager.url()+":addVersion", header, body)
+	if err != nil {
+		return wrap("GCPSecretManager.Put", err)
+	}
+
+	return nil
+}
+
+func (manager *GCPSecretManager) url() string {
+	endpoint := firstSet(manager.Endpoint, "https://secretmanager.googleapis.com")
+	return endpoint + "/v1/projects/" + manager.Project + "/secrets/" + manager.Secret
+}
+
+func (manager *GCPSecretManager) header(ctx context.Context) (http.Header, error) {
+	token := firstSet(manager.AccessToken, os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"))
+	if token == "" {
+		data, err := send(ctx, manager.HTTPClient, http.MethodGet, gcpMetadataTokenURL, http.Header{"Metadata-Flavor": {"Google"}}, nil)
+		if err != nil {
+			return nil, errors.New("no GOOGLE_OAUTH_ACCESS_TOKEN and no metadata server: " + err.Error())
+		}
+
+		response := struct {
+			AccessToken string `json:"access_token"`
+		}{}
+		err = json.Unmarshal(data, &response)
+		if err != nil {
+			return nil, err
+		}
+		token = response.AccessToken
+	}
+
+	return http.Header{"Authorization": {"Bearer " + token}, "Content-Type": {"application/json"}}, nil
+}
@@ -0,0 +1,80 @@
+package cloudsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault keeps the secret in a KV version 2 secrets engine, with the document's keys as the
+// secret's keys
+type Vault struct {
+	// Defaults to VAULT_ADDR
+	Address string
+
+	// Defaults to VAULT_TOKEN
+	Token string
+
+	// Where the KV engine is mounted. Defaults to "secret".
+	Mount string
+
+	// The secret's path in the engine, e.g. "chuckbot/prod"
+	Path string
+
+	HTTPClient *http.Client
+}
+
+// Get reads the latest version
+func (vault *Vault) Get(ctx context.Context) ([]byte, error) {
+	data, err := send(ctx, vault.HTTPClient, http.MethodGet, vault.url(), vault.header(), nil)
+	if err != nil {
+		return nil, wrap("Vault.Get", err)
+	}
+
+	response := struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}{}
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, wrap("Vault.Get", err)
+	}
+
+	return response.Data.Data, nil
+}
+
+// Put writes a new version
+func (vault *Vault) Put(ctx context.Context, data []byte) error {
+	body := marshal(map[string]json.RawMessage{"data": data})
+	_, err := send(ctx, vault.HTTPClient, http.MethodPost, vault.url(), vault.header(), body)
+	if err != nil {
+		return wrap("Vault.Put", err)
+	}
+
+	return nil
+}
+
+func (vault *Vault) url() string {
+	address := vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	mount := vault.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return strings.TrimSuffix(address, "/") + "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.Trim(vault.Path, "/")
+}
+
+func (vault *Vault) header() http.Header {
+	token := vault.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	return http.Header{"X-Vault-Token": {token}, "Content-Type": {"application/json"}}
+}
@@ -0,0 +1,142 @@
+package eventsub
+
+import "time"
+
+// Subscription types the bot knows how to decode
+const (
+	TypeFollow     = "channel.follow"
+	TypeSubscribe  = "channel.subscribe"
+	TypeRaid       = "channel.raid"
+	TypeRedemption = "channel.channel_points_custom_reward_redemption.add"
+	TypeOnline     = "stream.online"
+	TypeOffline    = "stream.offline"
+	TypeUpdate     = "channel.update"
+)
+
+// Follows is new followers of a channel. The token must belong to the broadcaster or one of
+// their moderators, moderatorID, and have the moderator:read:followers scope.
+func Follows(broadcasterID, moderatorID string) Subscription {
+	return Subscription{Type: TypeFollow, Version: "2", Condition: map[string]string{
+		"broadcaster_user_id": broadcasterID,
+		"moderator_user_id":   moderatorID,
+	}}
+}
+
+// Subscribes is new subscriptions to a channel, gifted ones included. The token must be the
+// broadcaster's, with the channel:read:subscriptions scope.
+func Subscribes(broadcasterID string) Subscription {
+	return Subscription{Type: TypeSubscribe, Version: "1", Condition: map[string]string{"broadcaster_user_id": broadcasterID}}
+}
+
+// Raids is raids into a channel. Any token can receive them.
+func Raids(broadcasterID string) Subscription {
+	return Subscription{Type: TypeRaid, Version: "1", Condition: map[string]string{"to_broadcaster_user_id": broadcasterID}}
+}
+
+// Redemptions is custom channel point rewards being redeemed. The token must be the
+// broadcaster's, with the channel:read:redemptions scope.
+func Redemptions(broadcasterID string) Subscription {
+	return Subscription{Type: TypeRedemption, Version: "1", Condition: map[string]string{"broadcaster_user_id": broadcasterID}}
+}
+
+// Online is a channel going live. Any token can receive it.
+func Online(broadcasterID string) Subscription {
+	return Subscription{Type: TypeOnline, Version: "1", Condition: map[string]string{"broadcaster_user_id": broadcasterID}}
+}
+
+// Offline is a channel's stream ending. Any token can receive it.
+func Offline(broadcasterID string) Subscription {
+	return Subscription{Type: TypeOffline, Version: "1", Condition: map[string]string{"broadcaster_user_id": broadcasterID}}
+}
+
+// Updates is a channel's title or category changing. Any token can receive it.
+func Updates(broadcasterID string) Subscription {
+	return Subscription{Type: TypeUpdate, Version: "2", Condition: map[string]string{"broadcaster_user_id": broadcasterID}}
+}
+
+// FollowEvent is someone following a channel
+type FollowEvent struct {
+	UserID               string    `json:"user_id"`
+	UserLogin            string    `json:"user_login"`
+	UserName             string    `json:"user_name"`
+	BroadcasterUserID    string    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	FollowedAt           time.Time `json:"followed_at"`
+}
+
+// SubscribeEvent is a new subscription. Resubscriptions are only in chat, as USERNOTICEs.
+type SubscribeEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+
+	// "1000", "2000" or "3000"
+	Tier   string `json:"tier"`
+	IsGift bool   `json:"is_gift"`
+}
+
+// RaidEvent is one channel raiding another
+type RaidEvent struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	ToBroadcasterUserID      string `json:"to_broadcaster_user_id"`
+	ToBroadcasterUserLogin   string `json:"to_broadcaster_user_login"`
+	Viewers                  int    `json:"viewers"`
+}
+
+// Reward is a custom channel point reward
+type Reward struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Cost   int    `json:"cost"`
+	Prompt string `json:"prompt"`
+}
+
+// RedemptionEvent is someone spending channel points on a custom reward
+type RedemptionEvent struct {
+	ID                   string    `json:"id"`
+	UserID               string    `json:"user_id"`
+	UserLogin            string    `json:"user_login"`
+	UserName             string    `json:"user_name"`
+	UserInput            string    `json:"user_input"`
+	BroadcasterUserID    string    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	Status               string    `json:"status"`
+	Reward               Reward    `json:"reward"`
+	RedeemedAt           time.Time `json:"redeemed_at"`
+}
+
+// OnlineEvent is a channel going live
+type OnlineEvent struct {
+	ID                   string `json:"id"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+
+	// "live", "playlist", "watch_party", "premiere" or "rerun"
+	Type      string    `json:"type"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// OfflineEvent is a channel's stream ending
+type OfflineEvent struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+// UpdateEvent is a channel's title, category or other information changing. It has all of
+// them, not only what changed.
+type UpdateEvent struct {
+	BroadcasterUserID           string   `json:"broadcaster_user_id"`
+	BroadcasterUserLogin        string   `json:"broadcaster_user_login"`
+	BroadcasterUserName         string   `json:"broadcaster_user_name"`
+	Title                       string   `json:"title"`
+	Language                    string   `json:"language"`
+	CategoryID                  string   `json:"category_id"`
+	CategoryName                string   `json:"category_name"`
+	ContentClassificationLabels []string `json:"content_classification_labels"`
+}
@@ -0,0 +1,326 @@
+// Package eventsub receives Twitch EventSub notifications over a WebSocket: follows, subs,
+// raids, channel point redemptions and anything else Twitch offers to WebSocket sessions.
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/websocket"
+)
+
+var logger = printpretty.Module("eventsub")
+
+// DefaultURL is Twitch's EventSub WebSocket server
+const DefaultURL = "wss://eventsub.wss.twitch.tv/ws"
+
+const (
+	// Added to the keepalive Twitch asks for before the connection counts as dead
+	keepaliveMargin = 5 * time.Second
+
+	// Used until the welcome message says otherwise
+	defaultKeepalive = 10 * time.Second
+
+	// Waits between reconnects grow to this
+	maxReconnectWait = 2 * time.Minute
+
+	// Twitch may send a message twice. This many IDs are remembered to drop the repeats.
+	recentMessageIDs = 200
+)
+
+// Subscription is a type of event to receive, e.g. Raids(broadcasterID)
+type Subscription struct {
+	Type      string
+	Version   string
+	Condition map[string]string
+}
+
+// Notification is an event Twitch sent for a subscription
+type Notification struct {
+	MessageID    string
+	Subscription helix.EventSubSubscription
+	At           time.Time
+
+	// The event as Twitch sent it. Decode it into FollowEvent, SubscribeEvent and the like.
+	Event json.RawMessage
+}
+
+// Type is the subscription type, e.g. "channel.follow"
+func (notification *Notification) Type() string {
+	return notification.Subscription.Type
+}
+
+// Decode unmarshals the event
+func (notification *Notification) Decode(event interface{}) error {
+	return json.Unmarshal(notification.Event, event)
+}
+
+// Client keeps one EventSub WebSocket session open and subscribed
+type Client struct {
+	// Creates the subscriptions. Its token is the one the events are delivered for.
+	Helix *helix.Client
+
+	Subscriptions []Subscription
+
+	// Called with each notification, on Run's goroutine
+	Handler func(*Notification)
+
+	// Defaults to DefaultURL
+	URL string
+}
+
+// A message on the WebSocket
+type message struct {
+	Metadata struct {
+		MessageID        string    `json:"message_id"`
+		MessageType      string    `json:"message_type"`
+		MessageTimestamp time.Time `json:"message_timestamp"`
+	} `json:"metadata"`
+	Payload struct {
+		Session *struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription helix.EventSubSubscription `json:"subscription"`
+		Event        json.RawMessage            `json:"event"`
+	} `json:"payload"`
+}
+
+// What a connection's reader goroutine passes on
+type received struct {
+	conn *websocket.Conn
+	data []byte
+	err  error
+}
+
+// Run connects, subscribes and delivers notifications until ctx is done, reconnecting when the
+// connection drops. Returns ctx's error.
+func (client *Client) Run(ctx context.Context) error {
+	failures := 0
+	for {
+		started := time.Now()
+		err := client.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A session that lasted a while resets the backoff
+		if time.Since(started) > maxReconnectWait {
+			failures = 0
+		}
+		failures++
+
+		wait := time.Duration(1<<uint(failures-1)) * time.Second
+		if wait > maxReconnectWait || failures > 8 {
+			wait = maxReconnectWait
+		}
+		logger.Warn("EventSub disconnected: %s. Reconnecting in %s", err.Error(), wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Runs one session, following Twitch's reconnect messages to new connections, until it fails
+func (client *Client) session(ctx context.Context) error {
+	address := client.URL
+	if address == "" {
+		address = DefaultURL
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	incoming := make(chan received)
+
+	conn, err := client.dial(ctx, address, incoming, done)
+	if err != nil {
+		return err
+	}
+
+	// While moving to a reconnect URL, the old connection keeps delivering until the new one
+	// is welcomed
+	var pending *websocket.Conn
+	defer func() {
+		conn.Close()
+		if pending != nil {
+			pending.Close()
+		}
+	}()
+
+	subscribed := false
+	seen := newRecentIDs(recentMessageIDs)
+	timeout := defaultKeepalive
+	keepalive := time.NewTimer(timeout + keepaliveMargin)
+	defer keepalive.Stop()
+
+	for {
+		var next received
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-keepalive.C:
+			return errors.New("no keepalive from Twitch")
+		case next = <-incoming:
+		}
+
+		if next.conn != conn && next.conn != pending {
+			continue
+		}
+		if next.err != nil {
+			if next.conn == pending {
+				logger.Warn("EventSub reconnect failed: %s", next.err.Error())
+				pending = nil
+				continue
+			}
+			return next.err
+		}
+		if next.conn == conn {
+			resetTimer(keepalive, timeout+keepaliveMargin)
+		}
+
+		msg := &message{}
+		err = json.Unmarshal(next.data, msg)
+		if err != nil {
+			logger.Warn("EventSub sent something that isn't JSON: %s", err.Error())
+			continue
+		}
+
+		switch msg.Metadata.MessageType {
+		case "session_welcome":
+			if msg.Payload.Session == nil {
+				continue
+			}
+			if next.conn == pending {
+				conn.Close()
+				conn, pending = pending, nil
+				logger.Info("EventSub moved to a new connection")
+			}
+			if seconds := msg.Payload.Session.KeepaliveTimeoutSeconds; seconds > 0 {
+				timeout = time.Duration(seconds) * time.Second
+			}
+			resetTimer(keepalive, timeout+keepaliveMargin)
+
+			// Subscriptions move along with reconnects, so only a new session needs them
+			if !subscribed {
+				subscribed = true
+				client.subscribe(ctx, msg.Payload.Session.ID)
+			}
+		case "session_keepalive":
+		case "session_reconnect":
+			if msg.Payload.Session == nil || msg.Payload.Session.ReconnectURL == "" {
+				continue
+			}
+			logger.Info("Twitch asked EventSub to reconnect")
+			pending, err = client.dial(ctx, msg.Payload.Session.ReconnectURL, incoming, done)
+			if err != nil {
+				return err
+			}
+		case "notification":
+			if !seen.add(msg.Metadata.MessageID) {
+				continue
+			}
+			logger.Debug("EventSub %s notification", msg.Payload.Subscription.Type)
+			if client.Handler != nil {
+				client.Handler(&Notification{
+					MessageID:    msg.Metadata.MessageID,
+					Subscription: msg.Payload.Subscription,
+					At:           msg.Metadata.MessageTimestamp,
+					Event:        msg.Payload.Event,
+				})
+			}
+		case "revocation":
+			subscription := msg.Payload.Subscription
+			logger.Warn("Twitch revoked the EventSub %s subscription: %s", subscription.Type, subscription.Status)
+		}
+	}
+}
+
+// Connects and reads the connection's messages into incoming until it fails or done is closed
+func (client *Client) dial(ctx context.Context, address string, incoming chan<- received, done <-chan struct{}) (*websocket.Conn, error) {
+	conn, err := websocket.Dial(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			data, err := conn.ReadMessage()
+			select {
+			case incoming <- received{conn: conn, data: data, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return conn, nil
+}
+
+// Creates the subscriptions for a session. Ones Twitch refuses, usually for a missing scope or
+// a channel the token has no rights in, are logged and skipped.
+func (client *Client) subscribe(ctx context.Context, sessionID string) {
+	created := 0
+	for _, subscription := range client.Subscriptions {
+		_, err := client.Helix.CreateEventSubSubscription(ctx, subscription.Type, subscription.Version, subscription.Condition, sessionID)
+		if err != nil {
+			logger.Warn("Couldn't subscribe to EventSub %s: %s", subscription.Type, err.Error())
+			continue
+		}
+		created++
+	}
+
+	logger.Success("EventSub connected with %d of %d subscriptions", created, len(client.Subscriptions))
+}
+
+func resetTimer(timer *time.Timer, duration time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(duration)
+}
+
+// The most recent message IDs, oldest dropped first
+type recentIDs struct {
+	order []string
+	ids   map[string]bool
+	limit int
+}
+
+func newRecentIDs(limit int) *recentIDs {
+	return &recentIDs{ids: map[string]bool{}, limit: limit}
+}
+
+// Remembers an ID, returning false if it was already there
+func (recent *recentIDs) add(id string) bool {
+	if id == "" {
+		return true
+	}
+	if recent.ids[id] {
+		return false
+	}
+
+	recent.ids[id] = true
+	recent.order = append(recent.order, id)
+	if len(recent.order) > recent.limit {
+		delete(recent.ids, recent.order[0])
+		recent.order = recent.order[1:]
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+package helix
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// EventSubSubscription is a subscription to EventSub notifications
+type EventSubSubscription struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Status    string            `json:"status"`
+	Condition map[string]string `json:"condition"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// CreateEventSubSubscription subscribes an EventSub WebSocket session to a type of event. The
+// token needs whatever scopes the type asks for, from the user its condition names.
+func (client *Client) CreateEventSubSubscription(ctx context.Context, subscriptionType, version string, condition map[string]string, sessionID string) (*EventSubSubscription, error) {
+	request := map[string]interface{}{
+		"type":      subscriptionType,
+		"version":   version,
+		"condition": condition,
+		"transport": map[string]string{"method": "websocket", "session_id": sessionID},
+	}
+
+	body := struct {
+		Data []EventSubSubscription `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodPost, "/eventsub/subscriptions", nil, request, &body)
+	if err != nil {
+		return nil, wrap("helix.CreateEventSubSubscription", err)
+	}
+
+	if len(body.Data) == 0 {
+		return &EventSubSubscription{Type: subscriptionType, Version: version, Condition: condition}, nil
+	}
+
+	return &body.Data[0], nil
+}
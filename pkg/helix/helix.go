@@ -0,0 +1,398 @@
+// Package helix is a small client for the parts of the Twitch Helix API a chat bot needs: users,
+// streams, channels, followers, whispers, announcements, shoutouts, bans, clips and EventSub
+// subscriptions.
+package helix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+var logger = printpretty.Module("helix")
+
+// DefaultBaseURL is where Helix lives
+const DefaultBaseURL = "https://api.twitch.tv/helix"
+
+// Most endpoints that take lists of IDs or logins take at most this many per request
+const MaxBatch = 100
+
+// Client calls Helix as one account
+type Client struct {
+	ClientID string
+
+	// Returns the account's OAuth token for each request, so a rotated token is picked up.
+	// An "oauth:" prefix is removed.
+	Token func() string
+
+	// Defaults to DefaultBaseURL
+	BaseURL string
+
+	// Defaults to a client with a ten second timeout
+	HTTPClient *http.Client
+}
+
+// Error is a response from Helix other than success
+type Error struct {
+	// The method that got it, e.g. "helix.GetUsers"
+	Op string
+
+	StatusCode int
+	Message    string
+
+	// When the rate limit bucket refills, for 429 responses
+	RetryAt time.Time
+}
+
+func (err *Error) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("%s: status %d", err.Op, err.StatusCode)
+	}
+
+	return fmt.Sprintf("%s: status %d: %s", err.Op, err.StatusCode, err.Message)
+}
+
+// Names the method in an error, keeping an *Error as one so callers can check its status
+func wrap(op string, err error) error {
+	if helixError, ok := err.(*Error); ok {
+		helixError.Op = op
+		return helixError
+	}
+
+	return errors.New(op + ": " + err.Error())
+}
+
+// User is a Twitch account
+type User struct {
+	ID          string    `json:"id"`
+	Login       string    `json:"login"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Stream is a live stream
+type Stream struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	UserLogin   string    `json:"user_login"`
+	UserName    string    `json:"user_name"`
+	GameID      string    `json:"game_id"`
+	GameName    string    `json:"game_name"`
+	Title       string    `json:"title"`
+	ViewerCount int       `json:"viewer_count"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// Clip is a clip from a channel
+type Clip struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url"`
+	BroadcasterID string    `json:"broadcaster_id"`
+	CreatorName   string    `json:"creator_name"`
+	Title         string    `json:"title"`
+	ViewCount     int       `json:"view_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	Duration      float64   `json:"duration"`
+}
+
+// GetUsers looks up accounts by ID and login, up to MaxBatch of them together
+func (client *Client) GetUsers(ctx context.Context, ids, logins []string) ([]User, error) {
+	query := url.Values{}
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	for _, login := range logins {
+		query.Add("login", strings.ToLower(login))
+	}
+
+	body := struct {
+		Data []User `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodGet, "/users", query, nil, &body)
+	if err != nil {
+		return nil, wrap("helix.GetUsers", err)
+	}
+
+	return body.Data, nil
+}
+
+// GetStreams returns the live streams among the given channels, up to MaxBatch of them together.
+// Channels that are offline are left out.
+func (client *Client) GetStreams(ctx context.Context, userIDs, userLogins []string) ([]Stream, error) {
+	query := url.Values{}
+	for _, id := range userIDs {
+		query.Add("user_id", id)
+	}
+	for _, login := range userLogins {
+		query.Add("user_login", strings.ToLower(login))
+	}
+
+	body := struct {
+		Data []Stream `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodGet, "/streams", query, nil, &body)
+	if err != nil {
+		return nil, wrap("helix.GetStreams", err)
+	}
+
+	return body.Data, nil
+}
+
+// GetClips returns a channel's clips created since startedAt, most viewed first. A zero
+// startedAt means any time. first is how many to return, at most 100.
+func (client *Client) GetClips(ctx context.Context, broadcasterID string, startedAt time.Time, first int) ([]Clip, error) {
+	query := url.Values{"broadcaster_id": {broadcasterID}}
+	if !startedAt.IsZero() {
+		query.Set("started_at", startedAt.UTC().Format(time.RFC3339))
+	}
+	if first > 0 {
+		query.Set("first", strconv.Itoa(first))
+	}
+
+	body := struct {
+		Data []Clip `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodGet, "/clips", query, nil, &body)
+	if err != nil {
+		return nil, wrap("helix.GetClips", err)
+	}
+
+	return body.Data, nil
+}
+
+// Follower is a user following a channel
+type Follower struct {
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	UserName   string    `json:"user_name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// GetChannelFollower reports whether a user follows a channel and since when. The token must
+// belong to the broadcaster or one of their moderators and have the moderator:read:followers scope.
+func (client *Client) GetChannelFollower(ctx context.Context, broadcasterID, userID string) (*Follower, bool, error) {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "user_id": {userID}}
+
+	body := struct {
+		Data []Follower `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodGet, "/channels/followers", query, nil, &body)
+	if err != nil {
+		return nil, false, wrap("helix.GetChannelFollower", err)
+	}
+
+	if len(body.Data) == 0 {
+		return nil, false, nil
+	}
+
+	return &body.Data[0], true, nil
+}
+
+// ChannelInformation is what a channel is set to stream: its category and title. They stay
+// after the stream ends, so offline channels show what they last streamed.
+type ChannelInformation struct {
+	BroadcasterID    string `json:"broadcaster_id"`
+	BroadcasterLogin string `json:"broadcaster_login"`
+	BroadcasterName  string `json:"broadcaster_name"`
+	GameID           string `json:"game_id"`
+	GameName         string `json:"game_name"`
+	Title            string `json:"title"`
+}
+
+// GetChannelInformation looks up channels by broadcaster ID, up to MaxBatch of them together
+func (client *Client) GetChannelInformation(ctx context.Context, broadcasterIDs []string) ([]ChannelInformation, error) {
+	query := url.Values{}
+	for _, id := range broadcasterIDs {
+		query.Add("broadcaster_id", id)
+	}
+
+	body := struct {
+		Data []ChannelInformation `json:"data"`
+	}{}
+	err := client.do(ctx, http.MethodGet, "/channels", query, nil, &body)
+	if err != nil {
+		return nil, wrap("helix.GetChannelInformation", err)
+	}
+
+	return body.Data, nil
+}
+
+// SendWhisper whispers message from one user to another. The token must belong to fromUserID,
+// have the user:manage:whispers scope and a verified phone number.
+func (client *Client) SendWhisper(ctx context.Context, fromUserID, toUserID, message string) error {
+	query := url.Values{"from_user_id": {fromUserID}, "to_user_id": {toUserID}}
+	request := map[string]string{"message": message}
+
+	err := client.do(ctx, http.MethodPost, "/whispers", query, request, nil)
+	if err != nil {
+		return wrap("helix.SendWhisper", err)
+	}
+
+	return nil
+}
+
+// SendAnnouncement posts a highlighted message in a channel. color is "blue", "green",
+// "orange", "purple" or "primary", the default. The token must belong to moderatorID and have
+// the moderator:manage:announcements scope.
+func (client *Client) SendAnnouncement(ctx context.Context, broadcasterID, moderatorID, message, color string) error {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}}
+	request := map[string]string{"message": message}
+	if color != "" {
+		request["color"] = color
+	}
+
+	err := client.do(ctx, http.MethodPost, "/chat/announcements", query, request, nil)
+	if err != nil {
+		return wrap("helix.SendAnnouncement", err)
+	}
+
+	return nil
+}
+
+// SendShoutout gives another channel Twitch's own shoutout, which shows a follow button in
+// chat. The token must belong to moderatorID and have the moderator:manage:shoutouts scope.
+// Twitch allows one every two minutes per channel, and one per hour for the same target.
+func (client *Client) SendShoutout(ctx context.Context, fromBroadcasterID, toBroadcasterID, moderatorID string) error {
+	query := url.Values{"from_broadcaster_id": {fromBroadcasterID}, "to_broadcaster_id": {toBroadcasterID}, "moderator_id": {moderatorID}}
+
+	err := client.do(ctx, http.MethodPost, "/chat/shoutouts", query, nil, nil)
+	if err != nil {
+		return wrap("helix.SendShoutout", err)
+	}
+
+	return nil
+}
+
+// BanUser bans a user from a channel, or times them out when duration isn't zero. The token must
+// belong to moderatorID and have the moderator:manage:banned_users scope.
+func (client *Client) BanUser(ctx context.Context, broadcasterID, moderatorID, userID string, duration time.Duration, reason string) error {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}}
+
+	ban := map[string]interface{}{"user_id": userID}
+	if duration > 0 {
+		seconds := int(duration.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		ban["duration"] = seconds
+	}
+	if reason != "" {
+		ban["reason"] = reason
+	}
+
+	err := client.do(ctx, http.MethodPost, "/moderation/bans", query, map[string]interface{}{"data": ban}, nil)
+	if err != nil {
+		return wrap("helix.BanUser", err)
+	}
+
+	return nil
+}
+
+// UnbanUser lifts a ban or timeout
+func (client *Client) UnbanUser(ctx context.Context, broadcasterID, moderatorID, userID string) error {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}, "user_id": {userID}}
+
+	err := client.do(ctx, http.MethodDelete, "/moderation/bans", query, nil, nil)
+	if err != nil {
+		return wrap("helix.UnbanUser", err)
+	}
+
+	return nil
+}
+
+// Sends a request with the client's credentials. request is encoded as the JSON body when set,
+// and the JSON response is decoded into response when set.
+func (client *Client) do(ctx context.Context, method, path string, query url.Values, request, response interface{}) error {
+	baseURL := client.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	address := baseURL + path
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	var body *bytes.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	var httpRequest *http.Request
+	var err error
+	if body != nil {
+		httpRequest, err = http.NewRequestWithContext(ctx, method, address, body)
+	} else {
+		httpRequest, err = http.NewRequestWithContext(ctx, method, address, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	token := ""
+	if client.Token != nil {
+		token = strings.TrimPrefix(client.Token(), "oauth:")
+	}
+	httpRequest.Header.Set("Client-Id", client.ClientID)
+	httpRequest.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	started := time.Now()
+	resp, err := httpClient.Do(httpRequest)
+	if err != nil {
+		logger.Debug("%s %s failed: %s", method, path, err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+	logger.Debug("%s %s: %s in %s", method, path, resp.Status, time.Since(started).Round(time.Millisecond))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// Reads Helix's error message and, for rate limits, when to try again
+func responseError(resp *http.Response) error {
+	helixError := &Error{StatusCode: resp.StatusCode}
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	body := struct {
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(data, &body) == nil {
+		helixError.Message = body.Message
+	}
+
+	if reset, err := strconv.ParseInt(resp.Header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+		helixError.RetryAt = time.Unix(reset, 0)
+	}
+
+	return helixError
+}
@@ -0,0 +1,229 @@
+package helix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAuthURL is where Twitch's OAuth endpoints live
+const DefaultAuthURL = "https://id.twitch.tv/oauth2"
+
+// Auth talks to Twitch's OAuth server for one application
+type Auth struct {
+	ClientID string
+
+	// Needed to refresh tokens of confidential clients
+	ClientSecret string
+
+	// Defaults to DefaultAuthURL
+	BaseURL string
+
+	// Defaults to a client with a ten second timeout
+	HTTPClient *http.Client
+}
+
+// TokenInfo is what Twitch says about an access token
+type TokenInfo struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	UserID    string   `json:"user_id"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// HasScope reports whether the token was granted a scope
+func (info *TokenInfo) HasScope(scope string) bool {
+	for _, granted := range info.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expires is how long the token has left. Zero means it doesn't expire.
+func (info *TokenInfo) Expires() time.Duration {
+	return time.Duration(info.ExpiresIn) * time.Second
+}
+
+// Validate asks Twitch whether a token is still good, and which account, application and scopes
+// it belongs to. A revoked or expired token is an *Error with status 401. An "oauth:" prefix is
+// removed.
+func (auth *Auth) Validate(ctx context.Context, token string) (*TokenInfo, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, auth.baseURL()+"/validate", nil)
+	if err != nil {
+		return nil, wrap("helix.Validate", err)
+	}
+	request.Header.Set("Authorization", "OAuth "+strings.TrimPrefix(token, "oauth:"))
+
+	resp, err := auth.httpClient().Do(request)
+	if err != nil {
+		return nil, wrap("helix.Validate", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, wrap("helix.Validate", responseError(resp))
+	}
+
+	info := &TokenInfo{}
+	err = json.NewDecoder(resp.Body).Decode(info)
+	if err != nil {
+		return nil, wrap("helix.Validate", err)
+	}
+
+	return info, nil
+}
+
+// Token is a new access token from Twitch
+type Token struct {
+	AccessToken string `json:"access_token"`
+
+	// Use it to get the next token. Twitch may hand out a new one with every refresh.
+	RefreshToken string `json:"refresh_token"`
+
+	ExpiresIn int      `json:"expires_in"`
+	Scopes    []string `json:"scope"`
+}
+
+// Expires is how long the token lasts
+func (token *Token) Expires() time.Duration {
+	return time.Duration(token.ExpiresIn) * time.Second
+}
+
+// Refresh trades a refresh token for a new access token. A refresh token that was revoked, or
+// belongs to another application, is an *Error with status 400 or 401.
+func (auth *Auth) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {auth.ClientID},
+	}
+	// Public clients, like ones set up for the device code flow, have no secret
+	if auth.ClientSecret != "" {
+		form.Set("client_secret", auth.ClientSecret)
+	}
+
+	token := &Token{}
+	err := auth.postForm(ctx, "/token", form, token)
+	if err != nil {
+		return nil, wrap("helix.Refresh", err)
+	}
+
+	return token, nil
+}
+
+// DeviceCode is what the user enters to approve a device code login
+type DeviceCode struct {
+	DeviceCode string `json:"device_code"`
+
+	// Show the user this code and where to enter it
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+
+	ExpiresIn int `json:"expires_in"`
+
+	// Seconds to wait between checks of whether the user approved
+	Interval int `json:"interval"`
+}
+
+// StartDeviceLogin begins Twitch's device code grant for an account to grant scopes to the
+// client. Show the user UserCode and VerificationURI, then call WaitForDeviceLogin.
+func (auth *Auth) StartDeviceLogin(ctx context.Context, scopes []string) (*DeviceCode, error) {
+	form := url.Values{"client_id": {auth.ClientID}, "scopes": {strings.Join(scopes, " ")}}
+
+	code := &DeviceCode{}
+	err := auth.postForm(ctx, "/device", form, code)
+	if err != nil {
+		return nil, wrap("helix.StartDeviceLogin", err)
+	}
+
+	return code, nil
+}
+
+// WaitForDeviceLogin checks every Interval until the user approves the login, returning its
+// token. It fails if the user declines, the code expires or ctx is done.
+func (auth *Auth) WaitForDeviceLogin(ctx context.Context, code *DeviceCode, scopes []string) (*Token, error) {
+	form := url.Values{
+		"client_id":   {auth.ClientID},
+		"scopes":      {strings.Join(scopes, " ")},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, wrap("helix.WaitForDeviceLogin", ctx.Err())
+		case <-timer.C:
+		}
+
+		token := &Token{}
+		err := auth.postForm(ctx, "/token", form, token)
+		if err == nil {
+			return token, nil
+		}
+
+		helixError, ok := err.(*Error)
+		if !ok || helixError.StatusCode != http.StatusBadRequest {
+			return nil, wrap("helix.WaitForDeviceLogin", err)
+		}
+
+		switch helixError.Message {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, wrap("helix.WaitForDeviceLogin", err)
+		}
+	}
+}
+
+// Posts a form and decodes the JSON response
+func (auth *Auth) postForm(ctx context.Context, path string, form url.Values, response interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.baseURL()+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := auth.httpClient().Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+func (auth *Auth) baseURL() string {
+	if auth.BaseURL == "" {
+		return DefaultAuthURL
+	}
+
+	return auth.BaseURL
+}
+
+func (auth *Auth) httpClient() *http.Client {
+	if auth.HTTPClient == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return auth.HTTPClient
+}
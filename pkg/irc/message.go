@@ -0,0 +1,229 @@
+// Package irc parses and formats lines of the IRC protocol as spoken by Twitch
+package irc
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Prefix identifies where a message came from, e.g. "nick!user@host" or "tmi.twitch.tv"
+type Prefix struct {
+	Name string
+	User string
+	Host string
+}
+
+// Message is a single line of the IRC protocol
+type Message struct {
+	// IRCv3 message tags, unescaped. Empty unless the tags capability was requested.
+	Tags map[string]string
+
+	// Empty when the line had no prefix
+	Prefix Prefix
+
+	// The command or numeric reply, such as PRIVMSG, NOTICE or 001
+	Command string
+
+	// The parameters before the trailing parameter
+	Params []string
+
+	// The final parameter, which may contain spaces. Only meaningful if HasTrailing is set.
+	Trailing string
+
+	HasTrailing bool
+
+	// The line as it was received
+	Raw string
+}
+
+// Parse breaks a line into its prefix, command, params and trailing param.
+// The line should not include the terminating "\r\n".
+func Parse(line string) (*Message, error) {
+	message := &Message{Raw: line, Tags: map[string]string{}}
+	rest := strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(rest, "@") {
+		end := strings.IndexByte(rest, ' ')
+		if end == -1 {
+			return nil, errors.New("irc.Parse: line has tags but no command")
+		}
+
+		message.Tags = parseTags(rest[1:end])
+		rest = strings.TrimLeft(rest[end+1:], " ")
+	}
+
+	if strings.HasPrefix(rest, ":") {
+		end := strings.IndexByte(rest, ' ')
+		if end == -1 {
+			return nil, errors.New("irc.Parse: line has a prefix but no command")
+		}
+
+		message.Prefix = parsePrefix(rest[1:end])
+		rest = strings.TrimLeft(rest[end+1:], " ")
+	}
+
+	if trailingStart := strings.Index(rest, " :"); trailingStart != -1 {
+		message.Trailing = rest[trailingStart+2:]
+		message.HasTrailing = true
+		rest = rest[:trailingStart]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil, errors.New("irc.Parse: line has no command")
+	}
+
+	message.Command = strings.ToUpper(fields[0])
+	message.Params = fields[1:]
+
+	return message, nil
+}
+
+func parseTags(raw string) map[string]string {
+	tags := map[string]string{}
+
+	for _, tag := range strings.Split(raw, ";") {
+		if tag == "" {
+			continue
+		}
+
+		key, value := tag, ""
+		if equals := strings.IndexByte(tag, '='); equals != -1 {
+			key, value = tag[:equals], unescapeTagValue(tag[equals+1:])
+		}
+
+		tags[key] = value
+	}
+
+	return tags
+}
+
+var tagValueEscaper = strings.NewReplacer(";", `\:`, " ", `\s`, `\`, `\\`, "\r", `\r`, "\n", `\n`)
+
+func unescapeTagValue(value string) string {
+	if strings.IndexByte(value, '\\') == -1 {
+		return value
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			builder.WriteByte(value[i])
+			continue
+		}
+
+		// A lone trailing backslash is dropped
+		i++
+		if i == len(value) {
+			break
+		}
+
+		switch value[i] {
+		case ':':
+			builder.WriteByte(';')
+		case 's':
+			builder.WriteByte(' ')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'n':
+			builder.WriteByte('\n')
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+
+	return builder.String()
+}
+
+func parsePrefix(raw string) Prefix {
+	prefix := Prefix{Name: raw}
+
+	if at := strings.IndexByte(prefix.Name, '@'); at != -1 {
+		prefix.Host = prefix.Name[at+1:]
+		prefix.Name = prefix.Name[:at]
+	}
+
+	if bang := strings.IndexByte(prefix.Name, '!'); bang != -1 {
+		prefix.User = prefix.Name[bang+1:]
+		prefix.Name = prefix.Name[:bang]
+	}
+
+	return prefix
+}
+
+// Param returns the i-th parameter, counting the trailing parameter last, or "" if there is none
+func (message *Message) Param(i int) string {
+	if i < len(message.Params) {
+		return message.Params[i]
+	}
+
+	if i == len(message.Params) && message.HasTrailing {
+		return message.Trailing
+	}
+
+	return ""
+}
+
+// Channel returns the channel a message targets without its "#", or "" if the first param isn't a channel
+func (message *Message) Channel() string {
+	if len(message.Params) == 0 || !strings.HasPrefix(message.Params[0], "#") {
+		return ""
+	}
+
+	return strings.TrimPrefix(message.Params[0], "#")
+}
+
+// String formats the message as a line without the terminating "\r\n"
+func (message *Message) String() string {
+	var builder strings.Builder
+
+	if len(message.Tags) > 0 {
+		keys := make([]string, 0, len(message.Tags))
+		for key := range message.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		builder.WriteByte('@')
+		for i, key := range keys {
+			if i > 0 {
+				builder.WriteByte(';')
+			}
+			builder.WriteString(key)
+			if value := message.Tags[key]; value != "" {
+				builder.WriteByte('=')
+				builder.WriteString(tagValueEscaper.Replace(value))
+			}
+		}
+		builder.WriteByte(' ')
+	}
+
+	if message.Prefix.Name != "" {
+		builder.WriteByte(':')
+		builder.WriteString(message.Prefix.Name)
+		if message.Prefix.User != "" {
+			builder.WriteByte('!')
+			builder.WriteString(message.Prefix.User)
+		}
+		if message.Prefix.Host != "" {
+			builder.WriteByte('@')
+			builder.WriteString(message.Prefix.Host)
+		}
+		builder.WriteByte(' ')
+	}
+
+	builder.WriteString(message.Command)
+
+	for _, param := range message.Params {
+		builder.WriteByte(' ')
+		builder.WriteString(param)
+	}
+
+	if message.HasTrailing {
+		builder.WriteString(" :")
+		builder.WriteString(message.Trailing)
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,162 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want Message
+	}{
+		{
+			line: "PING :tmi.twitch.tv",
+			want: Message{Tags: map[string]string{}, Command: "PING", Params: []string{}, Trailing: "tmi.twitch.tv", HasTrailing: true},
+		},
+		{
+			line: ":tmi.twitch.tv 001 chuckbot :Welcome, GLHF!",
+			want: Message{
+				Tags:        map[string]string{},
+				Prefix:      Prefix{Name: "tmi.twitch.tv"},
+				Command:     "001",
+				Params:      []string{"chuckbot"},
+				Trailing:    "Welcome, GLHF!",
+				HasTrailing: true,
+			},
+		},
+		{
+			line: "@badges=broadcaster/1;color=#FF0000;display-name=Mikkeever;mod=0 :mikkeever!mikkeever@mikkeever.tmi.twitch.tv PRIVMSG #mikkeever :!chucknorris please",
+			want: Message{
+				Tags:        map[string]string{"badges": "broadcaster/1", "color": "#FF0000", "display-name": "Mikkeever", "mod": "0"},
+				Prefix:      Prefix{Name: "mikkeever", User: "mikkeever", Host: "mikkeever.tmi.twitch.tv"},
+				Command:     "PRIVMSG",
+				Params:      []string{"#mikkeever"},
+				Trailing:    "!chucknorris please",
+				HasTrailing: true,
+			},
+		},
+		{
+			line: "@msg-id=slow_on :tmi.twitch.tv NOTICE #mikkeever :This room is now in slow mode.",
+			want: Message{
+				Tags:        map[string]string{"msg-id": "slow_on"},
+				Prefix:      Prefix{Name: "tmi.twitch.tv"},
+				Command:     "NOTICE",
+				Params:      []string{"#mikkeever"},
+				Trailing:    "This room is now in slow mode.",
+				HasTrailing: true,
+			},
+		},
+		{
+			line: `@msg-id=resub;msg-param-cumulative-months=6;system-msg=viewer\ssubscribed\sfor\s6\smonths! :tmi.twitch.tv USERNOTICE #mikkeever`,
+			want: Message{
+				Tags:    map[string]string{"msg-id": "resub", "msg-param-cumulative-months": "6", "system-msg": "viewer subscribed for 6 months!"},
+				Prefix:  Prefix{Name: "tmi.twitch.tv"},
+				Command: "USERNOTICE",
+				Params:  []string{"#mikkeever"},
+			},
+		},
+		{
+			line: ":viewer!viewer@viewer.tmi.twitch.tv JOIN #mikkeever",
+			want: Message{
+				Tags:    map[string]string{},
+				Prefix:  Prefix{Name: "viewer", User: "viewer", Host: "viewer.tmi.twitch.tv"},
+				Command: "JOIN",
+				Params:  []string{"#mikkeever"},
+			},
+		},
+		{
+			line: ":viewer!viewer@viewer.tmi.twitch.tv PART #mikkeever",
+			want: Message{
+				Tags:    map[string]string{},
+				Prefix:  Prefix{Name: "viewer", User: "viewer", Host: "viewer.tmi.twitch.tv"},
+				Command: "PART",
+				Params:  []string{"#mikkeever"},
+			},
+		},
+		{
+			// An empty trailing param is still there
+			line: ":tmi.twitch.tv CAP * ACK :",
+			want: Message{
+				Tags:        map[string]string{},
+				Prefix:      Prefix{Name: "tmi.twitch.tv"},
+				Command:     "CAP",
+				Params:      []string{"*", "ACK"},
+				HasTrailing: true,
+			},
+		},
+		{
+			line: "reconnect\r\n",
+			want: Message{Tags: map[string]string{}, Command: "RECONNECT", Params: []string{}},
+		},
+		{
+			// Flags without a value, escapes and a lone trailing backslash
+			line: `@emote-only;reply-parent-msg-body=a\:b\\c\sd\re\nf;trailing=x\ :tmi.twitch.tv ROOMSTATE #mikkeever`,
+			want: Message{
+				Tags:    map[string]string{"emote-only": "", "reply-parent-msg-body": "a;b\\c d\re\nf", "trailing": "x"},
+				Prefix:  Prefix{Name: "tmi.twitch.tv"},
+				Command: "ROOMSTATE",
+				Params:  []string{"#mikkeever"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.line)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", test.line, err.Error())
+			continue
+		}
+
+		test.want.Raw = test.line
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", test.line, *got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{"", "   ", "@badges=broadcaster/1", ":tmi.twitch.tv", "@a=b :tmi.twitch.tv"} {
+		_, err := Parse(line)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestMessageParams(t *testing.T) {
+	message, err := Parse(":tmi.twitch.tv NOTICE * :Login authentication failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.Param(0) != "*" || message.Param(1) != "Login authentication failed" || message.Param(2) != "" {
+		t.Errorf("Param(0..2) = %q, %q, %q", message.Param(0), message.Param(1), message.Param(2))
+	}
+	if message.Channel() != "" {
+		t.Errorf("Channel() = %q, want none", message.Channel())
+	}
+
+	message, _ = Parse("PRIVMSG #mikkeever :hi")
+	if message.Channel() != "mikkeever" {
+		t.Errorf("Channel() = %q, want mikkeever", message.Channel())
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, line := range []string{
+		"PING :tmi.twitch.tv",
+		":tmi.twitch.tv CAP * ACK :",
+		":viewer!viewer@viewer.tmi.twitch.tv JOIN #mikkeever",
+		`@badges=broadcaster/1;emote-only;system-msg=a\sb\:c\\d :tmi.twitch.tv USERNOTICE #mikkeever :hi there`,
+	} {
+		message, err := Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := message.String(); got != line {
+			t.Errorf("String() = %q, want %q", got, line)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package printpretty
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultGroupWindow is how long repeats of a QUIET or INFO line are collected into one summary
+const DefaultGroupWindow = 10 * time.Second
+
+// Lines printed with the same key within the window. The first one is printed right away, the
+// rest are counted and summed up when the window ends.
+type lineGroup struct {
+	level   Level
+	started time.Time
+	last    string
+	repeats int
+
+	// Whether the repeats had different text, e.g. JOINs from different users
+	varied bool
+}
+
+var (
+	groupsMutex   sync.Mutex
+	groups        = map[string]*lineGroup{}
+	groupWindow   = DefaultGroupWindow
+	flushStarted  sync.Once
+	flushInterval = time.Second
+)
+
+// SetGroupWindow changes how long repeated QUIET and INFO lines are collapsed for. Zero prints
+// every line.
+func SetGroupWindow(window time.Duration) {
+	groupsMutex.Lock()
+	defer groupsMutex.Unlock()
+
+	groupWindow = window
+}
+
+// Group prints a message at a level, collapsing lines with the same key that come within the
+// group window into one summary, e.g. a flood of JOINs keyed by channel
+func Group(key string, level Level, message string, args ...interface{}) {
+	groupFrom("", key, level, fmt.Sprintf(message, args...))
+}
+
+func groupFrom(module, key string, level Level, formattedMessage string) {
+	if !enabled(module, level) {
+		return
+	}
+
+	printGrouped(module+"/"+key, level, tag(module, formattedMessage))
+}
+
+func printGrouped(key string, level Level, formattedMessage string) {
+	groupsMutex.Lock()
+	window := groupWindow
+	if window <= 0 {
+		groupsMutex.Unlock()
+		printColored(levelColor(level), formattedMessage)
+		return
+	}
+
+	group, ok := groups[key]
+	if ok && time.Since(group.started) < window {
+		group.repeats++
+		if formattedMessage != group.last {
+			group.varied = true
+			group.last = formattedMessage
+		}
+		groupsMutex.Unlock()
+		return
+	}
+
+	groups[key] = &lineGroup{level: level, started: time.Now(), last: formattedMessage}
+	groupsMutex.Unlock()
+
+	// A group from an earlier window may not have been flushed yet
+	if ok {
+		printSummary(group, window)
+	}
+
+	flushStarted.Do(func() { go flushGroups() })
+	printColored(levelColor(level), formattedMessage)
+}
+
+// Prints the summaries of groups whose window has ended
+func flushGroups() {
+	for range time.Tick(flushInterval) {
+		groupsMutex.Lock()
+		window := groupWindow
+		done := []*lineGroup{}
+		for key, group := range groups {
+			if time.Since(group.started) >= window {
+				delete(groups, key)
+				done = append(done, group)
+			}
+		}
+		groupsMutex.Unlock()
+
+		for _, group := range done {
+			printSummary(group, window)
+		}
+	}
+}
+
+func printSummary(group *lineGroup, window time.Duration) {
+	switch {
+	case group.repeats == 0:
+	case group.varied:
+		printColored(levelColor(group.level), fmt.Sprintf("%s [%d similar messages in %s]", group.last, group.repeats, window))
+	default:
+		printColored(levelColor(group.level), fmt.Sprintf("%s [message repeated %d times in %s]", group.last, group.repeats, window))
+	}
+}
@@ -4,18 +4,21 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
 // #include "Windows.h"
 var (
-	reset  = "\033[0m"
-	red    = "\033[31m"
-	green  = "\033[32m"
-	yellow = "\033[33m"
-	cyan   = "\033[36m"
-	gray   = "\033[90m"
-	white  = "\033[97m"
+	reset   = "\033[0m"
+	red     = "\033[31m"
+	green   = "\033[32m"
+	yellow  = "\033[33m"
+	blue    = "\033[34m"
+	magenta = "\033[35m"
+	cyan    = "\033[36m"
+	gray    = "\033[90m"
+	white   = "\033[97m"
 )
 
 func init() {
@@ -24,44 +27,164 @@ func init() {
 		red = ""
 		green = ""
 		yellow = ""
+		blue = ""
+		magenta = ""
 		cyan = ""
 		gray = ""
 		white = ""
 	}
 }
 
-type messageType int
+// Level is how important a message is, and picks its color
+type Level int
 
 // Enum for status levels
 const (
-	INFO messageType = iota
+	INFO Level = iota
 	NOTICE
 	WARNING
 	ERROR
 	FATAL
 	QUIET
 	SUCCESS
+	DEBUG
 )
 
-func printPretty(messageType messageType, message string, args ...interface{}) {
-	color := white
+// Cue changes how one kind of event looks in the console, to make it easy to spot
+type Cue struct {
+	// One of red, green, yellow, blue, magenta, cyan, gray or white. Empty keeps the usual color.
+	Color string `json:"color"`
 
-	formattedMessage := fmt.Sprintf(message, args...)
+	// Print a line above the event
+	Separator bool `json:"separator"`
 
-	switch messageType {
-	case QUIET:
-		color = gray
+	// Ring the terminal bell
+	Bell bool `json:"bell"`
+}
+
+var (
+	cuesMutex sync.RWMutex
+	cues      = map[string]Cue{}
+)
+
+// SetCues replaces the cues for events, keyed by event name
+func SetCues(eventCues map[string]Cue) {
+	cuesMutex.Lock()
+	defer cuesMutex.Unlock()
+
+	cues = map[string]Cue{}
+	for event, cue := range eventCues {
+		cues[strings.ToLower(event)] = cue
+	}
+}
+
+// ValidColor reports whether a Cue can use a color name
+func ValidColor(name string) bool {
+	_, ok := colorCode(name)
+	return ok
+}
+
+func colorCode(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "red":
+		return red, true
+	case "green":
+		return green, true
+	case "yellow":
+		return yellow, true
+	case "blue":
+		return blue, true
+	case "magenta":
+		return magenta, true
+	case "cyan":
+		return cyan, true
+	case "gray", "grey":
+		return gray, true
+	case "white":
+		return white, true
+	}
+
+	return "", false
+}
+
+// Event prints a message at a level, e.g. NOTICE, styled by the cue set for the event if there is one
+func Event(event string, level Level, message string, args ...interface{}) {
+	printEvent("", event, level, fmt.Sprintf(message, args...))
+}
+
+func printEvent(module, event string, level Level, formattedMessage string) {
+	if !enabled(module, level) {
+		return
+	}
+
+	cuesMutex.RLock()
+	cue, ok := cues[strings.ToLower(event)]
+	cuesMutex.RUnlock()
+
+	if !ok {
+		printFrom(module, level, formattedMessage)
+		return
+	}
+
+	color, ok := colorCode(cue.Color)
+	if !ok {
+		color = levelColor(level)
+	}
+
+	if cue.Bell {
+		write("\a")
+	}
+	if cue.Separator {
+		write(sprintc(color, strings.Repeat("-", 60)) + "\r\n")
+	}
+
+	printColored(color, tag(module, formattedMessage))
+}
+
+func levelColor(level Level) string {
+	switch level {
+	case QUIET, DEBUG:
+		return gray
 	case NOTICE:
-		color = cyan
+		return cyan
 	case WARNING:
-		color = yellow
+		return yellow
 	case ERROR:
-		color = red
+		return red
 	case SUCCESS:
-		color = green
+		return green
+	}
+
+	return white
+}
+
+func printPretty(level Level, message string, args ...interface{}) {
+	printFrom("", level, fmt.Sprintf(message, args...))
+}
+
+// Prints a line from a module, if its level is shown for the module
+func printFrom(module string, level Level, formattedMessage string) {
+	if !enabled(module, level) {
+		return
+	}
+
+	formattedMessage = tag(module, formattedMessage)
+	if level == QUIET || level == INFO || level == DEBUG {
+		printGrouped(formattedMessage, level, formattedMessage)
+		return
 	}
 
-	fmt.Printf("[%s] %s\r\n", time.Now().Local().Format("15:04:05.000"), sprintc(color, formattedMessage))
+	printColored(levelColor(level), formattedMessage)
+}
+
+func printColored(color, formattedMessage string) {
+	stamp := timestamp(time.Now())
+	if stamp == "" {
+		write(sprintc(color, formattedMessage) + "\r\n")
+		return
+	}
+
+	write(fmt.Sprintf("[%s] %s\r\n", stamp, sprintc(color, formattedMessage)))
 }
 
 func sprintc(color, str string) string {
@@ -98,8 +221,16 @@ func Success(message string, args ...interface{}) {
 	printPretty(SUCCESS, message, args...)
 }
 
+// Debug prints a message with gray text. Debug messages are hidden unless SetLevel shows them.
+func Debug(message string, args ...interface{}) {
+	printPretty(DEBUG, message, args...)
+}
+
 // Highlight searches for a substring and highlights it green
 func Highlight(message, command string, args ...interface{}) {
-	formattedMessage := strings.ReplaceAll(message, command, green+command+reset)
-	printPretty(INFO, formattedMessage, args...)
+	printPretty(INFO, highlight(message, command), args...)
+}
+
+func highlight(message, command string) string {
+	return strings.ReplaceAll(message, command, green+command+reset)
 }
@@ -0,0 +1,164 @@
+package printpretty
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Logger prints messages tagged with the module they came from
+type Logger interface {
+	Debug(message string, args ...interface{})
+	Quiet(message string, args ...interface{})
+	Info(message string, args ...interface{})
+	Notice(message string, args ...interface{})
+	Success(message string, args ...interface{})
+	Warn(message string, args ...interface{})
+	Error(message string, args ...interface{})
+	Highlight(message, command string, args ...interface{})
+	Event(event string, level Level, message string, args ...interface{})
+	Group(key string, level Level, message string, args ...interface{})
+}
+
+// Module returns the Logger for a module, e.g. "helix". Its lines start with [helix] and
+// SetLevel can show or hide them apart from the rest.
+func Module(name string) Logger {
+	return moduleLogger{name: strings.ToLower(name)}
+}
+
+type moduleLogger struct {
+	name string
+}
+
+func (logger moduleLogger) Debug(message string, args ...interface{}) {
+	printFrom(logger.name, DEBUG, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Quiet(message string, args ...interface{}) {
+	printFrom(logger.name, QUIET, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Info(message string, args ...interface{}) {
+	printFrom(logger.name, INFO, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Notice(message string, args ...interface{}) {
+	printFrom(logger.name, NOTICE, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Success(message string, args ...interface{}) {
+	printFrom(logger.name, SUCCESS, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Warn(message string, args ...interface{}) {
+	printFrom(logger.name, WARNING, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Error(message string, args ...interface{}) {
+	printFrom(logger.name, ERROR, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Highlight(message, command string, args ...interface{}) {
+	printFrom(logger.name, INFO, fmt.Sprintf(highlight(message, command), args...))
+}
+
+func (logger moduleLogger) Event(event string, level Level, message string, args ...interface{}) {
+	printEvent(logger.name, event, level, fmt.Sprintf(message, args...))
+}
+
+func (logger moduleLogger) Group(key string, level Level, message string, args ...interface{}) {
+	groupFrom(logger.name, key, level, fmt.Sprintf(message, args...))
+}
+
+// DefaultModule is the SetLevel key for modules without a level of their own
+const DefaultModule = "default"
+
+var (
+	levelsMutex sync.RWMutex
+	levels      = map[string]Level{}
+)
+
+// SetLevel shows a module's messages at level and above, e.g. DEBUG to see everything or
+// WARNING for only warnings and errors. DefaultModule sets it for every other module. Until a
+// level is set, everything but DEBUG is shown.
+func SetLevel(module string, level Level) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	levels[strings.ToLower(module)] = level
+}
+
+// SetLevels replaces every module's level, keyed by module
+func SetLevels(moduleLevels map[string]Level) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	levels = map[string]Level{}
+	for module, level := range moduleLevels {
+		levels[strings.ToLower(module)] = level
+	}
+}
+
+// ParseLevel reads a level name: debug, quiet, info, notice, warning or error
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return DEBUG, nil
+	case "quiet":
+		return QUIET, nil
+	case "info":
+		return INFO, nil
+	case "notice":
+		return NOTICE, nil
+	case "warning", "warn":
+		return WARNING, nil
+	case "error":
+		return ERROR, nil
+	}
+
+	return INFO, fmt.Errorf("ParseLevel: unknown level %q, use debug, quiet, info, notice, warning or error", name)
+}
+
+// How severe a level is, to compare it with a module's level
+func severity(level Level) int {
+	switch level {
+	case DEBUG:
+		return 0
+	case QUIET:
+		return 1
+	case INFO, SUCCESS:
+		return 2
+	case NOTICE:
+		return 3
+	case WARNING:
+		return 4
+	case ERROR:
+		return 5
+	}
+
+	return 6
+}
+
+func enabled(module string, level Level) bool {
+	levelsMutex.RLock()
+	minimum, ok := levels[module]
+	if !ok {
+		minimum, ok = levels[DefaultModule]
+	}
+	levelsMutex.RUnlock()
+
+	if !ok {
+		minimum = QUIET
+	}
+
+	return severity(level) >= severity(minimum)
+}
+
+// Starts a module's lines with its name
+func tag(module, formattedMessage string) string {
+	if module == "" {
+		return formattedMessage
+	}
+
+	return "[" + module + "] " + formattedMessage
+}
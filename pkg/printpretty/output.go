@@ -0,0 +1,135 @@
+package printpretty
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Lines waiting to be written before new ones are dropped
+	queueLength = 1024
+
+	// A write taking longer than this counts as slow
+	slowWriteThreshold = 100 * time.Millisecond
+
+	// How long Flush waits for the queue to drain
+	flushTimeout = 2 * time.Second
+)
+
+// OutputStats counts what happened to printed lines, to spot a sink that can't keep up
+type OutputStats struct {
+	Written    uint64 `json:"written"`
+	Dropped    uint64 `json:"dropped"`
+	SlowWrites uint64 `json:"slow_writes"`
+	Failed     uint64 `json:"failed"`
+	Queued     int    `json:"queued"`
+}
+
+// A line to write, or a Flush waiting for the lines before it
+type queuedLine struct {
+	text    string
+	flushed chan struct{}
+}
+
+var (
+	outputMutex sync.Mutex
+	output      io.Writer = os.Stdout
+	stats       OutputStats
+
+	// Dropped lines not yet reported in the output
+	unreported uint64
+
+	queue      = make(chan queuedLine, queueLength)
+	writerOnce sync.Once
+)
+
+// SetOutput changes where lines are written, stdout by default. Lines are queued and written in
+// the background, so a slow writer never holds up the caller. When the queue is full, new lines
+// are dropped and counted in Stats.
+func SetOutput(writer io.Writer) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+
+	output = writer
+}
+
+// Stats reports how many lines were written and dropped so far
+func Stats() OutputStats {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+
+	current := stats
+	current.Queued = len(queue)
+	return current
+}
+
+// Flush waits up to two seconds for queued lines to be written, e.g. before the program exits
+func Flush() {
+	writerOnce.Do(func() { go writeLines() })
+
+	flushed := make(chan struct{})
+	timer := time.NewTimer(flushTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- queuedLine{flushed: flushed}:
+	case <-timer.C:
+		return
+	}
+
+	select {
+	case <-flushed:
+	case <-timer.C:
+	}
+}
+
+// Queues text for the writer without ever blocking
+func write(text string) {
+	writerOnce.Do(func() { go writeLines() })
+
+	select {
+	case queue <- queuedLine{text: text}:
+	default:
+		outputMutex.Lock()
+		stats.Dropped++
+		unreported++
+		outputMutex.Unlock()
+	}
+}
+
+func writeLines() {
+	for line := range queue {
+		if line.flushed != nil {
+			close(line.flushed)
+			continue
+		}
+
+		outputMutex.Lock()
+		writer := output
+		dropped := unreported
+		unreported = 0
+		outputMutex.Unlock()
+
+		text := line.text
+		if dropped > 0 {
+			text = sprintc(yellow, fmt.Sprintf("[printpretty] dropped %d line(s), output is too slow", dropped)) + "\r\n" + text
+		}
+
+		started := time.Now()
+		_, err := io.WriteString(writer, text)
+		slow := time.Since(started) > slowWriteThreshold
+
+		outputMutex.Lock()
+		stats.Written++
+		if slow {
+			stats.SlowWrites++
+		}
+		if err != nil {
+			stats.Failed++
+		}
+		outputMutex.Unlock()
+	}
+}
@@ -0,0 +1,49 @@
+package printpretty
+
+import (
+	"sync"
+	"time"
+)
+
+// Layouts for SetTimestamps
+const (
+	// The default, local time of day
+	TimeOnly = "15:04:05.000"
+
+	// With the date, for logs that span days
+	DateTime = "2006-01-02 15:04:05.000"
+
+	// With the date and offset, for log aggregators
+	RFC3339 = "2006-01-02T15:04:05.000Z07:00"
+)
+
+var (
+	timestampMutex    sync.RWMutex
+	timestampLayout   = TimeOnly
+	timestampLocation = time.Local
+)
+
+// SetTimestamps changes the timestamp in front of each line. layout is a time layout such as
+// DateTime or RFC3339, or empty for no timestamps. A nil location means local time.
+func SetTimestamps(layout string, location *time.Location) {
+	if location == nil {
+		location = time.Local
+	}
+
+	timestampMutex.Lock()
+	defer timestampMutex.Unlock()
+
+	timestampLayout = layout
+	timestampLocation = location
+}
+
+func timestamp(now time.Time) string {
+	timestampMutex.RLock()
+	defer timestampMutex.RUnlock()
+
+	if timestampLayout == "" {
+		return ""
+	}
+
+	return now.In(timestampLocation).Format(timestampLayout)
+}
@@ -0,0 +1,313 @@
+// Package s3 is a small client for S3-compatible object storage such as AWS S3, MinIO or Google
+// Cloud Storage with HMAC keys. It signs requests with AWS Signature Version 4.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files larger than this are uploaded in parts. S3 needs parts of at least 5 MiB.
+const DefaultPartSize = 8 << 20
+
+// Client talks to one bucket
+type Client struct {
+	// Base URL of the service, e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+	Endpoint string
+
+	// Region used in signatures, e.g. "eu-west-1". MinIO accepts "us-east-1".
+	Region string
+
+	Bucket string
+
+	AccessKeyID string
+
+	SecretAccessKey string
+
+	// Put the bucket in the path instead of the host name, as MinIO usually expects
+	PathStyle bool
+
+	// Size of multipart upload parts. Defaults to DefaultPartSize.
+	PartSize int
+
+	// Defaults to a client with a one minute timeout
+	HTTPClient *http.Client
+}
+
+// Object describes a stored object
+type Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	Size         int64     `xml:"Size"`
+}
+
+// Upload stores data under key, in parts if it is larger than PartSize
+func (client *Client) Upload(ctx context.Context, key string, data []byte) error {
+	partSize := client.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	if len(data) <= partSize {
+		_, err := client.do(ctx, http.MethodPut, key, nil, data)
+		if err != nil {
+			return errors.New("s3.Upload: " + err.Error())
+		}
+		return nil
+	}
+
+	err := client.uploadMultipart(ctx, key, data, partSize)
+	if err != nil {
+		return errors.New("s3.Upload: " + err.Error())
+	}
+
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (client *Client) uploadMultipart(ctx context.Context, key string, data []byte, partSize int) error {
+	response, err := client.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+
+	initiated := struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	err = xml.Unmarshal(response.body, &initiated)
+	if err != nil {
+		return err
+	}
+
+	parts := []completedPart{}
+	for start, number := 0, 1; start < len(data); start, number = start+partSize, number+1 {
+		end := start + partSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {initiated.UploadID}}
+		response, err := client.do(ctx, http.MethodPut, key, query, data[start:end])
+		if err != nil {
+			// Don't leave the parts behind, they are billed until the upload is aborted
+			client.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil)
+			return err
+		}
+
+		parts = append(parts, completedPart{PartNumber: number, ETag: response.header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.do(ctx, http.MethodPost, key, url.Values{"uploadId": {initiated.UploadID}}, body)
+	return err
+}
+
+// List returns the objects whose keys start with prefix
+func (client *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		response, err := client.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, errors.New("s3.List: " + err.Error())
+		}
+
+		page := struct {
+			Contents              []Object `xml:"Contents"`
+			IsTruncated           bool     `xml:"IsTruncated"`
+			NextContinuationToken string   `xml:"NextContinuationToken"`
+		}{}
+		err = xml.Unmarshal(response.body, &page)
+		if err != nil {
+			return nil, errors.New("s3.List: " + err.Error())
+		}
+
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Delete removes an object
+func (client *Client) Delete(ctx context.Context, key string) error {
+	_, err := client.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return errors.New("s3.Delete: " + err.Error())
+	}
+
+	return nil
+}
+
+type response struct {
+	header http.Header
+	body   []byte
+}
+
+// Sends a signed request for an object, or for the bucket when key is empty
+func (client *Client) do(ctx context.Context, method, key string, query url.Values, body []byte) (*response, error) {
+	endpoint, err := url.Parse(client.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	host := endpoint.Host
+	path := "/" + key
+	if client.PathStyle {
+		path = "/" + client.Bucket + path
+	} else {
+		host = client.Bucket + "." + host
+	}
+
+	target := endpoint.Scheme + "://" + host + encodePath(path)
+	if len(query) > 0 {
+		target += "?" + canonicalQuery(query)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	client.sign(request, host, path, query, body, time.Now().UTC())
+
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Minute}
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		failure := struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}{}
+		xml.Unmarshal(data, &failure)
+		return nil, fmt.Errorf("%s %s: %s %s %s", method, path, resp.Status, failure.Code, failure.Message)
+	}
+
+	return &response{header: resp.Header, body: data}, nil
+}
+
+// Adds AWS Signature Version 4 headers
+func (client *Client) sign(request *http.Request, host, path string, query url.Values, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		encodePath(path),
+		canonicalQuery(query),
+		"host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + client.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+client.SecretAccessKey), date)
+	key = hmacSHA256(key, client.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		client.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Percent-encodes everything but unreserved characters, as the signature requires
+func uriEncode(text string, keepSlash bool) string {
+	var builder strings.Builder
+	for _, char := range []byte(text) {
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~':
+			builder.WriteByte(char)
+		case char == '/' && keepSlash:
+			builder.WriteByte(char)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", char)
+		}
+	}
+
+	return builder.String()
+}
+
+func encodePath(path string) string {
+	return uriEncode(path, true)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
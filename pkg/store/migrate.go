@@ -0,0 +1,180 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The bucket and key the schema version is kept under
+const (
+	schemaBucket     = "schema"
+	schemaVersionKey = "version"
+)
+
+// Migration changes how data in a Store is laid out, from the version before it to Version.
+// Down undoes Up, for rolling back to an older release.
+type Migration struct {
+	Version int
+	Name    string
+
+	Up   func(Store) error
+	Down func(Store) error
+}
+
+// Snapshotter is a Store that can be put back the way it was, so a failed migration leaves
+// nothing half done
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// SchemaVersion is the version of the last migration applied to a Store, or 0 if none was
+func SchemaVersion(store Store) (int, error) {
+	version := 0
+	_, err := store.Get(schemaBucket, schemaVersionKey, &version)
+	if err != nil {
+		return 0, errors.New("store.SchemaVersion: " + err.Error())
+	}
+
+	return version, nil
+}
+
+// Migrate runs migrations up or down until the Store is at version target. If one fails, a
+// Snapshotter is restored to how it was before; other stores stay at the last version that
+// succeeded. Returns the version the Store was at.
+func Migrate(store Store, migrations []Migration, target int) (int, error) {
+	migrations = append([]Migration{}, migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version <= 0 || (i > 0 && migration.Version == migrations[i-1].Version) {
+			return 0, fmt.Errorf("store.Migrate: migration %q needs a unique version above 0", migration.Name)
+		}
+	}
+
+	current, err := SchemaVersion(store)
+	if err != nil {
+		return 0, err
+	}
+	if target < 0 || (target > 0 && indexOf(migrations, target) == -1) {
+		return current, fmt.Errorf("store.Migrate: there is no version %d", target)
+	}
+	if current > 0 && indexOf(migrations, current) == -1 {
+		return current, fmt.Errorf("store.Migrate: the Store is at version %d, which this release doesn't know. Upgrade, or migrate down with the release that wrote it", current)
+	}
+	if current == target {
+		return current, nil
+	}
+
+	var snapshot []byte
+	snapshotter, canRestore := store.(Snapshotter)
+	if canRestore {
+		snapshot, err = snapshotter.Snapshot()
+		if err != nil {
+			return current, errors.New("store.Migrate: " + err.Error())
+		}
+	}
+
+	err = step(store, migrations, current, target)
+	if err != nil && canRestore {
+		restoreErr := snapshotter.Restore(snapshot)
+		if restoreErr != nil {
+			return current, fmt.Errorf("store.Migrate: %s, and restoring the Store failed: %s", err.Error(), restoreErr.Error())
+		}
+		return current, fmt.Errorf("store.Migrate: %s. The Store was restored to version %d", err.Error(), current)
+	}
+	if err != nil {
+		return current, errors.New("store.Migrate: " + err.Error())
+	}
+
+	return current, nil
+}
+
+// Applies each migration between current and target, recording the version after each
+func step(store Store, migrations []Migration, current, target int) error {
+	if target > current {
+		for _, migration := range migrations {
+			if migration.Version <= current || migration.Version > target {
+				continue
+			}
+
+			logger.Info("Migrating the Store up to version %d: %s", migration.Version, migration.Name)
+			if migration.Up != nil {
+				err := migration.Up(store)
+				if err != nil {
+					return fmt.Errorf("migration %d (%s) failed: %s", migration.Version, migration.Name, err.Error())
+				}
+			}
+
+			err := store.Put(schemaBucket, schemaVersionKey, migration.Version)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version > current || migration.Version <= target {
+			continue
+		}
+		if migration.Down == nil {
+			return fmt.Errorf("migration %d (%s) can't be rolled back", migration.Version, migration.Name)
+		}
+
+		logger.Info("Rolling the Store back from version %d: %s", migration.Version, migration.Name)
+		err := migration.Down(store)
+		if err != nil {
+			return fmt.Errorf("rolling back migration %d (%s) failed: %s", migration.Version, migration.Name, err.Error())
+		}
+
+		previous := 0
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+		err = store.Put(schemaBucket, schemaVersionKey, previous)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func indexOf(migrations []Migration, version int) int {
+	for i, migration := range migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Restore replaces every bucket with a Snapshot
+func (memory *Memory) Restore(data []byte) error {
+	buckets := map[string]map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &buckets)
+	if err != nil {
+		return errors.New("store.Restore: " + err.Error())
+	}
+
+	memory.mutex.Lock()
+	memory.buckets = buckets
+	memory.mutex.Unlock()
+
+	return nil
+}
+
+// Restore replaces every bucket with a Snapshot and saves the file
+func (file *File) Restore(data []byte) error {
+	err := file.Memory.Restore(data)
+	if err != nil {
+		return err
+	}
+
+	return file.save()
+}
@@ -0,0 +1,233 @@
+// Package store persists data for the bot's features, such as raffle receipts, in named buckets
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+var logger = printpretty.Module("store")
+
+// Store holds JSON-encoded values grouped into buckets
+type Store interface {
+	// Get decodes the value for key into value, returning false if there is no such key
+	Get(bucket, key string, value interface{}) (bool, error)
+
+	// Put stores value under key, replacing any existing value
+	Put(bucket, key string, value interface{}) error
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(bucket, key string) error
+
+	// Keys lists the keys in a bucket in sorted order
+	Keys(bucket string) ([]string, error)
+}
+
+// Claimer is a Store that can write a key only if it is missing, atomically. A Store shared by
+// several Bot instances should implement it so they don't act twice on the same message.
+type Claimer interface {
+	// PutIfAbsent stores value under key unless the key exists, returning true if it stored it
+	PutIfAbsent(bucket, key string, value interface{}) (bool, error)
+}
+
+// Memory is a Store that only lives as long as the process
+type Memory struct {
+	mutex   sync.RWMutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+// NewMemory creates an empty in-memory Store
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]map[string]json.RawMessage{}}
+}
+
+// Get decodes the value for key into value
+func (memory *Memory) Get(bucket, key string, value interface{}) (bool, error) {
+	memory.mutex.RLock()
+	data, ok := memory.buckets[bucket][key]
+	memory.mutex.RUnlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	err := json.Unmarshal(data, value)
+	if err != nil {
+		return false, errors.New("store.Get: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// Put stores value under key
+func (memory *Memory) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errors.New("store.Put: " + err.Error())
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	if memory.buckets[bucket] == nil {
+		memory.buckets[bucket] = map[string]json.RawMessage{}
+	}
+	memory.buckets[bucket][key] = data
+
+	return nil
+}
+
+// PutIfAbsent stores value under key unless the key exists
+func (memory *Memory) PutIfAbsent(bucket, key string, value interface{}) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, errors.New("store.PutIfAbsent: " + err.Error())
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	if _, ok := memory.buckets[bucket][key]; ok {
+		return false, nil
+	}
+
+	if memory.buckets[bucket] == nil {
+		memory.buckets[bucket] = map[string]json.RawMessage{}
+	}
+	memory.buckets[bucket][key] = data
+
+	return true, nil
+}
+
+// Delete removes key
+func (memory *Memory) Delete(bucket, key string) error {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	delete(memory.buckets[bucket], key)
+
+	return nil
+}
+
+// Keys lists the keys in a bucket
+func (memory *Memory) Keys(bucket string) ([]string, error) {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	keys := make([]string, 0, len(memory.buckets[bucket]))
+	for key := range memory.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Snapshot returns every bucket as JSON, in the same format File saves, for backups
+func (memory *Memory) Snapshot() ([]byte, error) {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	data, err := json.MarshalIndent(memory.buckets, "", "  ")
+	if err != nil {
+		return nil, errors.New("store.Snapshot: " + err.Error())
+	}
+
+	return data, nil
+}
+
+// File is a Store kept in memory and written to a JSON file after every change
+type File struct {
+	*Memory
+
+	path string
+
+	writeMutex sync.Mutex
+}
+
+// OpenFile loads a Store from a JSON file, creating it on the first write if it doesn't exist
+func OpenFile(path string) (*File, error) {
+	file := &File{Memory: NewMemory(), path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, errors.New("store.OpenFile: " + err.Error())
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &file.buckets)
+		if err != nil {
+			return nil, errors.New("store.OpenFile: " + err.Error())
+		}
+	}
+	logger.Debug("Loaded %d bucket(s) from %s", len(file.buckets), path)
+
+	return file, nil
+}
+
+// Put stores value under key and saves the file
+func (file *File) Put(bucket, key string, value interface{}) error {
+	err := file.Memory.Put(bucket, key, value)
+	if err != nil {
+		return err
+	}
+
+	return file.save()
+}
+
+// PutIfAbsent stores value under key unless the key exists, and saves the file if it did
+func (file *File) PutIfAbsent(bucket, key string, value interface{}) (bool, error) {
+	stored, err := file.Memory.PutIfAbsent(bucket, key, value)
+	if err != nil || !stored {
+		return stored, err
+	}
+
+	return true, file.save()
+}
+
+// Delete removes key and saves the file
+func (file *File) Delete(bucket, key string) error {
+	err := file.Memory.Delete(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	return file.save()
+}
+
+func (file *File) save() error {
+	file.writeMutex.Lock()
+	defer file.writeMutex.Unlock()
+
+	file.mutex.RLock()
+	data, err := json.MarshalIndent(file.buckets, "", "  ")
+	file.mutex.RUnlock()
+
+	if err != nil {
+		return errors.New("store.save: " + err.Error())
+	}
+
+	// Write then rename so a crash never leaves a half written file behind
+	tmpPath := file.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return errors.New("store.save: " + err.Error())
+	}
+
+	err = os.Rename(tmpPath, file.path)
+	if err != nil {
+		return errors.New("store.save: " + err.Error())
+	}
+	logger.Debug("Saved %d bytes to %s", len(data), file.path)
+
+	return nil
+}
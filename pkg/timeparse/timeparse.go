@@ -0,0 +1,472 @@
+// Package timeparse reads the times people type in chat, such as "in 20 minutes", "at 8pm CET" or
+// "every friday 19:00". English, Spanish and German wording is understood.
+package timeparse
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is when something should happen, and how it repeats if it does
+type Schedule struct {
+	// The first time the schedule fires
+	At time.Time
+
+	// Fixed gap between repeats, from "every 30 minutes"
+	Interval time.Duration
+
+	// Repeats every day at the clock time of At, from "every day at 9am"
+	Daily bool
+
+	// Repeats on these days at the clock time of At, from "every friday 19:00"
+	Weekdays []time.Weekday
+}
+
+// Recurring reports whether the schedule repeats
+func (schedule *Schedule) Recurring() bool {
+	return schedule.Interval > 0 || schedule.Daily || len(schedule.Weekdays) > 0
+}
+
+// Next returns the first time the schedule fires after the given time, or the zero time if it has no
+// more occurrences
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	if schedule.At.After(after) {
+		return schedule.At
+	}
+
+	switch {
+	case schedule.Interval > 0:
+		skipped := after.Sub(schedule.At)/schedule.Interval + 1
+		return schedule.At.Add(skipped * schedule.Interval)
+	case schedule.Daily || len(schedule.Weekdays) > 0:
+		return schedule.nextOnDay(after)
+	}
+
+	return time.Time{}
+}
+
+// Finds the first matching day after the given time, at the clock time of At
+func (schedule *Schedule) nextOnDay(after time.Time) time.Time {
+	location := schedule.At.Location()
+	local := after.In(location)
+
+	for days := 0; days <= 7; days++ {
+		day := local.AddDate(0, 0, days)
+		next := time.Date(day.Year(), day.Month(), day.Day(), schedule.At.Hour(), schedule.At.Minute(), 0, 0, location)
+		if next.After(after) && schedule.firesOn(next.Weekday()) {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+func (schedule *Schedule) firesOn(weekday time.Weekday) bool {
+	if schedule.Daily {
+		return true
+	}
+
+	for _, day := range schedule.Weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse reads a time expression that makes up all of text. Times without a timezone are read in
+// location.
+func Parse(text string, now time.Time, location *time.Location) (*Schedule, error) {
+	schedule, rest, err := ParsePrefix(text, now, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if rest != "" {
+		return nil, errors.New("timeparse.Parse: didn't understand " + strconv.Quote(rest))
+	}
+
+	return schedule, nil
+}
+
+// ParsePrefix reads a time expression from the start of text and returns whatever follows it, so
+// "in 20 minutes stretch your legs" gives a schedule and "stretch your legs"
+func ParsePrefix(text string, now time.Time, location *time.Location) (*Schedule, string, error) {
+	words := strings.Fields(text)
+	p := &parser{
+		original: words,
+		tokens:   make([]string, len(words)),
+		now:      now,
+		location: location,
+	}
+	for i, word := range words {
+		p.tokens[i] = strings.Trim(strings.ToLower(word), ",")
+	}
+
+	schedule, err := p.parse()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return schedule, strings.Join(p.original[p.position:], " "), nil
+}
+
+type parser struct {
+	original []string
+	tokens   []string
+	position int
+	now      time.Time
+	location *time.Location
+}
+
+func (p *parser) peek(offset int) string {
+	if p.position+offset < len(p.tokens) {
+		return p.tokens[p.position+offset]
+	}
+
+	return ""
+}
+
+func (p *parser) parse() (*Schedule, error) {
+	switch {
+	case inWords[p.peek(0)]:
+		p.position++
+		duration, ok := p.duration()
+		if !ok {
+			return nil, errors.New("timeparse: expected an amount of time like \"20 minutes\"")
+		}
+
+		return &Schedule{At: p.now.Add(duration)}, nil
+	case everyWords[p.peek(0)]:
+		p.position++
+		return p.every()
+	}
+
+	return p.dayAndClock()
+}
+
+// "every 30 minutes", "every day at 9am", "every friday 19:00"
+func (p *parser) every() (*Schedule, error) {
+	schedule := &Schedule{}
+
+	for {
+		word := p.peek(0)
+		if weekday, ok := weekdayWords[word]; ok {
+			schedule.Weekdays = append(schedule.Weekdays, weekday)
+		} else if dayWords[word] {
+			schedule.Daily = true
+		} else if len(schedule.Weekdays) > 0 && andWords[word] {
+			// "every monday and friday"
+		} else {
+			break
+		}
+		p.position++
+	}
+
+	if !schedule.Daily && len(schedule.Weekdays) == 0 {
+		interval, ok := p.duration()
+		if !ok {
+			return nil, errors.New("timeparse: expected a day or an amount of time after \"every\"")
+		}
+
+		schedule.Interval = interval
+		schedule.At = p.now.Add(interval)
+		return schedule, nil
+	}
+
+	p.skipAtWords()
+	hour, minute, location, ok := p.clock()
+	if !ok {
+		return nil, errors.New("timeparse: expected a time of day like \"19:00\" or \"8pm\"")
+	}
+
+	// Anchor on the clock time today, then move to the first matching day
+	local := p.now.In(location)
+	schedule.At = time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, location)
+	schedule.At = schedule.nextOnDay(p.now)
+
+	return schedule, nil
+}
+
+// "at 8pm CET", "tomorrow at 9", "friday 19:00"
+func (p *parser) dayAndClock() (*Schedule, error) {
+	daysAhead := -1
+	var weekday time.Weekday
+	hasWeekday := false
+
+	word := p.peek(0)
+	if tomorrowWords[word] {
+		daysAhead = 1
+		p.position++
+	} else if todayWords[word] {
+		daysAhead = 0
+		p.position++
+	} else if day, ok := weekdayWords[word]; ok {
+		weekday, hasWeekday = day, true
+		p.position++
+	}
+
+	sawAt := p.skipAtWords()
+	if !sawAt && daysAhead == -1 && !hasWeekday {
+		return nil, errors.New("timeparse: expected a time like \"in 20 minutes\", \"at 8pm\" or \"every friday 19:00\"")
+	}
+
+	hour, minute, location, ok := p.clock()
+	if !ok {
+		return nil, errors.New("timeparse: expected a time of day like \"19:00\" or \"8pm\"")
+	}
+
+	local := p.now.In(location)
+	at := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, location)
+
+	switch {
+	case hasWeekday:
+		days := (int(weekday) - int(at.Weekday()) + 7) % 7
+		at = at.AddDate(0, 0, days)
+		if !at.After(p.now) {
+			at = at.AddDate(0, 0, 7)
+		}
+	case daysAhead >= 0:
+		at = at.AddDate(0, 0, daysAhead)
+	case !at.After(p.now):
+		at = at.AddDate(0, 0, 1)
+	}
+
+	return &Schedule{At: at}, nil
+}
+
+func (p *parser) skipAtWords() bool {
+	skipped := false
+	for atWords[p.peek(0)] {
+		p.position++
+		skipped = true
+	}
+
+	return skipped
+}
+
+// Reads one or more amounts of time: "20 minutes", "1 hour 30 minutes", "1h30m", "an hour"
+func (p *parser) duration() (time.Duration, bool) {
+	var total time.Duration
+
+	for {
+		word := p.peek(0)
+
+		if compact, ok := parseCompactDuration(word); ok {
+			total += compact
+			p.position++
+			continue
+		}
+
+		amount, isNumber := numberWords[word]
+		if !isNumber {
+			parsed, err := strconv.Atoi(word)
+			isNumber = err == nil && parsed > 0
+			amount = parsed
+		}
+
+		if isNumber {
+			unit, ok := unitWords[p.peek(1)]
+			if !ok {
+				break
+			}
+			total += time.Duration(amount) * unit
+			p.position += 2
+			continue
+		}
+
+		// "1 hour and 30 minutes"
+		if total > 0 && andWords[word] {
+			if _, ok := parseCompactDuration(p.peek(1)); ok {
+				p.position++
+				continue
+			}
+			if _, err := strconv.Atoi(p.peek(1)); err == nil {
+				p.position++
+				continue
+			}
+		}
+
+		break
+	}
+
+	return total, total > 0
+}
+
+// "20m", "1h30m", "90s", "2d"
+func parseCompactDuration(word string) (time.Duration, bool) {
+	if word == "" || word[0] < '0' || word[0] > '9' {
+		return 0, false
+	}
+
+	var total time.Duration
+	number := 0
+	hasNumber := false
+
+	for _, r := range word {
+		if r >= '0' && r <= '9' {
+			number = number*10 + int(r-'0')
+			hasNumber = true
+			continue
+		}
+
+		unit, ok := unitWords[string(r)]
+		if !ok || !hasNumber {
+			return 0, false
+		}
+
+		total += time.Duration(number) * unit
+		number, hasNumber = 0, false
+	}
+
+	if hasNumber {
+		return 0, false
+	}
+
+	return total, total > 0
+}
+
+// Reads a clock time, e.g. "8pm", "8:30 pm", "20:00", "20.00", "20h30", optionally followed by a timezone
+func (p *parser) clock() (hour, minute int, location *time.Location, ok bool) {
+	word := p.peek(0)
+	meridiem := ""
+
+	for _, suffix := range []string{"a.m.", "p.m.", "am", "pm"} {
+		if strings.HasSuffix(word, suffix) {
+			meridiem = suffix[:1]
+			word = strings.TrimSuffix(word, suffix)
+			break
+		}
+	}
+
+	separator := strings.IndexAny(word, ":.h")
+	hourText, minuteText := word, "0"
+	if separator != -1 {
+		hourText, minuteText = word[:separator], word[separator+1:]
+		if minuteText == "" {
+			minuteText = "0"
+		}
+	}
+
+	hour, hourErr := strconv.Atoi(hourText)
+	minute, minuteErr := strconv.Atoi(minuteText)
+	if hourErr != nil || minuteErr != nil || minute < 0 || minute > 59 {
+		return 0, 0, nil, false
+	}
+	consumed := 1
+
+	if meridiem == "" {
+		switch p.peek(1) {
+		case "am", "a.m.":
+			meridiem, consumed = "a", 2
+		case "pm", "p.m.":
+			meridiem, consumed = "p", 2
+		}
+	}
+
+	switch meridiem {
+	case "a", "p":
+		if hour < 1 || hour > 12 {
+			return 0, 0, nil, false
+		}
+		hour %= 12
+		if meridiem == "p" {
+			hour += 12
+		}
+	default:
+		if hour < 0 || hour > 23 {
+			return 0, 0, nil, false
+		}
+	}
+
+	p.position += consumed
+
+	location = p.location
+	if zone, found := lookupZone(p.original, p.position); found {
+		location = zone
+		p.position++
+	}
+
+	return hour, minute, location, true
+}
+
+// Timezones given as abbreviations are read as fixed offsets in minutes, since "CET" means +1 regardless
+// of daylight saving
+var zoneOffsets = map[string]int{
+	"utc": 0, "gmt": 0, "z": 0,
+	"bst": 60, "cet": 60, "cest": 120, "eet": 120, "eest": 180,
+	"est": -300, "edt": -240, "cst": -360, "cdt": -300, "mst": -420, "mdt": -360, "pst": -480, "pdt": -420,
+	"ist": 330, "jst": 540, "aest": 600, "aedt": 660,
+}
+
+func lookupZone(words []string, position int) (*time.Location, bool) {
+	if position >= len(words) {
+		return nil, false
+	}
+
+	word := strings.Trim(words[position], ",")
+	if minutes, ok := zoneOffsets[strings.ToLower(word)]; ok {
+		return time.FixedZone(strings.ToUpper(word), minutes*60), true
+	}
+
+	if strings.Contains(word, "/") {
+		location, err := time.LoadLocation(word)
+		if err == nil {
+			return location, true
+		}
+	}
+
+	return nil, false
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range words {
+		set[word] = true
+	}
+
+	return set
+}
+
+var (
+	inWords       = wordSet("in", "en", "dentro")
+	everyWords    = wordSet("every", "each", "cada", "jeden", "jede", "jedes", "alle")
+	atWords       = wordSet("at", "@", "a", "las", "la", "um")
+	andWords      = wordSet("and", "y", "und", "&")
+	todayWords    = wordSet("today", "tonight", "hoy", "heute")
+	tomorrowWords = wordSet("tomorrow", "mañana", "manana", "morgen")
+	dayWords      = wordSet("day", "daily", "día", "dia", "días", "dias", "tag", "tage", "täglich")
+)
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "five": 5, "ten": 10, "fifteen": 15, "thirty": 30,
+	"un": 1, "una": 1, "uno": 1, "dos": 2, "tres": 3, "cinco": 5, "diez": 10,
+	"ein": 1, "eine": 1, "einer": 1, "zwei": 2, "drei": 3, "fünf": 5, "zehn": 10,
+}
+
+var unitWords = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"segundo": time.Second, "segundos": time.Second, "sekunde": time.Second, "sekunden": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"minuto": time.Minute, "minutos": time.Minute, "minuten": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"hora": time.Hour, "horas": time.Hour, "stunde": time.Hour, "stunden": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"día": 24 * time.Hour, "días": 24 * time.Hour, "tag": 24 * time.Hour, "tage": 24 * time.Hour, "tagen": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"semana": 7 * 24 * time.Hour, "semanas": 7 * 24 * time.Hour, "woche": 7 * 24 * time.Hour, "wochen": 7 * 24 * time.Hour,
+}
+
+var weekdayWords = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	"domingo": time.Sunday, "lunes": time.Monday, "martes": time.Tuesday, "miércoles": time.Wednesday,
+	"miercoles": time.Wednesday, "jueves": time.Thursday, "viernes": time.Friday, "sábado": time.Saturday, "sabado": time.Saturday,
+	"sonntag": time.Sunday, "montag": time.Monday, "dienstag": time.Tuesday, "mittwoch": time.Wednesday,
+	"donnerstag": time.Thursday, "freitag": time.Friday, "samstag": time.Saturday,
+}
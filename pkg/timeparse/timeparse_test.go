@@ -0,0 +1,237 @@
+package timeparse
+
+import (
+	"testing"
+	"time"
+
+	// So Europe/Berlin loads wherever the tests run
+	_ "time/tzdata"
+)
+
+func berlin(t *testing.T) *time.Location {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location
+}
+
+func TestParse(t *testing.T) {
+	location := berlin(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, location)
+	}
+	cet := time.FixedZone("CET", 60*60)
+
+	// Saturday, the day before clocks in Berlin go forward an hour
+	now := at(time.March, 28, 12, 0)
+
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		// Relative offsets are exact durations
+		{"in 20 minutes", now.Add(20 * time.Minute)},
+		{"in 1 hour and 30 minutes", now.Add(90 * time.Minute)},
+		{"in 1h30m", now.Add(90 * time.Minute)},
+		{"in an hour", now.Add(time.Hour)},
+		{"en diez minutos", now.Add(10 * time.Minute)},
+		{"in zwei Stunden", now.Add(2 * time.Hour)},
+		// A day later in time, which is 13:00 on the clock once summer time starts
+		{"in 1 day", at(time.March, 29, 13, 0)},
+
+		// Clock times later today, or tomorrow once they've passed
+		{"at 8pm", at(time.March, 28, 20, 0)},
+		{"at 20:00", at(time.March, 28, 20, 0)},
+		{"at 8:30 pm", at(time.March, 28, 20, 30)},
+		{"at 12:00", at(time.March, 29, 12, 0)},
+		{"at 9am", at(time.March, 29, 9, 0)},
+		{"today at 11pm", at(time.March, 28, 23, 0)},
+
+		// "tomorrow" keeps the wall clock time over the switch to summer time
+		{"tomorrow at 9", at(time.March, 29, 9, 0)},
+		{"mañana a las 9", at(time.March, 29, 9, 0)},
+		{"morgen um 9", at(time.March, 29, 9, 0)},
+
+		// Weekdays roll over to next week once this week's has passed
+		{"saturday 19:00", at(time.March, 28, 19, 0)},
+		{"saturday 10:00", at(time.April, 4, 10, 0)},
+		{"friday 19:00", at(time.April, 3, 19, 0)},
+		{"monday at 8am", at(time.March, 30, 8, 0)},
+		{"Freitag 20h30", at(time.April, 3, 20, 30)},
+
+		// Zones
+		{"at 8pm CET", time.Date(2026, time.March, 28, 20, 0, 0, 0, cet)},
+		{"at 3pm UTC", time.Date(2026, time.March, 28, 15, 0, 0, 0, time.UTC)},
+		{"at 8pm America/New_York", time.Date(2026, time.March, 28, 20, 0, 0, 0, mustLoad(t, "America/New_York"))},
+	}
+
+	for _, test := range tests {
+		schedule, err := Parse(test.text, now, location)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", test.text, err.Error())
+			continue
+		}
+
+		if !schedule.At.Equal(test.want) {
+			t.Errorf("Parse(%q) = %s, want %s", test.text, schedule.At, test.want)
+		}
+		if schedule.Recurring() {
+			t.Errorf("Parse(%q) repeats", test.text)
+		}
+	}
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location
+}
+
+func TestParseSummerTime(t *testing.T) {
+	location := berlin(t)
+	now := time.Date(2026, time.March, 28, 12, 0, 0, 0, location)
+
+	schedule, err := Parse("at 9am", now, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 9am in summer time is 7:00 UTC, where the day before it was 8:00
+	if hour := schedule.At.UTC().Hour(); hour != 7 {
+		t.Errorf("9am on the first day of summer time is %d:00 UTC, want 7:00", hour)
+	}
+}
+
+func TestParseRecurring(t *testing.T) {
+	location := berlin(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, location)
+	}
+	now := at(time.March, 28, 12, 0)
+
+	tests := []struct {
+		text  string
+		first time.Time
+		next  time.Time
+	}{
+		{"every 30 minutes", now.Add(30 * time.Minute), now.Add(time.Hour)},
+		{"every day at 9am", at(time.March, 29, 9, 0), at(time.March, 30, 9, 0)},
+		{"every day at 1pm", at(time.March, 28, 13, 0), at(time.March, 29, 13, 0)},
+		{"every friday 19:00", at(time.April, 3, 19, 0), at(time.April, 10, 19, 0)},
+		{"every monday and friday 19:00", at(time.March, 30, 19, 0), at(time.April, 3, 19, 0)},
+		{"cada viernes a las 19:00", at(time.April, 3, 19, 0), at(time.April, 10, 19, 0)},
+		{"jeden Tag um 9", at(time.March, 29, 9, 0), at(time.March, 30, 9, 0)},
+	}
+
+	for _, test := range tests {
+		schedule, err := Parse(test.text, now, location)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", test.text, err.Error())
+			continue
+		}
+
+		if !schedule.Recurring() {
+			t.Errorf("Parse(%q) doesn't repeat", test.text)
+		}
+		if !schedule.At.Equal(test.first) {
+			t.Errorf("Parse(%q) first = %s, want %s", test.text, schedule.At, test.first)
+		}
+		if next := schedule.Next(schedule.At); !next.Equal(test.next) {
+			t.Errorf("Parse(%q) next = %s, want %s", test.text, next, test.next)
+		}
+	}
+}
+
+func TestNextOverWinterTime(t *testing.T) {
+	location := berlin(t)
+
+	// Clocks in Berlin go back an hour at 3am on October 25th 2026
+	now := time.Date(2026, time.October, 24, 12, 0, 0, 0, location)
+	schedule, err := Parse("every day at 9am", now, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Time{
+		time.Date(2026, time.October, 25, 9, 0, 0, 0, location),
+		time.Date(2026, time.October, 26, 9, 0, 0, 0, location),
+	}
+	first := schedule.At
+	second := schedule.Next(first)
+	if !first.Equal(want[0]) || !second.Equal(want[1]) {
+		t.Errorf("firings = %s, %s, want %s, %s", first, second, want[0], want[1])
+	}
+
+	// The day the clocks go back is 25 hours long
+	if gap := first.Sub(now); gap != 22*time.Hour {
+		t.Errorf("first firing is %s away, want 22h", gap)
+	}
+
+	// An interval is exact, whatever the clock says
+	schedule, err = Parse("every 1 hour", time.Date(2026, time.October, 25, 1, 30, 0, 0, location), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := schedule.Next(schedule.At.Add(90 * time.Minute))
+	if gap := later.Sub(schedule.At); gap != 2*time.Hour {
+		t.Errorf("two intervals later is %s after the first firing, want 2h", gap)
+	}
+}
+
+func TestNextOneShot(t *testing.T) {
+	now := time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC)
+	schedule := &Schedule{At: now.Add(time.Hour)}
+
+	if next := schedule.Next(now); !next.Equal(schedule.At) {
+		t.Errorf("Next before it fires = %s, want %s", next, schedule.At)
+	}
+	if next := schedule.Next(now.Add(2 * time.Hour)); !next.IsZero() {
+		t.Errorf("Next after it fired = %s, want none", next)
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	now := time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC)
+
+	schedule, rest, err := ParsePrefix("in 20 minutes stretch your legs", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.At.Equal(now.Add(20*time.Minute)) || rest != "stretch your legs" {
+		t.Errorf("ParsePrefix = %s, %q", schedule.At, rest)
+	}
+
+	schedule, rest, err = ParsePrefix("every friday 19:00 Stream time!", now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule.Weekdays) != 1 || rest != "Stream time!" {
+		t.Errorf("ParsePrefix = %+v, %q", schedule, rest)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	now := time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC)
+
+	for _, text := range []string{
+		"",
+		"soon",
+		"in a while",
+		"at 25:00",
+		"at 13pm",
+		"at 8:61",
+		"every",
+		"every friday",
+		"in 20 minutes please",
+	} {
+		_, err := Parse(text, now, time.UTC)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", text)
+		}
+	}
+}
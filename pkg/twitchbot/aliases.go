@@ -0,0 +1,82 @@
+package twitchbot
+
+import (
+	"strings"
+	"sync"
+)
+
+// Built in localized names for the default commands, keyed by language then alias
+var commandLocales = map[string]map[string]string{
+	"es": {
+		"hecho":      "chucknorris",
+		"ayuda":      "help",
+		"comandos":   "commands",
+		"sorteo":     "raffle",
+		"unirse":     "join",
+		"recuerdame": "remindme",
+		"pregunta":   "ask",
+		"contador":   "counter",
+		"marcador":   "bookmark",
+	},
+	"de": {
+		"fakt":        "chucknorris",
+		"hilfe":       "help",
+		"befehle":     "commands",
+		"verlosung":   "raffle",
+		"mitmachen":   "join",
+		"erinnere":    "remindme",
+		"frage":       "ask",
+		"zaehler":     "counter",
+		"lesezeichen": "bookmark",
+	},
+}
+
+var commandLocalesMutex sync.RWMutex
+
+// RegisterCommandLocale adds aliases to a language's bundle, e.g. "fr" {"aide": "help"}
+func RegisterCommandLocale(language string, aliases map[string]string) {
+	commandLocalesMutex.Lock()
+	defer commandLocalesMutex.Unlock()
+
+	language = strings.ToLower(language)
+	bundle, ok := commandLocales[language]
+	if !ok {
+		bundle = map[string]string{}
+		commandLocales[language] = bundle
+	}
+
+	for alias, name := range aliases {
+		bundle[strings.ToLower(alias)] = strings.ToLower(name)
+	}
+}
+
+// Maps a localized command name to the built in command it stands for. Channel aliases win over
+// global aliases, which win over the channel language's bundle. Registered names are never aliased.
+func (bot *Bot) resolveCommandAlias(channel, name string) string {
+	if _, ok := bot.Commands.Get(name); ok {
+		return name
+	}
+
+	alias := strings.ToLower(name)
+	if target, ok := bot.ChannelCommandAliases[channel][alias]; ok {
+		return target
+	}
+
+	if target, ok := bot.CommandAliases[alias]; ok {
+		return target
+	}
+
+	language, ok := bot.ChannelLanguages[channel]
+	if !ok {
+		return name
+	}
+
+	commandLocalesMutex.RLock()
+	defer commandLocalesMutex.RUnlock()
+
+	if target, ok := commandLocales[strings.ToLower(language)][alias]; ok {
+		return target
+	}
+
+	return name
+}
@@ -0,0 +1,92 @@
+package twitchbot
+
+import (
+	"errors"
+	"strings"
+)
+
+// Colors Twitch draws announcements in. "primary" is the channel's accent color.
+var announcementColors = map[string]bool{
+	"primary": true,
+	"blue":    true,
+	"green":   true,
+	"orange":  true,
+	"purple":  true,
+}
+
+// Twitch cuts announcements off after this many characters
+const maxAnnouncementLength = 500
+
+// Announce posts a highlighted announcement in ChannelName. See AnnounceTo.
+func (bot *Bot) Announce(message, color string) {
+	bot.AnnounceTo(bot.ChannelName, message, color)
+}
+
+// AnnounceTo posts a highlighted announcement in a channel through Helix, so it stands out from
+// regular chat. color is "blue", "green", "orange", "purple" or "primary", the default. The bot
+// must be a moderator there and its token needs the moderator:manage:announcements scope.
+// Without a ClientID, or if Twitch refuses, the message is sent as regular chat instead.
+func (bot *Bot) AnnounceTo(channel, message, color string) {
+	if bot.Anonymous {
+		return
+	}
+
+	message = lineBreaks.Replace(message)
+	if message == "" {
+		logger.Warn("Bot.announce: message was empty")
+		return
+	}
+
+	_, filter := bot.filters()
+	if phrase, blocked := filter.match(message); blocked {
+		filterLogger.Warn("Bot.announce: not announcing a message in #%s that matches %q: %s", channel, phrase, message)
+		return
+	}
+
+	color = strings.ToLower(color)
+	if color == "" {
+		color = "primary"
+	}
+	if !announcementColors[color] {
+		logger.Warn("Bot.announce: unknown announcement color %q, using primary", color)
+		color = "primary"
+	}
+
+	if bot.ClientID == "" {
+		bot.ChatWithPriority(channel, message, PrioritySystem)
+		return
+	}
+
+	go func() {
+		err := bot.sendAnnouncement(channel, message, color)
+		if err != nil {
+			logger.Warn("%s. Sending it as chat instead", err.Error())
+			bot.ChatWithPriority(channel, message, PrioritySystem)
+		}
+	}()
+}
+
+func (bot *Bot) sendAnnouncement(channel, message, color string) error {
+	moderatorID, err := bot.botUserID()
+	if err != nil {
+		return errors.New("Bot.sendAnnouncement: " + err.Error())
+	}
+
+	broadcasterID, err := bot.channelUserID(channel)
+	if err != nil {
+		return errors.New("Bot.sendAnnouncement: " + err.Error())
+	}
+
+	if runes := []rune(message); len(runes) > maxAnnouncementLength {
+		message = string(runes[:maxAnnouncementLength])
+	}
+
+	err = bot.helix().SendAnnouncement(bot.context(), broadcasterID, moderatorID, message, color)
+	if err != nil {
+		return errors.New("Bot.sendAnnouncement: " + err.Error())
+	}
+
+	logger.Quiet("Announced in #%s: %s", channel, message)
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package twitchbot
+
+import (
+	"strconv"
+)
+
+// Twitch lets any "justinfan" login with a number after it read chat without a token
+const anonymousLoginPrefix = "justinfan"
+
+// Picks a random justinfan login for an anonymous connection
+func (bot *Bot) anonymousLogin() string {
+	return anonymousLoginPrefix + strconv.Itoa(10000+bot.Random.Intn(90000))
+}
@@ -0,0 +1,372 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+const (
+	appealsBucket        = "appeals"
+	lastModerationBucket = "last_moderation"
+
+	// Users can appeal a timeout or ban for this long after it happened
+	appealWindow = 7 * 24 * time.Hour
+
+	// Chat lines kept per user to attach to their appeal, and for how long
+	recentChatLines = 5
+	recentChatTTL   = 30 * time.Minute
+
+	// Discord cuts webhook messages off at 2000 characters
+	maxAppealNotificationLength = 2000
+)
+
+// ChatLine is a message someone sent in a channel
+type ChatLine struct {
+	At   time.Time `json:"at"`
+	Text string    `json:"text"`
+}
+
+// ModerationAction is the last timeout or ban a user got, with what they said just before it
+type ModerationAction struct {
+	Channel string     `json:"channel"`
+	Login   string     `json:"login"`
+	Ban     bool       `json:"ban"`
+	Seconds int        `json:"seconds"`
+	At      time.Time  `json:"at"`
+	History []ChatLine `json:"history"`
+}
+
+// Describes the action, e.g. "10m0s timeout" or "ban"
+func (action ModerationAction) String() string {
+	if action.Ban {
+		return "ban"
+	}
+
+	return fmt.Sprintf("%s timeout", time.Duration(action.Seconds)*time.Second)
+}
+
+// Appeal is a user asking the moderators of a channel to reconsider a timeout or ban
+type Appeal struct {
+	Channel   string           `json:"channel"`
+	UserID    string           `json:"user_id"`
+	Login     string           `json:"login"`
+	Text      string           `json:"text"`
+	Action    ModerationAction `json:"action"`
+	CreatedAt time.Time        `json:"created_at"`
+	Closed    bool             `json:"closed"`
+	ClosedBy  string           `json:"closed_by,omitempty"`
+}
+
+// The last few lines each user sent in each channel, so a timeout can be filed with what led to it
+type recentChat struct {
+	mutex sync.Mutex
+	lines map[string][]ChatLine
+}
+
+func (bot *Bot) rememberChat(message *Message) {
+	if bot.setting(&bot.AppealKeyword) == "" || message.UserID == "" {
+		return
+	}
+
+	key := leaderboardKey(message.Channel, message.UserID)
+	now := time.Now()
+
+	bot.recentChat.mutex.Lock()
+	defer bot.recentChat.mutex.Unlock()
+
+	if bot.recentChat.lines == nil {
+		bot.recentChat.lines = map[string][]ChatLine{}
+	}
+
+	lines := append(bot.recentChat.lines[key], ChatLine{At: now, Text: message.Text})
+	if len(lines) > recentChatLines {
+		lines = lines[len(lines)-recentChatLines:]
+	}
+	bot.recentChat.lines[key] = lines
+
+	// Forget users who have gone quiet once the map gets big
+	if len(bot.recentChat.lines) > 1000 {
+		for other, lines := range bot.recentChat.lines {
+			if now.Sub(lines[len(lines)-1].At) >= recentChatTTL {
+				delete(bot.recentChat.lines, other)
+			}
+		}
+	}
+}
+
+func (bot *Bot) chatHistory(channel, userID string) []ChatLine {
+	bot.recentChat.mutex.Lock()
+	defer bot.recentChat.mutex.Unlock()
+
+	history := []ChatLine{}
+	for _, line := range bot.recentChat.lines[leaderboardKey(channel, userID)] {
+		if time.Since(line.At) < recentChatTTL {
+			history = append(history, line)
+		}
+	}
+
+	return history
+}
+
+// Keeps a user's latest timeout or ban from CLEARCHAT so they can appeal it
+func (bot *Bot) recordModerationAction(ircMessage *irc.Message) {
+	userID := ircMessage.Tags["target-user-id"]
+	if bot.setting(&bot.AppealKeyword) == "" || userID == "" {
+		return
+	}
+
+	action := ModerationAction{
+		Channel: ircMessage.Channel(),
+		Login:   strings.ToLower(ircMessage.Trailing),
+		At:      time.Now(),
+		History: bot.chatHistory(ircMessage.Channel(), userID),
+	}
+	if duration, ok := ircMessage.Tags["ban-duration"]; ok {
+		action.Seconds, _ = strconv.Atoi(duration)
+	} else {
+		action.Ban = true
+	}
+
+	err := bot.Store.Put(lastModerationBucket, userID, action)
+	if err != nil {
+		logger.Error("Bot.recordModerationAction: %s", err.Error())
+	}
+}
+
+// Files an appeal when a whisper starts with AppealKeyword. Returns false for other whispers.
+func (bot *Bot) handleAppeal(message *Message) bool {
+	keyword := bot.setting(&bot.AppealKeyword)
+	if keyword == "" || message.UserID == "" {
+		return false
+	}
+
+	fields := strings.Fields(message.Text)
+	if len(fields) == 0 || !strings.EqualFold(strings.TrimPrefix(fields[0], "!"), strings.TrimPrefix(keyword, "!")) {
+		return false
+	}
+
+	action := ModerationAction{}
+	found, err := bot.Store.Get(lastModerationBucket, message.UserID, &action)
+	if err != nil {
+		logger.Error("Bot.handleAppeal: %s", err.Error())
+		return true
+	}
+	if !found || time.Since(action.At) > appealWindow {
+		bot.whisper(message.Username, "I don't have a recent timeout or ban on record for you.")
+		return true
+	}
+
+	key := leaderboardKey(action.Channel, message.UserID)
+	existing := Appeal{}
+	found, err = bot.Store.Get(appealsBucket, key, &existing)
+	if err != nil {
+		logger.Error("Bot.handleAppeal: %s", err.Error())
+		return true
+	}
+	if found && !existing.Closed && !existing.CreatedAt.Before(action.At) {
+		bot.whisper(message.Username, fmt.Sprintf("Your appeal in #%s is already with the moderators.", action.Channel))
+		return true
+	}
+
+	appeal := Appeal{
+		Channel:   action.Channel,
+		UserID:    message.UserID,
+		Login:     strings.ToLower(message.Username),
+		Text:      strings.TrimSpace(strings.Join(fields[1:], " ")),
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+
+	err = bot.Store.Put(appealsBucket, key, appeal)
+	if err != nil {
+		logger.Error("Bot.handleAppeal: %s", err.Error())
+		return true
+	}
+
+	logger.Notice("@%s appealed their %s in #%s: %s", appeal.Login, action, appeal.Channel, appeal.Text)
+	bot.notifyAppeal(appeal)
+	bot.whisper(message.Username, fmt.Sprintf("Thanks, your appeal was sent to the moderators of #%s.", action.Channel))
+
+	return true
+}
+
+// Posts an appeal to AppealWebhook, such as a Discord channel's webhook
+func (bot *Bot) notifyAppeal(appeal Appeal) {
+	webhook := bot.setting(&bot.AppealWebhook)
+	if webhook == "" {
+		return
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "**@%s** appealed their %s in #%s", appeal.Login, appeal.Action, appeal.Channel)
+	if appeal.Text != "" {
+		fmt.Fprintf(&content, ": %s", appeal.Text)
+	}
+	if len(appeal.Action.History) > 0 {
+		content.WriteString("\nWhat they said before it:")
+		for _, line := range appeal.Action.History {
+			fmt.Fprintf(&content, "\n> %s", line.Text)
+		}
+	}
+
+	text := content.String()
+	if runes := []rune(text); len(runes) > maxAppealNotificationLength {
+		text = string(runes[:maxAppealNotificationLength])
+	}
+
+	body, err := json.Marshal(map[string]string{"content": text})
+	if err == nil {
+		var effect Effect
+		effect, err = NewEffect("webhook", WebhookEffect{URL: webhook, Body: body})
+		if err == nil {
+			err = bot.RecordEffects(effect)
+		}
+	}
+	if err != nil {
+		logger.Error("Bot.notifyAppeal: %s", err.Error())
+	}
+}
+
+// OpenAppeals lists a channel's appeals that no moderator has closed, oldest first
+func (bot *Bot) OpenAppeals(channel string) ([]Appeal, error) {
+	keys, err := bot.Store.Keys(appealsBucket)
+	if err != nil {
+		return nil, errors.New("Bot.OpenAppeals: " + err.Error())
+	}
+
+	appeals := []Appeal{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		appeal := Appeal{}
+		found, err := bot.Store.Get(appealsBucket, key, &appeal)
+		if err != nil {
+			return nil, errors.New("Bot.OpenAppeals: " + err.Error())
+		}
+		if found && !appeal.Closed {
+			appeals = append(appeals, appeal)
+		}
+	}
+
+	sort.Slice(appeals, func(i, j int) bool { return appeals[i].CreatedAt.Before(appeals[j].CreatedAt) })
+
+	return appeals, nil
+}
+
+// CloseAppeal marks a user's open appeal in a channel as handled. Returns false if they have none.
+func (bot *Bot) CloseAppeal(channel, login, closedBy string) (bool, error) {
+	// Appellants were timed out for chatting, so their login is known
+	userID, found := bot.UserIDForLogin(login)
+	if !found {
+		return false, nil
+	}
+
+	key := leaderboardKey(channel, userID)
+	appeal := Appeal{}
+	found, err := bot.Store.Get(appealsBucket, key, &appeal)
+	if err != nil {
+		return false, errors.New("Bot.CloseAppeal: " + err.Error())
+	}
+	if !found || appeal.Closed {
+		return false, nil
+	}
+
+	appeal.Closed = true
+	appeal.ClosedBy = closedBy
+	err = bot.Store.Put(appealsBucket, key, appeal)
+	if err != nil {
+		return false, errors.New("Bot.CloseAppeal: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// Lists open appeals, or closes one: !appeals [close @user]
+func appealsCommand(bot *Bot, command *Command) {
+	if len(command.Args) >= 2 && strings.EqualFold(command.Args[0], "close") {
+		login := strings.ToLower(strings.TrimPrefix(command.Args[1], "@"))
+		closed, err := bot.CloseAppeal(command.Channel, login, command.Username)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		if !closed {
+			bot.Reply(command.Message, fmt.Sprintf("@%s has no open appeal.", login))
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Closed @%s's appeal.", login))
+		return
+	}
+
+	appeals, err := bot.OpenAppeals(command.Channel)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if len(appeals) == 0 {
+		bot.Reply(command.Message, "No open appeals.")
+		return
+	}
+
+	items := make([]string, 0, len(appeals))
+	for _, appeal := range appeals {
+		items = append(items, fmt.Sprintf("@%s (%s)", appeal.Login, appeal.Action))
+	}
+
+	for _, message := range packMessages("Open appeals: ", ", ", items) {
+		bot.Reply(command.Message, message)
+	}
+}
+
+// Serves a channel's open appeals for a dashboard, e.g. /appeals?channel=mikkeever.
+// POST with action=close and user=login to close one. Appeals hold chat history, so it needs
+// AdminToken.
+func (bot *Bot) serveAppeals(w http.ResponseWriter, r *http.Request) {
+	if !bot.authorizedAdmin(r) {
+		bot.refuseUnauthorized(w)
+		return
+	}
+
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if r.FormValue("action") != "close" {
+			http.Error(w, "action must be close", http.StatusBadRequest)
+			return
+		}
+
+		closed, err := bot.CloseAppeal(channel, strings.TrimPrefix(r.FormValue("user"), "@"), "dashboard")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !closed {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	appeals, err := bot.OpenAppeals(channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(appeals)
+}
@@ -0,0 +1,272 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const bitsBucket = "bits"
+
+// The login Twitch cheers from people who didn't want their name shown
+const anonymousCheererLogin = "ananonymouscheerer"
+
+// CheerResponse is sent for cheers of at least MinBits. $(bits), $(total), $(cheers) and
+// $(message) are filled in, e.g. "$(user) just cheered $(bits) bits, $(total) in total!"
+type CheerResponse struct {
+	MinBits  int    `json:"min_bits"`
+	Response string `json:"response"`
+}
+
+// BitsEntry is what one user has cheered in a channel, all time
+type BitsEntry struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+	Bits   int    `json:"bits"`
+	Cheers int    `json:"cheers"`
+}
+
+// A cheermote is a name followed by an amount, e.g. Cheer100 or 4Head50
+var cheermote = regexp.MustCompile(`^([0-9A-Za-z]*[A-Za-z])([0-9]+)$`)
+
+// Twitch's global cheermotes. Channels can add their own, which are matched by their amounts.
+var globalCheermotes = map[string]bool{
+	"cheer": true, "doodlecheer": true, "biblethump": true, "cheerwhal": true, "corgo": true,
+	"uni": true, "showlove": true, "party": true, "seemsgood": true, "pride": true,
+	"kappa": true, "frankerz": true, "heyguys": true, "dansgame": true, "elegiggle": true,
+	"trihard": true, "kreygasm": true, "4head": true, "swiftrage": true, "notlikethis": true,
+	"failfish": true, "vohiyo": true, "pjsalt": true, "mrdestructoid": true, "bday": true,
+	"ripcheer": true, "shamrock": true, "bitboss": true, "streamlabs": true, "muxy": true,
+	"holidaycheer": true, "goal": true, "anogirl": true, "charity": true,
+}
+
+// The bits a message cheered, from its bits tag
+func cheerBits(message *Message) int {
+	bits, err := strconv.Atoi(message.Tags["bits"])
+	if err != nil || bits < 0 {
+		return 0
+	}
+
+	return bits
+}
+
+// Removes the cheermotes from a cheer, leaving what the user wrote. Global cheermotes go first,
+// then other words that look like one go as long as they don't add up to more than bits, so
+// "Cheer100 my mp3 player" keeps the mp3.
+func stripCheermotes(text string, bits int) string {
+	words := strings.Fields(text)
+	stripped := make([]bool, len(words))
+	total := 0
+
+	for _, global := range []bool{true, false} {
+		for i, word := range words {
+			match := cheermote.FindStringSubmatch(word)
+			if stripped[i] || match == nil || globalCheermotes[strings.ToLower(match[1])] != global {
+				continue
+			}
+
+			amount, err := strconv.Atoi(match[2])
+			if err != nil || total+amount > bits {
+				continue
+			}
+			total += amount
+			stripped[i] = true
+		}
+	}
+
+	kept := []string{}
+	for i, word := range words {
+		if !stripped[i] {
+			kept = append(kept, word)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// The response for a cheer: the CheerResponses entry with the highest MinBits it reaches. A
+// channel's own cheer_responses replace the Bot's.
+func (bot *Bot) cheerResponse(channel string, bits int) string {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	responses := bot.CheerResponses
+	if settings, ok := bot.ChannelSettings[channel]; ok && len(settings.CheerResponses) > 0 {
+		responses = settings.CheerResponses
+	}
+
+	best := -1
+	response := ""
+	for _, candidate := range responses {
+		if bits >= candidate.MinBits && candidate.MinBits > best {
+			best = candidate.MinBits
+			response = candidate.Response
+		}
+	}
+
+	return response
+}
+
+// Adds a cheer to the user's total and answers it from CheerResponses. Anonymous cheers are
+// answered but not counted.
+func (bot *Bot) handleCheer(message *Message) {
+	bits := cheerBits(message)
+	if bits == 0 || message.Channel == "" {
+		return
+	}
+
+	text := stripCheermotes(message.Text, bits)
+	anonymous := strings.EqualFold(message.Username, anonymousCheererLogin)
+
+	logger.Success("@%s cheered %d bits in #%s", message.Username, bits, message.Channel)
+	bot.publish(FeedBits, message.Channel, message.Username, strings.TrimSpace(fmt.Sprintf("cheered %d bits: %s", bits, text)))
+
+	entry := BitsEntry{}
+	if !anonymous {
+		var err error
+		entry, err = bot.addBits(message, bits)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+
+	template := bot.cheerResponse(message.Channel, bits)
+	if template == "" || bot.Anonymous {
+		return
+	}
+
+	values := map[string]string{
+		"bits":    strconv.Itoa(bits),
+		"total":   strconv.Itoa(entry.Bits),
+		"cheers":  strconv.Itoa(entry.Cheers),
+		"message": sanitizeTemplateInput(text),
+	}
+	if anonymous {
+		values["user"] = "An anonymous cheerer"
+		values["total"] = strconv.Itoa(bits)
+		values["cheers"] = "1"
+	}
+
+	bot.ChatWithPriority(message.Channel, strings.TrimSpace(bot.RenderTemplate(template, &Command{Message: message}, values)), PriorityReply)
+}
+
+// Adds bits to what a user has cheered in a channel, returning their new total
+func (bot *Bot) addBits(message *Message, bits int) (BitsEntry, error) {
+	user := leaderboardUser(message)
+	key := leaderboardKey(message.Channel, user)
+
+	bot.bitsMutex.Lock()
+	defer bot.bitsMutex.Unlock()
+
+	entry := BitsEntry{UserID: user}
+	_, err := bot.Store.Get(bitsBucket, key, &entry)
+	if err != nil {
+		return entry, errors.New("Bot.addBits: " + err.Error())
+	}
+
+	entry.Login = strings.ToLower(message.Username)
+	entry.Bits += bits
+	entry.Cheers++
+
+	err = bot.Store.Put(bitsBucket, key, entry)
+	if err != nil {
+		return entry, errors.New("Bot.addBits: " + err.Error())
+	}
+
+	return entry, nil
+}
+
+// BitsLeaderboard ranks a channel's users by bits cheered of all time. Users who opted out of
+// leaderboards are left out.
+func (bot *Bot) BitsLeaderboard(channel string, size int) []BitsEntry {
+	keys, err := bot.Store.Keys(bitsBucket)
+	if err != nil {
+		logger.Warn("Bot.BitsLeaderboard: %s", err.Error())
+	}
+
+	entries := []BitsEntry{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		entry := BitsEntry{}
+		_, err := bot.Store.Get(bitsBucket, key, &entry)
+		if err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bits != entries[j].Bits {
+			return entries[i].Bits > entries[j].Bits
+		}
+		return entries[i].Login < entries[j].Login
+	})
+
+	ranked := []BitsEntry{}
+	for _, entry := range entries {
+		if len(ranked) == size {
+			break
+		}
+		if bot.hiddenFromLeaderboards(entry.UserID) {
+			continue
+		}
+		ranked = append(ranked, entry)
+	}
+
+	return ranked
+}
+
+// !topcheerers [size]
+func topCheerersCommand(bot *Bot, command *Command) {
+	size := defaultLeaderboardSize
+	if len(command.Args) > 0 {
+		number, err := strconv.Atoi(command.Args[0])
+		if err != nil || number <= 0 {
+			bot.Reply(command.Message, "Usage: !topcheerers [size]")
+			return
+		}
+		size = number
+	}
+	if size > maxLeaderboardSize {
+		size = maxLeaderboardSize
+	}
+
+	top := bot.BitsLeaderboard(command.Channel, size)
+	if len(top) == 0 {
+		bot.Reply(command.Message, "Nobody has cheered yet.")
+		return
+	}
+
+	ranks := make([]string, 0, len(top))
+	for i, entry := range top {
+		ranks = append(ranks, fmt.Sprintf("%d. %s (%d bits)", i+1, entry.Login, entry.Bits))
+	}
+
+	for _, message := range packMessages("Top cheerers of all time: ", ", ", ranks) {
+		bot.Reply(command.Message, message)
+	}
+}
+
+// Serves /overlay/bits?channel=name[&size=10] as JSON
+func (bot *Bot) serveBitsLeaderboard(w http.ResponseWriter, r *http.Request) {
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultLeaderboardSize
+	if number, err := strconv.Atoi(r.FormValue("size")); err == nil && number > 0 && number <= maxLeaderboardSize {
+		size = number
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bot.BitsLeaderboard(channel, size))
+}
@@ -0,0 +1,154 @@
+package twitchbot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const bookmarksBucket = "bookmarks"
+
+// Bookmark marks a moment of the stream for editors working on the VOD
+type Bookmark struct {
+	Label     string        `json:"label"`
+	Offset    time.Duration `json:"offset"`
+	CreatedBy string        `json:"created_by"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// The bookmarks of one stream in a channel
+type bookmarkSession struct {
+	StartedAt time.Time  `json:"started_at"`
+	Bookmarks []Bookmark `json:"bookmarks"`
+}
+
+func (bot *Bot) bookmarkSession(channel string) (*bookmarkSession, error) {
+	session := &bookmarkSession{}
+	_, err := bot.Store.Get(bookmarksBucket, channel, session)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.bookmarkSession: %s", err.Error())
+	}
+
+	return session, nil
+}
+
+// StartBookmarkSession starts counting bookmark offsets from the given time, discarding the
+// previous session's bookmarks
+func (bot *Bot) StartBookmarkSession(channel string, startedAt time.Time) error {
+	err := bot.Store.Put(bookmarksBucket, channel, &bookmarkSession{StartedAt: startedAt})
+	if err != nil {
+		return fmt.Errorf("Bot.StartBookmarkSession: %s", err.Error())
+	}
+
+	return nil
+}
+
+// AddBookmark records a labelled moment at the current offset into the stream. If no session was
+// started, one starts now.
+func (bot *Bot) AddBookmark(channel, label, createdBy string) (*Bookmark, error) {
+	bot.bookmarkMutex.Lock()
+	defer bot.bookmarkMutex.Unlock()
+
+	session, err := bot.bookmarkSession(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if session.StartedAt.IsZero() {
+		session.StartedAt = now
+	}
+
+	bookmark := Bookmark{
+		Label:     label,
+		Offset:    now.Sub(session.StartedAt).Truncate(time.Second),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+	session.Bookmarks = append(session.Bookmarks, bookmark)
+
+	err = bot.Store.Put(bookmarksBucket, channel, session)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.AddBookmark: %s", err.Error())
+	}
+
+	return &bookmark, nil
+}
+
+// Formats an offset as H:MM:SS, the way VOD players and chapter lists expect
+func formatOffset(offset time.Duration) string {
+	seconds := int(offset.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// ExportBookmarks writes a channel's bookmarks as "H:MM:SS label" lines to a file in ClipsExportDir,
+// returning the file's path
+func (bot *Bot) ExportBookmarks(channel string) (string, error) {
+	session, err := bot.bookmarkSession(channel)
+	if err != nil {
+		return "", err
+	}
+
+	if len(session.Bookmarks) == 0 {
+		return "", fmt.Errorf("Bot.ExportBookmarks: no bookmarks in #%s", channel)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Bookmarks from #%s, stream started %s\n\n", channel, session.StartedAt.Format(time.RFC3339))
+	for _, bookmark := range session.Bookmarks {
+		fmt.Fprintf(&builder, "%s %s (%s)\n", formatOffset(bookmark.Offset), bookmark.Label, bookmark.CreatedBy)
+	}
+
+	dir := bot.ClipsExportDir
+	if dir == "" {
+		dir = "."
+	}
+	path := filepath.Join(dir, fmt.Sprintf("bookmarks-%s-%s.txt", channel, session.StartedAt.Format("2006-01-02-150405")))
+
+	err = ioutil.WriteFile(path, []byte(builder.String()), 0644)
+	if err != nil {
+		return "", fmt.Errorf("Bot.ExportBookmarks: %s", err.Error())
+	}
+
+	logger.Success("Exported %d bookmark(s) from #%s to %s", len(session.Bookmarks), channel, path)
+	return path, nil
+}
+
+// !bookmark <label> | !bookmark start | !bookmark export
+func bookmarkCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !bookmark <label> | !bookmark start | !bookmark export")
+		return
+	}
+
+	switch strings.ToLower(command.Args[0]) {
+	case "start":
+		err := bot.StartBookmarkSession(command.Channel, time.Now())
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, "Bookmarks now count from the start of this stream.")
+		return
+	case "export":
+		path, err := bot.ExportBookmarks(command.Channel)
+		if err != nil {
+			bot.Reply(command.Message, "No bookmarks to export.")
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Exported bookmarks to %s.", filepath.Base(path)))
+		return
+	}
+
+	bookmark, err := bot.AddBookmark(command.Channel, strings.Join(command.Args, " "), command.Username)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Bookmarked \"%s\" at %s.", bookmark.Label, formatOffset(bookmark.Offset)))
+}
@@ -0,0 +1,246 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bossBattlesBucket = "boss_battles"
+
+	defaultBossDamagePerRedemption = 100
+	defaultBossDamagePerBit        = 1
+	defaultBossRewardPoints        = 100
+)
+
+// Boss health left, in percent, at which progress is announced
+var bossThresholds = []int{75, 50, 25, 10}
+
+// BossBattleSettings configures the community boss battle
+type BossBattleSettings struct {
+	// Channel point rewards that hit the boss. Only redemptions that come with a message show up in chat.
+	RewardIDs []string `json:"reward_ids"`
+
+	// Damage per redemption. Defaults to 100.
+	DamagePerRedemption int `json:"damage_per_redemption"`
+
+	// Damage per bit cheered. Defaults to 1.
+	DamagePerBit int `json:"damage_per_bit"`
+
+	// Points everyone who hit the boss gets when it is defeated. Defaults to 100.
+	RewardPoints int `json:"reward_points"`
+}
+
+// BossBattle is a boss the chat fights together
+type BossBattle struct {
+	Name      string            `json:"name"`
+	MaxHP     int               `json:"max_hp"`
+	HP        int               `json:"hp"`
+	Damage    map[string]int    `json:"damage"`
+	Logins    map[string]string `json:"logins"`
+	StartedAt time.Time         `json:"started_at"`
+}
+
+// The lowest threshold the boss's health has reached, or 100 if none
+func (battle *BossBattle) threshold() int {
+	percent := battle.HP * 100 / battle.MaxHP
+	reached := 100
+	for _, threshold := range bossThresholds {
+		if percent <= threshold {
+			reached = threshold
+		}
+	}
+
+	return reached
+}
+
+// Boss returns the boss being fought in a channel
+func (bot *Bot) Boss(channel string) (*BossBattle, bool) {
+	battle := &BossBattle{}
+	found, err := bot.Store.Get(bossBattlesBucket, channel, battle)
+	if err != nil {
+		logger.Warn("Bot.Boss: %s", err.Error())
+		return nil, false
+	}
+
+	return battle, found
+}
+
+// StartBossBattle summons a boss, replacing any boss already being fought
+func (bot *Bot) StartBossBattle(channel, name string, hp int) error {
+	bot.bossMutex.Lock()
+	defer bot.bossMutex.Unlock()
+
+	battle := &BossBattle{Name: name, MaxHP: hp, HP: hp, Damage: map[string]int{}, Logins: map[string]string{}, StartedAt: time.Now()}
+	err := bot.Store.Put(bossBattlesBucket, channel, battle)
+	if err != nil {
+		return errors.New("Bot.StartBossBattle: " + err.Error())
+	}
+
+	return nil
+}
+
+// EndBossBattle sends the boss away without a winner
+func (bot *Bot) EndBossBattle(channel string) error {
+	bot.bossMutex.Lock()
+	defer bot.bossMutex.Unlock()
+
+	err := bot.Store.Delete(bossBattlesBucket, channel)
+	if err != nil {
+		return errors.New("Bot.EndBossBattle: " + err.Error())
+	}
+
+	return nil
+}
+
+// How much a message hurts the boss: bits cheered and redemptions of the boss rewards
+func (bot *Bot) bossDamage(message *Message) int {
+	settings := bot.BossBattle
+	damage := 0
+
+	if bits, err := strconv.Atoi(message.Tags["bits"]); err == nil && bits > 0 {
+		perBit := settings.DamagePerBit
+		if perBit <= 0 {
+			perBit = defaultBossDamagePerBit
+		}
+		damage += bits * perBit
+	}
+
+	if reward := message.Tags["custom-reward-id"]; reward != "" {
+		for _, id := range settings.RewardIDs {
+			if id == reward {
+				perRedemption := settings.DamagePerRedemption
+				if perRedemption <= 0 {
+					perRedemption = defaultBossDamagePerRedemption
+				}
+				damage += perRedemption
+			}
+		}
+	}
+
+	return damage
+}
+
+// Applies a message's damage to the channel's boss, announcing thresholds and the defeat
+func (bot *Bot) hitBoss(message *Message) {
+	damage := bot.bossDamage(message)
+	if damage == 0 {
+		return
+	}
+
+	bot.bossMutex.Lock()
+	defer bot.bossMutex.Unlock()
+
+	battle, ok := bot.Boss(message.Channel)
+	if !ok || battle.HP <= 0 {
+		return
+	}
+
+	before := battle.threshold()
+	battle.HP -= damage
+	if battle.HP < 0 {
+		battle.HP = 0
+	}
+	battle.Damage[leaderboardUser(message)] += damage
+	battle.Logins[leaderboardUser(message)] = strings.ToLower(message.Username)
+
+	if battle.HP == 0 {
+		err := bot.Store.Delete(bossBattlesBucket, message.Channel)
+		if err != nil {
+			logger.Error("Bot.hitBoss: %s", err.Error())
+			return
+		}
+
+		bot.defeatBoss(message.Channel, battle, message)
+		return
+	}
+
+	err := bot.Store.Put(bossBattlesBucket, message.Channel, battle)
+	if err != nil {
+		logger.Error("Bot.hitBoss: %s", err.Error())
+		return
+	}
+
+	if after := battle.threshold(); after < before {
+		bot.ChatWithPriority(message.Channel, fmt.Sprintf("%s is down to %d%% (%d/%d HP)! @%s hit it for %d.", battle.Name, battle.HP*100/battle.MaxHP, battle.HP, battle.MaxHP, message.Username, damage), PriorityGame)
+	}
+}
+
+// Hands out points to everyone who fought and shouts out the top fighter and the finishing blow
+func (bot *Bot) defeatBoss(channel string, battle *BossBattle, finisher *Message) {
+	reward := bot.BossBattle.RewardPoints
+	if reward <= 0 {
+		reward = defaultBossRewardPoints
+	}
+
+	fighters := make([]string, 0, len(battle.Damage))
+	for user := range battle.Damage {
+		fighters = append(fighters, user)
+		_, err := bot.AddPoints(channel, user, reward)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+	sort.Slice(fighters, func(i, j int) bool { return battle.Damage[fighters[i]] > battle.Damage[fighters[j]] })
+
+	top := battle.Logins[fighters[0]]
+	logger.Success("%s was defeated in #%s by %d fighter(s)", battle.Name, channel, len(fighters))
+
+	bot.ChatWithPriority(channel, fmt.Sprintf("%s has been defeated! @%s landed the final blow. %d fighter(s) get %d points each.", battle.Name, finisher.Username, len(fighters), reward), PriorityGame)
+	bot.ChatWithPriority(channel, fmt.Sprintf("Shoutout to @%s for the most damage (%d)! Check them out at twitch.tv/%s", top, battle.Damage[fighters[0]], top), PriorityGame)
+}
+
+// !boss | !boss start <hp> <name> | !boss stop
+func bossCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		battle, ok := bot.Boss(command.Channel)
+		if !ok {
+			bot.Reply(command.Message, "There is no boss to fight right now.")
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("%s has %d/%d HP left. Cheer bits or redeem rewards to attack!", battle.Name, battle.HP, battle.MaxHP))
+		return
+	}
+
+	if !command.Can(Moderator) {
+		return
+	}
+
+	switch strings.ToLower(command.Args[0]) {
+	case "start":
+		if len(command.Args) < 3 {
+			bot.Reply(command.Message, "Usage: !boss start <hp> <name>")
+			return
+		}
+
+		hp, err := strconv.Atoi(command.Args[1])
+		if err != nil || hp <= 0 {
+			bot.Reply(command.Message, "Usage: !boss start <hp> <name>")
+			return
+		}
+
+		name := strings.Join(command.Args[2:], " ")
+		err = bot.StartBossBattle(command.Channel, name, hp)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.ChatWithPriority(command.Channel, fmt.Sprintf("%s appears with %d HP! Cheer bits or redeem rewards to attack!", name, hp), PriorityGame)
+	case "stop":
+		err := bot.EndBossBattle(command.Channel)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, "The boss retreats. Nobody wins this time.")
+	default:
+		bot.Reply(command.Message, "Usage: !boss | !boss start <hp> <name> | !boss stop")
+	}
+}
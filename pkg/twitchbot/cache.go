@@ -0,0 +1,78 @@
+package twitchbot
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedCommandFunc produces the reply for an idempotent command
+type CachedCommandFunc func(bot *Bot, command *Command) (string, error)
+
+type cachedReply struct {
+	reply   string
+	expires time.Time
+}
+
+// Holds strings, such as command replies, until they expire
+type replyCache struct {
+	mutex   sync.Mutex
+	replies map[string]cachedReply
+}
+
+func (cache *replyCache) get(key string) (string, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cached, ok := cache.replies[key]
+	if !ok {
+		return "", false
+	}
+
+	if time.Now().After(cached.expires) {
+		delete(cache.replies, key)
+		return "", false
+	}
+
+	return cached.reply, true
+}
+
+func (cache *replyCache) set(key, reply string, ttl time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := time.Now()
+	for k, cached := range cache.replies {
+		if now.After(cached.expires) {
+			delete(cache.replies, k)
+		}
+	}
+
+	cache.replies[key] = cachedReply{reply: reply, expires: now.Add(ttl)}
+}
+
+// CachedCommand wraps a command whose reply only depends on its arguments so that
+// repeated uses within ttl are answered from memory instead of calling out again
+func CachedCommand(ttl time.Duration, produce CachedCommandFunc) CommandHandler {
+	cache := &replyCache{replies: map[string]cachedReply{}}
+
+	return func(bot *Bot, command *Command) {
+		key := strings.ToLower(command.Name + " " + strings.Join(command.Args, " "))
+
+		reply, ok := cache.get(key)
+		if ok {
+			logger.Quiet("Using cached reply for !%s", key)
+		} else {
+			var err error
+			reply, err = produce(bot, command)
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+
+			cache.set(key, reply, ttl)
+		}
+
+		bot.Reply(command.Message, reply)
+	}
+}
@@ -0,0 +1,151 @@
+package twitchbot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+const defaultCategoryPollInterval = time.Minute
+
+// A channel's category and title as last seen on Twitch
+type channelInfo struct {
+	Game  string
+	Title string
+}
+
+type categories struct {
+	mutex    sync.Mutex
+	channels map[string]channelInfo
+}
+
+// Whether anything needs each channel's Twitch category: CommandPacks, GameCounters or the
+// announcements of changes
+func (bot *Bot) tracksCategories() bool {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if len(bot.CommandPacks) > 0 || len(bot.GameCounters) > 0 || bot.CategoryAnnouncement != "" || bot.TitleAnnouncement != "" {
+		return true
+	}
+	for _, settings := range bot.ChannelSettings {
+		if settings.CategoryAnnouncement != "" || settings.TitleAnnouncement != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keeps the game of every channel up to date from its Twitch category, so packs and game
+// counters switch by themselves. With "updates" in EventSubEvents the first look is all
+// that's needed, unless CategoryPollInterval is set too.
+func (bot *Bot) startCategoryPolling() {
+	if !bot.tracksCategories() || bot.ClientID == "" || bot.Anonymous {
+		return
+	}
+
+	interval := bot.CategoryPollInterval
+	if interval <= 0 {
+		interval = defaultCategoryPollInterval
+		for _, event := range bot.EventSubEvents {
+			if strings.EqualFold(event, "updates") {
+				interval = 0
+			}
+		}
+	}
+
+	go func() {
+		bot.pollCategories()
+
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bot.context().Done():
+				return
+			case <-ticker.C:
+				bot.pollCategories()
+			}
+		}
+	}()
+}
+
+// Asks Helix for the category and title of every channel
+func (bot *Bot) pollCategories() {
+	ids := []string{}
+	for _, channel := range bot.channels() {
+		id, err := bot.channelUserID(channel)
+		if err != nil {
+			logger.Warn("Bot.pollCategories: %s", err.Error())
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	for start := 0; start < len(ids); start += helix.MaxBatch {
+		end := start + helix.MaxBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		channels, err := bot.helix().GetChannelInformation(bot.context(), ids[start:end])
+		if err != nil {
+			logger.Warn("Bot.pollCategories: %s", err.Error())
+			return
+		}
+		for _, info := range channels {
+			bot.channelUpdated(strings.ToLower(info.BroadcasterLogin), info.GameName, info.Title)
+		}
+	}
+}
+
+// Notes a channel's category and title from Twitch. Changes from what was seen before are
+// logged, published and announced with CategoryAnnouncement and TitleAnnouncement; the first
+// look at a channel only sets its game.
+func (bot *Bot) channelUpdated(channel, game, title string) {
+	bot.categories.mutex.Lock()
+	if bot.categories.channels == nil {
+		bot.categories.channels = map[string]channelInfo{}
+	}
+	previous, seen := bot.categories.channels[channel]
+	bot.categories.channels[channel] = channelInfo{Game: game, Title: title}
+	bot.categories.mutex.Unlock()
+
+	bot.liveStreams.mutex.Lock()
+	if stream, live := bot.liveStreams.streams[channel]; live {
+		stream.Game, stream.Title = game, title
+		bot.liveStreams.streams[channel] = stream
+	}
+	bot.liveStreams.mutex.Unlock()
+
+	if game != "" {
+		bot.SetGame(channel, game)
+	}
+	if !seen {
+		return
+	}
+
+	values := map[string]string{"game": game, "previous": previous.Game, "title": title}
+	if game != "" && !strings.EqualFold(game, previous.Game) {
+		logger.Notice("#%s is now playing %s", channel, game)
+		bot.publish(FeedStreams, channel, "", "now playing "+game)
+
+		template := bot.channelResponse(channel, func(settings ChannelSettings) string { return settings.CategoryAnnouncement }, &bot.CategoryAnnouncement)
+		bot.announceStream(channel, template, values)
+	}
+	if title != previous.Title {
+		logger.Notice("#%s changed the title to %s", channel, title)
+		bot.publish(FeedStreams, channel, "", "title: "+title)
+
+		template := bot.channelResponse(channel, func(settings ChannelSettings) string { return settings.TitleAnnouncement }, &bot.TitleAnnouncement)
+		bot.announceStream(channel, template, values)
+	}
+}
@@ -0,0 +1,75 @@
+package twitchbot
+
+import (
+	"strings"
+	"time"
+)
+
+// Twitch allows 20 joins every 10 seconds
+const (
+	joinBatchSize = 20
+	joinRateLimit = 10 * time.Second
+)
+
+// Every channel the Bot should be in: ChannelName first, then Channels, without duplicates
+func (bot *Bot) channels() []string {
+	seen := map[string]bool{}
+	channels := []string{}
+
+	for _, channel := range append([]string{bot.ChannelName}, bot.Channels...) {
+		channel = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+		if channel == "" || seen[channel] {
+			continue
+		}
+
+		seen[channel] = true
+		channels = append(channels, channel)
+	}
+
+	return channels
+}
+
+// Joins every channel, in batches that stay under Twitch's join rate limit. Batches after the
+// first are sent in the background so chat can be read in the meantime. This runs again on every
+// reconnect, and room state and slow mode timing carry over from the last connection until Twitch
+// sends them again.
+func (bot *Bot) joinChannels() {
+	channels := bot.channels()
+
+	batches := [][]string{}
+	for len(channels) > 0 {
+		size := joinBatchSize
+		if len(channels) < size {
+			size = len(channels)
+		}
+
+		batches = append(batches, channels[:size])
+		channels = channels[size:]
+	}
+
+	join := func(batch []string) {
+		logger.Info("Joining #%s...", strings.Join(batch, ", #"))
+		bot.writeToTwitch("JOIN", "#"+strings.Join(batch, ",#"))
+	}
+
+	if len(batches) == 0 {
+		return
+	}
+
+	join(batches[0])
+
+	// Stop if the connection drops, since the next one starts joining from the top again
+	box := bot.currentOutbox()
+	if len(batches) > 1 {
+		go func() {
+			for _, batch := range batches[1:] {
+				select {
+				case <-time.After(joinRateLimit):
+				case <-box.closed:
+					return
+				}
+				join(batch)
+			}
+		}()
+	}
+}
@@ -0,0 +1,183 @@
+package twitchbot
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChannelSettings overrides the Bot's settings in one channel. Empty fields use the Bot's.
+type ChannelSettings struct {
+	// Prefixes that start a command here, instead of CommandPrefixes
+	CommandPrefixes []string `json:"command_prefixes"`
+
+	// When set, only these commands can be used here
+	EnabledCommands []string `json:"enabled_commands"`
+
+	// Commands that can't be used here
+	DisabledCommands []string `json:"disabled_commands"`
+
+	// Text commands only this channel has, and permission or cooldown changes to existing commands
+	Commands map[string]CommandConfig `json:"commands"`
+
+	ChuckNorrisResponse  string `json:"chucknorris_response"`
+	ShoutoutResponse     string `json:"shoutout_response"`
+	FirstChatterGreeting string `json:"first_chatter_greeting"`
+	FollowGreeting       string `json:"follow_greeting"`
+	EmoteOnlyResponse    string `json:"emote_only_response"`
+	RaidShoutout         string `json:"raid_shoutout"`
+	SubThanks            string `json:"sub_thanks"`
+	ResubThanks          string `json:"resub_thanks"`
+	GiftThanks           string `json:"gift_thanks"`
+	GiftBombThanks       string `json:"gift_bomb_thanks"`
+	GoLiveAnnouncement   string `json:"go_live_announcement"`
+	OfflineAnnouncement  string `json:"offline_announcement"`
+	CategoryAnnouncement string `json:"category_announcement"`
+	TitleAnnouncement    string `json:"title_announcement"`
+
+	// Replace the Bot's CheerResponses here
+	CheerResponses []CheerResponse `json:"cheer_responses"`
+}
+
+// The overrides for a channel, if it has any
+func (bot *Bot) channelSettings(channel string) (ChannelSettings, bool) {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	settings, ok := bot.ChannelSettings[channel]
+	return settings, ok
+}
+
+// A response setting for a channel: its override if it has one, otherwise the Bot's
+func (bot *Bot) channelResponse(channel string, override func(ChannelSettings) string, fallback *string) string {
+	if settings, ok := bot.channelSettings(channel); ok {
+		if response := override(settings); response != "" {
+			return response
+		}
+	}
+
+	return bot.setting(fallback)
+}
+
+func containsCommand(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(strings.TrimPrefix(candidate, "!"), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Finds the command to run for a name in a channel, with the channel's overrides applied.
+// Commands turned off in the channel are not found.
+func (bot *Bot) resolveCommand(channel, name string) (*RegisteredCommand, bool) {
+	name = strings.ToLower(name)
+	settings, hasSettings := bot.channelSettings(channel)
+
+	if hasSettings {
+		if len(settings.EnabledCommands) > 0 && !containsCommand(settings.EnabledCommands, name) {
+			return nil, false
+		}
+
+		if containsCommand(settings.DisabledCommands, name) {
+			return nil, false
+		}
+	}
+
+	override, hasOverride := settings.Commands[name]
+	if !hasOverride {
+		for configured, command := range settings.Commands {
+			if strings.EqualFold(strings.TrimPrefix(configured, "!"), name) {
+				override, hasOverride = command, true
+				break
+			}
+		}
+	}
+
+	// Then the packs for the game being played
+	if !hasOverride {
+		override, hasOverride = bot.packCommand(channel, name)
+	}
+
+	cooldown, hasCooldown := bot.cooldowns.override(channel, name)
+
+	var resolved RegisteredCommand
+	if hasOverride && override.Response != "" {
+		response := override.Response
+		resolved = RegisteredCommand{Name: name, Description: override.Description, Handler: func(bot *Bot, command *Command) {
+			bot.Reply(command.Message, bot.renderTemplate(response, command))
+		}}
+	} else {
+		registered, ok := bot.Commands.Get(name)
+		if !ok {
+			return nil, false
+		}
+
+		if !hasOverride && !hasCooldown {
+			return registered, true
+		}
+		resolved = *registered
+	}
+
+	if override.Permission != "" {
+		permission, err := ParsePermission(override.Permission)
+		if err != nil {
+			logger.Warn("Bot.resolveCommand: #%s !%s: %s", channel, name, err.Error())
+		} else {
+			resolved.Permission = permission
+		}
+	}
+
+	if override.Cooldown != 0 || override.UserCooldown != 0 {
+		resolved.GlobalCooldown = time.Duration(override.Cooldown)
+		resolved.UserCooldown = time.Duration(override.UserCooldown)
+	}
+	if hasCooldown {
+		resolved.GlobalCooldown = cooldown.global
+		resolved.UserCooldown = cooldown.perUser
+	}
+
+	if override.Description != "" {
+		resolved.Description = override.Description
+	}
+
+	return &resolved, true
+}
+
+// Every command that can be used in a channel, sorted by name
+func (bot *Bot) channelCommands(channel string) []*RegisteredCommand {
+	names := map[string]bool{}
+	for _, registered := range bot.Commands.All() {
+		names[registered.Name] = true
+	}
+
+	if settings, ok := bot.channelSettings(channel); ok {
+		for name, command := range settings.Commands {
+			if command.Response != "" {
+				names[strings.ToLower(strings.TrimPrefix(name, "!"))] = true
+			}
+		}
+	}
+
+	packs := bot.ActiveCommandPacks(channel)
+	bot.settingsMutex.RLock()
+	for _, pack := range packs {
+		for name, command := range bot.CommandPacks[pack].Commands {
+			if command.Response != "" {
+				names[strings.ToLower(strings.TrimPrefix(name, "!"))] = true
+			}
+		}
+	}
+	bot.settingsMutex.RUnlock()
+
+	commands := []*RegisteredCommand{}
+	for name := range names {
+		if resolved, ok := bot.resolveCommand(channel, name); ok {
+			commands = append(commands, resolved)
+		}
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
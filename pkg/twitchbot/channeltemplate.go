@@ -0,0 +1,428 @@
+package twitchbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/timeparse"
+)
+
+// Imports read from chat or HTTP are cut off after this many bytes
+const maxTemplateSize = 1 << 20
+
+// ChannelTemplate is a channel's custom commands, timers and variables, to set up another
+// channel the same way. It's what !template export writes and !template import reads.
+type ChannelTemplate struct {
+	Commands  []TemplateCommand `json:"commands"`
+	Timers    []TemplateTimer   `json:"timers,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// TemplateCommand is a custom command in a ChannelTemplate
+type TemplateCommand struct {
+	Name     string `json:"name"`
+	Response string `json:"response"`
+
+	// "everyone" when empty, "subscriber", "vip", "moderator" or "broadcaster"
+	Permission string `json:"permission,omitempty"`
+}
+
+// TemplateTimer is a scheduled message in a ChannelTemplate
+type TemplateTimer struct {
+	Text   string              `json:"text"`
+	At     time.Time           `json:"at"`
+	Repeat *timeparse.Schedule `json:"repeat,omitempty"`
+}
+
+// ImportResult tells what an import did with each command, by name, and how many timers it
+// scheduled and variables it set
+type ImportResult struct {
+	Added     []string `json:"added"`
+	Replaced  []string `json:"replaced"`
+	Skipped   []string `json:"skipped"`
+	Timers    int      `json:"timers"`
+	Variables int      `json:"variables"`
+}
+
+func (result *ImportResult) String() string {
+	parts := []string{fmt.Sprintf("added %d commands", len(result.Added))}
+	if len(result.Replaced) > 0 {
+		parts = append(parts, fmt.Sprintf("replaced %d", len(result.Replaced)))
+	}
+	if len(result.Skipped) > 0 {
+		parts = append(parts, fmt.Sprintf("skipped %d (%s)", len(result.Skipped), strings.Join(result.Skipped, ", ")))
+	}
+	if result.Timers > 0 {
+		parts = append(parts, fmt.Sprintf("scheduled %d timers", result.Timers))
+	}
+	if result.Variables > 0 {
+		parts = append(parts, fmt.Sprintf("set %d variables", result.Variables))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ParseChannelTemplate reads a ChannelTemplate, or a bare list of commands as someone might
+// paste in chat: [{"name":"discord","response":"Join us at ..."}]
+func ParseChannelTemplate(data []byte) (*ChannelTemplate, error) {
+	data = bytes.TrimSpace(data)
+	template := &ChannelTemplate{}
+
+	var err error
+	if bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &template.Commands)
+	} else {
+		err = json.Unmarshal(data, template)
+	}
+	if err != nil {
+		return nil, errors.New("ParseChannelTemplate: " + err.Error())
+	}
+
+	for i, command := range template.Commands {
+		if strings.TrimLeft(command.Name, "!?~") == "" || command.Response == "" {
+			return nil, fmt.Errorf("ParseChannelTemplate: commands[%d] needs a name and a response", i)
+		}
+		if _, err := ParsePermission(command.Permission); err != nil {
+			return nil, fmt.Errorf("ParseChannelTemplate: commands[%d]: %s", i, err.Error())
+		}
+	}
+	for i, timer := range template.Timers {
+		if timer.Text == "" {
+			return nil, fmt.Errorf("ParseChannelTemplate: timers[%d] has no text", i)
+		}
+	}
+	for name, value := range template.Variables {
+		if !variableName.MatchString(strings.ToLower(name)) || value == "" {
+			return nil, fmt.Errorf("ParseChannelTemplate: variables.%s needs a name of up to 32 letters, digits, _ and - and a value", name)
+		}
+	}
+
+	return template, nil
+}
+
+// ExportChannel collects a channel's custom commands and the timers still to come
+func (bot *Bot) ExportChannel(channel string) (*ChannelTemplate, error) {
+	commands, err := bot.CustomCommands(channel)
+	if err != nil {
+		return nil, errors.New("Bot.ExportChannel: " + err.Error())
+	}
+
+	template := &ChannelTemplate{Commands: []TemplateCommand{}}
+	for _, custom := range commands {
+		exported := TemplateCommand{Name: custom.Name, Response: custom.Response}
+		if custom.Permission != Everyone {
+			exported.Permission = custom.Permission.String()
+		}
+		template.Commands = append(template.Commands, exported)
+	}
+
+	timers, err := bot.ScheduledMessages()
+	if err != nil {
+		return nil, errors.New("Bot.ExportChannel: " + err.Error())
+	}
+	for _, scheduled := range timers {
+		if scheduled.Channel == channel {
+			template.Timers = append(template.Timers, TemplateTimer{Text: scheduled.Text, At: scheduled.At, Repeat: scheduled.Repeat})
+		}
+	}
+
+	variables, err := bot.ChannelVariables(channel)
+	if err != nil {
+		return nil, errors.New("Bot.ExportChannel: " + err.Error())
+	}
+	for _, variable := range variables {
+		if template.Variables == nil {
+			template.Variables = map[string]string{}
+		}
+		template.Variables[variable.Name] = variable.Value
+	}
+
+	return template, nil
+}
+
+// ImportChannel adds a template's commands, timers and variables to a channel. Commands and
+// variables that exist there are skipped, or with replace overwritten; old commands can be
+// brought back with !restore.
+// Built-in command names are always skipped, and so are timers the channel already has or
+// that are past and don't repeat.
+func (bot *Bot) ImportChannel(channel string, template *ChannelTemplate, replace bool, importedBy string) (*ImportResult, error) {
+	result := &ImportResult{Added: []string{}, Replaced: []string{}, Skipped: []string{}}
+
+	for _, imported := range template.Commands {
+		name := strings.ToLower(strings.TrimLeft(imported.Name, "!?~"))
+		permission, _ := ParsePermission(imported.Permission)
+
+		if _, builtIn := bot.Commands.Get(name); builtIn {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+
+		custom, exists := bot.CustomCommand(channel, name)
+		if exists && !replace {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		if exists {
+			err := bot.trash(trashCommand, channel, name, importedBy, custom)
+			if err != nil {
+				return result, errors.New("Bot.ImportChannel: " + err.Error())
+			}
+			custom.Response = imported.Response
+			custom.Permission = permission
+		} else {
+			custom = &CustomCommand{Channel: channel, Name: name, Response: imported.Response, Permission: permission, CreatedBy: importedBy}
+		}
+
+		err := bot.SaveCustomCommand(custom)
+		if err != nil {
+			return result, errors.New("Bot.ImportChannel: " + err.Error())
+		}
+
+		if exists {
+			result.Replaced = append(result.Replaced, name)
+		} else {
+			result.Added = append(result.Added, name)
+		}
+	}
+
+	for name, value := range template.Variables {
+		if _, exists := bot.ChannelVariable(channel, name); exists && !replace {
+			continue
+		}
+
+		err := bot.SetChannelVariable(channel, name, value, importedBy)
+		if err != nil {
+			return result, errors.New("Bot.ImportChannel: " + err.Error())
+		}
+		result.Variables++
+	}
+
+	if len(template.Timers) == 0 {
+		return result, nil
+	}
+
+	existing, err := bot.ScheduledMessages()
+	if err != nil {
+		return result, errors.New("Bot.ImportChannel: " + err.Error())
+	}
+	scheduled := map[string]bool{}
+	for _, message := range existing {
+		if message.Channel == channel {
+			scheduled[message.Text] = true
+		}
+	}
+
+	for _, timer := range template.Timers {
+		if scheduled[timer.Text] {
+			continue
+		}
+
+		schedule := &timeparse.Schedule{At: timer.At}
+		if timer.Repeat != nil {
+			copied := *timer.Repeat
+			schedule = &copied
+		}
+		at := restoredTime(&ScheduledMessage{At: timer.At, Repeat: timer.Repeat})
+		if at.IsZero() {
+			continue
+		}
+		schedule.At = at
+
+		_, err := bot.scheduleMessage(channel, timer.Text, schedule)
+		if err != nil {
+			return result, errors.New("Bot.ImportChannel: " + err.Error())
+		}
+		scheduled[timer.Text] = true
+		result.Timers++
+	}
+
+	return result, nil
+}
+
+// Writes a channel's template with the data exports, returning the file's name
+func (bot *Bot) saveChannelTemplate(channel string) (string, *ChannelTemplate, error) {
+	template, err := bot.ExportChannel(channel)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return "", nil, errors.New("Bot.saveChannelTemplate: " + err.Error())
+	}
+
+	name := fmt.Sprintf("template-%s-%s.json", channel, time.Now().Format("2006-01-02-150405"))
+	err = bot.exportSink().Save(name, data)
+	if err != nil {
+		return "", nil, errors.New("Bot.saveChannelTemplate: " + err.Error())
+	}
+
+	return name, template, nil
+}
+
+// Whether the Bot is in a channel, so its commands can be copied from chat
+func (bot *Bot) managesChannel(channel string) bool {
+	for _, managed := range bot.channels() {
+		if managed == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// !template export | !template copy #channel [replace] | !template import [replace] <json>
+func templateCommand(bot *Bot, command *Command) {
+	usage := "Usage: !template export | copy #channel [replace] | import [replace] <json>"
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	args := command.Args[1:]
+	replace := len(args) > 0 && strings.EqualFold(args[len(args)-1], "replace")
+
+	var template *ChannelTemplate
+	switch strings.ToLower(command.Args[0]) {
+	case "export":
+		name, template, err := bot.saveChannelTemplate(command.Channel)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Exported %d commands, %d timers and %d variables to %s.", len(template.Commands), len(template.Timers), len(template.Variables), name))
+		return
+	case "copy":
+		if len(args) == 0 {
+			bot.Reply(command.Message, usage)
+			return
+		}
+		from := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(args[0], "#"), "@"))
+		if from == command.Channel || !bot.managesChannel(from) {
+			bot.Reply(command.Message, fmt.Sprintf("I can only copy from another channel I'm in, not #%s.", from))
+			return
+		}
+
+		var err error
+		template, err = bot.ExportChannel(from)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+	case "import":
+		if replace {
+			args = args[:len(args)-1]
+		}
+		if len(args) > 0 && strings.EqualFold(args[0], "replace") {
+			replace = true
+			args = args[1:]
+		}
+
+		var err error
+		template, err = ParseChannelTemplate([]byte(strings.Join(args, " ")))
+		if err != nil {
+			bot.Reply(command.Message, "That isn't a template I can read. Paste a JSON list like [{\"name\":\"discord\",\"response\":\"...\"}]")
+			return
+		}
+	default:
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	result, err := bot.ImportChannel(command.Channel, template, replace, command.Username)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	logger.Notice("@%s imported a template into #%s: %s", command.Username, command.Channel, result)
+	bot.Reply(command.Message, "Template imported: "+result.String()+".")
+}
+
+// Serves /templates?channel=name. GET exports the channel's ChannelTemplate, POST imports one
+// from a JSON body, with replace=true to overwrite commands that exist. POST needs AdminToken,
+// and GET does too when one is set.
+func (bot *Bot) serveChannelTemplate(w http.ResponseWriter, r *http.Request) {
+	if !bot.authorizedRequest(r) {
+		bot.refuseUnauthorized(w)
+		return
+	}
+
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			http.Error(w, "templates have to be sent as application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTemplateSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		template, err := ParseChannelTemplate(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := bot.ImportChannel(channel, template, r.FormValue("replace") == "true", "dashboard")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	template, err := bot.ExportChannel(channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// ExportChannelTemplate reads a channel's template from the Store a config opens, for
+// chuckbot template export
+func ExportChannelTemplate(config *Config, channel string) (*ChannelTemplate, error) {
+	bot, err := NewBot(config)
+	if err != nil {
+		return nil, errors.New("ExportChannelTemplate: " + err.Error())
+	}
+	bot.fillDefaults()
+
+	return bot.ExportChannel(strings.ToLower(channel))
+}
+
+// ImportChannelTemplate adds a template to a channel in the Store a config opens, for
+// chuckbot template import. Timers are saved and start when the bot does. Stop the bot first,
+// or the two may overwrite each other's changes.
+func ImportChannelTemplate(config *Config, channel string, template *ChannelTemplate, replace bool) (*ImportResult, error) {
+	bot, err := NewBot(config)
+	if err != nil {
+		return nil, errors.New("ImportChannelTemplate: " + err.Error())
+	}
+	bot.fillDefaults()
+	bot.registerDefaultCommands()
+
+	return bot.ImportChannel(strings.ToLower(channel), template, replace, "import")
+}
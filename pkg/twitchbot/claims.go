@@ -0,0 +1,80 @@
+package twitchbot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+const (
+	messageClaimsBucket = "message_claims"
+
+	// Twitch doesn't redeliver messages, so a claim only has to outlive the instances that saw it
+	messageClaimTTL = 10 * time.Minute
+)
+
+type messageClaim struct {
+	Instance  string    `json:"instance"`
+	ClaimedAt time.Time `json:"claimed_at"`
+}
+
+type messageClaims struct {
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+// Reports whether this instance gets to answer a message. When the Store is shared with other
+// instances and implements store.Claimer, the first instance to claim the message ID answers it
+// and the rest stay quiet. Otherwise every message is this instance's to answer.
+func (bot *Bot) claimMessage(message *Message) bool {
+	claimer, ok := bot.Store.(store.Claimer)
+	if !ok || message.MessageID == "" {
+		return true
+	}
+
+	bot.sweepMessageClaims()
+
+	claimed, err := claimer.PutIfAbsent(messageClaimsBucket, message.MessageID, messageClaim{Instance: bot.InstanceID, ClaimedAt: time.Now()})
+	if err != nil {
+		// Answering twice beats not answering at all
+		logger.Warn("Bot.claimMessage: %s", err.Error())
+		return true
+	}
+
+	if !claimed {
+		logger.Quiet("Another instance is answering @%s in #%s", message.Username, message.Channel)
+	}
+
+	return claimed
+}
+
+// Forgets expired claims, at most once per TTL
+func (bot *Bot) sweepMessageClaims() {
+	bot.claims.mutex.Lock()
+	if time.Since(bot.claims.lastSweep) < messageClaimTTL {
+		bot.claims.mutex.Unlock()
+		return
+	}
+	bot.claims.lastSweep = time.Now()
+	bot.claims.mutex.Unlock()
+
+	keys, err := bot.Store.Keys(messageClaimsBucket)
+	if err != nil {
+		logger.Warn("Bot.sweepMessageClaims: %s", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		claim := messageClaim{}
+		found, err := bot.Store.Get(messageClaimsBucket, key, &claim)
+		if err != nil || !found || time.Since(claim.ClaimedAt) < messageClaimTTL {
+			continue
+		}
+
+		err = bot.Store.Delete(messageClaimsBucket, key)
+		if err != nil {
+			logger.Warn("Bot.sweepMessageClaims: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package twitchbot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// Bots that share a Store, like instances of one bot would
+func newClaimingBots(t *testing.T, shared store.Store, count int) []*Bot {
+	bots := make([]*Bot, 0, count)
+	for i := 0; i < count; i++ {
+		bot, err := NewBot(&Config{BotName: "chuckbot", Channel: "mikkeever", Server: "irc.chat.twitch.tv", Port: "6697", Token: "oauth:test", SkipTokenValidation: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bot.Store = shared
+		bot.InstanceID = fmt.Sprintf("instance-%d", i)
+		bot.fillDefaults()
+		bots = append(bots, bot)
+	}
+
+	return bots
+}
+
+// Every Bot claims every message at once. Each message must be claimed exactly once.
+func hammerClaims(t *testing.T, bots []*Bot, messages int) {
+	claims := make([]int, messages)
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+
+	for _, bot := range bots {
+		for i := 0; i < messages; i++ {
+			wait.Add(1)
+			go func(bot *Bot, i int) {
+				defer wait.Done()
+
+				message := &Message{Type: "PRIVMSG", Channel: "mikkeever", Username: "viewer", MessageID: fmt.Sprintf("message-%d", i)}
+				if bot.claimMessage(message) {
+					mutex.Lock()
+					claims[i]++
+					mutex.Unlock()
+				}
+			}(bot, i)
+		}
+	}
+	wait.Wait()
+
+	for i, count := range claims {
+		if count != 1 {
+			t.Errorf("message-%d was claimed %d times, want 1", i, count)
+		}
+	}
+}
+
+func TestClaimMessageMemory(t *testing.T) {
+	hammerClaims(t, newClaimingBots(t, store.NewMemory(), 5), 200)
+}
+
+func TestClaimMessageFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chuckbot-claims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file, err := store.OpenFile(filepath.Join(dir, "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hammerClaims(t, newClaimingBots(t, file, 5), 100)
+}
+
+func TestClaimMessageWithoutID(t *testing.T) {
+	for _, bot := range newClaimingBots(t, store.NewMemory(), 2) {
+		if !bot.claimMessage(&Message{Type: "PRIVMSG", Channel: "mikkeever", Username: "viewer"}) {
+			t.Error("a message without an ID should always be answered")
+		}
+	}
+}
+
+func TestWhisperMessageID(t *testing.T) {
+	ircMessage, err := irc.Parse("@display-name=Viewer;message-id=42;thread-id=1_2;user-id=2 :viewer!viewer@viewer.tmi.twitch.tv WHISPER chuckbot :hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := newMessage(ircMessage)
+	if message.MessageID != "42" {
+		t.Fatalf("MessageID = %q, want 42", message.MessageID)
+	}
+
+	bots := newClaimingBots(t, store.NewMemory(), 2)
+	if !bots[0].claimMessage(message) || bots[1].claimMessage(message) {
+		t.Error("only the first instance should claim a whisper")
+	}
+}
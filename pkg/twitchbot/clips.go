@@ -0,0 +1,142 @@
+package twitchbot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Matches clips.twitch.tv/<slug> and twitch.tv/<channel>/clip/<slug> links
+// 1: (slug)
+var clipRegex *regexp.Regexp = regexp.MustCompile(`(?i)(?:https?://)?(?:clips\.twitch\.tv/|(?:www\.|m\.)?twitch\.tv/\w+/clip/)([\w-]+)`)
+
+// Clip is a clip link shared during the current session
+type Clip struct {
+	URL      string    `json:"url"`
+	Slug     string    `json:"slug"`
+	SharedBy string    `json:"shared_by"`
+	SharedAt time.Time `json:"shared_at"`
+}
+
+type clipTracker struct {
+	mutex    sync.Mutex
+	sessions map[string][]Clip
+}
+
+// Records any clip links in a chat message, once per clip per session
+func (bot *Bot) trackClips(message *Message) {
+	matches := clipRegex.FindAllStringSubmatch(message.Text, -1)
+	if matches == nil {
+		return
+	}
+
+	tracker := &bot.clips
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if tracker.sessions == nil {
+		tracker.sessions = map[string][]Clip{}
+	}
+
+	for _, match := range matches {
+		slug := match[1]
+		known := false
+		for _, clip := range tracker.sessions[message.Channel] {
+			if clip.Slug == slug {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+
+		tracker.sessions[message.Channel] = append(tracker.sessions[message.Channel], Clip{
+			URL:      "https://clips.twitch.tv/" + slug,
+			Slug:     slug,
+			SharedBy: message.Username,
+			SharedAt: time.Now(),
+		})
+		logger.Notice("Clip shared by @%s in #%s: %s", message.Username, message.Channel, slug)
+	}
+}
+
+// SessionClips lists the clips shared in a channel this session, oldest first
+func (bot *Bot) SessionClips(channel string) []Clip {
+	bot.clips.mutex.Lock()
+	defer bot.clips.mutex.Unlock()
+
+	return append([]Clip{}, bot.clips.sessions[channel]...)
+}
+
+// ExportSessionClips writes the session's clips for a channel to a text file in ClipsExportDir and starts a
+// new session. It returns the file's path.
+func (bot *Bot) ExportSessionClips(channel string) (string, error) {
+	clips := bot.SessionClips(channel)
+	if len(clips) == 0 {
+		return "", fmt.Errorf("Bot.ExportSessionClips: no clips in #%s", channel)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Clips from #%s\n\n", channel)
+	for _, clip := range clips {
+		fmt.Fprintf(&builder, "%s  %s  shared by %s\n", clip.SharedAt.Format(time.RFC3339), clip.URL, clip.SharedBy)
+	}
+
+	dir := bot.ClipsExportDir
+	if dir == "" {
+		dir = "."
+	}
+	path := filepath.Join(dir, fmt.Sprintf("clips-%s-%s.txt", channel, time.Now().Format("2006-01-02-150405")))
+
+	err := ioutil.WriteFile(path, []byte(builder.String()), 0644)
+	if err != nil {
+		return "", fmt.Errorf("Bot.ExportSessionClips: %s", err.Error())
+	}
+
+	bot.clips.mutex.Lock()
+	delete(bot.clips.sessions, channel)
+	bot.clips.mutex.Unlock()
+
+	logger.Success("Exported %d clip(s) from #%s to %s", len(clips), channel, path)
+	return path, nil
+}
+
+// !clips shows the latest clips, !clips export saves the session's clips (mods only)
+func clipsCommand(bot *Bot, command *Command) {
+	if len(command.Args) > 0 && strings.EqualFold(command.Args[0], "export") {
+		if !command.Can(Moderator) {
+			return
+		}
+
+		path, err := bot.ExportSessionClips(command.Channel)
+		if err != nil {
+			bot.Reply(command.Message, "No clips to export.")
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Exported this session's clips to %s.", filepath.Base(path)))
+		return
+	}
+
+	clips := bot.SessionClips(command.Channel)
+	if len(clips) == 0 {
+		bot.Reply(command.Message, "No clips shared yet this stream.")
+		return
+	}
+
+	if len(clips) > 3 {
+		clips = clips[len(clips)-3:]
+	}
+
+	urls := make([]string, 0, len(clips))
+	for i := len(clips) - 1; i >= 0; i-- {
+		urls = append(urls, clips[i].URL)
+	}
+
+	bot.Reply(command.Message, "Latest clips: "+strings.Join(urls, " "))
+}
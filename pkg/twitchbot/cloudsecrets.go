@@ -0,0 +1,150 @@
+package twitchbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/cloudsecrets"
+)
+
+// How long a call to the secret manager may take
+const cloudSecretsTimeout = 15 * time.Second
+
+// CloudSecretsConfig says where the "vault", "aws" and "gcp" secrets providers keep the secrets.
+// Their credentials come from the environment, the way each platform's own tools find them.
+type CloudSecretsConfig struct {
+	// The secret's path in Vault, its name or ARN in AWS, or its name in GCP
+	Name string `json:"name"`
+
+	// Default to VAULT_ADDR and "secret"
+	VaultAddress string `json:"vault_address"`
+	VaultMount   string `json:"vault_mount"`
+
+	// Defaults to AWS_REGION
+	AWSRegion string `json:"aws_region"`
+
+	GCPProject string `json:"gcp_project"`
+}
+
+// Builds the backend for a provider name
+func (config *CloudSecretsConfig) backend(provider string) (cloudsecrets.Backend, error) {
+	if config == nil || config.Name == "" {
+		return nil, fmt.Errorf("the %s provider needs cloud_secrets.name", provider)
+	}
+
+	switch provider {
+	case "vault":
+		return &cloudsecrets.Vault{Address: config.VaultAddress, Mount: config.VaultMount, Path: config.Name}, nil
+	case "aws":
+		return &cloudsecrets.AWSSecretsManager{SecretID: config.Name, Region: config.AWSRegion}, nil
+	case "gcp":
+		if config.GCPProject == "" {
+			return nil, errors.New("the gcp provider needs cloud_secrets.gcp_project")
+		}
+		return &cloudsecrets.GCPSecretManager{Project: config.GCPProject, Secret: config.Name}, nil
+	}
+
+	return nil, fmt.Errorf("unknown cloud secrets provider %q", provider)
+}
+
+// CloudSecrets keeps the Secrets as one JSON secret, with the secrets file's keys, in HashiCorp
+// Vault, AWS Secrets Manager or GCP Secret Manager. It is read at startup, on reloads and before
+// each token refresh, and refreshed tokens are written back.
+type CloudSecrets struct {
+	Backend cloudsecrets.Backend
+
+	// Serializes SaveToken's read, change and write
+	mutex sync.Mutex
+}
+
+// Secrets reads the secret
+func (cloud *CloudSecrets) Secrets() (*Secrets, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cloudSecretsTimeout)
+	defer cancel()
+
+	data, err := cloud.Backend.Get(ctx)
+	if err != nil {
+		return nil, errors.New("CloudSecrets: " + err.Error())
+	}
+
+	stored := &Secrets{}
+	err = json.Unmarshal(data, stored)
+	if err != nil {
+		return nil, errors.New("CloudSecrets: " + err.Error())
+	}
+
+	return stored, nil
+}
+
+// SaveToken writes the tokens into the secret, keeping its other keys
+func (cloud *CloudSecrets) SaveToken(accessToken, refreshToken string) error {
+	return cloud.save(map[string]string{"token": accessToken, "refresh_token": refreshToken})
+}
+
+// Writes keys into the secret, creating it in Vault if there is none
+func (cloud *CloudSecrets) save(secrets map[string]string) error {
+	cloud.mutex.Lock()
+	defer cloud.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cloudSecretsTimeout)
+	defer cancel()
+
+	// Keep keys the Secrets struct doesn't know about
+	values := map[string]interface{}{}
+	data, err := cloud.Backend.Get(ctx)
+	if err != nil && !cloudsecrets.NotFound(err) {
+		return errors.New("CloudSecrets: " + err.Error())
+	}
+	if err == nil && len(data) > 0 {
+		err = json.Unmarshal(data, &values)
+		if err != nil {
+			return errors.New("CloudSecrets: " + err.Error())
+		}
+	}
+
+	for key, value := range secrets {
+		values[key] = value
+	}
+
+	data, err = json.Marshal(values)
+	if err != nil {
+		return errors.New("CloudSecrets: " + err.Error())
+	}
+
+	err = cloud.Backend.Put(ctx, data)
+	if err != nil {
+		return errors.New("CloudSecrets: " + err.Error())
+	}
+
+	return nil
+}
+
+// Picks up a refresh token another instance of the bot saved to the secret manager, since Twitch
+// may invalidate the one this Bot has once it was used
+func (bot *Bot) latestRefreshToken() {
+	cloud, ok := bot.secretsProvider().(*CloudSecrets)
+	if !ok {
+		return
+	}
+
+	stored, err := cloud.Secrets()
+	if err != nil {
+		logger.Warn("Bot.latestRefreshToken: %s", err.Error())
+		return
+	}
+	if stored.RefreshToken == "" {
+		return
+	}
+
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	if stored.RefreshToken != bot.refreshToken {
+		logger.Info("Using the refresh token saved in the secret manager")
+		bot.refreshToken = stored.RefreshToken
+	}
+}
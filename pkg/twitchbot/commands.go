@@ -0,0 +1,375 @@
+package twitchbot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/eventsub"
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+// Message is a chat message, whisper or EventSub event received from Twitch
+type Message struct {
+	// PRIVMSG for chat messages, WHISPER for whispers or EVENTSUB for EventSub notifications
+	Type string
+
+	// The sender's login name
+	Username string
+
+	// The sender's name as shown in chat, which may differ from Username in capitalization or script
+	DisplayName string
+
+	UserID string
+
+	// Unique ID of this message, used for replies and moderation
+	MessageID string
+
+	// The sender's chat color as "#RRGGBB", or empty if they never set one
+	Color string
+
+	// Badge name to version, e.g. "subscriber" -> "12"
+	Badges map[string]string
+
+	Emotes []Emote
+
+	Mod bool
+
+	Subscriber bool
+
+	// All IRCv3 tags sent with the message
+	Tags map[string]string
+
+	// The channel the message was sent to, without the leading "#". Empty for whispers.
+	Channel string
+
+	Text string
+
+	// The line as it was received from Twitch
+	Raw string
+
+	// The fully parsed line
+	IRC *irc.Message
+
+	// For EVENTSUB messages, the notification. The user is who followed, subscribed, raided or
+	// redeemed, and Text is what they typed for a redemption.
+	Event *eventsub.Notification
+}
+
+// Command is a single use of a command in chat
+type Command struct {
+	*Message
+
+	// The prefix the command was called with, such as "!"
+	Prefix string
+
+	// The command name without its prefix
+	Name string
+
+	// The words following the command
+	Args []string
+}
+
+// CommandHandler responds to a command
+type CommandHandler func(bot *Bot, command *Command)
+
+// RegisteredCommand is a command and the rules for using it
+type RegisteredCommand struct {
+	Name string
+
+	Handler CommandHandler
+
+	// Who may run the command. Defaults to Everyone.
+	Permission Permission
+
+	// How long the command is unavailable in a channel after anyone uses it
+	GlobalCooldown time.Duration
+
+	// How long the command is unavailable to a user after they use it
+	UserCooldown time.Duration
+
+	// A short explanation shown by !help
+	Description string
+}
+
+// CommandOption configures a command as it is registered
+type CommandOption func(command *RegisteredCommand)
+
+// WithPermission restricts a command to users with at least the given permission
+func WithPermission(permission Permission) CommandOption {
+	return func(command *RegisteredCommand) {
+		command.Permission = permission
+	}
+}
+
+// CommandRegistry maps command names to their handlers. The zero value is ready to use.
+type CommandRegistry struct {
+	mutex    sync.RWMutex
+	commands map[string]*RegisteredCommand
+}
+
+// Register adds a command, replacing any existing command with the same name
+func (registry *CommandRegistry) Register(name string, handler CommandHandler, options ...CommandOption) {
+	command := &RegisteredCommand{Name: strings.ToLower(name), Handler: handler}
+	for _, option := range options {
+		option(command)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.commands == nil {
+		registry.commands = map[string]*RegisteredCommand{}
+	}
+
+	registry.commands[command.Name] = command
+}
+
+// Unregister removes a command
+func (registry *CommandRegistry) Unregister(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.commands, strings.ToLower(name))
+}
+
+// Lookup finds the handler for a command
+func (registry *CommandRegistry) Lookup(name string) (CommandHandler, bool) {
+	command, ok := registry.Get(name)
+	if !ok {
+		return nil, false
+	}
+
+	return command.Handler, true
+}
+
+// Applies options to an already registered command. The command is copied so handlers
+// already holding it are unaffected.
+func (registry *CommandRegistry) update(name string, options ...CommandOption) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	existing, ok := registry.commands[strings.ToLower(name)]
+	if !ok {
+		return false
+	}
+
+	updated := *existing
+	for _, option := range options {
+		option(&updated)
+	}
+	registry.commands[updated.Name] = &updated
+
+	return true
+}
+
+// Get finds a command along with the rules for using it
+func (registry *CommandRegistry) Get(name string) (*RegisteredCommand, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	command, ok := registry.commands[strings.ToLower(name)]
+	return command, ok
+}
+
+// RegisterCommand adds a command that users can call in chat with "!name"
+func (bot *Bot) RegisterCommand(name string, handler CommandHandler, options ...CommandOption) {
+	bot.Commands.Register(strings.TrimPrefix(name, "!"), handler, options...)
+}
+
+// Reply responds to a message in the place it came from, or as a whisper if the user prefers that
+func (bot *Bot) Reply(message *Message, text string) {
+	if message.Type == "WHISPER" || (!bot.whispersDisabled() && bot.UserPreferences(message).WhisperReplies) {
+		bot.whisper(message.Username, text)
+		return
+	}
+
+	bot.chatTo(message.Channel, text)
+}
+
+type defaultCommand struct {
+	name    string
+	handler CommandHandler
+	options []CommandOption
+}
+
+// Registers the commands the Bot ships with, unless the user already registered their own
+func (bot *Bot) registerDefaultCommands() {
+	defaults := []defaultCommand{
+		{name: "chucknorris", handler: chuckNorrisCommand, options: []CommandOption{
+			WithDescription("Get a random Chuck Norris fact"),
+			WithCooldown(5*time.Second, 30*time.Second),
+		}},
+		{name: "help", handler: helpCommand, options: []CommandOption{
+			WithDescription("List the commands you can use, or describe one: !help <command>"),
+			WithCooldown(10*time.Second, 30*time.Second),
+		}},
+		{name: "commands", handler: helpCommand, options: []CommandOption{
+			WithDescription("List the commands you can use"),
+			WithCooldown(10*time.Second, 30*time.Second),
+		}},
+		{name: "raffle", handler: raffleCommand, options: []CommandOption{
+			WithDescription("Run a raffle: !raffle open | !raffle draw"),
+			WithPermission(Moderator),
+		}},
+		{name: "join", handler: joinRaffleCommand, options: []CommandOption{
+			WithDescription("Enter the open raffle"),
+		}},
+		{name: "rafflereceipt", handler: raffleReceiptCommand, options: []CommandOption{
+			WithDescription("Show the audit receipt for a raffle draw: !rafflereceipt <id>"),
+		}},
+		{name: "remindme", handler: remindMeCommand, options: []CommandOption{
+			WithDescription("Set a reminder: !remindme in 20 minutes stretch"),
+			WithCooldown(0, 10*time.Second),
+		}},
+		{name: "pref", handler: preferencesCommand, options: []CommandOption{
+			WithDescription("Set your timezone, language, reply style or pronouns: !pref timezone Europe/Berlin"),
+			WithCooldown(0, 5*time.Second),
+		}},
+		{name: "highlights", handler: highlightsCommand, options: []CommandOption{
+			WithDescription("Show highlighted messages waiting for approval"),
+			WithPermission(Moderator),
+		}},
+		{name: "approve", handler: highlightDecisionCommand(true), options: []CommandOption{
+			WithDescription("Show a highlighted message on the overlay: !approve [id]"),
+			WithPermission(Moderator),
+		}},
+		{name: "dismiss", handler: highlightDecisionCommand(false), options: []CommandOption{
+			WithDescription("Drop a highlighted message: !dismiss [id]"),
+			WithPermission(Moderator),
+		}},
+		{name: "ask", handler: askCommand, options: []CommandOption{
+			WithDescription("Ask a question for the Q&A: !ask <question>"),
+			WithCooldown(0, 10*time.Second),
+		}},
+		{name: "nextq", handler: nextQuestionCommand, options: []CommandOption{
+			WithDescription("Show the next question from the Q&A queue"),
+			WithPermission(Moderator),
+		}},
+		{name: "removeq", handler: removeQuestionsCommand, options: []CommandOption{
+			WithDescription("Remove a user's questions from the Q&A queue: !removeq <user>"),
+			WithPermission(Moderator),
+		}},
+		{name: "clips", handler: clipsCommand, options: []CommandOption{
+			WithDescription("Show the latest clips shared this stream"),
+			WithCooldown(15*time.Second, 0),
+		}},
+		{name: "addcom", handler: addCommandCommand, options: []CommandOption{
+			WithDescription("Add a custom command: !addcom [-ul=moderator] !name <response>"),
+			WithPermission(Moderator),
+		}},
+		{name: "editcom", handler: editCommandCommand, options: []CommandOption{
+			WithDescription("Change a custom command: !editcom [-ul=moderator] !name <response>"),
+			WithPermission(Moderator),
+		}},
+		{name: "delcom", handler: deleteCommandCommand, options: []CommandOption{
+			WithDescription("Delete a custom command: !delcom !name"),
+			WithPermission(Moderator),
+		}},
+		{name: "var", handler: varCommand, options: []CommandOption{
+			WithDescription("Keep values like links for responses to use as {var.name}: !var set <name> <value> | del <name> | list | <name>"),
+			WithPermission(Moderator),
+		}},
+		{name: "template", handler: templateCommand, options: []CommandOption{
+			WithDescription("Export this channel's commands and timers, or bring them in from elsewhere: !template export | copy #channel [replace] | import [replace] <json>"),
+			WithPermission(Moderator),
+		}},
+		{name: "restore", handler: restoreCommand, options: []CommandOption{
+			WithDescription("Bring back a deleted command, quote or timer: !restore [!name | quote <number> | timer <id>]"),
+			WithPermission(Moderator),
+		}},
+		{name: "bookmark", handler: bookmarkCommand, options: []CommandOption{
+			WithDescription("Bookmark this moment for the VOD: !bookmark <label> | start | export"),
+			WithPermission(Moderator),
+		}},
+		{name: "counter", handler: counterCommand, options: []CommandOption{
+			WithDescription("Add or remove a counter like !deaths: !counter add <name> | remove <name>"),
+			WithPermission(Moderator),
+		}},
+		{name: "quote", handler: quoteCommand, options: []CommandOption{
+			WithDescription("Show a random quote, or one by number: !quote [number]"),
+		}},
+		{name: "addquote", handler: addQuoteCommand, options: []CommandOption{
+			WithDescription("Save a quote: !addquote [@user] <text>"),
+			WithPermission(Moderator),
+		}},
+		{name: "delquote", handler: deleteQuoteCommand, options: []CommandOption{
+			WithDescription("Delete a quote: !delquote <number>"),
+			WithPermission(Moderator),
+		}},
+		{name: "game", handler: gameCommand, options: []CommandOption{
+			WithDescription("Show the game, or set it as a moderator: !game [name]"),
+		}},
+		{name: "uptime", handler: uptimeCommand, options: []CommandOption{
+			WithDescription("Show how long the stream has been live"),
+			WithCooldown(10*time.Second, 0),
+		}},
+		{name: "stats", handler: statsCommand, options: []CommandOption{
+			WithDescription("Compare chat over the last few days: !stats [days]"),
+			WithPermission(Moderator),
+		}},
+		{name: "topchatters", handler: topChattersCommand, options: []CommandOption{
+			WithDescription("Show who chatted most this stream or of all time: !topchatters [all] [size]"),
+			WithCooldown(30*time.Second, 0),
+		}},
+		{name: "topcheerers", handler: topCheerersCommand, options: []CommandOption{
+			WithDescription("Show who cheered the most bits: !topcheerers [size]"),
+			WithCooldown(30*time.Second, 0),
+		}},
+		{name: "points", handler: pointsCommand, options: []CommandOption{
+			WithDescription("Show your points, or someone else's: !points [@user]"),
+			WithCooldown(0, 10*time.Second),
+		}},
+		{name: "daily", handler: dailyCommand, options: []CommandOption{
+			WithDescription("Claim your points for today. Come back every day for a streak bonus!"),
+		}},
+		{name: "duel", handler: duelCommand, options: []CommandOption{
+			WithDescription("Bet points on a duel with another viewer: !duel @user <points> | !duel record @user"),
+			WithCooldown(0, 30*time.Second),
+		}},
+		{name: "accept", handler: acceptDuelCommand, options: []CommandOption{
+			WithDescription("Accept the duel you were challenged to"),
+		}},
+		{name: "decline", handler: declineDuelCommand, options: []CommandOption{
+			WithDescription("Turn down the duel you were challenged to"),
+		}},
+		{name: "so", handler: shoutoutCommand, options: []CommandOption{
+			WithDescription("Give another streamer a shoutout: !so @user"),
+			WithPermission(Moderator),
+		}},
+		{name: "userinfo", handler: userInfoCommand, options: []CommandOption{
+			WithDescription("Sum up what the bot knows about a user: !userinfo @user"),
+			WithPermission(Moderator),
+		}},
+		{name: "appeals", handler: appealsCommand, options: []CommandOption{
+			WithDescription("List open appeals, or close one: !appeals [close @user]"),
+			WithPermission(Moderator),
+		}},
+		{name: "boss", handler: bossCommand, options: []CommandOption{
+			WithDescription("Show the boss's health, or summon one as a moderator: !boss [start <hp> <name> | stop]"),
+			WithCooldown(10*time.Second, 0),
+		}},
+		{name: "names", handler: namesCommand, options: []CommandOption{
+			WithDescription("Show a user's previous names: !names <user>"),
+			WithPermission(Moderator),
+		}},
+		{name: "cooldown", handler: cooldownCommand, options: []CommandOption{
+			WithDescription("Change a command's cooldowns: !cooldown <command> <channel> [user]"),
+			WithPermission(Moderator),
+		}},
+	}
+
+	for _, command := range defaults {
+		if _, ok := bot.Commands.Get(command.name); !ok {
+			bot.RegisterCommand(command.name, command.handler, command.options...)
+		}
+	}
+}
+
+// Send a Chuck Norris fact to whoever asked
+func chuckNorrisCommand(bot *Bot, command *Command) {
+	fact := bot.fetchChuckFact()
+	logger.Success("< Chuck Fact for #%s: %s", command.Username, fact)
+
+	bot.Reply(command.Message, bot.RenderTemplate(bot.channelResponse(command.Channel, func(settings ChannelSettings) string { return settings.ChuckNorrisResponse }, &bot.ChuckNorrisResponse), command, map[string]string{"fact": fact}))
+}
@@ -0,0 +1,640 @@
+package twitchbot
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// Duration is a time.Duration written as "30s" or "1h" in config files
+type Duration time.Duration
+
+// UnmarshalJSON reads a duration string like "90s"
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return fmt.Errorf("expected a duration like \"30s\", got %s", string(data))
+	}
+
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+
+	*duration = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string like "1m30s"
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+// Config holds the settings a Bot can be built from, as read from a JSON config file
+type Config struct {
+	BotName             string   `json:"bot_name"`
+	Anonymous           bool     `json:"anonymous"`
+	Channel             string   `json:"channel"`
+	Channels            []string `json:"channels"`
+	Server              string   `json:"server"`
+	Port                string   `json:"port"`
+	TLSCAFile           string   `json:"tls_ca_file"`
+	Proxy               string   `json:"proxy"`
+	SecretsPath         string   `json:"secrets_path"`
+	SecretsProvider     string   `json:"secrets_provider"`
+	KeychainService     string   `json:"keychain_service"`
+	SecretsPassphrase   string   `json:"secrets_passphrase"`
+	SecretsKeyFile      string   `json:"secrets_key_file"`
+	Token               string   `json:"token"`
+	WatchSecrets        bool     `json:"watch_secrets"`
+	AdminToken          string   `json:"admin_token"`
+	SkipTokenValidation bool     `json:"skip_token_validation"`
+	WhisperAutoResponse string   `json:"whisper_auto_response"`
+	ChuckNorrisResponse string   `json:"chucknorris_response"`
+	ShoutoutResponse    string   `json:"shoutout_response"`
+	WhispersDisabled    bool     `json:"whispers_disabled"`
+	EmoteOnlyResponse   string   `json:"emote_only_response"`
+
+	FirstChatterGreeting   string   `json:"first_chatter_greeting"`
+	FollowGreeting         string   `json:"follow_greeting"`
+	FollowGreetingWindow   Duration `json:"follow_greeting_window"`
+	GreetingFloodThreshold int      `json:"greeting_flood_threshold"`
+	RaidQuietPeriod        Duration `json:"raid_quiet_period"`
+	RaidShoutout           string   `json:"raid_shoutout"`
+	RaidHelixShoutout      bool     `json:"raid_helix_shoutout"`
+
+	SubThanks      string `json:"sub_thanks"`
+	ResubThanks    string `json:"resub_thanks"`
+	GiftThanks     string `json:"gift_thanks"`
+	GiftBombThanks string `json:"gift_bomb_thanks"`
+	BatchGiftBombs bool   `json:"batch_gift_bombs"`
+
+	CheerResponses []CheerResponse `json:"cheer_responses"`
+
+	TrashRetention Duration `json:"trash_retention"`
+
+	GoLiveAnnouncement  string   `json:"go_live_announcement"`
+	OfflineAnnouncement string   `json:"offline_announcement"`
+	StreamPollInterval  Duration `json:"stream_poll_interval"`
+	TimersOnlyWhenLive  bool     `json:"timers_only_when_live"`
+
+	CommandPacks         map[string]CommandPack `json:"command_packs"`
+	CategoryPollInterval Duration               `json:"category_poll_interval"`
+	GameCounters         []string               `json:"game_counters"`
+	CategoryAnnouncement string                 `json:"category_announcement"`
+	TitleAnnouncement    string                 `json:"title_announcement"`
+
+	// Path of a JSON file to keep data in. Data is kept in memory when empty.
+	StorePath string `json:"store_path"`
+
+	HealthAddress      string `json:"health_address"`
+	ChannelWorkers     int    `json:"channel_workers"`
+	ChannelQueueLength int    `json:"channel_queue_length"`
+
+	LeaderLockPath      string   `json:"leader_lock_path"`
+	InstanceID          string   `json:"instance_id"`
+	LeaderLeaseDuration Duration `json:"leader_lease_duration"`
+
+	IgnoredUsers    []string `json:"ignored_users"`
+	IgnoredCommands []string `json:"ignored_commands"`
+	BannedPhrases   []string `json:"banned_phrases"`
+	CleanChat       bool     `json:"clean_chat"`
+	SpamStrikes     int      `json:"spam_strikes"`
+	SpamTimeout     Duration `json:"spam_timeout"`
+
+	SpamWaveThreshold int `json:"spam_wave_threshold"`
+
+	ModerationReasons map[string]string `json:"moderation_reasons"`
+	ModerationWhisper string            `json:"moderation_whisper"`
+	AppealKeyword     string            `json:"appeal_keyword"`
+	AppealWebhook     string            `json:"appeal_webhook"`
+
+	PriorityWeights map[string]int `json:"priority_weights"`
+
+	Timezone            string   `json:"timezone"`
+	HighlightRewardIDs  []string `json:"highlight_reward_ids"`
+	MaxQuestionsPerUser int      `json:"max_questions_per_user"`
+	ClipsExportDir      string   `json:"clips_export_dir"`
+	DataExportInterval  Duration `json:"data_export_interval"`
+	DataExportDir       string   `json:"data_export_dir"`
+	DataExportBackups   bool     `json:"data_export_backups"`
+	ShowPronouns        bool     `json:"show_pronouns"`
+	PronounsCacheTTL    Duration `json:"pronouns_cache_ttl"`
+
+	CommandPrefixes        []string                     `json:"command_prefixes"`
+	ChannelCommandPrefixes map[string][]string          `json:"channel_command_prefixes"`
+	ChannelLanguages       map[string]string            `json:"channel_languages"`
+	CommandAliases         map[string]string            `json:"command_aliases"`
+	ChannelCommandAliases  map[string]map[string]string `json:"channel_command_aliases"`
+
+	MaxReconnectWait     Duration `json:"max_reconnect_wait"`
+	ReconnectJitter      float64  `json:"reconnect_jitter"`
+	MaxReconnectAttempts int      `json:"max_reconnect_attempts"`
+	KeepaliveInterval    Duration `json:"keepalive_interval"`
+	KeepaliveTimeout     Duration `json:"keepalive_timeout"`
+
+	// Twitch application for API calls, such as looking up users seen in chat
+	ClientID             string   `json:"client_id"`
+	UserBackfillInterval Duration `json:"user_backfill_interval"`
+
+	// Events to receive over EventSub: follows, subs, raids, redemptions, streams and updates
+	EventSubEvents []string `json:"eventsub_events"`
+
+	// What to do for channel point rewards, by reward ID or title
+	RedemptionActions map[string]RedemptionAction `json:"redemption_actions"`
+
+	// Save exports and backups to object storage instead of data_export_dir
+	S3 *S3Config `json:"s3"`
+
+	// Where the vault, aws and gcp secrets providers find the secrets
+	CloudSecrets *CloudSecretsConfig `json:"cloud_secrets"`
+
+	BossBattle  BossBattleSettings  `json:"boss_battle"`
+	DailyReward DailyRewardSettings `json:"daily_reward"`
+
+	// Text commands to add and changes to existing commands, keyed by command name
+	Commands map[string]CommandConfig `json:"commands"`
+
+	// Overrides for single channels, keyed by channel name
+	ChannelSettings map[string]ChannelSettings `json:"channel_settings"`
+
+	// Colors, separators and bells for events in the console, keyed by event
+	ConsoleCues map[string]printpretty.Cue `json:"console_cues"`
+
+	ConsoleGroupWindow Duration `json:"console_group_window"`
+	ConsoleTimestamps  string   `json:"console_timestamps"`
+	ConsoleTimezone    string   `json:"console_timezone"`
+
+	// Lowest level shown for each module, e.g. {"helix": "debug", "default": "info"}
+	LogLevels map[string]string `json:"log_levels"`
+
+	// Shell commands and webhooks to run at connected, joined, auth_failed and shutting_down
+	Hooks map[string][]Hook `json:"hooks"`
+}
+
+// DefaultConfig returns the settings used for anything a config file leaves out
+func DefaultConfig() Config {
+	return Config{
+		Server:      "irc.chat.twitch.tv",
+		Port:        "6697",
+		SecretsPath: "./secrets.json",
+	}
+}
+
+// LoadConfig reads a JSON config file on top of DefaultConfig and validates it
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	err := LoadConfigFile(path, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %s: %s", path, err.Error())
+	}
+
+	return &config, nil
+}
+
+// LoadConfigFile reads a JSON config file over the settings already in config.
+// Unknown fields are errors so typos don't go unnoticed.
+func LoadConfigFile(path string, config *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New("LoadConfigFile: " + err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(config)
+	if err != nil {
+		return fmt.Errorf("LoadConfigFile: %s: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+// ApplyEnvironment overrides settings from CHUCKBOT_ environment variables named after the
+// config fields, e.g. CHUCKBOT_CHANNEL or CHUCKBOT_TOKEN. Lists are comma separated. Map
+// settings can only be set in the config file.
+func (config *Config) ApplyEnvironment() error {
+	value := reflect.ValueOf(config).Elem()
+	configType := value.Type()
+
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("json"), ",")[0]
+		variable := "CHUCKBOT_" + strings.ToUpper(name)
+
+		text, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+
+		err := setConfigField(value.Field(i), text)
+		if err != nil {
+			return fmt.Errorf("Config.ApplyEnvironment: %s: %s", variable, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Sets a config field from its text form
+func setConfigField(field reflect.Value, text string) error {
+	switch field.Interface().(type) {
+	case Duration:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(Duration(duration)))
+	case string:
+		field.SetString(text)
+	case bool:
+		on, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(on)
+	case float64:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case int:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(number))
+	case []string:
+		items := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return errors.New("can only be set in the config file")
+	}
+
+	return nil
+}
+
+// Validate checks the settings and names the first field that is wrong
+func (config *Config) Validate() error {
+	required := []struct {
+		field string
+		value string
+	}{
+		{"server", config.Server},
+		{"port", config.Port},
+	}
+
+	// An anonymous connection picks its own name and needs no token
+	if !config.Anonymous && strings.TrimSpace(config.BotName) == "" {
+		return errors.New("bot_name is required")
+	}
+
+	for _, setting := range required {
+		if strings.TrimSpace(setting.value) == "" {
+			return fmt.Errorf("%s is required", setting.field)
+		}
+	}
+
+	if _, err := NewSecretsProvider(config); err != nil {
+		return errors.New("secrets_provider: " + strings.TrimPrefix(err.Error(), "NewSecretsProvider: "))
+	}
+
+	if config.SecretsPassphrase != "" && config.SecretsKeyFile != "" {
+		return errors.New("set secrets_passphrase or secrets_key_file, not both")
+	}
+
+	fileSecrets := config.SecretsProvider == "" || strings.EqualFold(config.SecretsProvider, "file")
+	if fileSecrets && config.SecretsPath == "" && config.Token == "" && !config.Anonymous {
+		return errors.New("secrets_path or token is required")
+	}
+
+	if config.Token != "" && !strings.HasPrefix(config.Token, "oauth:") {
+		return errors.New("token should look like oauth:xxxxxxxx")
+	}
+
+	if config.Channel == "" && len(config.Channels) == 0 {
+		return errors.New("channel or channels is required")
+	}
+
+	if strings.HasPrefix(config.Channel, "#") {
+		return errors.New("channel should not start with #")
+	}
+
+	for i, channel := range config.Channels {
+		if strings.TrimSpace(channel) == "" || strings.HasPrefix(channel, "#") {
+			return fmt.Errorf("channels[%d] should be a channel name without #", i)
+		}
+	}
+
+	if config.ChannelWorkers < 0 {
+		return errors.New("channel_workers can't be negative")
+	}
+
+	if config.ChannelQueueLength < 0 {
+		return errors.New("channel_queue_length can't be negative")
+	}
+
+	if config.ReconnectJitter > 1 {
+		return errors.New("reconnect_jitter can't be more than 1")
+	}
+
+	if config.MaxReconnectAttempts < 0 {
+		return errors.New("max_reconnect_attempts can't be negative")
+	}
+
+	if config.MaxQuestionsPerUser < 0 {
+		return errors.New("max_questions_per_user can't be negative")
+	}
+
+	if config.LeaderLeaseDuration < 0 {
+		return errors.New("leader_lease_duration can't be negative")
+	}
+
+	if config.Timezone != "" {
+		_, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return fmt.Errorf("timezone: %s", err.Error())
+		}
+	}
+
+	for channel, prefixes := range config.ChannelCommandPrefixes {
+		for _, prefix := range prefixes {
+			if prefix == "" {
+				return fmt.Errorf("channel_command_prefixes.%s has an empty prefix", channel)
+			}
+		}
+	}
+
+	for name, command := range config.Commands {
+		_, err := ParsePermission(orDefault(command.Permission, "everyone"))
+		if err != nil {
+			return fmt.Errorf("commands.%s.permission: %s", name, err.Error())
+		}
+	}
+
+	for channel, settings := range config.ChannelSettings {
+		for name, command := range settings.Commands {
+			_, err := ParsePermission(orDefault(command.Permission, "everyone"))
+			if err != nil {
+				return fmt.Errorf("channel_settings.%s.commands.%s.permission: %s", channel, name, err.Error())
+			}
+		}
+
+		for _, prefix := range settings.CommandPrefixes {
+			if prefix == "" {
+				return fmt.Errorf("channel_settings.%s.command_prefixes has an empty prefix", channel)
+			}
+		}
+	}
+
+	for _, prefix := range config.CommandPrefixes {
+		if prefix == "" {
+			return errors.New("command_prefixes has an empty prefix")
+		}
+	}
+
+	_, err := consoleTimestampLayout(config.ConsoleTimestamps)
+	if err != nil {
+		return err
+	}
+
+	_, err = consoleLocation(config.ConsoleTimezone)
+	if err != nil {
+		return err
+	}
+
+	err = validateLogLevels(config.LogLevels)
+	if err != nil {
+		return err
+	}
+
+	err = validateCommandPacks(config.CommandPacks)
+	if err != nil {
+		return err
+	}
+
+	err = validateEventSubEvents(config.EventSubEvents)
+	if err != nil {
+		return err
+	}
+
+	err = validateRedemptionActions(config.RedemptionActions)
+	if err != nil {
+		return err
+	}
+
+	err = validateHooks(config.Hooks)
+	if err != nil {
+		return err
+	}
+
+	err = validateConsoleCues(config.ConsoleCues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewBot builds a Bot from a config. Call Start on it to connect.
+func NewBot(config *Config) (*Bot, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, errors.New("NewBot: " + err.Error())
+	}
+
+	bot := &Bot{
+		BotName:                strings.ToLower(config.BotName),
+		Anonymous:              config.Anonymous,
+		ChannelName:            strings.ToLower(config.Channel),
+		Channels:               config.Channels,
+		Server:                 config.Server,
+		Port:                   config.Port,
+		SecretsPath:            config.SecretsPath,
+		SecretsPassphrase:      config.SecretsPassphrase,
+		SecretsKeyFile:         config.SecretsKeyFile,
+		Token:                  config.Token,
+		ClientID:               config.ClientID,
+		MaxReconnectWait:       time.Duration(config.MaxReconnectWait),
+		ReconnectJitter:        config.ReconnectJitter,
+		MaxReconnectAttempts:   config.MaxReconnectAttempts,
+		KeepaliveInterval:      time.Duration(config.KeepaliveInterval),
+		KeepaliveTimeout:       time.Duration(config.KeepaliveTimeout),
+		UserBackfillInterval:   time.Duration(config.UserBackfillInterval),
+		EventSubEvents:         config.EventSubEvents,
+		RedemptionActions:      config.RedemptionActions,
+		WatchSecrets:           config.WatchSecrets,
+		AdminToken:             config.AdminToken,
+		SkipTokenValidation:    config.SkipTokenValidation,
+		WhisperAutoResponse:    config.WhisperAutoResponse,
+		ChuckNorrisResponse:    config.ChuckNorrisResponse,
+		ShoutoutResponse:       config.ShoutoutResponse,
+		WhispersDisabled:       config.WhispersDisabled,
+		EmoteOnlyResponse:      config.EmoteOnlyResponse,
+		FirstChatterGreeting:   config.FirstChatterGreeting,
+		FollowGreeting:         config.FollowGreeting,
+		FollowGreetingWindow:   time.Duration(config.FollowGreetingWindow),
+		RaidShoutout:           config.RaidShoutout,
+		RaidHelixShoutout:      config.RaidHelixShoutout,
+		SubThanks:              config.SubThanks,
+		ResubThanks:            config.ResubThanks,
+		GiftThanks:             config.GiftThanks,
+		GiftBombThanks:         config.GiftBombThanks,
+		BatchGiftBombs:         config.BatchGiftBombs,
+		CheerResponses:         config.CheerResponses,
+		TrashRetention:         time.Duration(config.TrashRetention),
+		GoLiveAnnouncement:     config.GoLiveAnnouncement,
+		OfflineAnnouncement:    config.OfflineAnnouncement,
+		StreamPollInterval:     time.Duration(config.StreamPollInterval),
+		TimersOnlyWhenLive:     config.TimersOnlyWhenLive,
+		CommandPacks:           config.CommandPacks,
+		CategoryPollInterval:   time.Duration(config.CategoryPollInterval),
+		GameCounters:           config.GameCounters,
+		CategoryAnnouncement:   config.CategoryAnnouncement,
+		TitleAnnouncement:      config.TitleAnnouncement,
+		GreetingFloodThreshold: config.GreetingFloodThreshold,
+		RaidQuietPeriod:        time.Duration(config.RaidQuietPeriod),
+		HealthAddress:          config.HealthAddress,
+		ChannelWorkers:         config.ChannelWorkers,
+		ChannelQueueLength:     config.ChannelQueueLength,
+		InstanceID:             config.InstanceID,
+		LeaderLeaseDuration:    time.Duration(config.LeaderLeaseDuration),
+		IgnoredUsers:           config.IgnoredUsers,
+		IgnoredCommands:        config.IgnoredCommands,
+		BannedPhrases:          config.BannedPhrases,
+		CleanChat:              config.CleanChat,
+		SpamStrikes:            config.SpamStrikes,
+		SpamTimeout:            time.Duration(config.SpamTimeout),
+		SpamWaveThreshold:      config.SpamWaveThreshold,
+		ModerationReasons:      config.ModerationReasons,
+		ModerationWhisper:      config.ModerationWhisper,
+		AppealKeyword:          config.AppealKeyword,
+		AppealWebhook:          config.AppealWebhook,
+		Timezone:               config.Timezone,
+		HighlightRewardIDs:     config.HighlightRewardIDs,
+		BossBattle:             config.BossBattle,
+		DailyReward:            config.DailyReward,
+		MaxQuestionsPerUser:    config.MaxQuestionsPerUser,
+		ClipsExportDir:         config.ClipsExportDir,
+		DataExportInterval:     time.Duration(config.DataExportInterval),
+		DataExportDir:          config.DataExportDir,
+		DataExportBackups:      config.DataExportBackups,
+		ShowPronouns:           config.ShowPronouns,
+		PronounsCacheTTL:       time.Duration(config.PronounsCacheTTL),
+		CommandPrefixes:        config.CommandPrefixes,
+		ChannelCommandPrefixes: config.ChannelCommandPrefixes,
+		ChannelLanguages:       config.ChannelLanguages,
+		CommandAliases:         config.CommandAliases,
+		ChannelCommandAliases:  config.ChannelCommandAliases,
+		ChannelSettings:        config.ChannelSettings,
+		ConsoleCues:            config.ConsoleCues,
+		ConsoleGroupWindow:     time.Duration(config.ConsoleGroupWindow),
+		ConsoleTimestamps:      config.ConsoleTimestamps,
+		ConsoleTimezone:        config.ConsoleTimezone,
+		LogLevels:              config.LogLevels,
+		Hooks:                  config.Hooks,
+		commandConfig:          config.Commands,
+	}
+
+	// The first channel stands in for ChannelName when only a list is given
+	if bot.ChannelName == "" {
+		bot.ChannelName = strings.ToLower(config.Channels[0])
+	}
+
+	if config.LeaderLockPath != "" {
+		bot.LeaderLock = &FileLeaderLock{Path: config.LeaderLockPath}
+	}
+
+	// A file provider is left unset so it follows SecretsPath when a reload changes it
+	if config.SecretsProvider != "" && !strings.EqualFold(config.SecretsProvider, "file") {
+		bot.Secrets, err = NewSecretsProvider(config)
+		if err != nil {
+			return nil, errors.New("NewBot: " + err.Error())
+		}
+	}
+
+	if config.S3 != nil {
+		bot.DataExportSink, err = config.S3.sink(bot.secretsProvider())
+		if err != nil {
+			return nil, errors.New("NewBot: " + err.Error())
+		}
+	}
+
+	if len(config.PriorityWeights) > 0 {
+		bot.PriorityWeights = map[MessagePriority]int{}
+		for name, weight := range config.PriorityWeights {
+			priority, err := ParsePriority(name)
+			if err != nil || priority >= PriorityModeration {
+				return nil, fmt.Errorf("NewBot: priority_weights: %q isn't game, timer, reply or system", name)
+			}
+			if weight < 1 {
+				return nil, fmt.Errorf("NewBot: priority_weights: %s must be at least 1", name)
+			}
+			bot.PriorityWeights[priority] = weight
+		}
+	}
+
+	if config.Proxy != "" {
+		bot.Proxy, err = url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.New("NewBot: proxy: " + err.Error())
+		}
+	}
+
+	if config.TLSCAFile != "" {
+		bot.TLSConfig, err = loadTLSConfig(config.TLSCAFile)
+		if err != nil {
+			return nil, errors.New("NewBot: tls_ca_file: " + err.Error())
+		}
+	}
+
+	if config.StorePath != "" {
+		bot.Store, err = store.OpenFile(config.StorePath)
+		if err != nil {
+			return nil, errors.New("NewBot: store_path: " + err.Error())
+		}
+	}
+
+	return bot, nil
+}
+
+// Trusts the PEM certificates in a file, e.g. the root CA of a local test server
+func loadTLSConfig(caFile string) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return &tls.Config{RootCAs: roots}, nil
+}
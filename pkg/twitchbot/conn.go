@@ -0,0 +1,54 @@
+package twitchbot
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+)
+
+// Conn is a connection to a Twitch chat server. A net.Conn is one, but anything that reads and
+// writes IRC lines will do: a WebSocket adapter, or one end of net.Pipe in tests.
+type Conn interface {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// Dialer opens the Bot's connection to an address like "irc.chat.twitch.tv:6697"
+type Dialer interface {
+	Dial(ctx context.Context, address string) (Conn, error)
+}
+
+// DialerFunc lets a plain function be used as a Dialer
+type DialerFunc func(ctx context.Context, address string) (Conn, error)
+
+// Dial calls the function
+func (dial DialerFunc) Dial(ctx context.Context, address string) (Conn, error) {
+	return dial(ctx, address)
+}
+
+// Twitch's port for chat without TLS. Local test servers often use it too.
+const plaintextPort = "6667"
+
+// TLSDialer connects over TLS. It is the default Dialer.
+type TLSDialer struct {
+	// Custom root CAs, client certificates and the like. The system defaults are used when nil.
+	Config *tls.Config
+}
+
+// Dial opens a TLS connection over TCP
+func (dialer TLSDialer) Dial(ctx context.Context, address string) (Conn, error) {
+	tlsDialer := &tls.Dialer{Config: dialer.Config}
+	return tlsDialer.DialContext(ctx, "tcp", address)
+}
+
+// PlaintextDialer connects over TCP without encryption. The token is sent in the clear, so only
+// use it with local test servers. It is the default Dialer when Port is 6667.
+type PlaintextDialer struct{}
+
+// Dial opens a TCP connection
+func (PlaintextDialer) Dial(ctx context.Context, address string) (Conn, error) {
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, "tcp", address)
+}
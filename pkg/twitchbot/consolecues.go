@@ -0,0 +1,123 @@
+package twitchbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+// Events that can be given a ConsoleCues entry
+const (
+	EventRaid      = "raid"
+	EventSub       = "sub"
+	EventFilter    = "filter"
+	EventReconnect = "reconnect"
+)
+
+var consoleEvents = []string{EventRaid, EventSub, EventFilter, EventReconnect}
+
+// Checks a config's console_cues for unknown events and colors
+func validateConsoleCues(cues map[string]printpretty.Cue) error {
+	for event, cue := range cues {
+		known := false
+		for _, name := range consoleEvents {
+			if strings.EqualFold(event, name) {
+				known = true
+			}
+		}
+		if !known {
+			events := append([]string{}, consoleEvents...)
+			sort.Strings(events)
+			return fmt.Errorf("console_cues.%s: unknown event, use one of %s", event, strings.Join(events, ", "))
+		}
+
+		if cue.Color != "" && !printpretty.ValidColor(cue.Color) {
+			return fmt.Errorf("console_cues.%s.color: unknown color %q", event, cue.Color)
+		}
+	}
+
+	return nil
+}
+
+// Applies the console settings other than cues, which are process wide
+func (bot *Bot) applyConsoleSettings() {
+	bot.settingsMutex.RLock()
+	window := bot.ConsoleGroupWindow
+	timestamps := bot.ConsoleTimestamps
+	timezone := bot.ConsoleTimezone
+	bot.settingsMutex.RUnlock()
+
+	// Both were checked by Config.Validate
+	layout, err := consoleTimestampLayout(timestamps)
+	if err != nil {
+		logger.Warn("Bot.applyConsoleSettings: %s", err.Error())
+		layout = printpretty.TimeOnly
+	}
+	location, err := consoleLocation(timezone)
+	if err != nil {
+		logger.Warn("Bot.applyConsoleSettings: %s", err.Error())
+	}
+	printpretty.SetTimestamps(layout, location)
+
+	bot.applyLogLevels()
+
+	switch {
+	case window == 0:
+		window = printpretty.DefaultGroupWindow
+	case window < 0:
+		window = 0
+	}
+
+	printpretty.SetGroupWindow(window)
+}
+
+// The time layout for a console_timestamps setting. Empty turns timestamps off.
+func consoleTimestampLayout(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", "time":
+		return printpretty.TimeOnly, nil
+	case "datetime":
+		return printpretty.DateTime, nil
+	case "rfc3339":
+		return printpretty.RFC3339, nil
+	case "none":
+		return "", nil
+	}
+
+	// Anything else has to be a layout, so it prints something other than itself
+	if time.Date(2001, 3, 4, 7, 8, 9, 0, time.UTC).Format(name) == name {
+		return "", fmt.Errorf("console_timestamps: %q is not time, datetime, rfc3339, none or a Go time layout", name)
+	}
+
+	return name, nil
+}
+
+func consoleLocation(name string) (*time.Location, error) {
+	if name == "" || strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	if strings.EqualFold(name, "utc") {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("console_timezone: %s", err.Error())
+	}
+
+	return location, nil
+}
+
+// Raw lines are grouped by their text, except JOINs and PARTs, which are grouped by channel so
+// a flood of them from different users collapses into one summary
+func consoleGroupKey(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && (fields[1] == "JOIN" || fields[1] == "PART") {
+		return fields[1] + " " + fields[2]
+	}
+
+	return line
+}
@@ -0,0 +1,78 @@
+package twitchbot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ContentFilter inspects message text. Returning false blocks it.
+type ContentFilter func(text string) bool
+
+// Look-alike characters people use to get around phrase lists
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// Checks chat and the bot's own messages against banned phrases and custom filters
+type contentFilter struct {
+	phrases []string
+	custom  []ContentFilter
+}
+
+func newContentFilter(phrases []string, custom []ContentFilter) *contentFilter {
+	filter := &contentFilter{custom: custom}
+
+	for _, phrase := range phrases {
+		if normalized := normalizeForFilter(phrase); normalized != " " {
+			filter.phrases = append(filter.phrases, normalized)
+		}
+	}
+
+	return filter
+}
+
+// Lowercases, undoes look-alikes and turns everything but letters and digits into single
+// spaces, padded so phrases only match whole words
+func normalizeForFilter(text string) string {
+	text = leetReplacer.Replace(strings.ToLower(text))
+
+	var builder strings.Builder
+	builder.WriteByte(' ')
+	space := true
+	for _, char := range text {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			builder.WriteRune(char)
+			space = false
+		} else if !space {
+			builder.WriteByte(' ')
+			space = true
+		}
+	}
+	if !space {
+		builder.WriteByte(' ')
+	}
+
+	return builder.String()
+}
+
+// Returns the banned phrase found in text, or "custom filter" if a ContentFilter blocked it
+func (filter *contentFilter) match(text string) (string, bool) {
+	if filter == nil {
+		return "", false
+	}
+
+	if len(filter.phrases) > 0 {
+		normalized := normalizeForFilter(text)
+		for _, phrase := range filter.phrases {
+			if strings.Contains(normalized, phrase) {
+				return strings.TrimSpace(phrase), true
+			}
+		}
+	}
+
+	for _, allowed := range filter.custom {
+		if !allowed(text) {
+			return "custom filter", true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,161 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Expired cooldowns are swept once this many are being tracked
+const cooldownSweepThreshold = 1000
+
+// WithCooldown limits how often a command runs in a channel. global applies to everyone in the
+// channel, perUser to each user separately. Moderators and the broadcaster skip cooldowns.
+func WithCooldown(global, perUser time.Duration) CommandOption {
+	return func(command *RegisteredCommand) {
+		command.GlobalCooldown = global
+		command.UserCooldown = perUser
+	}
+}
+
+// Tracks when commands can next run, per channel and per user in a channel
+type cooldowns struct {
+	mutex sync.Mutex
+	until map[string]time.Time
+
+	// Cooldowns set with !cooldown, keyed by channel and command
+	overrides map[string]cooldownOverride
+}
+
+type cooldownOverride struct {
+	global  time.Duration
+	perUser time.Duration
+}
+
+func cooldownOverrideKey(channel, name string) string {
+	return channel + " " + strings.ToLower(name)
+}
+
+// The cooldowns set for a command in a channel with SetChannelCooldown, if any
+func (c *cooldowns) override(channel, name string) (cooldownOverride, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	override, ok := c.overrides[cooldownOverrideKey(channel, name)]
+	return override, ok
+}
+
+// Reports whether the command may run now and starts its cooldowns if so
+func (c *cooldowns) allow(command *RegisteredCommand, message *Message) bool {
+	if command.GlobalCooldown == 0 && command.UserCooldown == 0 {
+		return true
+	}
+
+	if message.Can(Moderator) {
+		return true
+	}
+
+	globalKey := message.Channel + " " + command.Name
+	userKey := globalKey + " " + strings.ToLower(message.Username)
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.until == nil {
+		c.until = map[string]time.Time{}
+	}
+
+	if now.Before(c.until[globalKey]) || now.Before(c.until[userKey]) {
+		return false
+	}
+
+	if len(c.until) >= cooldownSweepThreshold {
+		for key, until := range c.until {
+			if now.After(until) {
+				delete(c.until, key)
+			}
+		}
+	}
+
+	if command.GlobalCooldown > 0 {
+		c.until[globalKey] = now.Add(command.GlobalCooldown)
+	}
+	if command.UserCooldown > 0 {
+		c.until[userKey] = now.Add(command.UserCooldown)
+	}
+
+	return true
+}
+
+// SetCooldown changes a command's cooldowns in every channel while the Bot is running
+func (bot *Bot) SetCooldown(name string, global, perUser time.Duration) error {
+	if global < 0 || perUser < 0 {
+		return errors.New("Bot.SetCooldown: cooldowns can't be negative")
+	}
+
+	ok := bot.Commands.update(name, WithCooldown(global, perUser))
+	if !ok {
+		return fmt.Errorf("Bot.SetCooldown: no command %q", name)
+	}
+
+	logger.Info("Cooldowns for !%s set to %s for the channel and %s per user", name, global, perUser)
+	return nil
+}
+
+// SetChannelCooldown changes a command's cooldowns in one channel while the Bot is running. They
+// take the place of the command's own and the config's until the Bot restarts.
+func (bot *Bot) SetChannelCooldown(channel, name string, global, perUser time.Duration) error {
+	if global < 0 || perUser < 0 {
+		return errors.New("Bot.SetChannelCooldown: cooldowns can't be negative")
+	}
+	if _, ok := bot.resolveCommand(channel, name); !ok {
+		return fmt.Errorf("Bot.SetChannelCooldown: no command %q in #%s", name, channel)
+	}
+
+	bot.cooldowns.mutex.Lock()
+	if bot.cooldowns.overrides == nil {
+		bot.cooldowns.overrides = map[string]cooldownOverride{}
+	}
+	bot.cooldowns.overrides[cooldownOverrideKey(channel, name)] = cooldownOverride{global: global, perUser: perUser}
+	bot.cooldowns.mutex.Unlock()
+
+	logger.Info("Cooldowns for !%s in #%s set to %s for the channel and %s per user", name, channel, global, perUser)
+	return nil
+}
+
+// !cooldown <command> <channel cooldown> [user cooldown], e.g. !cooldown chucknorris 30s 2m
+func cooldownCommand(bot *Bot, command *Command) {
+	usage := "Usage: !cooldown <command> <channel cooldown> [user cooldown], e.g. !cooldown chucknorris 30s 2m"
+	if len(command.Args) < 2 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	name := strings.TrimPrefix(command.Args[0], "!")
+
+	global, err := time.ParseDuration(command.Args[1])
+	if err != nil || global < 0 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	var perUser time.Duration
+	if len(command.Args) > 2 {
+		perUser, err = time.ParseDuration(command.Args[2])
+		if err != nil || perUser < 0 {
+			bot.Reply(command.Message, usage)
+			return
+		}
+	}
+
+	err = bot.SetChannelCooldown(command.Channel, name, global, perUser)
+	if err != nil {
+		bot.Reply(command.Message, fmt.Sprintf("There is no !%s command.", name))
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("!%s now has a %s channel cooldown and a %s user cooldown.", name, global, perUser))
+}
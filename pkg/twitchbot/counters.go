@@ -0,0 +1,237 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const countersBucket = "counters"
+
+// Counter is a named number a channel keeps track of, like deaths in a run
+type Counter struct {
+	Channel   string    `json:"channel"`
+	Name      string    `json:"name"`
+	Value     int       `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// The game it counts for, if it's one of GameCounters
+	Game string `json:"game,omitempty"`
+}
+
+// Shows the counter as "deaths: 5", or "deaths in Elden Ring: 5" for GameCounters
+func (counter *Counter) String() string {
+	if counter.Game != "" {
+		return fmt.Sprintf("%s in %s: %d", counter.Name, counter.Game, counter.Value)
+	}
+
+	return fmt.Sprintf("%s: %d", counter.Name, counter.Value)
+}
+
+func counterKey(channel, name string) string {
+	return channel + "/" + strings.ToLower(name)
+}
+
+// The game a counter counts for right now: the channel's game for GameCounters, otherwise ""
+func (bot *Bot) counterGame(channel, name string) string {
+	bot.settingsMutex.RLock()
+	perGame := containsCommand(bot.GameCounters, name)
+	bot.settingsMutex.RUnlock()
+
+	if !perGame {
+		return ""
+	}
+
+	return bot.Game(channel)
+}
+
+// Where a counter is kept. GameCounters are kept apart for every game, so switching games
+// switches counts.
+func (bot *Bot) counterStoreKey(channel, name string) string {
+	if game := bot.counterGame(channel, name); game != "" {
+		return counterKey(channel, name) + "/" + strings.ToLower(game)
+	}
+
+	return counterKey(channel, name)
+}
+
+// Counter looks up a channel's counter
+func (bot *Bot) Counter(channel, name string) (*Counter, bool) {
+	counter := &Counter{}
+	ok, err := bot.Store.Get(countersBucket, bot.counterStoreKey(channel, name), counter)
+	if err != nil {
+		logger.Warn("Bot.Counter: %s", err.Error())
+		return nil, false
+	}
+
+	return counter, ok
+}
+
+// Whether a channel has a counter by that name, including ones in CommandPacks and GameCounters
+// that don't need adding
+func (bot *Bot) isCounter(channel, name string) bool {
+	if packed, _ := bot.packCounter(channel, name); packed || bot.counterGame(channel, name) != "" {
+		return true
+	}
+
+	_, ok := bot.Counter(channel, name)
+	return ok
+}
+
+// UpdateCounter changes a counter, creating it if create is set. The change is applied under a lock
+// so concurrent increments aren't lost.
+func (bot *Bot) UpdateCounter(channel, name string, create bool, change func(value int) int) (*Counter, error) {
+	bot.counterMutex.Lock()
+	defer bot.counterMutex.Unlock()
+
+	counter, ok := bot.Counter(channel, name)
+	if !ok {
+		if !create {
+			return nil, fmt.Errorf("Bot.UpdateCounter: no counter %q in #%s", name, channel)
+		}
+		counter = &Counter{Channel: channel, Name: strings.ToLower(name), Game: bot.counterGame(channel, name)}
+	}
+
+	counter.Value = change(counter.Value)
+	counter.UpdatedAt = time.Now()
+
+	err := bot.Store.Put(countersBucket, bot.counterStoreKey(channel, name), counter)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.UpdateCounter: %s", err.Error())
+	}
+
+	return counter, nil
+}
+
+// DeleteCounter removes a counter
+func (bot *Bot) DeleteCounter(channel, name string) error {
+	err := bot.Store.Delete(countersBucket, bot.counterStoreKey(channel, name))
+	if err != nil {
+		return fmt.Errorf("Bot.DeleteCounter: %s", err.Error())
+	}
+
+	return nil
+}
+
+func init() {
+	// $(counter deaths) shows a counter's value
+	RegisterTemplateVariable("counter", func(context *TemplateContext, args []string) string {
+		if len(args) == 0 {
+			return "?"
+		}
+
+		counter, ok := context.Bot.Counter(context.Command.Channel, args[0])
+		if !ok {
+			return "0"
+		}
+
+		return strconv.Itoa(counter.Value)
+	})
+}
+
+// Handles "!deaths", "!deaths+", "!deaths-", "!deaths reset" and "!deaths set 5" for existing counters.
+// Returns false if there is no counter with the command's name, or it belongs to CommandPacks
+// that are off. Counters in packs and GameCounters don't need adding first.
+func (bot *Bot) runCounterCommand(command *Command) bool {
+	packed, active := bot.packCounter(command.Channel, command.Name)
+	if packed && !active {
+		return false
+	}
+
+	game := bot.counterGame(command.Channel, command.Name)
+	create := packed || game != ""
+
+	counter, ok := bot.Counter(command.Channel, command.Name)
+	if !ok {
+		if !create {
+			return false
+		}
+		counter = &Counter{Channel: command.Channel, Name: strings.ToLower(command.Name), Game: game}
+	}
+
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, counter.String())
+		return true
+	}
+
+	// Viewers can look, only moderators can change counters
+	if !command.Can(Moderator) {
+		return true
+	}
+
+	var change func(int) int
+	switch action := strings.ToLower(command.Args[0]); action {
+	case "+", "++", "add":
+		change = func(value int) int { return value + 1 }
+	case "-", "--", "remove":
+		change = func(value int) int { return value - 1 }
+	case "reset":
+		change = func(int) int { return 0 }
+	case "set":
+		if len(command.Args) < 2 {
+			bot.Reply(command.Message, fmt.Sprintf("Usage: %s%s set <number>", command.Prefix, counter.Name))
+			return true
+		}
+
+		value, err := strconv.Atoi(command.Args[1])
+		if err != nil {
+			bot.Reply(command.Message, fmt.Sprintf("Usage: %s%s set <number>", command.Prefix, counter.Name))
+			return true
+		}
+		change = func(int) int { return value }
+	default:
+		bot.Reply(command.Message, counter.String())
+		return true
+	}
+
+	counter, err := bot.UpdateCounter(command.Channel, command.Name, create, change)
+	if err != nil {
+		logger.Error(err.Error())
+		return true
+	}
+
+	bot.Reply(command.Message, counter.String())
+	return true
+}
+
+// !counter add <name> | !counter remove <name>
+func counterCommand(bot *Bot, command *Command) {
+	usage := "Usage: !counter add <name> | !counter remove <name>"
+	if len(command.Args) < 2 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimLeft(command.Args[1], "!?~"))
+
+	switch strings.ToLower(command.Args[0]) {
+	case "add":
+		if _, ok := bot.Commands.Get(name); ok {
+			bot.Reply(command.Message, fmt.Sprintf("!%s is a built-in command.", name))
+			return
+		}
+		if _, ok := bot.CustomCommand(command.Channel, name); ok {
+			bot.Reply(command.Message, fmt.Sprintf("!%s is already a command.", name))
+			return
+		}
+
+		_, err := bot.UpdateCounter(command.Channel, name, true, func(value int) int { return value })
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Added the %s counter. Use !%s+ to count up.", name, name))
+	case "remove", "delete":
+		err := bot.DeleteCounter(command.Channel, name)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Removed the %s counter.", name))
+	default:
+		bot.Reply(command.Message, usage)
+	}
+}
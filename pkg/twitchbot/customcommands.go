@@ -0,0 +1,233 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const customCommandsBucket = "custom_commands"
+
+// Custom commands answer at most this often per channel
+const customCommandCooldown = 5 * time.Second
+
+// CustomCommand is a text response defined from chat by a moderator
+type CustomCommand struct {
+	Channel    string     `json:"channel"`
+	Name       string     `json:"name"`
+	Response   string     `json:"response"`
+	Permission Permission `json:"permission"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func customCommandKey(channel, name string) string {
+	return channel + "/" + strings.ToLower(name)
+}
+
+// CustomCommand looks up a custom command in a channel
+func (bot *Bot) CustomCommand(channel, name string) (*CustomCommand, bool) {
+	custom := &CustomCommand{}
+	ok, err := bot.Store.Get(customCommandsBucket, customCommandKey(channel, name), custom)
+	if err != nil {
+		logger.Warn("Bot.CustomCommand: %s", err.Error())
+		return nil, false
+	}
+
+	return custom, ok
+}
+
+// SaveCustomCommand creates or replaces a custom command
+func (bot *Bot) SaveCustomCommand(custom *CustomCommand) error {
+	custom.Name = strings.ToLower(custom.Name)
+	custom.UpdatedAt = time.Now()
+	if custom.CreatedAt.IsZero() {
+		custom.CreatedAt = custom.UpdatedAt
+	}
+
+	err := bot.Store.Put(customCommandsBucket, customCommandKey(custom.Channel, custom.Name), custom)
+	if err != nil {
+		return fmt.Errorf("Bot.SaveCustomCommand: %s", err.Error())
+	}
+
+	return nil
+}
+
+// DeleteCustomCommand removes a custom command. It can be brought back with Restore for
+// TrashRetention.
+func (bot *Bot) DeleteCustomCommand(channel, name, deletedBy string) error {
+	custom, ok := bot.CustomCommand(channel, name)
+	if !ok {
+		return nil
+	}
+
+	err := bot.trash(trashCommand, channel, custom.Name, deletedBy, custom)
+	if err != nil {
+		return fmt.Errorf("Bot.DeleteCustomCommand: %s", err.Error())
+	}
+
+	err = bot.Store.Delete(customCommandsBucket, customCommandKey(channel, name))
+	if err != nil {
+		return fmt.Errorf("Bot.DeleteCustomCommand: %s", err.Error())
+	}
+
+	return nil
+}
+
+// CustomCommands lists a channel's custom commands sorted by name
+func (bot *Bot) CustomCommands(channel string) ([]*CustomCommand, error) {
+	keys, err := bot.Store.Keys(customCommandsBucket)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.CustomCommands: %s", err.Error())
+	}
+
+	commands := []*CustomCommand{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		custom := &CustomCommand{}
+		ok, err := bot.Store.Get(customCommandsBucket, key, custom)
+		if err != nil {
+			return nil, fmt.Errorf("Bot.CustomCommands: %s", err.Error())
+		}
+		if ok {
+			commands = append(commands, custom)
+		}
+	}
+
+	return commands, nil
+}
+
+// Runs a custom command, returning false if there is none with that name
+func (bot *Bot) runCustomCommand(command *Command) bool {
+	custom, ok := bot.CustomCommand(command.Channel, command.Name)
+	if !ok {
+		return false
+	}
+
+	if !command.Can(custom.Permission) {
+		logger.Quiet("@%s is not allowed to use %s%s, it needs %s", command.Username, command.Prefix, command.Name, custom.Permission)
+		return true
+	}
+
+	if !bot.cooldowns.allow(&RegisteredCommand{Name: custom.Name, GlobalCooldown: customCommandCooldown}, command.Message) {
+		return true
+	}
+
+	bot.recordCommand(command.Message, custom.Name)
+	logger.Highlight("> "+bot.consoleName(command.Message)+": "+command.Text, command.Prefix+command.Name)
+	bot.Reply(command.Message, bot.renderTemplate(custom.Response, command))
+
+	return true
+}
+
+// Splits "!addcom [-ul=moderator] !name response..." into its parts
+func parseCustomCommandArgs(args []string) (name string, permission Permission, response string, err error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-ul=") {
+		permission, err = ParsePermission(strings.TrimPrefix(args[0], "-ul="))
+		if err != nil {
+			return "", Everyone, "", err
+		}
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		return "", Everyone, "", fmt.Errorf("expected a name and a response")
+	}
+
+	name = strings.ToLower(strings.TrimLeft(args[0], "!?~"))
+	if name == "" {
+		return "", Everyone, "", fmt.Errorf("expected a name and a response")
+	}
+
+	return name, permission, strings.Join(args[1:], " "), nil
+}
+
+// !addcom [-ul=<permission>] !name <response>
+func addCommandCommand(bot *Bot, command *Command) {
+	name, permission, response, err := parseCustomCommandArgs(command.Args)
+	if err != nil {
+		bot.Reply(command.Message, "Usage: !addcom [-ul=moderator] !name <response>")
+		return
+	}
+
+	if _, ok := bot.Commands.Get(name); ok {
+		bot.Reply(command.Message, fmt.Sprintf("!%s is a built-in command.", name))
+		return
+	}
+
+	if _, ok := bot.CustomCommand(command.Channel, name); ok {
+		bot.Reply(command.Message, fmt.Sprintf("!%s already exists, use !editcom to change it.", name))
+		return
+	}
+
+	if bot.isCounter(command.Channel, name) {
+		bot.Reply(command.Message, fmt.Sprintf("!%s is a counter.", name))
+		return
+	}
+
+	err = bot.SaveCustomCommand(&CustomCommand{
+		Channel:    command.Channel,
+		Name:       name,
+		Response:   response,
+		Permission: permission,
+		CreatedBy:  command.Username,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Added !%s.", name))
+}
+
+// !editcom [-ul=<permission>] !name <response>
+func editCommandCommand(bot *Bot, command *Command) {
+	name, permission, response, err := parseCustomCommandArgs(command.Args)
+	if err != nil {
+		bot.Reply(command.Message, "Usage: !editcom [-ul=moderator] !name <response>")
+		return
+	}
+
+	custom, ok := bot.CustomCommand(command.Channel, name)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("There is no !%s command.", name))
+		return
+	}
+
+	custom.Response = response
+	custom.Permission = permission
+
+	err = bot.SaveCustomCommand(custom)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Updated !%s.", name))
+}
+
+// !delcom !name
+func deleteCommandCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !delcom !name")
+		return
+	}
+
+	name := strings.ToLower(strings.TrimLeft(command.Args[0], "!?~"))
+	if _, ok := bot.CustomCommand(command.Channel, name); !ok {
+		bot.Reply(command.Message, fmt.Sprintf("There is no !%s command.", name))
+		return
+	}
+
+	err := bot.DeleteCustomCommand(command.Channel, name, command.Username)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Deleted !%s. Undo with !restore !%s", name, name))
+}
@@ -0,0 +1,127 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	dailyRewardsBucket = "daily_rewards"
+
+	defaultDailyPoints        = 50
+	defaultDailyStreakBonus   = 10
+	defaultDailyMaxStreakDays = 7
+)
+
+// DailyRewardSettings configures !daily
+type DailyRewardSettings struct {
+	// Points for each claim. Defaults to 50.
+	Points int `json:"points"`
+
+	// Extra points for every day in a row the user claimed before. Defaults to 10.
+	StreakBonus int `json:"streak_bonus"`
+
+	// The streak stops adding bonus points after this many days. Defaults to 7.
+	MaxStreakDays int `json:"max_streak_days"`
+}
+
+// DailyClaim is a user's !daily history in a channel
+type DailyClaim struct {
+	// The day of the last claim as YYYY-MM-DD in the bot's Timezone
+	LastClaim string `json:"last_claim"`
+
+	// Days in a row with a claim, including the last one
+	Streak int `json:"streak"`
+
+	BestStreak int `json:"best_streak"`
+}
+
+// ClaimDaily gives the user their points for today. Days follow Timezone, so everyone's day ends
+// at the same moment. Returns the points given and the claim; claimed is false if the user
+// already claimed today.
+func (bot *Bot) ClaimDaily(channel, userID string, now time.Time) (points int, claim *DailyClaim, claimed bool, err error) {
+	bot.pointsMutex.Lock()
+	key := pointsKey(channel, userID)
+
+	claim = &DailyClaim{}
+	_, err = bot.Store.Get(dailyRewardsBucket, key, claim)
+	if err != nil {
+		bot.pointsMutex.Unlock()
+		return 0, nil, false, errors.New("Bot.ClaimDaily: " + err.Error())
+	}
+
+	local := now.In(bot.location())
+	today := local.Format("2006-01-02")
+	if claim.LastClaim == today {
+		bot.pointsMutex.Unlock()
+		return 0, claim, false, nil
+	}
+
+	yesterday := time.Date(local.Year(), local.Month(), local.Day()-1, 12, 0, 0, 0, local.Location()).Format("2006-01-02")
+	if claim.LastClaim == yesterday {
+		claim.Streak++
+	} else {
+		claim.Streak = 1
+	}
+	if claim.Streak > claim.BestStreak {
+		claim.BestStreak = claim.Streak
+	}
+	claim.LastClaim = today
+
+	settings := bot.DailyReward
+	points = settings.Points
+	if points <= 0 {
+		points = defaultDailyPoints
+	}
+	bonus := settings.StreakBonus
+	if bonus <= 0 {
+		bonus = defaultDailyStreakBonus
+	}
+	maxDays := settings.MaxStreakDays
+	if maxDays <= 0 {
+		maxDays = defaultDailyMaxStreakDays
+	}
+
+	streakDays := claim.Streak - 1
+	if streakDays > maxDays {
+		streakDays = maxDays
+	}
+	points += streakDays * bonus
+
+	err = bot.Store.Put(dailyRewardsBucket, key, claim)
+	bot.pointsMutex.Unlock()
+	if err != nil {
+		return 0, nil, false, errors.New("Bot.ClaimDaily: " + err.Error())
+	}
+
+	_, err = bot.AddPoints(channel, userID, points)
+	if err != nil {
+		return 0, nil, false, errors.New("Bot.ClaimDaily: " + err.Error())
+	}
+
+	return points, claim, true, nil
+}
+
+// !daily
+func dailyCommand(bot *Bot, command *Command) {
+	points, claim, claimed, err := bot.ClaimDaily(command.Channel, leaderboardUser(command.Message), time.Now())
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	if !claimed {
+		tomorrow := time.Now().In(bot.location()).AddDate(0, 0, 1)
+		midnight := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
+		bot.Reply(command.Message, fmt.Sprintf("@%s you already claimed today. Come back in %s!", command.Username, time.Until(midnight).Round(time.Minute)))
+		return
+	}
+
+	text := fmt.Sprintf("@%s claimed %d points", command.Username, points)
+	if claim.Streak > 1 {
+		text += fmt.Sprintf(" with a %d day streak", claim.Streak)
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("%s and now has %d.", text, bot.Points(command.Channel, leaderboardUser(command.Message))))
+}
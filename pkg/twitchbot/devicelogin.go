@@ -0,0 +1,96 @@
+package twitchbot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// LoginScopes are the scopes DeviceLogin asks for: chat, plus whispers, announcements and
+// follower lookups
+func LoginScopes() []string {
+	return append(append([]string{}, requiredScopes...), helixScopes...)
+}
+
+// DeviceLogin gets a token through Twitch's device code grant and saves it to the config's
+// SecretsPath, or the keychain or secret manager when that's the SecretsProvider, along with the refresh token and
+// ClientID so the Bot can keep it fresh. prompt is
+// called with the code the user enters at the verification URI, signed in as the bot's account.
+// ClientID must belong to an app registered as a public client. Returns who the token belongs to.
+func DeviceLogin(ctx context.Context, config *Config, scopes []string, prompt func(userCode, verificationURI string)) (*helix.TokenInfo, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("DeviceLogin: client_id is required")
+	}
+
+	provider, err := NewSecretsProvider(config)
+	if err != nil {
+		return nil, errors.New("DeviceLogin: " + err.Error())
+	}
+	switch provider.(type) {
+	case *FileSecrets, *KeychainSecrets, *CloudSecrets:
+	default:
+		return nil, errors.New("DeviceLogin: the token can only be saved to a secrets file, the keychain or a secret manager")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	if config.Proxy != "" {
+		proxy, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.New("DeviceLogin: " + err.Error())
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxy)
+		client.Transport = transport
+	}
+	auth := &helix.Auth{ClientID: config.ClientID, HTTPClient: client}
+
+	code, err := auth.StartDeviceLogin(ctx, scopes)
+	if err != nil {
+		return nil, errors.New("DeviceLogin: " + err.Error())
+	}
+
+	prompt(code.UserCode, code.VerificationURI)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+	defer cancel()
+
+	token, err := auth.WaitForDeviceLogin(ctx, code, scopes)
+	if err != nil {
+		return nil, errors.New("DeviceLogin: " + err.Error())
+	}
+
+	info, err := auth.Validate(ctx, token.AccessToken)
+	if err != nil {
+		return nil, errors.New("DeviceLogin: " + err.Error())
+	}
+
+	switch provider := provider.(type) {
+	case *KeychainSecrets:
+		err = provider.Update(func(stored *Secrets) {
+			stored.OAuthToken = "oauth:" + token.AccessToken
+			stored.RefreshToken = token.RefreshToken
+			stored.ClientID = config.ClientID
+		})
+	case *CloudSecrets:
+		err = provider.save(map[string]string{
+			"token":         "oauth:" + token.AccessToken,
+			"refresh_token": token.RefreshToken,
+			"client_id":     config.ClientID,
+		})
+	default:
+		err = saveSecrets(config.SecretsPath, secretsKey{Passphrase: config.SecretsPassphrase, KeyFile: config.SecretsKeyFile}, map[string]string{
+			"token":         "oauth:" + token.AccessToken,
+			"refresh_token": token.RefreshToken,
+			"client_id":     config.ClientID,
+		})
+	}
+	if err != nil {
+		return nil, errors.New("DeviceLogin: " + err.Error())
+	}
+
+	return info, nil
+}
@@ -0,0 +1,146 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChannelWorkers     = 2
+	defaultChannelQueueLength = 20
+)
+
+// Queue key for work that doesn't belong to a channel, such as whispers
+const whisperQueue = "@whispers"
+
+// ChannelStats describes the work processed for one channel
+type ChannelStats struct {
+	Processed      int64         `json:"processed"`
+	Dropped        int64         `json:"dropped"`
+	AverageLatency time.Duration `json:"average_latency_ns"`
+	MaxLatency     time.Duration `json:"max_latency_ns"`
+
+	totalLatency time.Duration
+}
+
+type job struct {
+	queued time.Time
+	run    func()
+}
+
+// Gives every channel its own queue and workers so a busy channel can't starve the others
+type dispatcher struct {
+	workers     int
+	queueLength int
+
+	mutex  sync.Mutex
+	queues map[string]chan job
+	stats  map[string]*ChannelStats
+}
+
+func newDispatcher(workers, queueLength int) *dispatcher {
+	return &dispatcher{
+		workers:     workers,
+		queueLength: queueLength,
+		queues:      map[string]chan job{},
+		stats:       map[string]*ChannelStats{},
+	}
+}
+
+// Queues work for a channel. Returns false if the channel's queue is full and the work was dropped.
+func (d *dispatcher) dispatch(channel string, run func()) bool {
+	queue := d.queue(channel)
+
+	select {
+	case queue <- job{queued: time.Now(), run: run}:
+		return true
+	default:
+		d.mutex.Lock()
+		d.stats[channel].Dropped++
+		d.mutex.Unlock()
+		logger.Warn("Work queue for %s is full, dropping", channel)
+		return false
+	}
+}
+
+// Lazily creates the queue and workers for a channel
+func (d *dispatcher) queue(channel string) chan job {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	queue, ok := d.queues[channel]
+	if ok {
+		return queue
+	}
+
+	queue = make(chan job, d.queueLength)
+	d.queues[channel] = queue
+	d.stats[channel] = &ChannelStats{}
+
+	for i := 0; i < d.workers; i++ {
+		go d.work(channel, queue)
+	}
+
+	return queue
+}
+
+func (d *dispatcher) work(channel string, queue chan job) {
+	for j := range queue {
+		d.run(channel, j)
+		d.record(channel, time.Since(j.queued))
+	}
+}
+
+// Runs a job, logging a panic instead of letting one handler take the bot down
+func (d *dispatcher) run(channel string, j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Handler for %s panicked: %v\n%s", channel, r, debug.Stack())
+		}
+	}()
+
+	j.run()
+}
+
+func (d *dispatcher) record(channel string, latency time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stats := d.stats[channel]
+	stats.Processed++
+	stats.totalLatency += latency
+	stats.AverageLatency = stats.totalLatency / time.Duration(stats.Processed)
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+}
+
+func (d *dispatcher) snapshot() map[string]ChannelStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	snapshot := make(map[string]ChannelStats, len(d.stats))
+	for channel, stats := range d.stats {
+		snapshot[channel] = *stats
+	}
+
+	return snapshot
+}
+
+// ChannelStats reports queue and latency metrics for every channel the Bot has handled work for
+func (bot *Bot) ChannelStats() map[string]ChannelStats {
+	if bot.dispatcher == nil {
+		return map[string]ChannelStats{}
+	}
+
+	return bot.dispatcher.snapshot()
+}
+
+// Serves ChannelStats as JSON on /metrics
+func (bot *Bot) serveChannelStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bot.ChannelStats())
+}
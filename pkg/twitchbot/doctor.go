@@ -0,0 +1,236 @@
+package twitchbot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// How long each network check of Doctor may take
+const doctorTimeout = 10 * time.Second
+
+// DoctorCheck is one line of Doctor's report
+type DoctorCheck struct {
+	Name   string
+	Passed bool
+
+	// Passed, but something is likely to be wrong or missing
+	Warning bool
+
+	Detail string
+}
+
+func passed(name, detail string, args ...interface{}) DoctorCheck {
+	return DoctorCheck{Name: name, Passed: true, Detail: fmt.Sprintf(detail, args...)}
+}
+
+func warned(name, detail string, args ...interface{}) DoctorCheck {
+	return DoctorCheck{Name: name, Passed: true, Warning: true, Detail: fmt.Sprintf(detail, args...)}
+}
+
+func failed(name, detail string, args ...interface{}) DoctorCheck {
+	return DoctorCheck{Name: name, Detail: fmt.Sprintf(detail, args...)}
+}
+
+// Doctor checks that a Bot built from config can go live: the config itself, the secrets, the
+// token's account and scopes, whether Twitch chat, Helix and api.chucknorris.io can be reached,
+// the Store and that the bot can write where it needs to. Nothing is changed, and an expired
+// token isn't refreshed.
+func Doctor(ctx context.Context, config *Config) []DoctorCheck {
+	checks := []DoctorCheck{}
+
+	bot, err := NewBot(config)
+	if err != nil {
+		return append(checks, failed("config", "%s", strings.TrimPrefix(err.Error(), "NewBot: ")))
+	}
+	bot.ctx = ctx
+	bot.fillDefaults()
+	checks = append(checks, passed("config", "%s in #%s", bot.BotName, strings.Join(bot.channels(), ", #")))
+
+	checks = append(checks, bot.doctorToken(ctx)...)
+	checks = append(checks, bot.doctorNetwork(ctx)...)
+	checks = append(checks, bot.doctorStore(config))
+	checks = append(checks, bot.doctorWritable(config)...)
+
+	return checks
+}
+
+// Reads the secrets and asks Twitch about the token
+func (bot *Bot) doctorToken(ctx context.Context) []DoctorCheck {
+	if bot.Anonymous {
+		return []DoctorCheck{passed("secrets", "anonymous, no token needed")}
+	}
+
+	err := bot.getOAuthToken()
+	if err != nil {
+		return []DoctorCheck{failed("secrets", "%s", err.Error())}
+	}
+	if bot.ClientID == "" {
+		bot.ClientID = bot.secretsClientID
+	}
+
+	source := "the secrets provider"
+	if bot.configToken() != "" {
+		source = "the config"
+	} else if file, ok := bot.secretsProvider().(*FileSecrets); ok {
+		source = file.Path
+	}
+	checks := []DoctorCheck{passed("secrets", "token read from %s", source)}
+
+	bot.secretsMutex.Lock()
+	token := bot.oAuthToken
+	bot.secretsMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	info, err := bot.auth().Validate(ctx, token)
+	if helixError, ok := err.(*helix.Error); ok && helixError.StatusCode == http.StatusUnauthorized {
+		if bot.canRefreshToken() {
+			return append(checks, warned("token", "expired, the bot will refresh it when it starts"))
+		}
+		return append(checks, failed("token", "invalid or expired, generate a new one for %s", bot.BotName))
+	}
+	if err != nil {
+		return append(checks, warned("token", "couldn't ask Twitch about it: %s", err.Error()))
+	}
+
+	if !strings.EqualFold(info.Login, bot.BotName) {
+		return append(checks, failed("token", "belongs to %s, not %s", info.Login, bot.BotName))
+	}
+
+	check := passed("token", "belongs to %s", info.Login)
+	if expires := info.Expires(); expires > 0 {
+		check.Detail += fmt.Sprintf(", expires in %s", expires.Round(time.Minute))
+		if !bot.canRefreshToken() && expires < 24*time.Hour {
+			check.Warning = true
+			check.Detail += " and can't be refreshed without a refresh_token and client_id"
+		}
+	}
+	if bot.ClientID != "" && info.ClientID != bot.ClientID {
+		check.Warning = true
+		check.Detail += fmt.Sprintf(", but was made for client ID %s, not %s", info.ClientID, bot.ClientID)
+	}
+	checks = append(checks, check)
+
+	if missing := missingScopes(info, requiredScopes); len(missing) > 0 {
+		return append(checks, failed("scopes", "missing %s, which the bot needs to chat", strings.Join(missing, ", ")))
+	}
+	if missing := missingScopes(info, helixScopes); bot.ClientID != "" && len(missing) > 0 {
+		return append(checks, warned("scopes", "missing %s, so whispers, announcements or follow dates won't work", strings.Join(missing, ", ")))
+	}
+
+	return append(checks, passed("scopes", "%s", strings.Join(info.Scopes, ", ")))
+}
+
+// Connects to chat and makes a request to Helix and api.chucknorris.io, through Proxy if set
+func (bot *Bot) doctorNetwork(ctx context.Context) []DoctorCheck {
+	checks := []DoctorCheck{}
+
+	address := fmt.Sprintf("%s:%s", bot.Server, bot.Port)
+	dialCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	started := time.Now()
+	connection, err := bot.Dialer.Dial(dialCtx, address)
+	cancel()
+	if err != nil {
+		checks = append(checks, failed("chat", "can't connect to %s: %s", address, err.Error()))
+	} else {
+		connection.Close()
+		checks = append(checks, passed("chat", "connected to %s in %s", address, time.Since(started).Round(time.Millisecond)))
+	}
+
+	// Any answer at all means the API is reachable
+	urls := []struct{ name, url string }{
+		{"helix", helix.DefaultBaseURL + "/users"},
+		{"chucknorris.io", "https://api.chucknorris.io/jokes/random"},
+	}
+	for _, target := range urls {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+		if err != nil {
+			checks = append(checks, failed(target.name, "%s", err.Error()))
+			continue
+		}
+
+		started := time.Now()
+		resp, err := bot.httpClient(doctorTimeout).Do(request)
+		if err != nil {
+			check := failed(target.name, "can't reach %s: %s", request.URL.Host, err.Error())
+			if target.name == "chucknorris.io" {
+				// The bot falls back on facts of its own
+				check = warned(target.name, "can't reach %s, !chucknorris will use built in facts: %s", request.URL.Host, err.Error())
+			}
+			checks = append(checks, check)
+			continue
+		}
+		resp.Body.Close()
+		checks = append(checks, passed(target.name, "%s answered in %s", request.URL.Host, time.Since(started).Round(time.Millisecond)))
+	}
+
+	return checks
+}
+
+// Reads from the Store NewBot opened
+func (bot *Bot) doctorStore(config *Config) DoctorCheck {
+	if config.StorePath == "" {
+		return warned("store", "in memory, so points, quotes and the rest are lost on restart. Set store_path to keep them")
+	}
+
+	_, err := bot.Store.Keys(pointsBucket)
+	if err != nil {
+		return failed("store", "%s: %s", config.StorePath, err.Error())
+	}
+
+	return passed("store", "opened %s", config.StorePath)
+}
+
+// Checks the bot can create files where it keeps data
+func (bot *Bot) doctorWritable(config *Config) []DoctorCheck {
+	paths := []struct{ setting, dir string }{}
+	add := func(setting, path string, isDir bool) {
+		if path == "" {
+			return
+		}
+		if !isDir {
+			path = filepath.Dir(path)
+		}
+		// Missing export directories are created, so it's their parent that has to be writable
+		for isDir && path != filepath.Dir(path) {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			path = filepath.Dir(path)
+		}
+		paths = append(paths, struct{ setting, dir string }{setting, path})
+	}
+
+	add("store_path", config.StorePath, false)
+	if _, file := bot.secretsProvider().(*FileSecrets); file && bot.configToken() == "" && !bot.Anonymous {
+		// Refreshed tokens are written back next to the secrets
+		add("secrets_path", config.SecretsPath, false)
+	}
+	if bot.DataExportSink == nil {
+		add("data_export_dir", config.DataExportDir, true)
+	}
+	add("leader_lock_path", config.LeaderLockPath, false)
+
+	checks := []DoctorCheck{}
+	for _, path := range paths {
+		probe, err := ioutil.TempFile(path.dir, ".chuckbot-doctor-")
+		if err != nil {
+			checks = append(checks, failed("write "+path.setting, "can't create files in %s: %s", path.dir, err.Error()))
+			continue
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+		checks = append(checks, passed("write "+path.setting, "%s is writable", path.dir))
+	}
+
+	return checks
+}
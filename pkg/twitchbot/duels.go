@@ -0,0 +1,242 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	duelRecordsBucket = "duel_records"
+
+	// How long the challenged user has to !accept
+	duelAcceptWindow = time.Minute
+)
+
+// A challenge waiting for the challenged user to accept
+type duel struct {
+	challengerID    string
+	challengerLogin string
+	targetLogin     string
+	points          int
+	timer           *time.Timer
+}
+
+// Pending duels keyed by channel and challenged login
+type duels struct {
+	mutex   sync.Mutex
+	pending map[string]*duel
+}
+
+func duelKey(channel, login string) string {
+	return channel + "/" + strings.ToLower(login)
+}
+
+// DuelRecord is the head-to-head record of two users in a channel
+type DuelRecord struct {
+	// Wins keyed by user-id
+	Wins map[string]int `json:"wins"`
+}
+
+// Both orders of a pair share one record
+func duelRecordKey(channel, userA, userB string) string {
+	if userB < userA {
+		userA, userB = userB, userA
+	}
+
+	return channel + "/" + userA + "/" + userB
+}
+
+// DuelRecord returns how often each of two users beat the other
+func (bot *Bot) DuelRecord(channel, userA, userB string) (*DuelRecord, error) {
+	record := &DuelRecord{Wins: map[string]int{}}
+	_, err := bot.Store.Get(duelRecordsBucket, duelRecordKey(channel, userA, userB), record)
+	if err != nil {
+		return nil, errors.New("Bot.DuelRecord: " + err.Error())
+	}
+	if record.Wins == nil {
+		record.Wins = map[string]int{}
+	}
+
+	return record, nil
+}
+
+func (bot *Bot) recordDuel(channel, winner, loser string) error {
+	record, err := bot.DuelRecord(channel, winner, loser)
+	if err != nil {
+		return err
+	}
+
+	record.Wins[winner]++
+	err = bot.Store.Put(duelRecordsBucket, duelRecordKey(channel, winner, loser), record)
+	if err != nil {
+		return errors.New("Bot.recordDuel: " + err.Error())
+	}
+
+	return nil
+}
+
+// !duel @user <points> | !duel record @user
+func duelCommand(bot *Bot, command *Command) {
+	usage := "Usage: !duel @user <points> | !duel record @user"
+	if len(command.Args) < 2 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	if strings.EqualFold(command.Args[0], "record") {
+		duelRecordCommand(bot, command, strings.ToLower(strings.TrimPrefix(command.Args[1], "@")))
+		return
+	}
+
+	target := strings.ToLower(strings.TrimPrefix(command.Args[0], "@"))
+	points, err := strconv.Atoi(command.Args[1])
+	if err != nil || points <= 0 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	challenger := strings.ToLower(command.Username)
+	if target == challenger {
+		bot.Reply(command.Message, fmt.Sprintf("@%s you can't duel yourself.", command.Username))
+		return
+	}
+
+	challengerID := leaderboardUser(command.Message)
+	if bot.Points(command.Channel, challengerID) < points {
+		bot.Reply(command.Message, fmt.Sprintf("@%s you don't have %d points to bet.", command.Username, points))
+		return
+	}
+
+	key := duelKey(command.Channel, target)
+
+	bot.duels.mutex.Lock()
+	if bot.duels.pending == nil {
+		bot.duels.pending = map[string]*duel{}
+	}
+	if _, waiting := bot.duels.pending[key]; waiting {
+		bot.duels.mutex.Unlock()
+		bot.Reply(command.Message, fmt.Sprintf("@%s already has a duel waiting.", target))
+		return
+	}
+	// One challenge at a time, so the same points can't be bet on several duels
+	for pendingKey, pending := range bot.duels.pending {
+		if pending.challengerID == challengerID && strings.HasPrefix(pendingKey, command.Channel+"/") {
+			bot.duels.mutex.Unlock()
+			bot.Reply(command.Message, fmt.Sprintf("@%s you already challenged @%s.", command.Username, pending.targetLogin))
+			return
+		}
+	}
+
+	challenge := &duel{challengerID: challengerID, challengerLogin: challenger, targetLogin: target, points: points}
+	channel := command.Channel
+	challenge.timer = time.AfterFunc(duelAcceptWindow, func() {
+		bot.duels.mutex.Lock()
+		expired := bot.duels.pending[key] == challenge
+		if expired {
+			delete(bot.duels.pending, key)
+		}
+		bot.duels.mutex.Unlock()
+
+		if expired {
+			bot.ChatWithPriority(channel, fmt.Sprintf("@%s didn't accept the duel from @%s in time.", target, challenger), PriorityGame)
+		}
+	})
+	bot.duels.pending[key] = challenge
+	bot.duels.mutex.Unlock()
+
+	bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s challenges @%s to a duel for %d points! Type !accept or !decline within %d seconds.", challenger, target, points, int(duelAcceptWindow.Seconds())), PriorityGame)
+}
+
+// Takes the duel waiting for a user, if any
+func (bot *Bot) takeDuel(channel, login string) (*duel, bool) {
+	bot.duels.mutex.Lock()
+	defer bot.duels.mutex.Unlock()
+
+	key := duelKey(channel, login)
+	challenge, ok := bot.duels.pending[key]
+	if !ok {
+		return nil, false
+	}
+
+	challenge.timer.Stop()
+	delete(bot.duels.pending, key)
+	return challenge, true
+}
+
+// !accept fights the duel waiting for the user
+func acceptDuelCommand(bot *Bot, command *Command) {
+	challenge, ok := bot.takeDuel(command.Channel, command.Username)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("@%s nobody has challenged you.", command.Username))
+		return
+	}
+
+	targetID := leaderboardUser(command.Message)
+	if bot.Points(command.Channel, targetID) < challenge.points {
+		bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s doesn't have %d points, so the duel is off.", command.Username, challenge.points), PriorityGame)
+		return
+	}
+	if bot.Points(command.Channel, challenge.challengerID) < challenge.points {
+		bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s no longer has %d points, so the duel is off.", challenge.challengerLogin, challenge.points), PriorityGame)
+		return
+	}
+
+	winnerID, winner, loserID, loser := challenge.challengerID, challenge.challengerLogin, targetID, strings.ToLower(command.Username)
+	if bot.Random.Intn(2) == 1 {
+		winnerID, winner, loserID, loser = loserID, loser, winnerID, winner
+	}
+
+	err := bot.transferPoints(command.Channel, loserID, winnerID, challenge.points)
+	if err == errNotEnoughPoints {
+		bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s no longer has %d points, so the duel is off.", loser, challenge.points), PriorityGame)
+		return
+	}
+	if err == nil {
+		err = bot.recordDuel(command.Channel, winnerID, loserID)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	record, err := bot.DuelRecord(command.Channel, winnerID, loserID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s wins the duel against @%s and takes %d points! Head to head: %d-%d.",
+		winner, loser, challenge.points, record.Wins[winnerID], record.Wins[loserID]), PriorityGame)
+}
+
+// !decline turns down the duel waiting for the user
+func declineDuelCommand(bot *Bot, command *Command) {
+	challenge, ok := bot.takeDuel(command.Channel, command.Username)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("@%s nobody has challenged you.", command.Username))
+		return
+	}
+
+	bot.ChatWithPriority(command.Channel, fmt.Sprintf("@%s declined the duel from @%s.", command.Username, challenge.challengerLogin), PriorityGame)
+}
+
+func duelRecordCommand(bot *Bot, command *Command, opponent string) {
+	opponentID, ok := bot.UserIDForLogin(opponent)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("I haven't seen @%s in chat.", opponent))
+		return
+	}
+
+	userID := leaderboardUser(command.Message)
+	record, err := bot.DuelRecord(command.Channel, userID, opponentID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s %d-%d against @%s.", command.Username, record.Wins[userID], record.Wins[opponentID], opponent))
+}
@@ -0,0 +1,330 @@
+package twitchbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pendingEffectsBucket = "pending_effects"
+	failedEffectsBucket  = "failed_effects"
+
+	// Look for effects due a retry this often
+	effectPollInterval = 5 * time.Second
+
+	firstEffectRetry = time.Second
+	maxEffectRetry   = 10 * time.Minute
+
+	// After this many failed attempts an effect is moved to failed_effects
+	maxEffectAttempts = 10
+)
+
+// Effect is one side effect of a handler, like a chat message or a webhook post, that should
+// happen even if the Bot crashes or the other end is down for a while
+type Effect struct {
+	// Set by RecordEffects. Webhooks get it as an Idempotency-Key so the receiver can ignore repeats.
+	ID string `json:"id"`
+
+	// Which EffectHandler delivers it, e.g. "chat" or "webhook"
+	Kind string `json:"kind"`
+
+	// Whatever the handler needs, as JSON
+	Payload json.RawMessage `json:"payload"`
+
+	Delivered bool   `json:"delivered"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// EffectHandler delivers one kind of Effect. Returning an error retries it later with backoff.
+type EffectHandler func(bot *Bot, effect *Effect) error
+
+// Effects recorded together, delivered in order. Kept as one Store value so they are written
+// all at once or not at all.
+type effectBatch struct {
+	ID          string    `json:"id"`
+	Effects     []Effect  `json:"effects"`
+	CreatedAt   time.Time `json:"created_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+type effectDelivery struct {
+	mutex    sync.Mutex
+	handlers map[string]EffectHandler
+	wake     chan struct{}
+	started  sync.Once
+	sequence int64
+}
+
+// ChatEffect is the payload of a "chat" Effect
+type ChatEffect struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// WebhookEffect is the payload of a "webhook" Effect: Body is POSTed to URL as JSON. Discord
+// webhooks take a body like {"content": "..."}.
+type WebhookEffect struct {
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+}
+
+// RegisterEffect sets the handler for a kind of Effect. "chat" and "webhook" are built in.
+func (bot *Bot) RegisterEffect(kind string, handler EffectHandler) {
+	bot.effects.mutex.Lock()
+	defer bot.effects.mutex.Unlock()
+
+	if bot.effects.handlers == nil {
+		bot.effects.handlers = map[string]EffectHandler{}
+	}
+	bot.effects.handlers[kind] = handler
+}
+
+// RecordEffects saves side effects to the Store before any of them happen, then delivers them
+// in the background, in order, retrying failures. If the Bot crashes they are picked up again on
+// the next start, so a reply and the webhook that goes with it either both happen or are both
+// still pending.
+//
+// Chat can't be taken back or deduplicated by Twitch, so a "chat" effect is marked delivered
+// just before it is sent and is never sent twice. Other effects are marked after they succeed
+// and may be repeated after a crash, so their receivers should use the effect ID to skip repeats.
+func (bot *Bot) RecordEffects(effects ...Effect) error {
+	if len(effects) == 0 {
+		return nil
+	}
+
+	bot.effects.mutex.Lock()
+	bot.effects.sequence++
+	batchID := fmt.Sprintf("%s-%d-%d", bot.InstanceID, time.Now().UnixNano(), bot.effects.sequence)
+	bot.effects.mutex.Unlock()
+
+	batch := effectBatch{ID: batchID, CreatedAt: time.Now()}
+	for i, effect := range effects {
+		effect.ID = batchID + "-" + strconv.Itoa(i)
+		batch.Effects = append(batch.Effects, effect)
+	}
+
+	err := bot.Store.Put(pendingEffectsBucket, batchID, batch)
+	if err != nil {
+		return errors.New("Bot.RecordEffects: " + err.Error())
+	}
+
+	bot.startEffectDelivery()
+	select {
+	case bot.effects.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// NewEffect builds an Effect with payload encoded as JSON
+func NewEffect(kind string, payload interface{}) (Effect, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Effect{}, errors.New("NewEffect: " + err.Error())
+	}
+
+	return Effect{Kind: kind, Payload: data}, nil
+}
+
+// Starts the delivery loop once, which also picks up anything left from the last run. Called
+// when Twitch welcomes the Bot, so chat left over from a crash has somewhere to go.
+func (bot *Bot) startEffectDelivery() {
+	bot.effects.started.Do(func() {
+		bot.effects.mutex.Lock()
+		bot.effects.wake = make(chan struct{}, 1)
+		bot.effects.mutex.Unlock()
+
+		bot.registerDefaultEffects()
+		go bot.deliverEffects()
+	})
+}
+
+// Adds the built-in "chat" and "webhook" handlers, keeping any registered under those kinds already
+func (bot *Bot) registerDefaultEffects() {
+	defaults := map[string]EffectHandler{
+		"chat":    deliverChatEffect,
+		"webhook": deliverWebhookEffect,
+	}
+
+	for kind, handler := range defaults {
+		bot.effects.mutex.Lock()
+		_, ok := bot.effects.handlers[kind]
+		bot.effects.mutex.Unlock()
+
+		if !ok {
+			bot.RegisterEffect(kind, handler)
+		}
+	}
+}
+
+func (bot *Bot) deliverEffects() {
+	for {
+		bot.deliverDueEffects()
+
+		timer := time.NewTimer(effectPollInterval)
+		select {
+		case <-timer.C:
+		case <-bot.effects.wake:
+		case <-bot.stopping:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+func (bot *Bot) deliverDueEffects() {
+	// Standbys leave delivery to the leader so nothing goes out twice
+	if !bot.isLeader() {
+		return
+	}
+
+	keys, err := bot.Store.Keys(pendingEffectsBucket)
+	if err != nil {
+		logger.Error("Bot.deliverEffects: %s", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		batch := effectBatch{}
+		found, err := bot.Store.Get(pendingEffectsBucket, key, &batch)
+		if err != nil {
+			logger.Error("Bot.deliverEffects: %s", err.Error())
+			continue
+		}
+		if !found || time.Now().Before(batch.NextAttempt) {
+			continue
+		}
+
+		bot.deliverBatch(&batch)
+	}
+}
+
+// Delivers a batch's effects in order, stopping at the first failure so later effects never
+// get ahead of earlier ones
+func (bot *Bot) deliverBatch(batch *effectBatch) {
+	for i := range batch.Effects {
+		effect := &batch.Effects[i]
+		if effect.Delivered {
+			continue
+		}
+
+		bot.effects.mutex.Lock()
+		handler, ok := bot.effects.handlers[effect.Kind]
+		bot.effects.mutex.Unlock()
+
+		var err error
+		if !ok {
+			err = fmt.Errorf("no handler registered for %q effects", effect.Kind)
+		} else if effect.Kind == "chat" {
+			// At most once: record it as sent first
+			effect.Delivered = true
+			err = bot.Store.Put(pendingEffectsBucket, batch.ID, batch)
+			if err != nil {
+				effect.Delivered = false
+			} else {
+				err = handler(bot, effect)
+			}
+		} else {
+			err = handler(bot, effect)
+			if err == nil {
+				effect.Delivered = true
+			}
+		}
+
+		if err != nil {
+			bot.retryBatch(batch, effect, err)
+			return
+		}
+
+		err = bot.Store.Put(pendingEffectsBucket, batch.ID, batch)
+		if err != nil {
+			logger.Error("Bot.deliverBatch: %s", err.Error())
+			return
+		}
+	}
+
+	err := bot.Store.Delete(pendingEffectsBucket, batch.ID)
+	if err != nil {
+		logger.Error("Bot.deliverBatch: %s", err.Error())
+	}
+}
+
+// Schedules the next attempt with exponential backoff, or gives up on the batch
+func (bot *Bot) retryBatch(batch *effectBatch, effect *Effect, cause error) {
+	effect.Attempts++
+	effect.LastError = cause.Error()
+
+	if effect.Attempts >= maxEffectAttempts {
+		logger.Error("Giving up on %s effect %s after %d attempts: %s", effect.Kind, effect.ID, effect.Attempts, cause.Error())
+
+		err := bot.Store.Put(failedEffectsBucket, batch.ID, batch)
+		if err == nil {
+			err = bot.Store.Delete(pendingEffectsBucket, batch.ID)
+		}
+		if err != nil {
+			logger.Error("Bot.retryBatch: %s", err.Error())
+		}
+		return
+	}
+
+	wait := firstEffectRetry << uint(effect.Attempts-1)
+	if wait > maxEffectRetry {
+		wait = maxEffectRetry
+	}
+	batch.NextAttempt = time.Now().Add(wait)
+	logger.Warn("Bot.deliverEffects: %s effect %s failed, retrying in %s: %s", effect.Kind, effect.ID, wait, cause.Error())
+
+	err := bot.Store.Put(pendingEffectsBucket, batch.ID, batch)
+	if err != nil {
+		logger.Error("Bot.retryBatch: %s", err.Error())
+	}
+}
+
+func deliverChatEffect(bot *Bot, effect *Effect) error {
+	chat := ChatEffect{}
+	err := json.Unmarshal(effect.Payload, &chat)
+	if err != nil {
+		return errors.New("deliverChatEffect: " + err.Error())
+	}
+
+	bot.chatTo(strings.ToLower(chat.Channel), chat.Text)
+
+	return nil
+}
+
+func deliverWebhookEffect(bot *Bot, effect *Effect) error {
+	webhook := WebhookEffect{}
+	err := json.Unmarshal(effect.Payload, &webhook)
+	if err != nil {
+		return errors.New("deliverWebhookEffect: " + err.Error())
+	}
+
+	request, err := http.NewRequestWithContext(bot.context(), http.MethodPost, webhook.URL, bytes.NewReader(webhook.Body))
+	if err != nil {
+		return errors.New("deliverWebhookEffect: " + err.Error())
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", effect.ID)
+
+	resp, err := bot.httpClient(10 * time.Second).Do(request)
+	if err != nil {
+		return errors.New("deliverWebhookEffect: " + err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("deliverWebhookEffect: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
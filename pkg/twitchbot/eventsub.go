@@ -0,0 +1,207 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mike1104/chuckbot/pkg/eventsub"
+)
+
+// Kinds of events in EventSubEvents
+var eventSubEvents = []string{"follows", "subs", "raids", "redemptions", "streams", "updates"}
+
+// Checks a config's eventsub_events for unknown kinds
+func validateEventSubEvents(events []string) error {
+	for _, event := range events {
+		known := false
+		for _, name := range eventSubEvents {
+			if strings.EqualFold(event, name) {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("eventsub_events: unknown event %q, use %s", event, strings.Join(eventSubEvents, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Opens an EventSub WebSocket for EventSubEvents in every channel, so follows, subs, raids,
+// channel point redemptions, streams going live and category changes go through the middleware and handlers like chat does. Needs a
+// ClientID, and Twitch only allows most events for channels the token has rights in. Follows
+// are received for FollowGreeting even when EventSubEvents doesn't have them.
+func (bot *Bot) startEventSub() {
+	if (len(bot.EventSubEvents) == 0 && !bot.greetsFollowers()) || bot.Anonymous {
+		return
+	}
+	if bot.ClientID == "" {
+		logger.Warn("eventsub_events needs a client_id, so EventSub is off")
+		return
+	}
+
+	go func() {
+		subscriptions := bot.eventSubSubscriptions()
+		if len(subscriptions) == 0 {
+			return
+		}
+
+		client := &eventsub.Client{
+			Helix:         bot.helix(),
+			Subscriptions: subscriptions,
+			Handler:       bot.handleNotification,
+		}
+		client.Run(bot.context())
+	}()
+}
+
+// The subscriptions for each channel
+func (bot *Bot) eventSubSubscriptions() []eventsub.Subscription {
+	wanted := map[string]bool{}
+	for _, event := range bot.EventSubEvents {
+		wanted[strings.ToLower(event)] = true
+	}
+	if bot.greetsFollowers() {
+		wanted["follows"] = true
+	}
+
+	moderatorID := ""
+	if wanted["follows"] {
+		var err error
+		moderatorID, err = bot.botUserID()
+		if err != nil {
+			logger.Warn("Not subscribing to follows: %s", err.Error())
+			delete(wanted, "follows")
+		}
+	}
+
+	subscriptions := []eventsub.Subscription{}
+	for _, channel := range bot.channels() {
+		broadcasterID, err := bot.channelUserID(channel)
+		if err != nil {
+			logger.Warn("Not subscribing to EventSub events in #%s: %s", channel, err.Error())
+			continue
+		}
+
+		if wanted["follows"] {
+			subscriptions = append(subscriptions, eventsub.Follows(broadcasterID, moderatorID))
+		}
+		if wanted["subs"] {
+			subscriptions = append(subscriptions, eventsub.Subscribes(broadcasterID))
+		}
+		if wanted["raids"] {
+			subscriptions = append(subscriptions, eventsub.Raids(broadcasterID))
+		}
+		if wanted["redemptions"] {
+			subscriptions = append(subscriptions, eventsub.Redemptions(broadcasterID))
+		}
+		if wanted["streams"] {
+			subscriptions = append(subscriptions, eventsub.Online(broadcasterID), eventsub.Offline(broadcasterID))
+		}
+		if wanted["updates"] {
+			subscriptions = append(subscriptions, eventsub.Updates(broadcasterID))
+		}
+	}
+
+	return subscriptions
+}
+
+// Turns a notification into an EVENTSUB Message and dispatches it on its channel's queue
+func (bot *Bot) handleNotification(notification *eventsub.Notification) {
+	message := eventSubMessage(notification)
+	if message == nil {
+		logger.Debug("Ignoring EventSub %s notification", notification.Type())
+		return
+	}
+
+	// Standby instances leave responding to the leader
+	if !bot.isLeader() {
+		return
+	}
+
+	handler := bot.handler()
+	bot.dispatcher.dispatch(message.Channel, func() { handler(bot, message) })
+}
+
+// The Message for a notification: who did it, in which channel, and for redemptions what they
+// typed. Streams going live or offline and channel updates are done by the broadcaster. Returns nil for types the bot doesn't know.
+func eventSubMessage(notification *eventsub.Notification) *Message {
+	message := &Message{Type: "EVENTSUB", MessageID: notification.MessageID, Event: notification, Tags: map[string]string{}}
+
+	var err error
+	switch notification.Type() {
+	case eventsub.TypeFollow:
+		event := eventsub.FollowEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.UserID, event.UserLogin, event.UserName
+	case eventsub.TypeSubscribe:
+		event := eventsub.SubscribeEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.UserID, event.UserLogin, event.UserName
+	case eventsub.TypeRaid:
+		event := eventsub.RaidEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.ToBroadcasterUserLogin, event.FromBroadcasterUserID, event.FromBroadcasterUserLogin, event.FromBroadcasterUserName
+	case eventsub.TypeRedemption:
+		event := eventsub.RedemptionEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.UserID, event.UserLogin, event.UserName
+		message.Text = event.UserInput
+		message.Tags["custom-reward-id"] = event.Reward.ID
+	case eventsub.TypeOnline:
+		event := eventsub.OnlineEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.BroadcasterUserID, event.BroadcasterUserLogin, event.BroadcasterUserName
+	case eventsub.TypeOffline:
+		event := eventsub.OfflineEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.BroadcasterUserID, event.BroadcasterUserLogin, event.BroadcasterUserName
+	case eventsub.TypeUpdate:
+		event := eventsub.UpdateEvent{}
+		err = notification.Decode(&event)
+		message.Channel, message.UserID, message.Username, message.DisplayName = event.BroadcasterUserLogin, event.BroadcasterUserID, event.BroadcasterUserLogin, event.BroadcasterUserName
+	default:
+		return nil
+	}
+	if err != nil {
+		logger.Warn("Bot.eventSubMessage: %s", err.Error())
+		return nil
+	}
+
+	message.Channel = strings.ToLower(message.Channel)
+	message.Username = strings.ToLower(message.Username)
+
+	return message
+}
+
+// The end of the middleware chain for EventSub events: logs them and puts them on the feed
+func (bot *Bot) handleEventSub(message *Message) {
+	switch message.Event.Type() {
+	case eventsub.TypeFollow:
+		logger.Success("@%s followed #%s", message.Username, message.Channel)
+		bot.publish(FeedFollows, message.Channel, message.Username, "followed")
+		bot.greetFollower(message)
+	// Subs and raids are announced in chat too, and logged from there
+	case eventsub.TypeSubscribe:
+		logger.Quiet("EventSub: @%s subscribed to #%s", message.Username, message.Channel)
+	case eventsub.TypeRaid:
+		logger.Quiet("EventSub: @%s raided #%s", message.Username, message.Channel)
+	case eventsub.TypeRedemption:
+		event := eventsub.RedemptionEvent{}
+		message.Event.Decode(&event)
+		logger.Info("@%s redeemed %q in #%s", message.Username, event.Reward.Title, message.Channel)
+		bot.publish(FeedRedemptions, message.Channel, message.Username, strings.TrimSpace(event.Reward.Title+": "+message.Text))
+		bot.handleRedemption(message, event)
+	// Logged and published when the live state changes
+	case eventsub.TypeOnline:
+		event := eventsub.OnlineEvent{}
+		message.Event.Decode(&event)
+		bot.streamOnline(bot.streamFromEventSub(message.Channel, event.BroadcasterUserID, event.StartedAt), true)
+	case eventsub.TypeOffline:
+		bot.streamOffline(message.Channel, true)
+	case eventsub.TypeUpdate:
+		event := eventsub.UpdateEvent{}
+		message.Event.Decode(&event)
+		bot.channelUpdated(message.Channel, event.CategoryName, event.Title)
+	}
+}
@@ -0,0 +1,227 @@
+package twitchbot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Chat lines kept between exports before the oldest are dropped
+const maxExportBuffer = 100000
+
+// ExportSink stores the files produced by data exports
+type ExportSink interface {
+	Save(name string, data []byte) error
+}
+
+// DirectorySink saves exports as files in a local directory
+type DirectorySink struct {
+	Dir string
+}
+
+// Save writes the file, creating the directory if needed
+func (sink *DirectorySink) Save(name string, data []byte) error {
+	dir := sink.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.New("DirectorySink.Save: " + err.Error())
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+	if err != nil {
+		return errors.New("DirectorySink.Save: " + err.Error())
+	}
+
+	return nil
+}
+
+type exportedLine struct {
+	at      time.Time
+	message *Message
+}
+
+// Chat collected since the last export
+type exportBuffer struct {
+	mutex   sync.Mutex
+	lines   []exportedLine
+	dropped int
+	started sync.Once
+}
+
+func (bot *Bot) exportSink() ExportSink {
+	if bot.DataExportSink != nil {
+		return bot.DataExportSink
+	}
+
+	dir := bot.DataExportDir
+	if dir == "" {
+		dir = bot.ClipsExportDir
+	}
+
+	return &DirectorySink{Dir: dir}
+}
+
+// Keeps chat for the next export when scheduled exports are on
+func (bot *Bot) bufferForExport(message *Message) {
+	if bot.DataExportInterval <= 0 {
+		return
+	}
+
+	bot.exports.started.Do(func() { go bot.exportEvery(bot.DataExportInterval) })
+
+	bot.exports.mutex.Lock()
+	defer bot.exports.mutex.Unlock()
+
+	if len(bot.exports.lines) >= maxExportBuffer {
+		bot.exports.lines = bot.exports.lines[1:]
+		bot.exports.dropped++
+	}
+
+	bot.exports.lines = append(bot.exports.lines, exportedLine{at: time.Now(), message: message})
+}
+
+func (bot *Bot) exportEvery(interval time.Duration) {
+	for !bot.sleepUnlessStopped(interval) {
+		err := bot.ExportData()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
+
+func csvFile(header []string, rows [][]string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	err := writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// ExportData saves CSV files for each channel: chat since the last export, channel point
+// redemptions seen in chat, and command uses for the days so far. Files are named after the
+// channel and the export time, e.g. chat-mikkeever-20260102-150405.csv.
+func (bot *Bot) ExportData() error {
+	bot.exports.mutex.Lock()
+	lines := bot.exports.lines
+	dropped := bot.exports.dropped
+	bot.exports.lines = nil
+	bot.exports.dropped = 0
+	bot.exports.mutex.Unlock()
+
+	if dropped > 0 {
+		logger.Warn("Bot.ExportData: %d chat lines were dropped because the export buffer was full", dropped)
+	}
+
+	chat := map[string][][]string{}
+	redemptions := map[string][][]string{}
+	for _, line := range lines {
+		message := line.message
+		at := line.at.UTC().Format(time.RFC3339)
+
+		chat[message.Channel] = append(chat[message.Channel], []string{at, message.UserID, message.Username, message.DisplayName, message.Text})
+
+		if reward := message.Tags["custom-reward-id"]; reward != "" {
+			redemptions[message.Channel] = append(redemptions[message.Channel], []string{at, message.UserID, message.Username, reward, message.Text})
+		}
+	}
+
+	bot.flushMetrics()
+	commands := map[string][][]string{}
+	bot.metrics.mutex.Lock()
+	for _, live := range bot.metrics.days {
+		names := make([]string, 0, len(live.stats.Commands))
+		for name := range live.stats.Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			commands[live.stats.Channel] = append(commands[live.stats.Channel], []string{live.stats.Date, name, strconv.Itoa(live.stats.Commands[name])})
+		}
+	}
+	bot.metrics.mutex.Unlock()
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	sink := bot.exportSink()
+	exports := []struct {
+		kind   string
+		header []string
+		rows   map[string][][]string
+	}{
+		{"chat", []string{"time", "user_id", "user", "display_name", "message"}, chat},
+		{"redemptions", []string{"time", "user_id", "user", "reward_id", "message"}, redemptions},
+		{"commands", []string{"date", "command", "uses"}, commands},
+	}
+
+	files := 0
+	for _, export := range exports {
+		for channel, rows := range export.rows {
+			data, err := csvFile(export.header, rows)
+			if err != nil {
+				return errors.New("Bot.ExportData: " + err.Error())
+			}
+
+			name := fmt.Sprintf("%s-%s-%s.csv", export.kind, channel, stamp)
+			err = sink.Save(name, data)
+			if err != nil {
+				return errors.New("Bot.ExportData: " + err.Error())
+			}
+			files++
+		}
+	}
+
+	if bot.DataExportBackups {
+		err := bot.BackupStore(sink, stamp)
+		if err != nil {
+			return errors.New("Bot.ExportData: " + err.Error())
+		}
+		files++
+	}
+
+	if files > 0 {
+		logger.Success("Exported %d file(s) with %d chat line(s)", files, len(lines))
+	}
+
+	return nil
+}
+
+// BackupStore saves the whole Store as backup-<stamp>.json, if the Store can take snapshots
+func (bot *Bot) BackupStore(sink ExportSink, stamp string) error {
+	snapshotter, ok := bot.Store.(interface{ Snapshot() ([]byte, error) })
+	if !ok {
+		return errors.New("Bot.BackupStore: the store can't be backed up")
+	}
+
+	data, err := snapshotter.Snapshot()
+	if err != nil {
+		return errors.New("Bot.BackupStore: " + err.Error())
+	}
+
+	err = sink.Save(fmt.Sprintf("backup-%s.json", stamp), data)
+	if err != nil {
+		return errors.New("Bot.BackupStore: " + err.Error())
+	}
+
+	return nil
+}
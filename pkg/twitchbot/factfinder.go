@@ -1,22 +1,43 @@
 package twitchbot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
 )
 
+// Facts to fall back on when api.chucknorris.io can't be reached
+var fallbackChuckFacts = []string{
+	"Chuck Norris can unscramble eggs.",
+	"Chuck Norris counted to infinity. Twice.",
+	"Chuck Norris doesn't read books. He stares them down until he gets the information he wants.",
+	"When Chuck Norris does a pushup, he isn't lifting himself up, he's pushing the Earth down.",
+}
+
 type chuckFact struct {
 	Value string `json:"value,omitempty"`
 }
 
 // FetchChuckFact requests a "joke" from api.chucknorris.io
 func FetchChuckFact() (string, error) {
-	client := http.Client{
-		Timeout: 5 * time.Second,
+	return FetchChuckFactContext(context.Background())
+}
+
+// FetchChuckFactContext is FetchChuckFact with a context that can cancel the request. It
+// connects directly; a Bot fetches facts through its Proxy.
+func FetchChuckFactContext(ctx context.Context) (string, error) {
+	return fetchChuckFact(ctx, &http.Client{Timeout: 5 * time.Second})
+}
+
+func fetchChuckFact(ctx context.Context, client *http.Client) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.chucknorris.io/jokes/random", nil)
+	if err != nil {
+		return "", errors.New("FetchChuckFact: " + err.Error())
 	}
-	resp, err := client.Get("https://api.chucknorris.io/jokes/random")
+
+	resp, err := client.Do(request)
 	if err != nil {
 		return "", errors.New("FetchChuckFact: " + err.Error())
 	}
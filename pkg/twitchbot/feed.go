@@ -0,0 +1,217 @@
+package twitchbot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+	"github.com/mike1104/chuckbot/pkg/websocket"
+)
+
+// Kinds of events on the admin feed
+const (
+	FeedChat        = "chat"
+	FeedCommands    = "commands"
+	FeedFilters     = "filters"
+	FeedModeration  = "moderation"
+	FeedRaids       = "raids"
+	FeedSubs        = "subs"
+	FeedFollows     = "follows"
+	FeedRedemptions = "redemptions"
+	FeedBits        = "bits"
+	FeedStreams     = "streams"
+	FeedConnection  = "connection"
+)
+
+const (
+	// Events a slow subscriber can fall behind by before new ones are dropped for it
+	feedBufferLength = 256
+
+	// Keeps a quiet feed from being closed by proxies
+	feedPingInterval = 30 * time.Second
+)
+
+// FeedEvent is something that happened, as sent on /events
+type FeedEvent struct {
+	Kind    string    `json:"kind"`
+	Channel string    `json:"channel,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Text    string    `json:"text"`
+	At      time.Time `json:"at"`
+}
+
+// Which events a subscriber wants. Empty fields match everything.
+type feedFilter struct {
+	channel string
+	kinds   map[string]bool
+}
+
+func (filter feedFilter) matches(event FeedEvent) bool {
+	if filter.channel != "" && event.Channel != "" && filter.channel != event.Channel {
+		return false
+	}
+
+	return len(filter.kinds) == 0 || filter.kinds[event.Kind]
+}
+
+// Fans events out to everyone watching /events
+type eventFeed struct {
+	mutex       sync.Mutex
+	subscribers map[chan FeedEvent]feedFilter
+}
+
+// Sends an event to the feed's subscribers, dropping it for any who are behind
+func (bot *Bot) publish(kind, channel, user, text string) {
+	bot.feed.mutex.Lock()
+	defer bot.feed.mutex.Unlock()
+
+	if len(bot.feed.subscribers) == 0 {
+		return
+	}
+
+	event := FeedEvent{Kind: kind, Channel: channel, User: user, Text: text, At: time.Now()}
+	for events, filter := range bot.feed.subscribers {
+		if !filter.matches(event) {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+func (bot *Bot) subscribe(filter feedFilter) (chan FeedEvent, func()) {
+	events := make(chan FeedEvent, feedBufferLength)
+
+	bot.feed.mutex.Lock()
+	if bot.feed.subscribers == nil {
+		bot.feed.subscribers = map[chan FeedEvent]feedFilter{}
+	}
+	bot.feed.subscribers[events] = filter
+	bot.feed.mutex.Unlock()
+
+	return events, func() {
+		bot.feed.mutex.Lock()
+		delete(bot.feed.subscribers, events)
+		bot.feed.mutex.Unlock()
+	}
+}
+
+// Puts a timeout or ban from CLEARCHAT on the feed
+func (bot *Bot) publishModeration(ircMessage *irc.Message) {
+	login := ircMessage.Trailing
+	if login == "" {
+		bot.publish(FeedModeration, ircMessage.Channel(), "", "chat was cleared")
+		return
+	}
+
+	text := "banned"
+	if duration, ok := ircMessage.Tags["ban-duration"]; ok {
+		seconds, _ := strconv.Atoi(duration)
+		text = fmt.Sprintf("timed out for %s", time.Duration(seconds)*time.Second)
+	}
+	bot.publish(FeedModeration, ircMessage.Channel(), login, text)
+}
+
+// Streams events over a WebSocket for `chuckbot tail`: /events?channel=name&events=commands,filters.
+// AdminToken has to be set and sent as a bearer token.
+func (bot *Bot) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if !bot.authorizedAdmin(r) {
+		bot.refuseUnauthorized(w)
+		return
+	}
+
+	filter := feedFilter{channel: strings.ToLower(strings.TrimPrefix(r.FormValue("channel"), "#"))}
+	if kinds := r.FormValue("events"); kinds != "" {
+		filter.kinds = map[string]bool{}
+		for _, kind := range strings.Split(kinds, ",") {
+			filter.kinds[strings.ToLower(strings.TrimSpace(kind))] = true
+		}
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		logger.Warn("Bot.serveEvents: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := bot.subscribe(filter)
+	defer unsubscribe()
+
+	logger.Info("Streaming events to %s", r.RemoteAddr)
+
+	// Reading answers pings and notices when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(feedPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err == nil {
+				err = conn.WriteText(data)
+			}
+			if err != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.Ping() != nil {
+				return
+			}
+		case <-closed:
+			logger.Info("Stopped streaming events to %s", r.RemoteAddr)
+			return
+		case <-bot.stopping:
+			return
+		}
+	}
+}
+
+// Whether a request carries AdminToken. Nothing is authorized while there's no AdminToken, so
+// endpoints behind it stay off until one is set.
+func (bot *Bot) authorizedAdmin(r *http.Request) bool {
+	if bot.AdminToken == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(bot.AdminToken)) == 1
+}
+
+// Whether a request may use an endpoint that anyone can read until AdminToken is set. Changes
+// always need AdminToken.
+func (bot *Bot) authorizedRequest(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return bot.AdminToken == "" || bot.authorizedAdmin(r)
+	}
+
+	return bot.authorizedAdmin(r)
+}
+
+// Answers a request authorizedAdmin turned down
+func (bot *Bot) refuseUnauthorized(w http.ResponseWriter) {
+	if bot.AdminToken == "" {
+		http.Error(w, "set admin_token to use this", http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
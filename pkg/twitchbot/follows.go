@@ -0,0 +1,83 @@
+package twitchbot
+
+import (
+	"time"
+)
+
+const (
+	followGreetingsBucket = "follow_greetings"
+
+	// How long someone who unfollows and follows again isn't greeted a second time
+	defaultFollowGreetingWindow = 24 * time.Hour
+)
+
+// When a follower was last greeted in a channel
+type followGreeting struct {
+	UserID    string    `json:"user_id"`
+	GreetedAt time.Time `json:"greeted_at"`
+}
+
+func followGreetingKey(channel, userID string) string {
+	return channel + "/" + userID
+}
+
+// Whether some channel greets its followers, so follows are needed from EventSub
+func (bot *Bot) greetsFollowers() bool {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if bot.FollowGreeting != "" {
+		return true
+	}
+	for _, settings := range bot.ChannelSettings {
+		if settings.FollowGreeting != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bot *Bot) followGreetingWindow() time.Duration {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if bot.FollowGreetingWindow <= 0 {
+		return defaultFollowGreetingWindow
+	}
+
+	return bot.FollowGreetingWindow
+}
+
+// Greets a new follower with FollowGreeting, unless they were greeted within
+// FollowGreetingWindow or chat is being flooded
+func (bot *Bot) greetFollower(message *Message) {
+	greeting := bot.channelResponse(message.Channel, func(settings ChannelSettings) string { return settings.FollowGreeting }, &bot.FollowGreeting)
+	if greeting == "" || bot.Anonymous || !bot.claimMessage(message) {
+		return
+	}
+
+	key := followGreetingKey(message.Channel, message.UserID)
+	previous := followGreeting{}
+	found, err := bot.Store.Get(followGreetingsBucket, key, &previous)
+	if err != nil {
+		logger.Warn("Bot.greetFollower: %s", err.Error())
+		return
+	}
+	if found && time.Since(previous.GreetedAt) < bot.followGreetingWindow() {
+		logger.Quiet("Not greeting @%s in #%s again, they followed %s ago", message.Username, message.Channel, time.Since(previous.GreetedAt).Round(time.Minute))
+		return
+	}
+
+	if bot.GreetingsPaused(message.Channel) {
+		logger.Quiet("Not greeting follower @%s in #%s while chat is busy", message.Username, message.Channel)
+		return
+	}
+
+	err = bot.Store.Put(followGreetingsBucket, key, followGreeting{UserID: message.UserID, GreetedAt: time.Now()})
+	if err != nil {
+		logger.Warn("Bot.greetFollower: %s", err.Error())
+	}
+
+	bot.ChatWithPriority(message.Channel, bot.renderTemplate(greeting, &Command{Message: message}), PriorityTimer)
+}
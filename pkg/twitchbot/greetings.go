@@ -0,0 +1,141 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+const (
+	// How long greetings stay paused after a raid
+	defaultRaidQuietPeriod = 2 * time.Minute
+
+	// This many first time chatters within greetingFloodWindow looks like a raid or follow bots
+	defaultGreetingFloodThreshold = 5
+	greetingFloodWindow           = 30 * time.Second
+)
+
+type channelFlood struct {
+	raidUntil   time.Time
+	newChatters []time.Time
+	paused      bool
+}
+
+type floodWatch struct {
+	mutex    sync.Mutex
+	channels map[string]*channelFlood
+}
+
+func (watch *floodWatch) channel(channel string) *channelFlood {
+	if watch.channels == nil {
+		watch.channels = map[string]*channelFlood{}
+	}
+
+	flood, ok := watch.channels[channel]
+	if !ok {
+		flood = &channelFlood{}
+		watch.channels[channel] = flood
+	}
+
+	return flood
+}
+
+// GreetingsPaused reports whether a channel is being raided or flooded with new chatters.
+// Greetings, milestones and other messages that would add to the noise should wait while it is.
+func (bot *Bot) GreetingsPaused(channel string) bool {
+	bot.floods.mutex.Lock()
+	defer bot.floods.mutex.Unlock()
+
+	return bot.greetingsPaused(channel, time.Now())
+}
+
+// Called with floods.mutex held
+func (bot *Bot) greetingsPaused(channel string, now time.Time) bool {
+	flood := bot.floods.channel(channel)
+
+	recent := flood.newChatters[:0]
+	for _, seen := range flood.newChatters {
+		if now.Sub(seen) < greetingFloodWindow {
+			recent = append(recent, seen)
+		}
+	}
+	flood.newChatters = recent
+
+	threshold := bot.GreetingFloodThreshold
+	if threshold == 0 {
+		threshold = defaultGreetingFloodThreshold
+	}
+
+	paused := now.Before(flood.raidUntil) || len(flood.newChatters) >= threshold
+	if paused != flood.paused {
+		if paused {
+			logger.Notice("Chat is busy in #%s, pausing greetings", channel)
+		} else {
+			logger.Notice("Chat has calmed down in #%s, resuming greetings", channel)
+		}
+		flood.paused = paused
+	}
+
+	return paused
+}
+
+// Pauses greetings for RaidQuietPeriod when a raid comes in
+func (bot *Bot) noteRaid(ircMessage *irc.Message) {
+	channel := ircMessage.Channel()
+	viewers, _ := strconv.Atoi(ircMessage.Tags["msg-param-viewerCount"])
+	logger.Event(EventRaid, printpretty.NOTICE, "%s is raiding #%s with %d viewers", ircMessage.Tags["msg-param-displayName"], channel, viewers)
+	bot.publish(FeedRaids, channel, ircMessage.Tags["msg-param-login"], fmt.Sprintf("raiding with %d viewers", viewers))
+
+	quiet := bot.RaidQuietPeriod
+	if quiet == 0 {
+		quiet = defaultRaidQuietPeriod
+	}
+
+	bot.floods.mutex.Lock()
+	defer bot.floods.mutex.Unlock()
+
+	bot.floods.channel(channel).raidUntil = time.Now().Add(quiet)
+	bot.greetingsPaused(channel, time.Now())
+}
+
+// Greets people chatting in a channel for the first time, unless chat is being flooded
+func (bot *Bot) greetFirstChatter(message *Message) {
+	if message.Tags["first-msg"] != "1" {
+		return
+	}
+
+	bot.floods.mutex.Lock()
+	now := time.Now()
+	flood := bot.floods.channel(message.Channel)
+	flood.newChatters = append(flood.newChatters, now)
+	paused := bot.greetingsPaused(message.Channel, now)
+	bot.floods.mutex.Unlock()
+
+	greeting := bot.channelResponse(message.Channel, func(settings ChannelSettings) string { return settings.FirstChatterGreeting }, &bot.FirstChatterGreeting)
+	if greeting == "" {
+		return
+	}
+
+	if paused {
+		logger.Quiet("Not greeting @%s in #%s while chat is busy", message.Username, message.Channel)
+		return
+	}
+
+	bot.ChatWithPriority(message.Channel, bot.renderTemplate(greeting, &Command{Message: message}), PriorityTimer)
+}
+
+func (bot *Bot) handleUserNotice(ircMessage *irc.Message) {
+	switch ircMessage.Tags["msg-id"] {
+	case "raid":
+		bot.noteRaid(ircMessage)
+		bot.shoutoutRaider(ircMessage)
+	case "sub", "resub", "subgift", "anonsubgift", "submysterygift", "anonsubmysterygift":
+		logger.Event(EventSub, printpretty.NOTICE, "#%s: %s", ircMessage.Channel(), ircMessage.Tags["system-msg"])
+		bot.publish(FeedSubs, ircMessage.Channel(), ircMessage.Tags["login"], ircMessage.Tags["system-msg"])
+		bot.thankSubscriber(ircMessage)
+	}
+}
@@ -0,0 +1,87 @@
+package twitchbot
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Serves liveness and readiness probes for orchestrators like Kubernetes on HealthAddress,
+// along with the endpoints for dashboards and stream overlays
+func (bot *Bot) startHealthServer() {
+	if bot.HealthAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+
+	// Answers as long as the process is running
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	// Only answers once Twitch has confirmed the Bot joined all its channels
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !bot.isJoined() {
+			http.Error(w, "not joined", http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/metrics", bot.serveChannelStats)
+	mux.HandleFunc("/metrics/outbox", bot.serveOutboxStats)
+	mux.HandleFunc("/metrics/logging", serveLoggingStats)
+	mux.HandleFunc("/raffles", bot.serveRaffleReceipt)
+	mux.HandleFunc("/stats", bot.serveDailyStats)
+	mux.HandleFunc("/users", bot.serveUserReport)
+	mux.HandleFunc("/appeals", bot.serveAppeals)
+	mux.HandleFunc("/templates", bot.serveChannelTemplate)
+	mux.HandleFunc("/variables", bot.serveChannelVariables)
+	mux.HandleFunc("/events", bot.serveEvents)
+	mux.HandleFunc("/overlay/highlight", bot.serveHighlight)
+	mux.HandleFunc("/overlay/questions", bot.serveQuestions)
+	mux.HandleFunc("/overlay/leaderboard", bot.serveLeaderboard)
+	mux.HandleFunc("/overlay/bits", bot.serveBitsLeaderboard)
+
+	go func() {
+		logger.Info("Serving health checks on %s", bot.HealthAddress)
+		err := http.ListenAndServe(bot.HealthAddress, mux)
+		if err != nil {
+			logger.Error("Bot.startHealthServer: %s", err.Error())
+		}
+	}()
+}
+
+// Reports whether Twitch has confirmed every channel the Bot should be in
+func (bot *Bot) isJoined() bool {
+	bot.joinedMutex.Lock()
+	defer bot.joinedMutex.Unlock()
+
+	for _, channel := range bot.channels() {
+		if !bot.joinedChannels[channel] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (bot *Bot) setJoined(channel string, joined bool) {
+	bot.joinedMutex.Lock()
+	defer bot.joinedMutex.Unlock()
+
+	if bot.joinedChannels == nil {
+		bot.joinedChannels = map[string]bool{}
+	}
+
+	bot.joinedChannels[channel] = joined
+}
+
+// Forgets every join, e.g. when the connection drops
+func (bot *Bot) clearJoined() {
+	bot.joinedMutex.Lock()
+	defer bot.joinedMutex.Unlock()
+
+	bot.joinedChannels = map[string]bool{}
+}
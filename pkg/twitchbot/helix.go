@@ -0,0 +1,75 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// The bot's own user-id, which Twitch sends in GLOBALUSERSTATE after logging in, or else
+// looked up from Helix by BotName
+func (bot *Bot) botUserID() (string, error) {
+	bot.secretsMutex.Lock()
+	id := bot.selfUserID
+	bot.secretsMutex.Unlock()
+	if id != "" {
+		return id, nil
+	}
+
+	id, found, err := bot.lookupUserID(bot.BotName)
+	if err != nil {
+		return "", errors.New("Bot.botUserID: " + err.Error())
+	}
+	if !found {
+		return "", fmt.Errorf("Bot.botUserID: no Twitch account named %q", bot.BotName)
+	}
+
+	bot.setBotUserID(id)
+
+	return id, nil
+}
+
+// The broadcaster's user-id for a channel, from ROOMSTATE or else looked up from Helix
+func (bot *Bot) channelUserID(channel string) (string, error) {
+	if id := bot.RoomState(channel).RoomID; id != "" {
+		return id, nil
+	}
+
+	id, found, err := bot.lookupUserID(channel)
+	if err != nil {
+		return "", errors.New("Bot.channelUserID: " + err.Error())
+	}
+	if !found {
+		return "", fmt.Errorf("Bot.channelUserID: no Twitch account named %q", channel)
+	}
+
+	return id, nil
+}
+
+func (bot *Bot) setBotUserID(id string) {
+	if id == "" {
+		return
+	}
+
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	bot.selfUserID = id
+}
+
+// A Helix client authenticated as the bot with ClientID and its chat token
+func (bot *Bot) helix() *helix.Client {
+	return &helix.Client{
+		ClientID: bot.ClientID,
+		Token: func() string {
+			bot.secretsMutex.Lock()
+			defer bot.secretsMutex.Unlock()
+
+			return strings.TrimPrefix(bot.oAuthToken, "oauth:")
+		},
+		HTTPClient: bot.httpClient(10 * time.Second),
+	}
+}
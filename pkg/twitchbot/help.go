@@ -0,0 +1,108 @@
+package twitchbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Twitch drops chat messages longer than this
+const maxChatMessageLength = 500
+
+// WithDescription sets the short description shown by !help
+func WithDescription(description string) CommandOption {
+	return func(command *RegisteredCommand) {
+		command.Description = description
+	}
+}
+
+// All lists the registered commands sorted by name
+func (registry *CommandRegistry) All() []*RegisteredCommand {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	commands := make([]*RegisteredCommand, 0, len(registry.commands))
+	for _, command := range registry.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	return commands
+}
+
+// Packs items into as few chat messages as possible, starting the first with intro
+func packMessages(intro, separator string, items []string) []string {
+	messages := []string{}
+	current := intro
+	empty := true
+
+	for _, item := range items {
+		if !empty && len(current)+len(separator)+len(item) > maxChatMessageLength {
+			messages = append(messages, current)
+			current = ""
+			empty = true
+		}
+
+		if !empty {
+			current += separator
+		}
+		current += item
+		empty = false
+	}
+
+	if !empty {
+		messages = append(messages, current)
+	}
+
+	return messages
+}
+
+// !help [command] lists the commands the user may run, or describes one of them
+func helpCommand(bot *Bot, command *Command) {
+	prefix := command.Prefix
+
+	if len(command.Args) > 0 {
+		name := strings.TrimPrefix(command.Args[0], prefix)
+		registered, ok := bot.resolveCommand(command.Channel, name)
+		if !ok {
+			if custom, found := bot.CustomCommand(command.Channel, name); found && command.Can(custom.Permission) {
+				bot.Reply(command.Message, fmt.Sprintf("%s%s is a custom command.", prefix, custom.Name))
+				return
+			}
+		}
+
+		if !ok || !command.Can(registered.Permission) {
+			bot.Reply(command.Message, fmt.Sprintf("There is no %s%s command.", prefix, name))
+			return
+		}
+
+		description := registered.Description
+		if description == "" {
+			description = "No description."
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("%s%s: %s", prefix, registered.Name, description))
+		return
+	}
+
+	names := []string{}
+	for _, registered := range bot.channelCommands(command.Channel) {
+		if command.Can(registered.Permission) {
+			names = append(names, prefix+registered.Name)
+		}
+	}
+
+	customs, err := bot.CustomCommands(command.Channel)
+	if err != nil {
+		logger.Warn(err.Error())
+	}
+	for _, custom := range customs {
+		if command.Can(custom.Permission) {
+			names = append(names, prefix+custom.Name)
+		}
+	}
+
+	for _, message := range packMessages("Commands: ", ", ", names) {
+		bot.Reply(command.Message, message)
+	}
+}
@@ -0,0 +1,220 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Highlights waiting beyond this per channel push out the oldest
+const maxPendingHighlights = 50
+
+// Highlight is a message a viewer paid channel points to highlight, waiting on a moderator
+type Highlight struct {
+	ID         string    `json:"id"`
+	Channel    string    `json:"channel"`
+	Username   string    `json:"username"`
+	Text       string    `json:"text"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type highlightQueue struct {
+	mutex   sync.Mutex
+	pending map[string][]*Highlight
+
+	// The most recently approved highlight per channel, shown on the overlay
+	showing map[string]*Highlight
+}
+
+// Reports whether a chat message came from a "highlight my message" style redemption
+func (bot *Bot) isHighlightRedemption(message *Message) bool {
+	if message.Tags["msg-id"] == "highlighted-message" {
+		return true
+	}
+
+	rewardID := message.Tags["custom-reward-id"]
+	if rewardID == "" {
+		return false
+	}
+
+	for _, id := range bot.HighlightRewardIDs {
+		if id == rewardID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bot *Bot) queueHighlight(message *Message) {
+	highlight := &Highlight{
+		ID:         message.MessageID,
+		Channel:    message.Channel,
+		Username:   message.Username,
+		Text:       message.Text,
+		ReceivedAt: time.Now(),
+	}
+	if highlight.ID == "" {
+		highlight.ID = fmt.Sprintf("%d", highlight.ReceivedAt.UnixNano())
+	}
+
+	queue := &bot.highlights
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if queue.pending == nil {
+		queue.pending = map[string][]*Highlight{}
+		queue.showing = map[string]*Highlight{}
+	}
+
+	pending := append(queue.pending[message.Channel], highlight)
+	if len(pending) > maxPendingHighlights {
+		pending = pending[len(pending)-maxPendingHighlights:]
+	}
+	queue.pending[message.Channel] = pending
+
+	logger.Notice("Highlight from @%s queued for approval in #%s: %s", highlight.Username, highlight.Channel, highlight.Text)
+}
+
+// Takes a highlight off the channel's queue. An empty id takes the oldest.
+func (queue *highlightQueue) take(channel, id string) *Highlight {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	pending := queue.pending[channel]
+	for i, highlight := range pending {
+		if id == "" || highlight.ID == id {
+			queue.pending[channel] = append(pending[:i:i], pending[i+1:]...)
+			return highlight
+		}
+	}
+
+	return nil
+}
+
+// PendingHighlights lists the highlights in a channel waiting on a moderator, oldest first
+func (bot *Bot) PendingHighlights(channel string) []Highlight {
+	queue := &bot.highlights
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	highlights := make([]Highlight, 0, len(queue.pending[channel]))
+	for _, highlight := range queue.pending[channel] {
+		highlights = append(highlights, *highlight)
+	}
+
+	return highlights
+}
+
+// ApproveHighlight puts a pending highlight on the channel's overlay. An empty id approves the oldest.
+func (bot *Bot) ApproveHighlight(channel, id string) (*Highlight, bool) {
+	highlight := bot.highlights.take(channel, id)
+	if highlight == nil {
+		return nil, false
+	}
+
+	bot.highlights.mutex.Lock()
+	bot.highlights.showing[channel] = highlight
+	bot.highlights.mutex.Unlock()
+
+	logger.Success("Highlight from @%s approved in #%s", highlight.Username, channel)
+	return highlight, true
+}
+
+// DismissHighlight drops a pending highlight. An empty id dismisses the oldest.
+func (bot *Bot) DismissHighlight(channel, id string) (*Highlight, bool) {
+	highlight := bot.highlights.take(channel, id)
+	if highlight == nil {
+		return nil, false
+	}
+
+	logger.Info("Highlight from @%s dismissed in #%s", highlight.Username, channel)
+	return highlight, true
+}
+
+// !highlights shows what is waiting
+func highlightsCommand(bot *Bot, command *Command) {
+	pending := bot.PendingHighlights(command.Channel)
+	if len(pending) == 0 {
+		bot.Reply(command.Message, "No highlights waiting.")
+		return
+	}
+
+	next := pending[0]
+	bot.Reply(command.Message, fmt.Sprintf("%d highlight(s) waiting. Next from @%s: %s", len(pending), next.Username, next.Text))
+}
+
+// !approve [id] | !dismiss [id]
+func highlightDecisionCommand(approve bool) CommandHandler {
+	return func(bot *Bot, command *Command) {
+		id := ""
+		if len(command.Args) > 0 {
+			id = command.Args[0]
+		}
+
+		var highlight *Highlight
+		var ok bool
+		if approve {
+			highlight, ok = bot.ApproveHighlight(command.Channel, id)
+		} else {
+			highlight, ok = bot.DismissHighlight(command.Channel, id)
+		}
+
+		if !ok {
+			bot.Reply(command.Message, "There is no highlight waiting with that ID.")
+			return
+		}
+
+		if approve {
+			bot.Reply(command.Message, fmt.Sprintf("Highlighting @%s's message.", highlight.Username))
+		}
+	}
+}
+
+// Serves the highlight currently on a channel's overlay, e.g. /overlay/highlight?channel=mikkeever.
+// POST with action=approve or action=dismiss and an optional id to moderate from a dashboard,
+// which needs AdminToken. Overlays can GET without it.
+func (bot *Bot) serveHighlight(w http.ResponseWriter, r *http.Request) {
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !bot.authorizedAdmin(r) {
+			bot.refuseUnauthorized(w)
+			return
+		}
+
+		var ok bool
+		switch r.FormValue("action") {
+		case "approve":
+			_, ok = bot.ApproveHighlight(channel, r.FormValue("id"))
+		case "dismiss":
+			_, ok = bot.DismissHighlight(channel, r.FormValue("id"))
+		default:
+			http.Error(w, "action must be approve or dismiss", http.StatusBadRequest)
+			return
+		}
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	bot.highlights.mutex.Lock()
+	response := struct {
+		Showing *Highlight  `json:"showing"`
+		Pending []Highlight `json:"pending"`
+	}{Showing: bot.highlights.showing[channel]}
+	bot.highlights.mutex.Unlock()
+	response.Pending = bot.PendingHighlights(channel)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
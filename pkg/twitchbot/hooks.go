@@ -0,0 +1,186 @@
+package twitchbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle points hooks can run at
+const (
+	HookConnected    = "connected"
+	HookJoined       = "joined"
+	HookAuthFailed   = "auth_failed"
+	HookShuttingDown = "shutting_down"
+)
+
+var hookEvents = []string{HookConnected, HookJoined, HookAuthFailed, HookShuttingDown}
+
+// How long a hook may run when it doesn't say
+const defaultHookTimeout = 10 * time.Second
+
+// Hook is a shell command or webhook run at a lifecycle point. Commands get CHUCKBOT_EVENT,
+// CHUCKBOT_BOT and CHUCKBOT_CHANNEL in their environment, and webhooks are POSTed the same as
+// JSON.
+type Hook struct {
+	Command string   `json:"command"`
+	Webhook string   `json:"webhook"`
+	Timeout Duration `json:"timeout"`
+}
+
+// What a webhook hook is sent
+type hookPayload struct {
+	Event   string    `json:"event"`
+	Bot     string    `json:"bot"`
+	Channel string    `json:"channel,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Checks a config's hooks for unknown events and hooks that don't say what to run
+func validateHooks(hooks map[string][]Hook) error {
+	for event, list := range hooks {
+		known := false
+		for _, name := range hookEvents {
+			if strings.EqualFold(event, name) {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("hooks.%s: unknown event, use one of %s", event, strings.Join(hookEvents, ", "))
+		}
+
+		for i, hook := range list {
+			if (hook.Command == "") == (hook.Webhook == "") {
+				return fmt.Errorf("hooks.%s[%d]: set command or webhook", event, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Runs the hooks for a lifecycle point side by side. For auth_failed and shutting_down this
+// returns once they're all done, the others run in the background.
+func (bot *Bot) runHooks(event, channel string) {
+	bot.settingsMutex.RLock()
+	hooks := []Hook{}
+	for name, list := range bot.Hooks {
+		if strings.EqualFold(name, event) {
+			hooks = append(hooks, list...)
+		}
+	}
+	bot.settingsMutex.RUnlock()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload := hookPayload{Event: event, Bot: bot.BotName, Channel: channel, At: time.Now()}
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook Hook) {
+			defer wg.Done()
+			bot.runHook(hook, payload)
+		}(hook)
+	}
+
+	if event == HookAuthFailed || event == HookShuttingDown {
+		wg.Wait()
+	}
+}
+
+func (bot *Bot) runHook(hook Hook, payload hookPayload) {
+	timeout := time.Duration(hook.Timeout)
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	// Not the Bot's context, which is already cancelled while shutting down
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	if hook.Command != "" {
+		err = runHookCommand(ctx, hook.Command, payload)
+	} else {
+		err = bot.postHook(ctx, hook.Webhook, payload)
+	}
+	if err != nil {
+		logger.Warn("Bot.runHook: %s hook failed: %s", payload.Event, err.Error())
+		return
+	}
+
+	logger.Debug("Ran a %s hook", payload.Event)
+}
+
+// Runs a command through the shell, logging what it printed
+func runHookCommand(ctx context.Context, command string, payload hookPayload) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(hookEnvironment(),
+		"CHUCKBOT_EVENT="+payload.Event,
+		"CHUCKBOT_BOT="+payload.Bot,
+		"CHUCKBOT_CHANNEL="+payload.Channel,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if text := strings.TrimSpace(string(output)); text != "" {
+		logger.Debug("%s hook: %s", payload.Event, text)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// The Bot's environment without its CHUCKBOT_ variables, which hold settings like the token,
+// the admin token and the secrets passphrase that a hook has no business seeing
+func hookEnvironment() []string {
+	environment := []string{}
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(strings.ToUpper(variable), "CHUCKBOT_") {
+			environment = append(environment, variable)
+		}
+	}
+
+	return environment
+}
+
+func (bot *Bot) postHook(ctx context.Context, webhook string, payload hookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := bot.httpClient(0).Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", webhook, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package twitchbot
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Twitch pings about every five minutes, so a quiet minute is worth checking on
+	defaultKeepaliveInterval = time.Minute
+
+	defaultKeepaliveTimeout = 20 * time.Second
+)
+
+// When the last line arrived on a connection, in Unix nanoseconds
+type connectionActivity struct {
+	lastRead int64
+}
+
+func (activity *connectionActivity) touch() {
+	atomic.StoreInt64(&activity.lastRead, time.Now().UnixNano())
+}
+
+func (activity *connectionActivity) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&activity.lastRead)))
+}
+
+// Pings Twitch once the connection has been quiet for KeepaliveInterval, and closes it if nothing
+// comes back within KeepaliveTimeout. A TCP connection that silently died would otherwise leave
+// the read blocked forever; closing it makes the read fail so the Bot reconnects.
+func (bot *Bot) keepAlive(conn Conn, activity *connectionActivity, done chan struct{}) {
+	interval := bot.KeepaliveInterval
+	if interval <= 0 {
+		interval = defaultKeepaliveInterval
+	}
+	timeout := bot.KeepaliveTimeout
+	if timeout <= 0 {
+		timeout = defaultKeepaliveTimeout
+	}
+
+	check := timeout / 4
+	if check > time.Second {
+		check = time.Second
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	pinged := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		idle := activity.idle()
+		switch {
+		case idle < interval:
+			pinged = false
+		case !pinged:
+			bot.writeToTwitch("PING", ":tmi.twitch.tv")
+			pinged = true
+		case idle >= interval+timeout:
+			logger.Warn("Bot.keepAlive: nothing from %s for %s, reconnecting", bot.Server, idle.Round(time.Second))
+			conn.Close()
+			return
+		}
+	}
+}
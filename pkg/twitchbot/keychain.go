@@ -0,0 +1,221 @@
+package twitchbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultKeychainService is the service KeychainSecrets are stored under
+const DefaultKeychainService = "chuckbot"
+
+// KeychainSecrets keeps the Secrets in the OS keychain instead of a plaintext file: the macOS
+// Keychain through the security tool, Windows Credential Manager, or the Secret Service (GNOME
+// Keyring, KWallet) through libsecret's secret-tool elsewhere. The secrets are stored as one
+// JSON item.
+type KeychainSecrets struct {
+	// Defaults to DefaultKeychainService
+	Service string
+
+	// Usually the bot's login, so several bots can share a keychain
+	Account string
+
+	// Serializes SaveToken's read, change and write
+	mutex sync.Mutex
+}
+
+// A keychain that stores one secret per service and account
+type keychainBackend interface {
+	get(service, account string) (string, bool, error)
+	set(service, account, secret string) error
+}
+
+// Set on Windows, which has no command to read Credential Manager with
+var windowsKeychain keychainBackend
+
+// Secrets reads the item from the keychain
+func (keychain *KeychainSecrets) Secrets() (*Secrets, error) {
+	backend, err := keychainForOS()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, found, err := backend.get(keychain.service(), keychain.Account)
+	if err != nil {
+		return nil, errors.New("KeychainSecrets: " + err.Error())
+	}
+	if !found {
+		return nil, fmt.Errorf("KeychainSecrets: nothing stored for %s in %s", keychain.Account, keychain.service())
+	}
+
+	stored := &Secrets{}
+	err = json.Unmarshal([]byte(secret), stored)
+	if err != nil {
+		return nil, errors.New("KeychainSecrets: " + err.Error())
+	}
+
+	return stored, nil
+}
+
+// SaveToken writes the tokens into the item, keeping the rest of its secrets
+func (keychain *KeychainSecrets) SaveToken(accessToken, refreshToken string) error {
+	return keychain.Update(func(stored *Secrets) {
+		stored.OAuthToken = accessToken
+		stored.RefreshToken = refreshToken
+	})
+}
+
+// Update changes the stored secrets, creating the item if there is none
+func (keychain *KeychainSecrets) Update(change func(*Secrets)) error {
+	keychain.mutex.Lock()
+	defer keychain.mutex.Unlock()
+
+	backend, err := keychainForOS()
+	if err != nil {
+		return err
+	}
+
+	stored := &Secrets{}
+	secret, found, err := backend.get(keychain.service(), keychain.Account)
+	if err != nil {
+		return errors.New("KeychainSecrets: " + err.Error())
+	}
+	if found {
+		err = json.Unmarshal([]byte(secret), stored)
+		if err != nil {
+			return errors.New("KeychainSecrets: " + err.Error())
+		}
+	}
+
+	change(stored)
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return errors.New("KeychainSecrets: " + err.Error())
+	}
+
+	err = backend.set(keychain.service(), keychain.Account, string(data))
+	if err != nil {
+		return errors.New("KeychainSecrets: " + err.Error())
+	}
+
+	return nil
+}
+
+func (keychain *KeychainSecrets) service() string {
+	if keychain.Service == "" {
+		return DefaultKeychainService
+	}
+
+	return keychain.Service
+}
+
+func keychainForOS() (keychainBackend, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return macKeychain{}, nil
+	case "windows":
+		if windowsKeychain != nil {
+			return windowsKeychain, nil
+		}
+	default:
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return secretTool{}, nil
+		}
+		return nil, errors.New("KeychainSecrets: secret-tool not found, install libsecret-tools")
+	}
+
+	return nil, fmt.Errorf("KeychainSecrets: no keychain on %s", runtime.GOOS)
+}
+
+// The macOS Keychain, through the security tool
+type macKeychain struct{}
+
+// security exits with 44 when the item doesn't exist
+const macItemNotFound = 44
+
+func (macKeychain) get(service, account string) (string, bool, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == macItemNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, commandError("security", err)
+	}
+
+	return strings.TrimSuffix(string(output), "\n"), true, nil
+}
+
+// The command goes to security's interactive mode over stdin, so the secret never shows up in
+// the process list the way a -w argument would
+func (macKeychain) set(service, account, secret string) error {
+	if strings.ContainsAny(service+account+secret, "\r\n") {
+		return errors.New("security: secrets can't contain line breaks")
+	}
+
+	line := "add-generic-password -U -s " + securityQuote(service) + " -a " + securityQuote(account) + " -w " + securityQuote(secret) + "\n"
+	command := exec.Command("security", "-i")
+	command.Stdin = strings.NewReader(line)
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	err := command.Run()
+	// In interactive mode security exits with 0 even when the command failed
+	if err == nil && strings.TrimSpace(stderr.String()) != "" {
+		err = errors.New("add-generic-password failed")
+	}
+	if err != nil {
+		return fmt.Errorf("security: %s %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Quotes an argument for a line of security's interactive mode
+func securityQuote(argument string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(argument) + `"`
+}
+
+// The Secret Service on Linux and BSDs, through libsecret's secret-tool
+type secretTool struct{}
+
+func (secretTool) get(service, account string) (string, bool, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) == 0 {
+		// secret-tool exits with 1 and says nothing when there's no such item
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, commandError("secret-tool", err)
+	}
+
+	return string(output), true, nil
+}
+
+func (secretTool) set(service, account, secret string) error {
+	command := exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+	command.Stdin = strings.NewReader(secret)
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	err := command.Run()
+	if err != nil {
+		return fmt.Errorf("secret-tool: %s %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Includes what the tool printed to stderr
+func commandError(name string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+
+	return fmt.Errorf("%s: %s", name, err.Error())
+}
@@ -0,0 +1,97 @@
+package twitchbot
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = 1168
+)
+
+// CREDENTIALW from wincred.h
+type windowsCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// Windows Credential Manager, with each secret as a generic credential named service/account
+type credentialManager struct{}
+
+func init() {
+	windowsKeychain = credentialManager{}
+}
+
+func (credentialManager) get(service, account string) (string, bool, error) {
+	target, err := syscall.UTF16PtrFromString(service + "/" + account)
+	if err != nil {
+		return "", false, err
+	}
+
+	var credential *windowsCredential
+	result, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&credential)))
+	if result == 0 {
+		if errno, ok := err.(syscall.Errno); ok && errno == errorNotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(credential)))
+
+	size := int(credential.CredentialBlobSize)
+	blob := make([]byte, size)
+	if size > 0 {
+		copy(blob, (*[1 << 20]byte)(unsafe.Pointer(credential.CredentialBlob))[:size:size])
+	}
+
+	return string(blob), true, nil
+}
+
+func (credentialManager) set(service, account, secret string) error {
+	target, err := syscall.UTF16PtrFromString(service + "/" + account)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	credential := windowsCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		credential.CredentialBlob = &blob[0]
+	}
+
+	result, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&credential)), 0)
+	if result == 0 {
+		return err
+	}
+
+	return nil
+}
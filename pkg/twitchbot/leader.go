@@ -0,0 +1,211 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLeaderLeaseDuration = 10 * time.Second
+
+// LeaderLock lets several Bot instances watch the same channels while only one of them responds
+type LeaderLock interface {
+	// Acquire takes or renews the lock for holder. It returns true while holder owns the lock.
+	Acquire(holder string, ttl time.Duration) (bool, error)
+
+	// Release gives up the lock if holder currently owns it
+	Release(holder string) error
+}
+
+type leaderLease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileLeaderLock is a LeaderLock backed by a lease file on a disk shared between instances
+type FileLeaderLock struct {
+	Path string
+}
+
+// Acquire takes the lease if it is free or expired, or renews it if holder already owns it
+func (lock *FileLeaderLock) Acquire(holder string, ttl time.Duration) (bool, error) {
+	unlock, err := lock.mutex()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	lease, err := lock.read()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if lease.Holder != "" && lease.Holder != holder && now.Before(lease.Expires) {
+		return false, nil
+	}
+
+	return true, lock.write(leaderLease{Holder: holder, Expires: now.Add(ttl)})
+}
+
+// Release clears the lease so a standby can take over immediately
+func (lock *FileLeaderLock) Release(holder string) error {
+	unlock, err := lock.mutex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lease, err := lock.read()
+	if err != nil {
+		return err
+	}
+
+	if lease.Holder != holder {
+		return nil
+	}
+
+	return lock.write(leaderLease{})
+}
+
+func (lock *FileLeaderLock) read() (leaderLease, error) {
+	lease := leaderLease{}
+
+	data, err := ioutil.ReadFile(lock.Path)
+	if os.IsNotExist(err) {
+		return lease, nil
+	}
+	if err != nil {
+		return lease, errors.New("FileLeaderLock.read: " + err.Error())
+	}
+
+	if len(data) == 0 {
+		return lease, nil
+	}
+
+	err = json.Unmarshal(data, &lease)
+	if err != nil {
+		return lease, errors.New("FileLeaderLock.read: " + err.Error())
+	}
+
+	return lease, nil
+}
+
+func (lock *FileLeaderLock) write(lease leaderLease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return errors.New("FileLeaderLock.write: " + err.Error())
+	}
+
+	// Write then rename so a reader never sees a half written lease
+	tmpPath := lock.Path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return errors.New("FileLeaderLock.write: " + err.Error())
+	}
+
+	err = os.Rename(tmpPath, lock.Path)
+	if err != nil {
+		return errors.New("FileLeaderLock.write: " + err.Error())
+	}
+
+	return nil
+}
+
+// Serializes read-modify-write of the lease between processes with an exclusive lock file
+func (lock *FileLeaderLock) mutex() (func(), error) {
+	mutexPath := lock.Path + ".lock"
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		file, err := os.OpenFile(mutexPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(mutexPath) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, errors.New("FileLeaderLock.mutex: " + err.Error())
+		}
+
+		// A crashed instance may have left the lock file behind
+		info, statErr := os.Stat(mutexPath)
+		if statErr == nil && time.Since(info.ModTime()) > 5*time.Second {
+			os.Remove(mutexPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.New("FileLeaderLock.mutex: timed out waiting for " + mutexPath)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Reports whether this instance should respond in chat. Always true without a LeaderLock.
+func (bot *Bot) isLeader() bool {
+	if bot.LeaderLock == nil {
+		return true
+	}
+
+	return atomic.LoadInt32(&bot.leader) == 1
+}
+
+// Keeps trying to take or renew the leader lock in the background
+func (bot *Bot) startLeaderElection() {
+	if bot.LeaderLock == nil {
+		return
+	}
+
+	if bot.LeaderLeaseDuration == 0 {
+		bot.LeaderLeaseDuration = defaultLeaderLeaseDuration
+	}
+
+	bot.renewLeadership()
+
+	stopped := make(chan struct{})
+	bot.leaderRenewalStopped = stopped
+
+	go func() {
+		defer close(stopped)
+
+		// Renew well before the lease runs out so a slow disk can't cost us the lock
+		ticker := time.NewTicker(bot.LeaderLeaseDuration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bot.stopping:
+				return
+			case <-ticker.C:
+				bot.renewLeadership()
+			}
+		}
+	}()
+}
+
+func (bot *Bot) renewLeadership() {
+	acquired, err := bot.LeaderLock.Acquire(bot.InstanceID, bot.LeaderLeaseDuration)
+	if err != nil {
+		logger.Warn("Bot.renewLeadership: %s", err.Error())
+		acquired = false
+	}
+
+	var state int32
+	if acquired {
+		state = 1
+	}
+
+	previous := atomic.SwapInt32(&bot.leader, state)
+	if previous != state {
+		if acquired {
+			logger.Success("Instance %s is now the leader", bot.InstanceID)
+		} else {
+			logger.Notice("Instance %s is standing by", bot.InstanceID)
+		}
+	}
+}
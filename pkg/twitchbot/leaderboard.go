@@ -0,0 +1,262 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	leaderboardBucket = "leaderboard"
+
+	defaultLeaderboardSize = 5
+	maxLeaderboardSize     = 25
+)
+
+// LeaderboardEntry counts what one user did in a channel
+type LeaderboardEntry struct {
+	UserID   string `json:"user_id"`
+	Login    string `json:"login"`
+	Messages int    `json:"messages"`
+	Commands int    `json:"commands"`
+}
+
+// Counts for the current stream, which runs from when the bot started or the last
+// !topchatters end, and all-time counts that are written to the Store with the daily stats
+type leaderboards struct {
+	mutex  sync.Mutex
+	stream map[string]map[string]*LeaderboardEntry
+	all    map[string]*LeaderboardEntry
+	dirty  map[string]bool
+}
+
+func leaderboardKey(channel, userID string) string {
+	return channel + "/" + userID
+}
+
+// The bookkeeping key for a user; whoever chats without a user-id is kept by login
+func leaderboardUser(message *Message) string {
+	if message.UserID != "" {
+		return message.UserID
+	}
+
+	return "login:" + strings.ToLower(message.Username)
+}
+
+func (bot *Bot) countForLeaderboard(message *Message, messages, commands int) {
+	if message.Channel == "" {
+		return
+	}
+
+	user := leaderboardUser(message)
+	login := strings.ToLower(message.Username)
+
+	bot.leaderboards.mutex.Lock()
+	defer bot.leaderboards.mutex.Unlock()
+
+	if bot.leaderboards.stream == nil {
+		bot.leaderboards.stream = map[string]map[string]*LeaderboardEntry{}
+		bot.leaderboards.all = map[string]*LeaderboardEntry{}
+		bot.leaderboards.dirty = map[string]bool{}
+	}
+
+	stream := bot.leaderboards.stream[message.Channel]
+	if stream == nil {
+		stream = map[string]*LeaderboardEntry{}
+		bot.leaderboards.stream[message.Channel] = stream
+	}
+	if stream[user] == nil {
+		stream[user] = &LeaderboardEntry{UserID: user}
+	}
+
+	key := leaderboardKey(message.Channel, user)
+	all := bot.leaderboards.all[key]
+	if all == nil {
+		all = &LeaderboardEntry{UserID: user}
+		_, err := bot.Store.Get(leaderboardBucket, key, all)
+		if err != nil {
+			logger.Warn("Bot.countForLeaderboard: %s", err.Error())
+		}
+		bot.leaderboards.all[key] = all
+	}
+
+	for _, entry := range []*LeaderboardEntry{stream[user], all} {
+		entry.Login = login
+		entry.Messages += messages
+		entry.Commands += commands
+	}
+	bot.leaderboards.dirty[key] = true
+}
+
+// Writes the all-time counts that changed. Called along with flushMetrics.
+func (bot *Bot) flushLeaderboards() {
+	bot.leaderboards.mutex.Lock()
+	defer bot.leaderboards.mutex.Unlock()
+
+	for key := range bot.leaderboards.dirty {
+		err := bot.Store.Put(leaderboardBucket, key, bot.leaderboards.all[key])
+		if err != nil {
+			logger.Warn("Bot.flushLeaderboards: %s", err.Error())
+			continue
+		}
+		delete(bot.leaderboards.dirty, key)
+	}
+}
+
+// Whether a user turned leaderboards off with !pref leaderboard off
+func (bot *Bot) hiddenFromLeaderboards(userID string) bool {
+	preferences := UserPreferences{}
+	_, err := bot.Store.Get(userPreferencesBucket, userID, &preferences)
+	if err != nil {
+		logger.Warn("Bot.hiddenFromLeaderboards: %s", err.Error())
+	}
+
+	return preferences.HideFromLeaderboards
+}
+
+// Leaderboard ranks a channel's users by messages sent, for this stream or all time. Users who
+// opted out are left out.
+func (bot *Bot) Leaderboard(channel string, allTime bool, size int) []LeaderboardEntry {
+	entries := []LeaderboardEntry{}
+
+	if allTime {
+		bot.flushLeaderboards()
+
+		keys, err := bot.Store.Keys(leaderboardBucket)
+		if err != nil {
+			logger.Warn("Bot.Leaderboard: %s", err.Error())
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, channel+"/") {
+				continue
+			}
+
+			entry := LeaderboardEntry{}
+			_, err := bot.Store.Get(leaderboardBucket, key, &entry)
+			if err == nil {
+				entries = append(entries, entry)
+			}
+		}
+	} else {
+		bot.leaderboards.mutex.Lock()
+		for _, entry := range bot.leaderboards.stream[channel] {
+			entries = append(entries, *entry)
+		}
+		bot.leaderboards.mutex.Unlock()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Messages != entries[j].Messages {
+			return entries[i].Messages > entries[j].Messages
+		}
+		if entries[i].Commands != entries[j].Commands {
+			return entries[i].Commands > entries[j].Commands
+		}
+		return entries[i].Login < entries[j].Login
+	})
+
+	ranked := []LeaderboardEntry{}
+	for _, entry := range entries {
+		if len(ranked) == size {
+			break
+		}
+		if bot.hiddenFromLeaderboards(entry.UserID) {
+			continue
+		}
+		ranked = append(ranked, entry)
+	}
+
+	return ranked
+}
+
+func formatLeaderboard(entries []LeaderboardEntry) []string {
+	ranks := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		ranks = append(ranks, fmt.Sprintf("%d. %s (%d messages, %d commands)", i+1, entry.Login, entry.Messages, entry.Commands))
+	}
+
+	return ranks
+}
+
+// EndStream posts the stream's top chatters and starts counting a new stream
+func (bot *Bot) EndStream(channel string) {
+	top := bot.Leaderboard(channel, false, defaultLeaderboardSize)
+
+	bot.leaderboards.mutex.Lock()
+	delete(bot.leaderboards.stream, channel)
+	bot.leaderboards.mutex.Unlock()
+
+	if len(top) == 0 {
+		return
+	}
+
+	for _, message := range packMessages("Thanks for watching! Top chatters this stream: ", ", ", formatLeaderboard(top)) {
+		bot.ChatWithPriority(channel, message, PrioritySystem)
+	}
+}
+
+// !topchatters [all] [size] | !topchatters end
+func topChattersCommand(bot *Bot, command *Command) {
+	allTime := false
+	size := defaultLeaderboardSize
+
+	for _, arg := range command.Args {
+		switch strings.ToLower(arg) {
+		case "all", "alltime":
+			allTime = true
+		case "end":
+			if !command.Can(Moderator) {
+				return
+			}
+			bot.EndStream(command.Channel)
+			return
+		default:
+			number, err := strconv.Atoi(arg)
+			if err != nil || number <= 0 {
+				bot.Reply(command.Message, "Usage: !topchatters [all] [size]")
+				return
+			}
+			size = number
+		}
+	}
+
+	if size > maxLeaderboardSize {
+		size = maxLeaderboardSize
+	}
+
+	top := bot.Leaderboard(command.Channel, allTime, size)
+	if len(top) == 0 {
+		bot.Reply(command.Message, "Nobody is on the leaderboard yet.")
+		return
+	}
+
+	scope := "this stream"
+	if allTime {
+		scope = "of all time"
+	}
+
+	for _, message := range packMessages(fmt.Sprintf("Top chatters %s: ", scope), ", ", formatLeaderboard(top)) {
+		bot.Reply(command.Message, message)
+	}
+}
+
+// Serves /overlay/leaderboard?channel=name[&scope=all][&size=10] as JSON
+func (bot *Bot) serveLeaderboard(w http.ResponseWriter, r *http.Request) {
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultLeaderboardSize
+	if number, err := strconv.Atoi(r.FormValue("size")); err == nil && number > 0 && number <= maxLeaderboardSize {
+		size = number
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bot.Leaderboard(channel, r.FormValue("scope") == "all", size))
+}
@@ -0,0 +1,238 @@
+package twitchbot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// LiveStream is a channel's stream while it's live
+type LiveStream struct {
+	Channel   string    `json:"channel"`
+	Title     string    `json:"title"`
+	Game      string    `json:"game"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Which channels are live, from Helix polls or EventSub
+type liveStreams struct {
+	mutex   sync.RWMutex
+	streams map[string]LiveStream
+
+	// Set once the state of every channel was looked up, so going live can be told apart from
+	// the bot starting during a stream
+	known bool
+}
+
+func init() {
+	// How long the channel has been live, or "offline"
+	RegisterTemplateVariable("uptime", func(context *TemplateContext, args []string) string {
+		stream, live := context.Bot.LiveStream(context.Command.Channel)
+		if !live {
+			return "offline"
+		}
+
+		return time.Since(stream.StartedAt).Round(time.Minute).String()
+	})
+}
+
+// IsLive reports whether a channel is streaming right now. Only known when StreamPollInterval
+// is set or EventSubEvents has "streams"; otherwise it's always false.
+func (bot *Bot) IsLive(channel string) bool {
+	_, live := bot.LiveStream(channel)
+	return live
+}
+
+// LiveStream returns a channel's stream if it's live
+func (bot *Bot) LiveStream(channel string) (LiveStream, bool) {
+	bot.liveStreams.mutex.RLock()
+	defer bot.liveStreams.mutex.RUnlock()
+
+	stream, live := bot.liveStreams.streams[channel]
+	return stream, live
+}
+
+// Whether the Bot keeps track of which channels are live
+func (bot *Bot) detectsStreams() bool {
+	if bot.ClientID == "" || bot.Anonymous {
+		return false
+	}
+	if bot.StreamPollInterval > 0 {
+		return true
+	}
+	for _, event := range bot.EventSubEvents {
+		if event == "streams" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether timers in a channel wait for it to go live, because of TimersOnlyWhenLive
+func (bot *Bot) pausedWhileOffline(channel string) bool {
+	bot.settingsMutex.RLock()
+	onlyWhenLive := bot.TimersOnlyWhenLive
+	bot.settingsMutex.RUnlock()
+
+	return onlyWhenLive && bot.detectsStreams() && !bot.IsLive(channel)
+}
+
+// Looks up which channels are live, then keeps polling every StreamPollInterval if it's set.
+// With EventSub the first look is all that's needed.
+func (bot *Bot) startStreamDetection() {
+	if !bot.detectsStreams() {
+		return
+	}
+
+	go func() {
+		bot.pollStreams()
+
+		if bot.StreamPollInterval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(bot.StreamPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bot.context().Done():
+				return
+			case <-ticker.C:
+				bot.pollStreams()
+			}
+		}
+	}()
+}
+
+// Asks Helix which channels are live and notes the ones that went live or offline
+func (bot *Bot) pollStreams() {
+	channels := bot.channels()
+	live := map[string]helix.Stream{}
+
+	for start := 0; start < len(channels); start += helix.MaxBatch {
+		end := start + helix.MaxBatch
+		if end > len(channels) {
+			end = len(channels)
+		}
+
+		streams, err := bot.helix().GetStreams(bot.context(), nil, channels[start:end])
+		if err != nil {
+			logger.Warn("Bot.pollStreams: %s", err.Error())
+			return
+		}
+		for _, stream := range streams {
+			live[stream.UserLogin] = stream
+		}
+	}
+
+	bot.liveStreams.mutex.RLock()
+	announce := bot.liveStreams.known
+	bot.liveStreams.mutex.RUnlock()
+
+	for _, channel := range channels {
+		if stream, ok := live[channel]; ok {
+			bot.streamOnline(LiveStream{Channel: channel, Title: stream.Title, Game: stream.GameName, StartedAt: stream.StartedAt}, announce)
+		} else {
+			bot.streamOffline(channel, announce)
+		}
+	}
+
+	bot.liveStreams.mutex.Lock()
+	bot.liveStreams.known = true
+	bot.liveStreams.mutex.Unlock()
+}
+
+// Notes a channel as live. When it wasn't before, GoLiveAnnouncement is posted unless announce is
+// false, as for streams already running when the bot starts.
+func (bot *Bot) streamOnline(stream LiveStream, announce bool) {
+	bot.liveStreams.mutex.Lock()
+	_, wasLive := bot.liveStreams.streams[stream.Channel]
+	if bot.liveStreams.streams == nil {
+		bot.liveStreams.streams = map[string]LiveStream{}
+	}
+	bot.liveStreams.streams[stream.Channel] = stream
+	bot.liveStreams.mutex.Unlock()
+
+	if stream.Game != "" {
+		bot.SetGame(stream.Channel, stream.Game)
+	}
+	if wasLive {
+		return
+	}
+
+	logger.Success("#%s is live: %s", stream.Channel, stream.Title)
+	bot.publish(FeedStreams, stream.Channel, "", "went live: "+stream.Title)
+	if !announce {
+		return
+	}
+
+	template := bot.channelResponse(stream.Channel, func(settings ChannelSettings) string { return settings.GoLiveAnnouncement }, &bot.GoLiveAnnouncement)
+	bot.announceStream(stream.Channel, template, map[string]string{"title": stream.Title, "game": stream.Game})
+}
+
+// Notes a channel as offline, posting OfflineAnnouncement if it was live
+func (bot *Bot) streamOffline(channel string, announce bool) {
+	bot.liveStreams.mutex.Lock()
+	stream, wasLive := bot.liveStreams.streams[channel]
+	delete(bot.liveStreams.streams, channel)
+	bot.liveStreams.mutex.Unlock()
+
+	if !wasLive {
+		return
+	}
+
+	uptime := time.Since(stream.StartedAt).Round(time.Minute)
+	logger.Notice("#%s went offline after %s", channel, uptime)
+	bot.publish(FeedStreams, channel, "", "went offline")
+	if !announce {
+		return
+	}
+
+	template := bot.channelResponse(channel, func(settings ChannelSettings) string { return settings.OfflineAnnouncement }, &bot.OfflineAnnouncement)
+	bot.announceStream(channel, template, map[string]string{"title": stream.Title, "game": stream.Game, "uptime": uptime.String()})
+}
+
+// Posts an announcement from the leader only, since every instance keeps track of streams
+func (bot *Bot) announceStream(channel, template string, values map[string]string) {
+	if template == "" || bot.Anonymous || !bot.isLeader() {
+		return
+	}
+
+	message := &Message{Channel: channel, Username: channel, DisplayName: channel}
+	bot.ChatWithPriority(channel, bot.RenderTemplate(template, &Command{Message: message}, values), PrioritySystem)
+}
+
+// Fills in the stream's title and game for a stream.online notification, which has neither
+func (bot *Bot) streamFromEventSub(channel, broadcasterID string, startedAt time.Time) LiveStream {
+	stream := LiveStream{Channel: channel, StartedAt: startedAt}
+
+	info, err := bot.helix().GetChannelInformation(bot.context(), []string{broadcasterID})
+	if err != nil {
+		logger.Warn("Bot.streamFromEventSub: %s", err.Error())
+	} else if len(info) > 0 {
+		stream.Title = info[0].Title
+		stream.Game = info[0].GameName
+	}
+
+	return stream
+}
+
+// !uptime
+func uptimeCommand(bot *Bot, command *Command) {
+	if !bot.detectsStreams() {
+		bot.Reply(command.Message, "I can't tell when the stream is live.")
+		return
+	}
+
+	stream, live := bot.LiveStream(command.Channel)
+	if !live {
+		bot.Reply(command.Message, fmt.Sprintf("%s is offline.", command.Channel))
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("%s has been live for %s.", command.Channel, time.Since(stream.StartedAt).Round(time.Minute)))
+}
@@ -0,0 +1,68 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+// Loggers for the parts of the bot, so log_levels can show or hide them separately
+var (
+	logger       = printpretty.Module("twitchbot")
+	filterLogger = printpretty.Module("filters")
+)
+
+// Modules log_levels can name, besides printpretty.DefaultModule
+var logModules = []string{"eventsub", "filters", "helix", "store", "twitchbot"}
+
+// Checks a config's log_levels for unknown modules and levels
+func validateLogLevels(levels map[string]string) error {
+	for module, level := range levels {
+		known := strings.EqualFold(module, printpretty.DefaultModule)
+		for _, name := range logModules {
+			if strings.EqualFold(module, name) {
+				known = true
+			}
+		}
+		if !known {
+			modules := append([]string{printpretty.DefaultModule}, logModules...)
+			sort.Strings(modules)
+			return fmt.Errorf("log_levels.%s: unknown module, use one of %s", module, strings.Join(modules, ", "))
+		}
+
+		if _, err := printpretty.ParseLevel(level); err != nil {
+			return fmt.Errorf("log_levels.%s: %s", module, strings.TrimPrefix(err.Error(), "ParseLevel: "))
+		}
+	}
+
+	return nil
+}
+
+// Applies LogLevels, which are shared by every Bot in the process
+func (bot *Bot) applyLogLevels() {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	levels := map[string]printpretty.Level{}
+	for module, name := range bot.LogLevels {
+		level, err := printpretty.ParseLevel(name)
+		if err != nil {
+			logger.Warn("Bot.applyLogLevels: %s", err.Error())
+			continue
+		}
+		levels[module] = level
+	}
+
+	printpretty.SetLevels(levels)
+}
+
+// Serves printpretty's counts of written, dropped and slow console lines, shared by every Bot in
+// the process
+func serveLoggingStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(printpretty.Stats())
+}
@@ -2,167 +2,679 @@ package twitchbot
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net"
+	"net/http"
 	"net/textproto"
-	"regexp"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/mike1104/chuckbot/pkg/irc"
 	"github.com/mike1104/chuckbot/pkg/printpretty"
+	"github.com/mike1104/chuckbot/pkg/store"
 )
 
-var (
-	authenticationErrorMessage = ":tmi.twitch.tv NOTICE * :Login authentication failed"
-	pingMessage                = "PING :tmi.twitch.tv"
-)
-
-// Deconstruct a message
-// 1: (username) 2: (full message) 3: (message type) 4: (message)
-var messageRegex *regexp.Regexp = regexp.MustCompile(`^:(\w+)!\w+@\w+\.tmi\.twitch\.tv ((PRIVMSG|WHISPER) #?\w+ :(.*))$`)
-
-// Pull a command from anywhere in a PRIVMSG message
-// 1: (command)
-var commandRegex *regexp.Regexp = regexp.MustCompile(`!(\w+)`)
-
-// 1: (message)
-var noticeRegex *regexp.Regexp = regexp.MustCompile(`^:tmi\.twitch\.tv NOTICE #\w+ :(.+)`)
-
 // Notice messages
 const (
-	messageRateNotice   = "Your message was not sent because you are sending messages too quickly."
-	whisperDeniedNotice = "Your settings prevent you from sending this whisper."
+	authenticationFailedNotice = "Login authentication failed"
+	messageRateNotice          = "Your message was not sent because you are sending messages too quickly."
+	whisperDeniedNotice        = "Your settings prevent you from sending this whisper."
 )
 
-var chatRateLimit = 30 * time.Second / 20
-
 const maxMessageQueueLength = 10
 
+const defaultWhisperAutoResponse = "Blue Fairy? Please. Please, please make me into a real, live boy. Please. Blue Fairy? Please. Please. Make me real. Blue Fairy, please. Please make me real. Please make me a real boy. Please, Blue Fairy. Make me into a real boy. Please."
+
 // Bot will hit you with facts about Chuck Norris so hard your ancestors will feel it
 type Bot struct {
+	// Ignored when Anonymous is set
 	BotName string
 
+	// Connect as a random justinfan login without a token. The Bot can read chat, keep stats and
+	// export logs but can't chat, whisper or run commands.
+	Anonymous bool
+
 	ChannelName string
 
+	// More channels to join over the same connection. Replies go to the channel a command came from.
+	Channels []string
+
 	Port string
 
 	Server string
 
+	// SecretsPath, SecretsPassphrase, SecretsKeyFile and Token are guarded by secretsMutex once
+	// the Bot runs, since Reload changes them
 	SecretsPath string
 
+	// Open SecretsPath when it was encrypted with EncryptSecretsFile
+	SecretsPassphrase string
+	SecretsKeyFile    string
+
+	// Where the token comes from when Token is empty. Defaults to FileSecrets at SecretsPath.
+	Secrets SecretsProvider
+
+	// OAuth token to use instead of reading it from Secrets, e.g. from an environment variable
+	Token string
+
+	// Connect without first checking the token with Twitch, e.g. against a test server
+	SkipTokenValidation bool
+
+	// Template for whisper replies. See RenderTemplate.
 	WhisperAutoResponse string
 
+	// Template for !chucknorris replies. $(fact) is the fact. Defaults to "$(user): $(fact)".
+	ChuckNorrisResponse string
+
+	// Template for !so replies. $(clip) is a link to the target's most viewed recent clip, which
+	// needs ClientID. Defaults to "Go check out @$(touser) at twitch.tv/$(touser)! $(clip)".
+	ShoutoutResponse string
+
+	// Turns whispers off. The Bot also turns them off when Twitch refuses to deliver one.
 	WhispersDisabled bool
 
-	messageChannel chan string
+	// Optional lock shared with other instances. Only the instance holding it responds in chat.
+	LeaderLock LeaderLock
+
+	// Identifies this instance to the LeaderLock and in message claims. Defaults to the hostname
+	// and start time.
+	InstanceID string
+
+	LeaderLeaseDuration time.Duration
+
+	// Address for the /healthz and /readyz HTTP probes, e.g. ":8080". Disabled when empty.
+	HealthAddress string
+
+	// Bearer token for /events and for HTTP requests that change things or show moderation
+	// data. They're refused while it's empty.
+	AdminToken string
+
+	// Reload the secrets file when it changes, such as a Kubernetes Secret being updated
+	WatchSecrets bool
+
+	leader int32
+
+	joinedChannels map[string]bool
+
+	joinedMutex sync.Mutex
+
+	// Number of goroutines handling work for each channel
+	ChannelWorkers int
+
+	// Work waiting beyond this per channel is dropped
+	ChannelQueueLength int
+
+	// Users whose lines are dropped before parsing, such as other bots
+	IgnoredUsers []string
+
+	// IRC commands dropped before parsing, e.g. "JOIN" and "PART" to ignore join floods
+	IgnoredCommands []string
+
+	// Custom checks run on every raw line before parsing
+	PreFilters []PreFilter
+
+	// Words and phrases the bot ignores in chat and never says itself. Matching ignores case,
+	// punctuation and look-alikes like "3" for "e".
+	BannedPhrases []string
+
+	// Custom checks run on chat messages and everything the bot sends, alongside BannedPhrases
+	ContentFilters []ContentFilter
+
+	// Strip zero-width characters and zalgo from chat, and ignore ASCII art, before commands
+	// and the console see it
+	CleanChat bool
+
+	// With CleanChat, time out users after this many zalgo or ASCII art messages in 10 minutes.
+	// Needs the bot to be a moderator. 0 never times anyone out.
+	SpamStrikes int
+
+	// How long SpamStrikes timeouts last. Defaults to a minute.
+	SpamTimeout time.Duration
+
+	// Rule to the reason template given when the bot times someone out for breaking it, e.g.
+	// "ascii_art": "No ASCII art please (rule 3)". $(rule) describes the rule. Defaults to
+	// "Please don't post $(rule)". The rules are RuleZalgo and RuleASCIIArt.
+	ModerationReasons map[string]string
+
+	// Template whispered to users the bot times out, e.g. "You were timed out in $(channel) for
+	// $(duration): $(reason)". Empty sends nothing.
+	ModerationWhisper string
+
+	// Users who were timed out or banned can whisper this word, e.g. "appeal", to ask the
+	// channel's moderators to reconsider. Empty turns appeals off.
+	AppealKeyword string
+
+	// URL new appeals are posted to, such as a Discord webhook
+	AppealWebhook string
+
+	// How many filtered messages within 30 seconds count as a spam wave. Defaults to 5, negative
+	// never pauses. See SpamWave.
+	SpamWaveThreshold int
+
+	// IANA timezone for times typed in chat without one, e.g. "Europe/Berlin". Defaults to local time.
+	Timezone string
+
+	// Custom channel point reward IDs whose messages are queued as highlights, alongside Twitch's
+	// built in "Highlight My Message" reward
+	HighlightRewardIDs []string
+
+	// The community boss battle run with !boss
+	BossBattle BossBattleSettings
+
+	// Points handed out by !daily
+	DailyReward DailyRewardSettings
+
+	// How many questions a user may have waiting in the !ask queue. Defaults to 2.
+	MaxQuestionsPerUser int
+
+	// Directory clip and bookmark lists are exported to. Defaults to the working directory.
+	ClipsExportDir string
+
+	// Sent instead of text responses while a channel is in emote-only mode, e.g. "Kappa".
+	// Leave empty to stay quiet. Ignored when the bot is a moderator there.
+	EmoteOnlyResponse string
+
+	// Template for welcoming people chatting in a channel for the first time, e.g.
+	// "Welcome $(user)!". Paused during raids and floods of new chatters. Empty sends nothing.
+	FirstChatterGreeting string
+
+	// Template for thanking new followers, e.g. "Thanks for the follow $(user)!". Follows come
+	// from EventSub, which needs a ClientID and the moderator:read:followers scope. Paused like
+	// FirstChatterGreeting. Empty sends nothing.
+	FollowGreeting string
+
+	// How long someone who unfollows and follows again isn't greeted a second time. Defaults to
+	// a day.
+	FollowGreetingWindow time.Duration
+
+	// How many first time chatters within 30 seconds pause greetings. Defaults to 5.
+	GreetingFloodThreshold int
+
+	// Template posted when a channel is raided, e.g. "Thanks for the raid $(user)! They were
+	// playing $(game)". $(viewers), $(game) and $(clip) are filled in. Empty sends nothing.
+	RaidShoutout string
+
+	// Also give raiders Twitch's own shoutout. Needs the moderator:manage:shoutouts scope.
+	RaidHelixShoutout bool
+
+	// Templates thanking subscribers, e.g. "Thanks for the $(tier) sub, $(user)!". $(months),
+	// $(streak), $(tier), $(gifter), $(recipient), $(count) and $(message) are filled in. Empty
+	// sends nothing.
+	SubThanks      string
+	ResubThanks    string
+	GiftThanks     string
+	GiftBombThanks string
+
+	// Thank a gift bomb once with GiftBombThanks, instead of each gift with GiftThanks
+	BatchGiftBombs bool
+
+	// Answers to cheers by size. The one with the highest MinBits a cheer reaches is sent.
+	CheerResponses []CheerResponse
+
+	// How long deleted custom commands, quotes and timers can be brought back with !restore.
+	// Defaults to a week.
+	TrashRetention time.Duration
+
+	// Templates posted when a channel goes live and when its stream ends, e.g. "We're live with
+	// $(game): $(title)". $(title) and $(game) are filled in, and $(uptime) when it ends. Empty
+	// sends nothing.
+	GoLiveAnnouncement  string
+	OfflineAnnouncement string
+
+	// How often Helix is asked which channels are live. 0 polls only at startup, which is enough
+	// with "streams" in EventSubEvents. Either way needs a ClientID.
+	StreamPollInterval time.Duration
+
+	// Skip repeating timers while their channel is offline. Reminders still go off. Has no effect
+	// unless going live is detected.
+	TimersOnlyWhenLive bool
+
+	// Commands and counters that only exist while a channel plays certain games, by pack name.
+	// The game comes from the channel's Twitch category, or !game.
+	CommandPacks map[string]CommandPack
+
+	// How often each channel's Twitch category is looked up for CommandPacks, GameCounters and
+	// the announcements below. Defaults to a minute, or only at startup with "updates" in
+	// EventSubEvents. Needs a ClientID.
+	CategoryPollInterval time.Duration
+
+	// Counters like "deaths" that keep a separate count for every game a channel plays
+	GameCounters []string
+
+	// Templates posted when a channel's category or title changes on Twitch, e.g. "Now playing
+	// $(game)!". $(game), $(previous) (the game before) and $(title) are filled in. Empty sends
+	// nothing.
+	CategoryAnnouncement string
+	TitleAnnouncement    string
+
+	// How long greetings stay paused after a raid. Defaults to two minutes.
+	RaidQuietPeriod time.Duration
+
+	// How often chat logs, channel point redemptions and command uses are exported as CSV files.
+	// 0 turns scheduled exports off.
+	DataExportInterval time.Duration
+
+	// Directory exports are written to. Defaults to ClipsExportDir.
+	DataExportDir string
+
+	// Where exports are saved instead of DataExportDir, such as an S3Sink
+	DataExportSink ExportSink
+
+	// Also save a JSON backup of the Store with every export
+	DataExportBackups bool
+
+	// The longest wait between reconnection attempts. Defaults to two minutes.
+	MaxReconnectWait time.Duration
+
+	// Fraction of each wait, between 0 and 1, that is cut off at random. Defaults to 0.5; use a
+	// negative value for none.
+	ReconnectJitter float64
+
+	// Give up after this many attempts in a row fail, returning an error from Start. 0 keeps trying.
+	MaxReconnectAttempts int
+
+	// Ping Twitch after this long without hearing from it. Defaults to a minute.
+	KeepaliveInterval time.Duration
+
+	// Reconnect if Twitch doesn't answer a ping within this long. Defaults to 20 seconds.
+	KeepaliveTimeout time.Duration
+
+	// Client ID of the Twitch application the token belongs to. Needed for Twitch API calls, such
+	// as looking up the accounts of users seen in chat.
+	ClientID string
+
+	// How often a batch of up to 100 users is looked up. Defaults to every 10 seconds.
+	UserBackfillInterval time.Duration
+
+	// Events to receive over EventSub: "follows", "subs", "raids", "redemptions", "streams" and
+	// "updates". Empty leaves EventSub off.
+	EventSubEvents []string
+
+	// What to do when channel point rewards are redeemed, keyed by reward ID or title. Needs
+	// "redemptions" in EventSubEvents. Handlers from RegisterRedemption come first.
+	RedemptionActions map[string]RedemptionAction
+
+	// Show users' pronouns next to their names in the console
+	ShowPronouns bool
+
+	// How long pronouns fetched from pronouns.alejo.io are remembered. Defaults to an hour.
+	PronounsCacheTTL time.Duration
+
+	// Source of randomness for commands and games. Defaults to a time-seeded generator.
+	// Use NewSeededRandom for deterministic runs or CryptoRandom for giveaways.
+	Random RandomSource
+
+	// Where features like raffles keep their data. Defaults to an in-memory store.
+	Store store.Store
+
+	// Opens the connection to Server. Defaults to TLSDialer with TLSConfig, or PlaintextDialer when
+	// Port is 6667. Swap it for other transports or an in-memory connection in tests.
+	Dialer Dialer
+
+	// Used by the default Dialer, e.g. to trust a dev server's root CA
+	TLSConfig *tls.Config
+
+	// HTTP or SOCKS5 proxy for the chat connection and API calls, e.g. "socks5://proxy:1080".
+	// The default Dialer becomes a ProxyDialer when it is set.
+	Proxy *url.URL
+
+	// How many turns each priority below PriorityModeration gets when several are waiting.
+	// Defaults to system 8, reply 4, timer 2, game 1.
+	PriorityWeights map[MessagePriority]int
+
+	// Prefixes that start a command, e.g. "!" and "?". Defaults to "!".
+	CommandPrefixes []string
+
+	// Channel name to the prefixes used there instead of CommandPrefixes
+	ChannelCommandPrefixes map[string][]string
+
+	// Channel name to settings that apply only there: prefixes, which commands are on, cooldowns
+	// and responses. Can be changed with Reload.
+	ChannelSettings map[string]ChannelSettings
+
+	// Channel name to its language, e.g. "es" so !hecho runs !chucknorris. See RegisterCommandLocale.
+	ChannelLanguages map[string]string
+
+	// Extra names for commands in every channel, e.g. "fact": "chucknorris"
+	CommandAliases map[string]string
+
+	// Channel name to extra command names used only there
+	ChannelCommandAliases map[string]map[string]string
+
+	// Commands users can call in chat. "!chucknorris" is registered by default.
+	Commands CommandRegistry
+
+	// Event name to how it stands out in the console, e.g. EventRaid to a magenta line with a bell.
+	// Cues are shared by every Bot in the process.
+	ConsoleCues map[string]printpretty.Cue
+
+	// Repeats of a gray or white console line within this long are collapsed into one summary,
+	// e.g. "message repeated 40 times in 10s". Defaults to 10 seconds, negative value for none.
+	// Shared by every Bot in the process.
+	ConsoleGroupWindow time.Duration
+
+	// Timestamp in front of console lines: "time" (the default, e.g. 15:04:05.000), "datetime",
+	// "rfc3339", "none" or a Go time layout. Shared by every Bot in the process.
+	ConsoleTimestamps string
+
+	// "local" (the default), "UTC" or an IANA name like "Europe/Berlin" for console timestamps
+	ConsoleTimezone string
+
+	// Lowest level shown for each module: twitchbot, filters, helix, eventsub, store, or "default" for the
+	// rest. Levels are debug, quiet, info, notice, warning and error. Shared by every Bot in the
+	// process.
+	LogLevels map[string]string
+
+	// Shell commands and webhooks to run when the Bot connects, joins a channel, fails to log in
+	// or shuts down, keyed by HookConnected and the like
+	Hooks map[string][]Hook
+
+	// Config file Reload and SIGHUP read settings from. It's watched, and reloaded when it changes.
+	ConfigPath string
+
+	// Reads the config on Reload instead of ConfigPath, e.g. to layer in environment variables
+	ConfigLoader func() (*Config, error)
+
+	// Leave SIGINT, SIGTERM and SIGHUP to the program embedding the Bot. Call Stop and Reload yourself.
+	DisableSignalHandling bool
+
+	settingsMutex sync.RWMutex
+
+	commandConfig map[string]CommandConfig
+
+	configCommands map[string]bool
+
+	configCommandsMutex sync.Mutex
+
+	middleware []Middleware
+
+	middlewareMutex sync.RWMutex
+
+	dispatcher *dispatcher
+
+	cooldowns cooldowns
+
+	pronouns replyCache
+
+	shoutoutClips replyCache
+
+	templateMutex sync.Mutex
+
+	bookmarkMutex sync.Mutex
+
+	counterMutex sync.Mutex
+
+	rooms roomStates
+
+	quoteMutex sync.Mutex
+
+	slowMode slowModePacer
+
+	spamStrikes spamStrikes
+
+	spamWaves spamWaves
+
+	outboxStats outboxStats
+
+	claims messageClaims
+
+	effects effectDelivery
+
+	whispers whisperSender
+
+	firstSeen firstSeenTracker
+
+	recentChat recentChat
+
+	feed eventFeed
+
+	// Shared by API calls so they reuse connections to the proxy
+	proxyTransport     *http.Transport
+	proxyTransportOnce sync.Once
+
+	floods floodWatch
+
+	giftBombs giftBombs
+
+	metrics metricsRecorder
+
+	exports exportBuffer
+
+	rateLimiter chatRateLimiter
+
+	users userBackfill
+
+	names nameTracker
+
+	leaderboards leaderboards
+
+	pointsMutex sync.Mutex
+
+	bossMutex sync.Mutex
+	bitsMutex sync.Mutex
+
+	redemptionHandlers redemptionHandlers
+
+	liveStreams liveStreams
+
+	categories categories
+
+	duels duels
+
+	games map[string]string
+
+	gamesMutex sync.RWMutex
+
+	raffles raffles
+
+	highlights highlightQueue
+
+	questions questionQueue
+
+	clips clipTracker
+
+	scheduler scheduler
+
+	preFilters *preFilterSet
+
+	contentFilter *contentFilter
+
+	// Replaced on every connection, while timers and EventSub handlers queue chat
+	outbox *outbox
+
+	messagesFlushed chan struct{}
+
+	outboxMutex sync.Mutex
+
+	writeMutex sync.Mutex
+
+	stopping chan struct{}
+
+	stopped chan struct{}
+
+	// Closed once the leader lock is no longer being renewed
+	leaderRenewalStopped chan struct{}
+
+	stopOnce sync.Once
+
+	ctx context.Context
+
+	cancel context.CancelFunc
 
 	oAuthToken string
 
-	connection net.Conn
+	// The bot account's user-id, guarded by secretsMutex
+	selfUserID string
+
+	// From the secrets provider, for refreshing oAuthToken. Guarded by secretsMutex.
+	refreshToken     string
+	clientSecret     string
+	secretsClientID  string
+	tokenExpiry      time.Time
+	lastTokenRefresh time.Time
+
+	secretsMutex sync.Mutex
+
+	connection Conn
 
 	reconnectWaitTime time.Duration
-}
 
-type secrets struct {
-	// The bot account's OAuth token.
-	OAuthToken string `json:"token,omitempty"`
+	reconnectAttempts int
 }
 
-func (bot *Bot) connect() {
-	var err error
+// Connects, waiting longer after each attempt that fails or never gets welcomed by Twitch.
+// Returns an error once MaxReconnectAttempts attempts in a row have failed.
+func (bot *Bot) connect() error {
 	address := fmt.Sprintf("%s:%s", bot.Server, bot.Port)
 
-	printpretty.Info("Establishing connection to %s...", address)
+	for {
+		if bot.MaxReconnectAttempts > 0 && bot.reconnectAttempts >= bot.MaxReconnectAttempts {
+			return fmt.Errorf("Bot.connect: giving up on %s after %d attempts", address, bot.reconnectAttempts)
+		}
 
-	bot.connection, err = tls.Dial("tcp", address, nil)
-	if err != nil {
-		printpretty.Info("Connection to %s failed, trying again in %s", address, bot.reconnectWaitTime)
-		time.Sleep(bot.reconnectWaitTime)
-		bot.backoffConnectionRate()
-		bot.connect()
-		return
-	}
+		if bot.reconnectWaitTime > 0 {
+			wait := bot.reconnectWait()
+			logger.Info("Connecting to %s in %s", address, wait.Round(time.Millisecond))
+			if bot.sleepUnlessStopped(wait) {
+				bot.connection = nil
+				return nil
+			}
+		}
 
-	printpretty.Info("Connected to %s", address)
+		logger.Info("Establishing connection to %s...", address)
 
+		connection, err := bot.Dialer.Dial(bot.context(), address)
+		bot.backoffConnectionRate()
+		if err != nil {
+			logger.Info("Connection to %s failed: %s", address, err.Error())
+			continue
+		}
+
+		bot.connection = connection
+		logger.Info("Connected to %s", address)
+		return nil
+	}
 }
 
 func (bot *Bot) disconnect() {
-	printpretty.Info("Disconnecting from %s", bot.Server)
+	bot.clearJoined()
+	bot.currentOutbox().close()
+	logger.Info("Disconnecting from %s", bot.Server)
 	bot.connection.Close()
-	printpretty.Info("Closed connection to %s", bot.Server)
+	logger.Info("Closed connection to %s", bot.Server)
 }
 
 func (bot *Bot) authenticate() {
-	printpretty.Info("Authenticating %s...", bot.BotName)
-	bot.writeToTwitch("PASS", bot.oAuthToken)
+	logger.Info("Authenticating %s...", bot.BotName)
+	if !bot.Anonymous {
+		bot.secretsMutex.Lock()
+		token := bot.oAuthToken
+		bot.secretsMutex.Unlock()
+		bot.writeToTwitch("PASS", token)
+	}
 	bot.writeToTwitch("NICK", bot.BotName)
-	printpretty.Info("Authentication sent for %s", bot.BotName)
+	logger.Info("Authentication sent for %s", bot.BotName)
 }
 
-// Needed for receiving whispers
+// Commands are needed for receiving whispers, tags for badges, user IDs and message IDs
 func (bot *Bot) enableTwitchSpecificCommands() {
-	printpretty.Info("Enabling twitch commands")
-	bot.writeToTwitch("CAP REQ", ":twitch.tv/commands")
-	printpretty.Info("Enabled twitch commands")
+	logger.Info("Enabling twitch commands and tags")
+	bot.writeToTwitch("CAP REQ", ":twitch.tv/commands twitch.tv/tags")
+	logger.Info("Requested twitch commands and tags")
 }
 
-func (bot *Bot) joinChannel() {
-	printpretty.Info("Joining channel #%s...", bot.ChannelName)
-	bot.writeToTwitch("JOIN", "#"+bot.ChannelName)
-	printpretty.Info("Join attempted for channel #%s...", bot.ChannelName)
-}
+// Formats a line and checks it fits the 512 bytes IRC allows. Returns "" if it doesn't.
+func formatLine(command, message string) string {
+	fullMessage := fmt.Sprintf("%s %s\r\n", command, message)
+	if message == "" {
+		fullMessage = command + "\r\n"
+	}
 
-// Reduce the rate of reconnection attempts exponentially (first attempt is immediate)
-func (bot *Bot) backoffConnectionRate() {
-	if bot.reconnectWaitTime == 0 {
-		bot.reconnectWaitTime = time.Second
-	} else {
-		bot.reconnectWaitTime *= 2
+	// check if message is too long
+	if len(fullMessage) > 512 {
+		logger.Warn("Bot.writeToTwitch: formattedMessage exceeded 512 bytes")
+		return ""
 	}
+
+	return fullMessage
 }
 
-// Validates the total message length and writes to the twitch connection
+// Queues a protocol line ahead of any chat
 func (bot *Bot) writeToTwitch(command, message string) {
-	fullMessage := fmt.Sprintf("%s %s\r\n", command, message)
+	fullMessage := formatLine(command, message)
+	if fullMessage == "" {
+		return
+	}
 
-	// check if message is too long
-	if len(fullMessage) > 512 {
-		printpretty.Warn("Bot.writeToTwitch: formattedMessage exceeded 512 bytes")
+	box := bot.currentOutbox()
+	if box == nil {
+		bot.writeLine(fullMessage)
 		return
 	}
 
-	_, err := bot.connection.Write([]byte(fullMessage))
+	box.push(priorityControl, outgoingLine{text: fullMessage})
+}
+
+// Writes a line to the connection right away. Only the outbox writer and Stop, once the writer
+// is done, call this.
+func (bot *Bot) writeLine(line string) {
+	// Keep the final QUIT from interleaving with a line still being written
+	bot.writeMutex.Lock()
+	defer bot.writeMutex.Unlock()
+
+	_, err := bot.connection.Write([]byte(line))
 
 	if err != nil {
-		printpretty.Warn("Bot.writeToTwitch: failed to write to twitch")
+		logger.Warn("Bot.writeToTwitch: failed to write to twitch")
 	}
 }
 
 // Ensures all of the necessary configuration is present for the Bot
 func (bot *Bot) verifyConfiguration() error {
-	if bot.BotName == "" || bot.Server == "" || bot.Port == "" || bot.ChannelName == "" || bot.SecretsPath == "" {
+	if (bot.BotName == "" && !bot.Anonymous) || bot.Server == "" || bot.Port == "" || len(bot.channels()) == 0 ||
+		(bot.SecretsPath == "" && bot.Secrets == nil && bot.Token == "" && !bot.Anonymous) {
 		return errors.New("Bot is not configured")
 	}
 
 	return nil
 }
 
-// Get the OAuth token from a JSON file
-func (bot *Bot) getOAuthToken() error {
-	data, err := ioutil.ReadFile(bot.SecretsPath)
-	if err != nil {
-		return err
+// The Secrets provider, or the file at SecretsPath
+func (bot *Bot) secretsProvider() SecretsProvider {
+	if bot.Secrets != nil {
+		return bot.Secrets
 	}
 
-	var str secrets
-	err = json.Unmarshal(data, &str)
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	return &FileSecrets{Path: bot.SecretsPath, Passphrase: bot.SecretsPassphrase, KeyFile: bot.SecretsKeyFile}
+}
 
+// Token, which Reload can change while the Bot runs
+func (bot *Bot) configToken() string {
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	return bot.Token
+}
+
+// Get the OAuth token from Token or the secrets provider
+func (bot *Bot) getOAuthToken() error {
+	if token := bot.configToken(); token != "" {
+		bot.secretsMutex.Lock()
+		bot.oAuthToken = token
+		bot.secretsMutex.Unlock()
+		return nil
+	}
+
+	str, err := bot.secretsProvider().Secrets()
 	if err != nil {
 		return err
 	}
@@ -171,26 +683,58 @@ func (bot *Bot) getOAuthToken() error {
 		return errors.New("Bot.getOAuthToken: 'token' is empty")
 	}
 
+	bot.secretsMutex.Lock()
 	bot.oAuthToken = str.OAuthToken
+	bot.secretsClientID = str.ClientID
+	bot.clientSecret = str.ClientSecret
+	// A refreshed token is newer than the one the file had when the bot started
+	if str.RefreshToken != "" {
+		bot.refreshToken = str.RefreshToken
+	}
+	bot.secretsMutex.Unlock()
 
 	return nil
 }
 
-// Add a message to the rate limited queue
-func (bot *Bot) queueMessage(msg string) {
-	bot.messageChannel <- msg
+// Picks up a rotated token without dropping the connection. It is sent on the next authentication.
+func (bot *Bot) reloadSecrets() {
+	err := bot.getOAuthToken()
+	if err != nil {
+		logger.Warn("Bot.reloadSecrets: keeping the previous token: %s", err.Error())
+		return
+	}
+
+	bot.secretsMutex.Lock()
+	path := bot.SecretsPath
+	bot.secretsMutex.Unlock()
+
+	logger.Success("Reloaded secrets from %s", path)
 }
 
-// A rate limiter for message sends
-func (bot *Bot) createMessageChannel() {
-	bot.messageChannel = make(chan string, maxMessageQueueLength)
+// Add a chat message for a channel to the rate limited queue
+func (bot *Bot) queueMessage(channel, msg string, priority MessagePriority) {
+	box := bot.currentOutbox()
+	if box == nil {
+		logger.Warn("Bot.queueMessage: not connected yet, dropping message")
+		return
+	}
 
-	go func() {
-		for message := range bot.messageChannel {
-			bot.writeToTwitch("PRIVMSG", message)
-			time.Sleep(chatRateLimit)
-		}
-	}()
+	if bot.isStopping() {
+		logger.Warn("Bot.queueMessage: stopping, dropping message")
+		return
+	}
+
+	if bot.Anonymous {
+		logger.Quiet("Bot.queueMessage: connected anonymously, dropping message")
+		return
+	}
+
+	line := formatLine("PRIVMSG", fmt.Sprintf("#%s :%s", channel, msg))
+	if line == "" {
+		return
+	}
+
+	box.push(priority, outgoingLine{text: line, channel: channel})
 }
 
 func (bot *Bot) listenToChat() error {
@@ -199,157 +743,415 @@ func (bot *Bot) listenToChat() error {
 
 	defer bot.disconnect()
 
-	bot.createMessageChannel()
+	activity := &connectionActivity{}
+	activity.touch()
+	done := make(chan struct{})
+	defer close(done)
+	go bot.keepAlive(bot.connection, activity, done)
 
-	bot.chat("Hello everyone! Type `!chucknorris` to get some Chuck Norris facts!")
+	for _, channel := range bot.channels() {
+		bot.ChatWithPriority(channel, fmt.Sprintf("Hello everyone! Type `%schucknorris` to get some Chuck Norris facts!", bot.commandPrefixes(channel)[0]), PrioritySystem)
+	}
 
 	// listen for chat messages
 	for {
 		line, err := tp.ReadLine()
+		activity.touch()
+
+		if err == nil && !bot.acceptLine(line) {
+			filterLogger.Debug("Dropped: %s", line)
+			continue
+		}
 
 		// Quietly log everything from Twitch
-		printpretty.Quiet(line)
+		text := line
+		if bot.CleanChat {
+			text = consoleSafeLine(line)
+		}
+		logger.Group(consoleGroupKey(line), printpretty.QUIET, "%s", text)
 
 		if err != nil {
 			return errors.New("Bot.listenToChat: Failed to read line from channel")
 		}
 
-		switch line {
-		case authenticationErrorMessage:
-			printpretty.Error("Authentication failed. Check your Bot's username and token")
-			return nil
-		case pingMessage:
-			go bot.pong()
+		ircMessage, err := irc.Parse(line)
+		if err != nil {
+			logger.Warn("Bot.listenToChat: %s", err.Error())
 			continue
 		}
 
-		noticeMatches := noticeRegex.FindStringSubmatch(line)
-		if noticeMatches != nil {
-			noticeMessage := noticeMatches[1]
-
-			switch noticeMessage {
-			case messageRateNotice:
-				printpretty.Notice(noticeMessage)
-				continue
-			case whisperDeniedNotice:
-				printpretty.Notice(noticeMessage)
-				bot.WhispersDisabled = true
-			}
-			continue
+		// Twitch is about to restart the server. Reconnect now instead of waiting for it to drop us.
+		if ircMessage.Command == "RECONNECT" {
+			return errors.New("Bot.listenToChat: Twitch asked the bot to reconnect")
+		}
+
+		done := bot.handleIRCMessage(ircMessage)
+		if done {
+			return nil
 		}
+	}
+}
 
-		// handle a PRIVMSG message
-		chatMatches := messageRegex.FindStringSubmatch(line)
-		if chatMatches != nil {
-			username := chatMatches[1]
-			fullMessage := chatMatches[2]
-			messageType := chatMatches[3]
-			message := chatMatches[4]
-
-			switch messageType {
-			case "PRIVMSG":
-				commandMatches := commandRegex.FindStringSubmatch(message)
-				if commandMatches != nil {
-					command := strings.Trim(commandMatches[1], " ")
-
-					if command == "chucknorris" {
-						printpretty.Highlight("> "+fullMessage, "!"+command)
-						// Don't make more requests to the API if the message queue has maxed out
-						if len(bot.messageChannel) < maxMessageQueueLength {
-							go bot.replyWithChuckFact(&username)
-						} else {
-							printpretty.Info("Too many messages queued up. Not sending request for more facts")
-						}
-					}
-				}
-			case "WHISPER":
-				printpretty.Info("WHISPER received from @%s: %s", username, message)
-				go bot.whisper(username, bot.WhisperAutoResponse)
+// Reacts to a single line from Twitch. Returns true if the Bot can't continue and should stop.
+func (bot *Bot) handleIRCMessage(ircMessage *irc.Message) bool {
+	switch ircMessage.Command {
+	case "PING":
+		go bot.pong(ircMessage.Trailing)
+	case "JOIN":
+		if strings.EqualFold(ircMessage.Prefix.Name, bot.BotName) {
+			logger.Success("Joined channel #%s", ircMessage.Channel())
+			bot.publish(FeedConnection, ircMessage.Channel(), "", "joined")
+			bot.setJoined(ircMessage.Channel(), true)
+			bot.runHooks(HookJoined, ircMessage.Channel())
+		}
+	case "001":
+		bot.resetConnectionRate()
+		bot.startEffectDelivery()
+		bot.runHooks(HookConnected, "")
+	case "CAP":
+		if ircMessage.Param(1) == "NAK" {
+			logger.Warn("Twitch refused capabilities: %s", ircMessage.Trailing)
+		}
+	case "NOTICE":
+		return bot.handleNotice(ircMessage)
+	case "USERNOTICE":
+		bot.handleUserNotice(ircMessage)
+	case "GLOBALUSERSTATE":
+		bot.setBotUserID(ircMessage.Tags["user-id"])
+	case "CLEARCHAT":
+		bot.recordModeration(ircMessage)
+		bot.recordModerationAction(ircMessage)
+		bot.publishModeration(ircMessage)
+	case "ROOMSTATE", "USERSTATE":
+		bot.updateRoomState(ircMessage)
+	case "PRIVMSG", "WHISPER":
+		message := newMessage(ircMessage)
+		if !bot.cleanMessage(message) {
+			filterLogger.Event(EventFilter, printpretty.QUIET, "Ignoring ASCII art from @%s", message.Username)
+			bot.publish(FeedFilters, message.Channel, message.Username, "ASCII art: "+message.Text)
+			return false
+		}
+
+		queue := whisperQueue
+		if message.Type == "PRIVMSG" {
+			queue = message.Channel
+		}
+
+		// Standby instances keep reading chat but leave responding to the leader
+		if !bot.isLeader() {
+			return false
+		}
+
+		handler := bot.handler()
+		bot.dispatcher.dispatch(queue, func() { handler(bot, message) })
+	}
+
+	return false
+}
+
+func (bot *Bot) handleNotice(ircMessage *irc.Message) bool {
+	noticeMessage := ircMessage.Trailing
+
+	if ircMessage.Param(0) == "*" {
+		if noticeMessage == authenticationFailedNotice {
+			if bot.recoverFromRejectedToken() {
+				// Twitch drops the connection after a failed login; make sure, then log in again
+				logger.Warn("Twitch rejected the token, reconnecting with a refreshed one")
+				bot.connection.Close()
+				return false
 			}
+
+			logger.Error("Authentication failed. Check your Bot's username and token")
+			bot.runHooks(HookAuthFailed, "")
+			return true
 		}
+
+		logger.Notice(noticeMessage)
+		return false
+	}
+
+	switch noticeMessage {
+	case messageRateNotice:
+		logger.Notice(noticeMessage)
+	case whisperDeniedNotice:
+		logger.Notice(noticeMessage)
+		bot.disableWhispers()
 	}
+
+	return false
 }
 
-// Call out to the Chuck Norris API and send the returned fact to the Twitch channel
-func (bot *Bot) replyWithChuckFact(username *string) {
-	fact, err := FetchChuckFact()
+// Call out to the Chuck Norris API, falling back to a built-in fact if it can't be reached
+func (bot *Bot) fetchChuckFact() string {
+	fact, err := fetchChuckFact(bot.context(), bot.httpClient(5*time.Second))
 	if err != nil {
-		printpretty.Error(err.Error())
-		return
+		logger.Error(err.Error())
+		fact = bot.pickRandom(fallbackChuckFacts)
 	}
 
-	printpretty.Success("< Chuck Fact for #%s: %s", *username, fact)
-
-	bot.chat(fmt.Sprintf("%s: %s", *username, fact))
+	return fact
 }
 
+// Line breaks in a message would let it end the IRC line and start another command
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
 // send a message to the chat channel.
 func (bot *Bot) chat(message string) {
+	bot.chatTo(bot.ChannelName, message)
+}
+
+// send a message to a specific chat channel.
+func (bot *Bot) chatTo(channel, message string) {
+	bot.ChatWithPriority(channel, message, PriorityReply)
+}
+
+// ChatWithPriority sends a message to a channel. In slow mode, higher priority messages go out first.
+func (bot *Bot) ChatWithPriority(channel, message string, priority MessagePriority) {
+	message = lineBreaks.Replace(message)
+	if message == "" {
+		logger.Warn("Bot.chat: message was empty")
+		return
+	}
+
+	_, filter := bot.filters()
+	if phrase, blocked := filter.match(message); blocked {
+		filterLogger.Warn("Bot.chat: not sending a message to #%s that matches %q: %s", channel, phrase, message)
+		return
+	}
+
+	message = bot.emoteOnlyMessage(channel, message)
 	if message == "" {
-		printpretty.Warn("Bot.chat: message was empty")
 		return
 	}
 
-	bot.queueMessage(fmt.Sprintf("#%s :%s\r\n", bot.ChannelName, message))
+	if priority <= PriorityTimer && bot.SpamWave(channel) {
+		filterLogger.Quiet("Spam wave in #%s. Not sending: %s", channel, message)
+		return
+	}
+
+	bot.sendPaced(channel, message, priority)
 }
 
 // send a whisper to a specific user.
 func (bot *Bot) whisper(username, message string) {
-	if bot.WhispersDisabled {
-		printpretty.Info("Bot.whisper: Whispers disabled, refusing to send whisper")
+	if bot.whispersDisabled() || bot.Anonymous {
+		logger.Info("Bot.whisper: Whispers disabled, refusing to send whisper")
 		return
 	}
 
+	message = lineBreaks.Replace(message)
 	if message == "" {
-		printpretty.Warn("Bot.whisper: message was empty")
+		logger.Warn("Bot.whisper: message was empty")
 		return
 	}
 
-	bot.queueMessage(fmt.Sprintf("#%s :/w %s %s\r\n", username, username, message))
+	_, filter := bot.filters()
+	if phrase, blocked := filter.match(message); blocked {
+		filterLogger.Warn("Bot.whisper: not whispering @%s a message that matches %q: %s", username, phrase, message)
+		return
+	}
+
+	if bot.ClientID != "" {
+		bot.queueWhisper(username, message)
+		return
+	}
+
+	// Without a ClientID there's no Helix, so fall back to the deprecated /w command
+	bot.queueMessage(username, fmt.Sprintf("/w %s %s", username, message), PriorityReply)
 }
 
 // Lets Twicth know the Bot is still active
-func (bot *Bot) pong() {
-	bot.writeToTwitch("PONG", ":tmi.twitch.tv")
-	printpretty.Quiet("Returned PONG")
+func (bot *Bot) pong(server string) {
+	if server == "" {
+		server = "tmi.twitch.tv"
+	}
+
+	bot.writeToTwitch("PONG", ":"+server)
+	logger.Quiet("Returned PONG")
 }
 
 // Fills in any of the Bot's optional config with default values
 func (bot *Bot) fillDefaults() {
 	if bot.WhisperAutoResponse == "" {
-		bot.WhisperAutoResponse = "Blue Fairy? Please. Please, please make me into a real, live boy. Please. Blue Fairy? Please. Please. Make me real. Blue Fairy, please. Please make me real. Please make me a real boy. Please, Blue Fairy. Make me into a real boy. Please."
+		bot.WhisperAutoResponse = defaultWhisperAutoResponse
+	}
+
+	if bot.Random == nil {
+		bot.Random = defaultRandomSource()
+	}
+
+	if bot.Store == nil {
+		bot.Store = store.NewMemory()
+	}
+
+	if bot.InstanceID == "" {
+		hostname, _ := os.Hostname()
+		bot.InstanceID = hostname + "-" + time.Now().Format("150405.000000")
+	}
+
+	if bot.Dialer == nil {
+		if bot.Proxy != nil {
+			logger.Info("Connecting through the proxy at %s", bot.Proxy.Host)
+			bot.Dialer = ProxyDialer{Proxy: bot.Proxy, TLSConfig: bot.TLSConfig, Plaintext: bot.Port == plaintextPort}
+		} else if bot.Port == plaintextPort {
+			logger.Warn("Port %s doesn't use TLS, connecting without encryption", plaintextPort)
+			bot.Dialer = PlaintextDialer{}
+		} else {
+			bot.Dialer = TLSDialer{Config: bot.TLSConfig}
+		}
+	}
+
+	if bot.ChuckNorrisResponse == "" {
+		bot.ChuckNorrisResponse = defaultChuckNorrisResponse
+	}
+
+	if bot.ShoutoutResponse == "" {
+		bot.ShoutoutResponse = defaultShoutoutResponse
+	}
+
+	if bot.ChannelWorkers == 0 {
+		bot.ChannelWorkers = defaultChannelWorkers
+	}
+
+	if bot.ChannelQueueLength == 0 {
+		bot.ChannelQueueLength = defaultChannelQueueLength
 	}
 }
 
 // Start the process of connecting to Twitch...
 func (bot *Bot) Start() {
-	err := bot.verifyConfiguration()
+	err := bot.StartContext(context.Background())
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+}
+
+// StartContext connects to Twitch and handles chat until ctx is cancelled or Stop is called.
+// Cancelling ctx stops the Bot like Stop does and StartContext returns ctx's error; after Stop
+// it returns nil. Configuration and authentication problems are returned right away.
+func (bot *Bot) StartContext(ctx context.Context) error {
+	err := bot.verifyConfiguration()
+	if err != nil {
+		return err
+	}
+
+	// Console lines are written in the background, so let the last ones out before returning
+	defer printpretty.Flush()
+
+	bot.stopping = make(chan struct{})
+	bot.stopped = make(chan struct{})
+	defer close(bot.stopped)
+
+	bot.ctx, bot.cancel = context.WithCancel(ctx)
+	defer bot.cancel()
+
+	go func() {
+		select {
+		case <-bot.ctx.Done():
+			bot.Stop()
+		case <-bot.stopped:
+		}
+	}()
 
 	bot.fillDefaults()
 
-	err = bot.getOAuthToken()
+	err = bot.migrateStore()
 	if err != nil {
-		printpretty.Error(err.Error())
-		printpretty.Error("Could not find 'token' in %s", bot.SecretsPath)
-		return
+		return err
+	}
+
+	bot.raffles.open = map[string]*raffle{}
+	bot.pronouns.replies = map[string]cachedReply{}
+	bot.shoutoutClips.replies = map[string]cachedReply{}
+	bot.restoreScheduledMessages()
+	bot.registerDefaultCommands()
+	bot.applyCommandConfig(bot.commandConfig)
+	bot.dispatcher = newDispatcher(bot.ChannelWorkers, bot.ChannelQueueLength)
+	bot.preFilters = newPreFilterSet(bot.IgnoredUsers, bot.IgnoredCommands, bot.PreFilters)
+	bot.contentFilter = newContentFilter(bot.BannedPhrases, bot.ContentFilters)
+	printpretty.SetCues(bot.ConsoleCues)
+	bot.applyConsoleSettings()
+
+	if bot.Anonymous {
+		bot.BotName = bot.anonymousLogin()
+		logger.Info("Connecting anonymously as %s, chat is read-only", bot.BotName)
+	} else {
+		err = bot.getOAuthToken()
+		if err != nil {
+			logger.Error(err.Error())
+			if bot.Secrets != nil {
+				return errors.New("Could not get 'token' from the secrets provider")
+			}
+			return fmt.Errorf("Could not find 'token' in %s", bot.SecretsPath)
+		}
+
+		if bot.ClientID == "" {
+			bot.ClientID = bot.secretsClientID
+		}
+
+		if !bot.SkipTokenValidation {
+			err = bot.validateToken()
+			if err != nil {
+				logger.Error(err.Error())
+				bot.runHooks(HookAuthFailed, "")
+				return err
+			}
+		}
+
+		if bot.canRefreshToken() {
+			go bot.keepTokenFresh()
+		}
 	}
 
+	bot.startLeaderElection()
+	bot.startEventSub()
+	bot.startStreamDetection()
+	bot.startCategoryPolling()
+	bot.startHealthServer()
+	bot.reloadOnHangup()
+	bot.stopOnSignals()
+
+	if _, file := bot.secretsProvider().(*FileSecrets); bot.WatchSecrets && file && bot.Token == "" && !bot.Anonymous {
+		bot.watchFile(bot.SecretsPath, defaultFileWatchInterval, bot.reloadSecrets)
+	}
+
+	// A mounted ConfigMap is updated in place, without anyone to send SIGHUP
+	if bot.ConfigPath != "" {
+		bot.watchFile(bot.ConfigPath, defaultFileWatchInterval, func() {
+			err := bot.Reload()
+			if err != nil {
+				logger.Error(err.Error())
+			}
+		})
+	}
+
+	bot.resetConnectionRate()
 	for {
-		bot.reconnectWaitTime = 0
-		bot.connect()
+		err = bot.connect()
+		if bot.isStopping() {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		bot.startOutbox()
 		bot.authenticate()
 		bot.enableTwitchSpecificCommands()
-		bot.joinChannel()
+		bot.joinChannels()
 
 		err = bot.listenToChat()
+		if bot.isStopping() {
+			logger.Success("Stopped")
+			return ctx.Err()
+		}
+
 		if err != nil {
-			printpretty.Warn(err.Error())
+			logger.Event(EventReconnect, printpretty.WARNING, "%s", err.Error())
+			bot.publish(FeedConnection, "", "", "reconnecting: "+err.Error())
 		} else {
 			// Nothing more can be done here but break the loop and exit.
-			return
+			return errors.New("Bot.StartContext: Twitch refused to authenticate the bot")
 		}
 	}
 }
@@ -0,0 +1,365 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dailyStatsBucket = "daily_stats"
+
+	// How often the day's numbers are written to the Store
+	metricsFlushInterval = time.Minute
+
+	defaultTrendDays = 3
+)
+
+// DailyStats sums up a channel's chat for one day
+type DailyStats struct {
+	Channel  string         `json:"channel"`
+	Date     string         `json:"date"`
+	Messages int            `json:"messages"`
+	Chatters []string       `json:"chatters"`
+	Commands map[string]int `json:"commands"`
+	Follows  int            `json:"follows"`
+
+	// The day split up by the game being played, in order
+	Games []GameSegment `json:"games,omitempty"`
+}
+
+// GameSegment is the part of a day a channel spent on one game
+type GameSegment struct {
+	Game      string    `json:"game"`
+	StartedAt time.Time `json:"started_at"`
+
+	// Zero while the game is still being played
+	EndedAt time.Time `json:"ended_at"`
+
+	Messages int `json:"messages"`
+	Commands int `json:"commands"`
+}
+
+// How long the game was played, up to now if it still is
+func (segment *GameSegment) Duration() time.Duration {
+	if segment.EndedAt.IsZero() {
+		return time.Since(segment.StartedAt)
+	}
+
+	return segment.EndedAt.Sub(segment.StartedAt)
+}
+
+// The segment for the game being played, or nil
+func (stats *DailyStats) currentGame() *GameSegment {
+	if len(stats.Games) == 0 || !stats.Games[len(stats.Games)-1].EndedAt.IsZero() {
+		return nil
+	}
+
+	return &stats.Games[len(stats.Games)-1]
+}
+
+// UniqueChatters is how many different users chatted that day
+func (stats *DailyStats) UniqueChatters() int {
+	return len(stats.Chatters)
+}
+
+// CommandUses is how many commands were run that day
+func (stats *DailyStats) CommandUses() int {
+	total := 0
+	for _, uses := range stats.Commands {
+		total += uses
+	}
+
+	return total
+}
+
+type liveStats struct {
+	stats    *DailyStats
+	chatters map[string]bool
+	dirty    bool
+}
+
+// Collects today's numbers in memory and writes them to the Store now and then
+type metricsRecorder struct {
+	mutex sync.Mutex
+	days  map[string]*liveStats
+	once  sync.Once
+}
+
+func dailyStatsKey(channel, date string) string {
+	return channel + "/" + date
+}
+
+// The day's stats being collected for a channel, picking up where the Store left off after a restart
+func (bot *Bot) liveStats(channel string, now time.Time) *liveStats {
+	date := now.In(bot.location()).Format("2006-01-02")
+	key := dailyStatsKey(channel, date)
+
+	if bot.metrics.days == nil {
+		bot.metrics.days = map[string]*liveStats{}
+	}
+
+	if live, ok := bot.metrics.days[key]; ok {
+		return live
+	}
+
+	// Yesterday's numbers are done once a new day starts
+	for other, live := range bot.metrics.days {
+		if live.stats.Channel != channel {
+			continue
+		}
+		if segment := live.stats.currentGame(); segment != nil {
+			segment.EndedAt = now
+			live.dirty = true
+		}
+		if !live.dirty {
+			delete(bot.metrics.days, other)
+		}
+	}
+
+	stats := &DailyStats{Channel: channel, Date: date, Commands: map[string]int{}}
+	_, err := bot.Store.Get(dailyStatsBucket, key, stats)
+	if err != nil {
+		logger.Warn("Bot.liveStats: %s", err.Error())
+	}
+	if stats.Commands == nil {
+		stats.Commands = map[string]int{}
+	}
+
+	// A new day carries on with the game from the day before
+	if game := bot.Game(channel); game != "" && len(stats.Games) == 0 {
+		stats.Games = append(stats.Games, GameSegment{Game: game, StartedAt: now})
+	}
+
+	live := &liveStats{stats: stats, chatters: map[string]bool{}}
+	for _, chatter := range stats.Chatters {
+		live.chatters[chatter] = true
+	}
+	bot.metrics.days[key] = live
+
+	bot.metrics.once.Do(func() { go bot.flushMetricsEvery(metricsFlushInterval) })
+
+	return live
+}
+
+func (bot *Bot) recordChat(message *Message) {
+	bot.countForLeaderboard(message, 1, 0)
+
+	bot.metrics.mutex.Lock()
+	defer bot.metrics.mutex.Unlock()
+
+	live := bot.liveStats(message.Channel, time.Now())
+	live.stats.Messages++
+	if segment := live.stats.currentGame(); segment != nil {
+		segment.Messages++
+	}
+	live.dirty = true
+
+	if chatter := strings.ToLower(message.Username); !live.chatters[chatter] {
+		live.chatters[chatter] = true
+		live.stats.Chatters = append(live.stats.Chatters, chatter)
+	}
+}
+
+func (bot *Bot) recordCommand(message *Message, name string) {
+	bot.countForLeaderboard(message, 0, 1)
+
+	bot.metrics.mutex.Lock()
+	defer bot.metrics.mutex.Unlock()
+
+	live := bot.liveStats(message.Channel, time.Now())
+	live.stats.Commands[strings.ToLower(name)]++
+	if segment := live.stats.currentGame(); segment != nil {
+		segment.Commands++
+	}
+	live.dirty = true
+}
+
+// Ends today's segment for the game that was being played and starts one for the new game
+func (bot *Bot) startGameSegment(channel, game string) {
+	bot.metrics.mutex.Lock()
+	defer bot.metrics.mutex.Unlock()
+
+	now := time.Now()
+	live := bot.liveStats(channel, now)
+	if segment := live.stats.currentGame(); segment != nil {
+		if strings.EqualFold(segment.Game, game) {
+			return
+		}
+		segment.EndedAt = now
+	}
+
+	if game != "" {
+		live.stats.Games = append(live.stats.Games, GameSegment{Game: game, StartedAt: now})
+	}
+	live.dirty = true
+}
+
+// RecordFollow counts a new follower in today's stats. Twitch doesn't announce follows in
+// chat, so this is for whatever watches for them.
+func (bot *Bot) RecordFollow(channel string) {
+	bot.metrics.mutex.Lock()
+	defer bot.metrics.mutex.Unlock()
+
+	live := bot.liveStats(channel, time.Now())
+	live.stats.Follows++
+	live.dirty = true
+}
+
+// Writes the numbers that changed since the last flush
+func (bot *Bot) flushMetrics() {
+	bot.flushLeaderboards()
+
+	bot.metrics.mutex.Lock()
+	defer bot.metrics.mutex.Unlock()
+
+	for key, live := range bot.metrics.days {
+		if !live.dirty {
+			continue
+		}
+
+		sort.Strings(live.stats.Chatters)
+		err := bot.Store.Put(dailyStatsBucket, key, live.stats)
+		if err != nil {
+			logger.Warn("Bot.flushMetrics: %s", err.Error())
+			continue
+		}
+		live.dirty = false
+	}
+}
+
+func (bot *Bot) flushMetricsEvery(interval time.Duration) {
+	for !bot.sleepUnlessStopped(interval) {
+		bot.flushMetrics()
+	}
+}
+
+// DailyStatsHistory returns a channel's most recent days with chat, newest first
+func (bot *Bot) DailyStatsHistory(channel string, days int) ([]DailyStats, error) {
+	bot.flushMetrics()
+
+	keys, err := bot.Store.Keys(dailyStatsBucket)
+	if err != nil {
+		return nil, errors.New("Bot.DailyStatsHistory: " + err.Error())
+	}
+
+	history := []DailyStats{}
+	for i := len(keys) - 1; i >= 0 && len(history) < days; i-- {
+		if !strings.HasPrefix(keys[i], channel+"/") {
+			continue
+		}
+
+		stats := DailyStats{}
+		_, err := bot.Store.Get(dailyStatsBucket, keys[i], &stats)
+		if err != nil {
+			return nil, errors.New("Bot.DailyStatsHistory: " + err.Error())
+		}
+		history = append(history, stats)
+	}
+
+	return history, nil
+}
+
+// Describes how a number compares to the average of the ones before it
+func trend(latest int, previous []int) string {
+	if len(previous) == 0 {
+		return ""
+	}
+
+	total := 0
+	for _, value := range previous {
+		total += value
+	}
+
+	average := float64(total) / float64(len(previous))
+	if average == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%+.0f%%", (float64(latest)-average)/average*100)
+}
+
+// !stats [days] compares the channel's last few days of chat
+func statsCommand(bot *Bot, command *Command) {
+	days := defaultTrendDays
+	if len(command.Args) > 0 {
+		parsed, err := strconv.Atoi(command.Args[0])
+		if err != nil || parsed < 1 {
+			bot.Reply(command.Message, "Usage: !stats [days]")
+			return
+		}
+		days = parsed
+	}
+
+	history, err := bot.DailyStatsHistory(command.Channel, days)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	if len(history) == 0 {
+		bot.Reply(command.Message, "No stats yet.")
+		return
+	}
+
+	items := []string{}
+	for _, stats := range history {
+		date, _ := time.Parse("2006-01-02", stats.Date)
+		items = append(items, fmt.Sprintf("%s: %d messages, %d chatters, %d commands, %d follows",
+			date.Format("Jan 2"), stats.Messages, stats.UniqueChatters(), stats.CommandUses(), stats.Follows))
+	}
+
+	if len(history) > 1 {
+		messages, chatters := []int{}, []int{}
+		for _, stats := range history[1:] {
+			messages = append(messages, stats.Messages)
+			chatters = append(chatters, stats.UniqueChatters())
+		}
+
+		items = append(items, fmt.Sprintf("Latest vs the average before: messages %s, chatters %s",
+			orDefault(trend(history[0].Messages, messages), "n/a"), orDefault(trend(history[0].UniqueChatters(), chatters), "n/a")))
+	}
+
+	for _, segment := range history[0].Games {
+		items = append(items, fmt.Sprintf("%s for %s: %d messages, %d commands",
+			segment.Game, segment.Duration().Round(time.Minute), segment.Messages, segment.Commands))
+	}
+
+	for _, message := range packMessages("Stats: ", " | ", items) {
+		bot.Reply(command.Message, message)
+	}
+}
+
+// GET /stats?channel=name&days=7 serves the channel's recent daily stats as JSON
+func (bot *Bot) serveDailyStats(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "days must be a positive number", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	history, err := bot.DailyStatsHistory(channel, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,132 @@
+package twitchbot
+
+import (
+	"fmt"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+// Handler processes a message received from Twitch
+type Handler func(bot *Bot, message *Message)
+
+// Middleware wraps a Handler to run code before or after it, or to stop a message from going further
+type Middleware func(next Handler) Handler
+
+// Use adds middleware that every chat message, whisper and EventSub event passes through before
+// commands are dispatched. Middleware runs in the order it was added.
+func (bot *Bot) Use(middleware ...Middleware) {
+	bot.middlewareMutex.Lock()
+	defer bot.middlewareMutex.Unlock()
+
+	bot.middleware = append(bot.middleware, middleware...)
+}
+
+// Builds the middleware chain around the Bot's own message handling
+func (bot *Bot) handler() Handler {
+	bot.middlewareMutex.RLock()
+	defer bot.middlewareMutex.RUnlock()
+
+	handler := Handler((*Bot).handleMessage)
+	for i := len(bot.middleware) - 1; i >= 0; i-- {
+		handler = bot.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// The end of the middleware chain: runs commands in chat and answers whispers
+func (bot *Bot) handleMessage(message *Message) {
+	_, filter := bot.filters()
+	if phrase, blocked := filter.match(message.Text); blocked {
+		filterLogger.Event(EventFilter, printpretty.QUIET, "Ignoring message from @%s that matches %q", message.Username, phrase)
+		bot.publish(FeedFilters, message.Channel, message.Username, fmt.Sprintf("matches %q: %s", phrase, message.Text))
+		if message.Type == "PRIVMSG" {
+			bot.noteFiltered(message.Channel)
+		}
+		return
+	}
+
+	switch message.Type {
+	case "PRIVMSG":
+		bot.publish(FeedChat, message.Channel, message.Username, message.Text)
+		bot.recordChat(message)
+		bot.bufferForExport(message)
+		bot.noteUser(message)
+		bot.trackUsername(message)
+		bot.noteFirstSeen(message)
+		bot.rememberChat(message)
+
+		bot.hitBoss(message)
+		bot.handleCheer(message)
+
+		if bot.isHighlightRedemption(message) {
+			bot.queueHighlight(message)
+			return
+		}
+
+		bot.trackClips(message)
+		bot.greetFirstChatter(message)
+
+		command := bot.parseCommand(message)
+		if command == nil {
+			return
+		}
+
+		// Keep the queue free for moderation until the spam stops
+		if !message.Can(Moderator) && bot.SpamWave(message.Channel) {
+			filterLogger.Quiet("Not running %s%s for @%s during a spam wave in #%s", command.Prefix, command.Name, message.Username, message.Channel)
+			return
+		}
+
+		registered, ok := bot.resolveCommand(command.Channel, command.Name)
+		if !ok {
+			if !bot.claimMessage(message) {
+				return
+			}
+			if !bot.runCustomCommand(command) {
+				bot.runCounterCommand(command)
+			}
+			return
+		}
+
+		if !message.Can(registered.Permission) {
+			logger.Quiet("@%s is not allowed to use !%s, it needs %s", message.Username, command.Name, registered.Permission)
+			return
+		}
+
+		if !bot.cooldowns.allow(registered, message) {
+			logger.Quiet("!%s is cooling down for @%s in #%s", command.Name, message.Username, message.Channel)
+			return
+		}
+
+		bot.recordCommand(message, registered.Name)
+		bot.publish(FeedCommands, message.Channel, message.Username, message.Text)
+		logger.Highlight("> "+bot.consoleName(message)+": "+message.Text, command.Prefix+command.Name)
+		// Commands are counted but there's no way to answer them
+		if bot.Anonymous {
+			return
+		}
+		// Don't run more commands if the message queue has maxed out
+		if bot.currentOutbox().pending() >= maxMessageQueueLength {
+			logger.Info("Too many messages queued up. Not running !%s", command.Name)
+			return
+		}
+
+		if !bot.claimMessage(message) {
+			return
+		}
+
+		registered.Handler(bot, command)
+	case "WHISPER":
+		if bot.Anonymous || !bot.claimMessage(message) {
+			return
+		}
+		logger.Info("WHISPER received from @%s: %s", message.Username, message.Text)
+		if bot.handleAppeal(message) {
+			return
+		}
+		bot.whisper(message.Username, bot.renderTemplate(bot.setting(&bot.WhisperAutoResponse), &Command{Message: message}))
+	case "EVENTSUB":
+		bot.handleEventSub(message)
+	}
+}
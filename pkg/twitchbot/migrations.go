@@ -0,0 +1,71 @@
+package twitchbot
+
+import (
+	"errors"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// How the Bot's data is laid out in the Store, oldest first. Add a migration, with a Down that
+// undoes it, whenever a release changes the format of data that's already stored.
+var storeMigrations = []store.Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      func(store.Store) error { return nil },
+		Down:    func(store.Store) error { return nil },
+	},
+}
+
+// StoreMigrations lists the Store migrations this release knows, oldest first
+func StoreMigrations() []store.Migration {
+	return append([]store.Migration{}, storeMigrations...)
+}
+
+// LatestStoreVersion is the Store version this release reads and writes
+func LatestStoreVersion() int {
+	return storeMigrations[len(storeMigrations)-1].Version
+}
+
+// MigrateStore moves the Store a config opens to version target, up or down. Returns the
+// version it was at.
+func MigrateStore(config *Config, target int) (int, error) {
+	bot, err := NewBot(config)
+	if err != nil {
+		return 0, errors.New("MigrateStore: " + err.Error())
+	}
+	bot.fillDefaults()
+
+	return store.Migrate(bot.Store, storeMigrations, target)
+}
+
+// StoreVersion is the version of the Store a config opens
+func StoreVersion(config *Config) (int, error) {
+	bot, err := NewBot(config)
+	if err != nil {
+		return 0, errors.New("StoreVersion: " + err.Error())
+	}
+	bot.fillDefaults()
+
+	return store.SchemaVersion(bot.Store)
+}
+
+// Brings the Store up to date before the Bot uses it. A Store written by a newer release is
+// left alone and the Bot doesn't start, rather than misreading it.
+func (bot *Bot) migrateStore() error {
+	// A Store that only lives in memory starts out empty, in the latest format
+	if _, memory := bot.Store.(*store.Memory); memory {
+		return nil
+	}
+
+	from, err := store.Migrate(bot.Store, storeMigrations, LatestStoreVersion())
+	if err != nil {
+		return errors.New("Bot.migrateStore: " + err.Error())
+	}
+
+	if from != LatestStoreVersion() {
+		logger.Success("Migrated the Store from version %d to %d", from, LatestStoreVersion())
+	}
+
+	return nil
+}
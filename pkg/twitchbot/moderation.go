@@ -0,0 +1,67 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rules the bot enforces itself, as used in ModerationReasons
+const (
+	RuleZalgo    = "zalgo"
+	RuleASCIIArt = "ascii_art"
+)
+
+// How each rule is described in $(rule)
+var ruleNames = map[string]string{
+	RuleZalgo:    "zalgo",
+	RuleASCIIArt: "ASCII art",
+}
+
+const defaultModerationReason = "Please don't post $(rule)"
+
+// Times out a user for breaking a rule. The reason comes from ModerationReasons, and when
+// ModerationWhisper is set the user is whispered why.
+func (bot *Bot) moderate(message *Message, rule string, duration time.Duration) {
+	values := map[string]string{
+		"rule":     ruleName(rule),
+		"duration": duration.String(),
+	}
+
+	command := &Command{Message: message}
+	reason := strings.TrimSpace(bot.RenderTemplate(bot.moderationReason(rule), command, values))
+	values["reason"] = reason
+
+	seconds := int(duration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	filterLogger.Notice("Timing out @%s in #%s for %s", message.Username, message.Channel, values["rule"])
+	bot.publish(FeedFilters, message.Channel, message.Username, fmt.Sprintf("timed out for %s: %s", values["rule"], message.Text))
+	bot.ChatWithPriority(message.Channel, fmt.Sprintf("/timeout %s %d %s", message.Username, seconds, reason), PriorityModeration)
+
+	whisper := bot.setting(&bot.ModerationWhisper)
+	if whisper != "" && !bot.whispersDisabled() {
+		bot.whisper(message.Username, bot.RenderTemplate(whisper, command, values))
+	}
+}
+
+// The reason template for a rule, from ModerationReasons or the default
+func (bot *Bot) moderationReason(rule string) string {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if reason := bot.ModerationReasons[rule]; reason != "" {
+		return reason
+	}
+
+	return defaultModerationReason
+}
+
+func ruleName(rule string) string {
+	if name, ok := ruleNames[rule]; ok {
+		return name
+	}
+
+	return strings.Replace(rule, "_", " ", -1)
+}
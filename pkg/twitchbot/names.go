@@ -0,0 +1,151 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	userNamesBucket  = "user_names"
+	userLoginsBucket = "user_logins"
+)
+
+// PreviousName is a login a user had before renaming their account
+type PreviousName struct {
+	Login string    `json:"login"`
+	Until time.Time `json:"until"`
+}
+
+// UserNames is a user's current login and the ones they had before, oldest first
+type UserNames struct {
+	ID       string         `json:"id"`
+	Login    string         `json:"login"`
+	Previous []PreviousName `json:"previous,omitempty"`
+}
+
+// The last login seen for each user-id, so the Store is only read when something may have changed
+type nameTracker struct {
+	mutex  sync.Mutex
+	logins map[string]string
+}
+
+// Notices when a user-id shows up with a new login and keeps the old one in its history.
+// Logins stay mapped to the ID after a rename, so an old name still finds the user.
+func (bot *Bot) trackUsername(message *Message) {
+	if message.UserID == "" || message.Username == "" {
+		return
+	}
+
+	login := strings.ToLower(message.Username)
+
+	bot.names.mutex.Lock()
+	defer bot.names.mutex.Unlock()
+
+	if bot.names.logins == nil {
+		bot.names.logins = map[string]string{}
+	}
+
+	if bot.names.logins[message.UserID] == login {
+		return
+	}
+	bot.names.logins[message.UserID] = login
+
+	names := &UserNames{}
+	found, err := bot.Store.Get(userNamesBucket, message.UserID, names)
+	if err != nil {
+		logger.Warn("Bot.trackUsername: %s", err.Error())
+		return
+	}
+
+	if found && names.Login == login {
+		return
+	}
+
+	if found {
+		logger.Notice("@%s is now @%s", names.Login, login)
+		names.Previous = append(names.Previous, PreviousName{Login: names.Login, Until: time.Now()})
+	}
+	names.ID = message.UserID
+	names.Login = login
+
+	err = bot.Store.Put(userNamesBucket, message.UserID, names)
+	if err == nil {
+		err = bot.Store.Put(userLoginsBucket, login, message.UserID)
+	}
+	if err != nil {
+		logger.Error("Bot.trackUsername: %s", err.Error())
+	}
+}
+
+// UserNames returns the login history of a user-id
+func (bot *Bot) UserNames(userID string) (*UserNames, bool, error) {
+	names := &UserNames{}
+	found, err := bot.Store.Get(userNamesBucket, userID, names)
+	if err != nil {
+		return nil, false, errors.New("Bot.UserNames: " + err.Error())
+	}
+
+	return names, found, nil
+}
+
+// UserIDForLogin finds the user-id of a login, including logins the user has since changed
+func (bot *Bot) UserIDForLogin(login string) (string, bool) {
+	var userID string
+	found, err := bot.Store.Get(userLoginsBucket, strings.ToLower(strings.TrimPrefix(login, "@")), &userID)
+	if err != nil {
+		logger.Warn("Bot.UserIDForLogin: %s", err.Error())
+		return "", false
+	}
+
+	return userID, found
+}
+
+// CurrentLogin returns the latest login seen for a user-id, or fallback if the user was never seen
+func (bot *Bot) CurrentLogin(userID, fallback string) string {
+	if userID == "" {
+		return fallback
+	}
+
+	names, found, err := bot.UserNames(userID)
+	if err != nil || !found {
+		return fallback
+	}
+
+	return names.Login
+}
+
+// !names <user> lists a user's previous logins
+func namesCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !names <user>")
+		return
+	}
+
+	login := strings.ToLower(strings.TrimPrefix(command.Args[0], "@"))
+	userID, ok := bot.UserIDForLogin(login)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("I haven't seen @%s in chat.", login))
+		return
+	}
+
+	names, found, err := bot.UserNames(userID)
+	if err != nil || !found {
+		bot.Reply(command.Message, fmt.Sprintf("I haven't seen @%s in chat.", login))
+		return
+	}
+
+	if len(names.Previous) == 0 {
+		bot.Reply(command.Message, fmt.Sprintf("@%s hasn't changed their name since I first saw them.", names.Login))
+		return
+	}
+
+	previous := make([]string, 0, len(names.Previous))
+	for _, name := range names.Previous {
+		previous = append(previous, fmt.Sprintf("%s (until %s)", name.Login, name.Until.Format("Jan 2 2006")))
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s was previously %s.", names.Login, strings.Join(previous, ", ")))
+}
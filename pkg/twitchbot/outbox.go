@@ -0,0 +1,360 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol lines such as PASS, NICK, JOIN and PONG go ahead of all chat and skip the chat rate limit
+const priorityControl = PriorityModeration + 1
+
+// How many turns each priority below moderation gets when several are waiting
+var defaultPriorityWeights = map[MessagePriority]int{
+	PriorityGame:   1,
+	PriorityTimer:  2,
+	PriorityReply:  4,
+	PrioritySystem: 8,
+}
+
+type outgoingLine struct {
+	text string
+
+	// The channel a chat message goes to, for the rate limiter. Empty for protocol lines.
+	channel string
+
+	queued time.Time
+}
+
+// PriorityStats counts what happened to the chat messages of one priority
+type PriorityStats struct {
+	Queued      int64         `json:"queued"`
+	Sent        int64         `json:"sent"`
+	Dropped     int64         `json:"dropped"`
+	Pending     int           `json:"pending"`
+	AverageWait time.Duration `json:"average_wait_ns"`
+
+	totalWait time.Duration
+}
+
+// Kept by the Bot rather than an outbox so the numbers survive reconnects
+type outboxStats struct {
+	mutex      sync.Mutex
+	priorities [priorityControl]PriorityStats
+}
+
+func (stats *outboxStats) queued(priority MessagePriority) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.priorities[priority].Queued++
+}
+
+func (stats *outboxStats) dropped(priority MessagePriority) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.priorities[priority].Dropped++
+}
+
+func (stats *outboxStats) sent(priority MessagePriority, wait time.Duration) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	priorityStats := &stats.priorities[priority]
+	priorityStats.Sent++
+	priorityStats.totalWait += wait
+	priorityStats.AverageWait = priorityStats.totalWait / time.Duration(priorityStats.Sent)
+}
+
+// Lines waiting to be written to the connection, one lane per priority. A single writer drains
+// it, so nothing else writes to the socket while it runs.
+//
+// Protocol lines and moderation always go first. The lanes below share what's left by weight,
+// so a busy reply lane slows timers and games down without starving them.
+type outbox struct {
+	mutex   sync.Mutex
+	lanes   [priorityControl + 1][]outgoingLine
+	weights [PriorityModeration]int
+	credit  [PriorityModeration]int
+	stats   *outboxStats
+	wake    chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newOutbox(weights map[MessagePriority]int, stats *outboxStats) *outbox {
+	box := &outbox{stats: stats, wake: make(chan struct{}, 1), closed: make(chan struct{})}
+
+	for priority := PriorityGame; priority < PriorityModeration; priority++ {
+		weight, ok := weights[priority]
+		if !ok {
+			weight = defaultPriorityWeights[priority]
+		}
+		if weight < 1 {
+			weight = 1
+		}
+		box.weights[priority] = weight
+	}
+
+	return box
+}
+
+// Adds a line at the back of its lane. When the chat lanes are full the oldest message of the
+// lowest priority gives way, unless that is lower than the new one's.
+func (box *outbox) push(priority MessagePriority, line outgoingLine) {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	if priority != priorityControl {
+		box.stats.queued(priority)
+	}
+
+	if priority != priorityControl && box.chatLength() >= maxMessageQueueLength {
+		lowest := PriorityGame
+		for len(box.lanes[lowest]) == 0 {
+			lowest++
+		}
+
+		if lowest > priority {
+			logger.Notice("Too many messages queued up, dropping %s message: %s", priority, line.text)
+			box.stats.dropped(priority)
+			return
+		}
+
+		logger.Notice("Too many messages queued up, dropping %s message: %s", lowest, strings.TrimSpace(box.lanes[lowest][0].text))
+		box.lanes[lowest] = box.lanes[lowest][1:]
+		box.stats.dropped(lowest)
+	}
+
+	line.queued = time.Now()
+	box.lanes[priority] = append(box.lanes[priority], line)
+
+	select {
+	case box.wake <- struct{}{}:
+	default:
+	}
+}
+
+// The next line to write, without taking it off the queue
+func (box *outbox) peek() (outgoingLine, MessagePriority, bool) {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	for _, priority := range []MessagePriority{priorityControl, PriorityModeration} {
+		if len(box.lanes[priority]) > 0 {
+			return box.lanes[priority][0], priority, true
+		}
+	}
+
+	priority, ok := box.nextWeighted()
+	if !ok {
+		return outgoingLine{}, 0, false
+	}
+
+	return box.lanes[priority][0], priority, true
+}
+
+// Picks between the waiting lanes below moderation with smooth weighted round robin: each has
+// earned its weight in credit since it last went, and the one with the most goes next. Ties go
+// to the higher priority. Called with the mutex held.
+func (box *outbox) nextWeighted() (MessagePriority, bool) {
+	best, found := PriorityGame, false
+	for priority := PrioritySystem; priority >= PriorityGame; priority-- {
+		if len(box.lanes[priority]) == 0 {
+			continue
+		}
+
+		if !found || box.credit[priority]+box.weights[priority] > box.credit[best]+box.weights[best] {
+			best, found = priority, true
+		}
+	}
+
+	return best, found
+}
+
+func (box *outbox) pop(priority MessagePriority) {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	// The lane may have been handed to a new outbox since it was peeked
+	if len(box.lanes[priority]) == 0 {
+		return
+	}
+
+	if priority < PriorityModeration {
+		total := 0
+		for waiting := PriorityGame; waiting < PriorityModeration; waiting++ {
+			if len(box.lanes[waiting]) > 0 {
+				box.credit[waiting] += box.weights[waiting]
+				total += box.weights[waiting]
+			}
+		}
+		box.credit[priority] -= total
+	}
+
+	box.lanes[priority] = box.lanes[priority][1:]
+
+	// An empty lane starts over rather than saving up turns
+	if priority < PriorityModeration && len(box.lanes[priority]) == 0 {
+		box.credit[priority] = 0
+	}
+}
+
+// How many chat messages are waiting. Called with the mutex held.
+func (box *outbox) chatLength() int {
+	total := 0
+	for priority := PriorityGame; priority < priorityControl; priority++ {
+		total += len(box.lanes[priority])
+	}
+
+	return total
+}
+
+// How many chat messages are waiting to be sent
+func (box *outbox) pending() int {
+	if box == nil {
+		return 0
+	}
+
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	return box.chatLength()
+}
+
+// Stops the writer, e.g. because the connection dropped
+func (box *outbox) close() {
+	box.once.Do(func() { close(box.closed) })
+}
+
+// Takes over the chat messages another outbox didn't get to send. Protocol lines belong to the
+// old connection and are left behind.
+func (box *outbox) adopt(old *outbox) {
+	old.mutex.Lock()
+	defer old.mutex.Unlock()
+
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	for priority := PriorityGame; priority < priorityControl; priority++ {
+		box.lanes[priority] = append(box.lanes[priority], old.lanes[priority]...)
+		old.lanes[priority] = nil
+	}
+}
+
+// Starts the writer for a new connection, carrying over chat that was waiting when the last
+// one dropped
+func (bot *Bot) startOutbox() {
+	box := newOutbox(bot.PriorityWeights, &bot.outboxStats)
+	flushed := make(chan struct{})
+
+	bot.outboxMutex.Lock()
+	if bot.outbox != nil {
+		box.adopt(bot.outbox)
+	}
+	bot.outbox = box
+	bot.messagesFlushed = flushed
+	bot.outboxMutex.Unlock()
+
+	go bot.writeOutbox(box, flushed)
+}
+
+// The outbox for the current connection, or nil before the first one
+func (bot *Bot) currentOutbox() *outbox {
+	box, _ := bot.outboxAndFlushed()
+	return box
+}
+
+// The current outbox and the channel its writer closes once it has drained
+func (bot *Bot) outboxAndFlushed() (*outbox, chan struct{}) {
+	bot.outboxMutex.Lock()
+	defer bot.outboxMutex.Unlock()
+
+	return bot.outbox, bot.messagesFlushed
+}
+
+// Writes queued lines highest priority first. Chat waits for the rate limiter, but a protocol
+// line arriving meanwhile still goes out right away. Once the Bot is stopping, whatever is left
+// is sent before flushed closes.
+func (bot *Bot) writeOutbox(box *outbox, flushed chan struct{}) {
+	defer close(flushed)
+
+	for {
+		line, priority, ok := box.peek()
+		if !ok {
+			if bot.isStopping() {
+				return
+			}
+
+			select {
+			case <-box.wake:
+			case <-bot.stopping:
+			case <-box.closed:
+				return
+			}
+			continue
+		}
+
+		if priority != priorityControl {
+			if !bot.isLeader() {
+				logger.Quiet("Standing by, not sending: %s", strings.TrimSpace(line.text))
+				box.pop(priority)
+				continue
+			}
+
+			if wait := bot.rateLimiter.delay(bot.RoomState(line.channel).Privileged); wait > 0 {
+				logger.Quiet("Waiting %s before sending to #%s to stay within Twitch's rate limit", wait.Round(time.Millisecond), line.channel)
+
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-box.wake:
+				case <-box.closed:
+					timer.Stop()
+					return
+				}
+				timer.Stop()
+				continue
+			}
+		}
+
+		box.pop(priority)
+		bot.writeLine(line.text)
+
+		if priority != priorityControl {
+			bot.outboxStats.sent(priority, time.Since(line.queued))
+		}
+	}
+}
+
+// OutboxStats reports how many chat messages of each priority were queued, sent and dropped,
+// how many are waiting, and how long sent ones waited on average
+func (bot *Bot) OutboxStats() map[string]PriorityStats {
+	bot.outboxStats.mutex.Lock()
+	snapshot := map[string]PriorityStats{}
+	for priority := PriorityGame; priority < priorityControl; priority++ {
+		snapshot[priority.String()] = bot.outboxStats.priorities[priority]
+	}
+	bot.outboxStats.mutex.Unlock()
+
+	if box := bot.currentOutbox(); box != nil {
+		box.mutex.Lock()
+		for priority := PriorityGame; priority < priorityControl; priority++ {
+			stats := snapshot[priority.String()]
+			stats.Pending = len(box.lanes[priority])
+			snapshot[priority.String()] = stats
+		}
+		box.mutex.Unlock()
+	}
+
+	return snapshot
+}
+
+// Serves OutboxStats as JSON on /metrics/outbox
+func (bot *Bot) serveOutboxStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bot.OutboxStats())
+}
@@ -0,0 +1,53 @@
+package twitchbot
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// Reconnects hand the Bot a new outbox while other goroutines keep queueing chat
+func TestQueueWhileOutboxIsReplaced(t *testing.T) {
+	bot := newClaimingBots(t, store.NewMemory(), 1)[0]
+	bot.stopping = make(chan struct{})
+
+	near, far := net.Pipe()
+	defer near.Close()
+	go io.Copy(ioutil.Discard, far)
+	bot.connection = near
+
+	bot.startOutbox()
+
+	reconnected := make(chan struct{})
+	go func() {
+		defer close(reconnected)
+
+		for i := 0; i < 20; i++ {
+			bot.currentOutbox().close()
+			bot.startOutbox()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for queueing := true; queueing; {
+		select {
+		case <-reconnected:
+			queueing = false
+		default:
+			bot.queueMessage("mikkeever", "still here", PriorityTimer)
+		}
+	}
+
+	// The rate limit holds most of the chat back, so close rather than wait for it to drain
+	box, flushed := bot.outboxAndFlushed()
+	box.close()
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the last outbox didn't finish")
+	}
+}
@@ -0,0 +1,145 @@
+package twitchbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandPack is a group of commands that only exist while a channel is playing one of its games
+type CommandPack struct {
+	// Twitch categories the pack is on for, e.g. "Fortnite". Case doesn't matter.
+	Games []string `json:"games"`
+
+	// Text commands, and permission or cooldown changes to existing commands, like a channel's
+	// commands in ChannelSettings
+	Commands map[string]CommandConfig `json:"commands"`
+
+	// Counters like "deaths" that only exist while the pack is on, and start over at 0 each
+	// time it's switched on
+	Counters []string `json:"counters"`
+}
+
+func (pack CommandPack) playing(game string) bool {
+	for _, candidate := range pack.Games {
+		if game != "" && strings.EqualFold(candidate, game) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks a config's command_packs
+func validateCommandPacks(packs map[string]CommandPack) error {
+	for name, pack := range packs {
+		if len(pack.Games) == 0 {
+			return fmt.Errorf("command_packs.%s: needs at least one game", name)
+		}
+
+		for command, config := range pack.Commands {
+			_, err := ParsePermission(orDefault(config.Permission, "everyone"))
+			if err != nil {
+				return fmt.Errorf("command_packs.%s.commands.%s.permission: %s", name, command, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// The names of the packs that are on for a game, sorted
+func (bot *Bot) commandPacks(game string) []string {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	names := []string{}
+	for name, pack := range bot.CommandPacks {
+		if pack.playing(game) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ActiveCommandPacks lists the packs that are on in a channel for the game it's playing
+func (bot *Bot) ActiveCommandPacks(channel string) []string {
+	return bot.commandPacks(bot.Game(channel))
+}
+
+// The command a channel's active packs define for a name. When more than one pack has it, the
+// first by pack name wins.
+func (bot *Bot) packCommand(channel, name string) (CommandConfig, bool) {
+	packs := bot.ActiveCommandPacks(channel)
+
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	for _, pack := range packs {
+		for configured, command := range bot.CommandPacks[pack].Commands {
+			if strings.EqualFold(strings.TrimPrefix(configured, "!"), name) {
+				return command, true
+			}
+		}
+	}
+
+	return CommandConfig{}, false
+}
+
+// Whether a counter belongs to any pack, and if so whether one of them is on in the channel
+func (bot *Bot) packCounter(channel, name string) (packed, active bool) {
+	game := bot.Game(channel)
+
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	for _, pack := range bot.CommandPacks {
+		if containsCommand(pack.Counters, name) {
+			packed = true
+			if pack.playing(game) {
+				return true, true
+			}
+		}
+	}
+
+	return packed, false
+}
+
+// Switches packs over when a channel's game changes, starting the counters of packs that come
+// on over at 0
+func (bot *Bot) switchCommandPacks(channel, from, to string) {
+	before := map[string]bool{}
+	for _, name := range bot.commandPacks(from) {
+		before[name] = true
+	}
+
+	after := bot.commandPacks(to)
+	for _, name := range after {
+		if before[name] {
+			delete(before, name)
+			continue
+		}
+
+		logger.Info("Command pack %s is on in #%s for %s", name, channel, to)
+		bot.resetPackCounters(channel, name)
+	}
+	for name := range before {
+		logger.Info("Command pack %s is off in #%s", name, channel)
+	}
+}
+
+func (bot *Bot) resetPackCounters(channel, pack string) {
+	bot.settingsMutex.RLock()
+	counters := bot.CommandPacks[pack].Counters
+	bot.settingsMutex.RUnlock()
+
+	for _, name := range counters {
+		name = strings.ToLower(strings.TrimLeft(name, "!?~"))
+		_, err := bot.UpdateCounter(channel, name, true, func(int) int { return 0 })
+		if err != nil {
+			logger.Warn("Bot.resetPackCounters: %s", err.Error())
+		}
+	}
+}
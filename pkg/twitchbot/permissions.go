@@ -0,0 +1,71 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is the minimum standing in a channel a user needs to run a command
+type Permission int
+
+// Permission levels, lowest to highest
+const (
+	Everyone Permission = iota
+	Subscriber
+	VIP
+	Moderator
+	Broadcaster
+)
+
+var permissionNames = []string{"everyone", "subscriber", "vip", "moderator", "broadcaster"}
+
+func (permission Permission) String() string {
+	if permission < Everyone || permission > Broadcaster {
+		return fmt.Sprintf("Permission(%d)", int(permission))
+	}
+
+	return permissionNames[permission]
+}
+
+// ParsePermission reads a permission level by name, e.g. "moderator" or "mod"
+func ParsePermission(name string) (Permission, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "mod", "mods":
+		return Moderator, nil
+	case "sub", "subs":
+		return Subscriber, nil
+	case "", "all":
+		return Everyone, nil
+	}
+
+	for i, permissionName := range permissionNames {
+		if name == permissionName {
+			return Permission(i), nil
+		}
+	}
+
+	return Everyone, fmt.Errorf("ParsePermission: unknown permission %q", name)
+}
+
+// Permission resolves the sender's standing in the channel from their badges
+func (message *Message) Permission() Permission {
+	switch {
+	case message.HasBadge("broadcaster") || (message.Channel != "" && strings.EqualFold(message.Username, message.Channel)):
+		return Broadcaster
+	case message.HasBadge("moderator") || message.Mod:
+		return Moderator
+	case message.HasBadge("vip"):
+		return VIP
+	case message.HasBadge("subscriber") || message.HasBadge("founder") || message.Subscriber:
+		return Subscriber
+	}
+
+	return Everyone
+}
+
+// Can reports whether the sender is allowed to use commands that need permission
+func (message *Message) Can(permission Permission) bool {
+	return message.Permission() >= permission
+}
@@ -0,0 +1,91 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const pointsBucket = "points"
+
+func pointsKey(channel, userID string) string {
+	return channel + "/" + userID
+}
+
+// Points returns a user's loyalty points in a channel
+func (bot *Bot) Points(channel, userID string) int {
+	points := 0
+	_, err := bot.Store.Get(pointsBucket, pointsKey(channel, userID), &points)
+	if err != nil {
+		return 0
+	}
+
+	return points
+}
+
+// AddPoints changes a user's loyalty points by amount, which may be negative, and returns the
+// new balance. Balances never go below zero.
+func (bot *Bot) AddPoints(channel, userID string, amount int) (int, error) {
+	bot.pointsMutex.Lock()
+	defer bot.pointsMutex.Unlock()
+
+	points := bot.Points(channel, userID) + amount
+	if points < 0 {
+		points = 0
+	}
+
+	err := bot.Store.Put(pointsBucket, pointsKey(channel, userID), points)
+	if err != nil {
+		return 0, errors.New("Bot.AddPoints: " + err.Error())
+	}
+
+	return points, nil
+}
+
+// Returned by transferPoints when the sender's balance is too low
+var errNotEnoughPoints = errors.New("not enough points")
+
+// Moves amount points from one user to another, failing with errNotEnoughPoints rather than
+// taking the sender below zero. The check and both writes happen under pointsMutex, so
+// concurrent transfers can't spend the same points twice.
+func (bot *Bot) transferPoints(channel, from, to string, amount int) error {
+	bot.pointsMutex.Lock()
+	defer bot.pointsMutex.Unlock()
+
+	balance := bot.Points(channel, from)
+	if balance < amount {
+		return errNotEnoughPoints
+	}
+
+	err := bot.Store.Put(pointsBucket, pointsKey(channel, from), balance-amount)
+	if err != nil {
+		return errors.New("Bot.transferPoints: " + err.Error())
+	}
+
+	err = bot.Store.Put(pointsBucket, pointsKey(channel, to), bot.Points(channel, to)+amount)
+	if err != nil {
+		// Give the sender their points back rather than lose them
+		bot.Store.Put(pointsBucket, pointsKey(channel, from), balance)
+		return errors.New("Bot.transferPoints: " + err.Error())
+	}
+
+	return nil
+}
+
+// !points [@user]
+func pointsCommand(bot *Bot, command *Command) {
+	login := strings.ToLower(command.Username)
+	userID := leaderboardUser(command.Message)
+
+	if len(command.Args) > 0 {
+		login = strings.ToLower(strings.TrimPrefix(command.Args[0], "@"))
+		id, ok := bot.UserIDForLogin(login)
+		if !ok {
+			bot.Reply(command.Message, fmt.Sprintf("I haven't seen @%s in chat.", login))
+			return
+		}
+		userID = id
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s has %d points.", login, bot.Points(command.Channel, userID)))
+}
@@ -0,0 +1,162 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const userPreferencesBucket = "user_preferences"
+
+// UserPreferences are the settings a user chose for how the Bot talks to them
+type UserPreferences struct {
+	// IANA timezone for times the user types and is shown
+	Timezone string `json:"timezone,omitempty"`
+
+	// Preferred language as a code such as "en" or "es"
+	Language string `json:"language,omitempty"`
+
+	// Send command replies as whispers instead of in chat, when the Bot can whisper
+	WhisperReplies bool `json:"whisper_replies,omitempty"`
+
+	// e.g. "they/them"
+	Pronouns string `json:"pronouns,omitempty"`
+
+	// Leave the user off !topchatters and the leaderboard overlay
+	HideFromLeaderboards bool `json:"hide_from_leaderboards,omitempty"`
+}
+
+// Users are stored by ID so their preferences survive a name change
+func preferencesKey(message *Message) string {
+	if message.UserID != "" {
+		return message.UserID
+	}
+
+	return "login:" + strings.ToLower(message.Username)
+}
+
+// UserPreferences loads the preferences of a message's sender. Users who never set any get the zero value.
+func (bot *Bot) UserPreferences(message *Message) UserPreferences {
+	preferences := UserPreferences{}
+
+	_, err := bot.Store.Get(userPreferencesBucket, preferencesKey(message), &preferences)
+	if err != nil {
+		logger.Warn("Bot.UserPreferences: %s", err.Error())
+	}
+
+	return preferences
+}
+
+// SetUserPreferences saves the preferences of a message's sender
+func (bot *Bot) SetUserPreferences(message *Message, preferences UserPreferences) error {
+	err := bot.Store.Put(userPreferencesBucket, preferencesKey(message), preferences)
+	if err != nil {
+		return fmt.Errorf("Bot.SetUserPreferences: %s", err.Error())
+	}
+
+	return nil
+}
+
+// The timezone a user chose, falling back to the Bot's
+func (bot *Bot) userLocation(message *Message) *time.Location {
+	timezone := bot.UserPreferences(message).Timezone
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err == nil {
+			return location
+		}
+	}
+
+	return bot.location()
+}
+
+// !pref [timezone|language|replies|pronouns|leaderboard] [value]
+func preferencesCommand(bot *Bot, command *Command) {
+	preferences := bot.UserPreferences(command.Message)
+
+	if len(command.Args) == 0 {
+		replies := "chat"
+		if preferences.WhisperReplies {
+			replies = "whisper"
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("@%s timezone: %s, language: %s, replies: %s, pronouns: %s, leaderboard: %s",
+			command.Username, orUnset(preferences.Timezone), orUnset(preferences.Language), replies, orUnset(preferences.Pronouns), onOff(!preferences.HideFromLeaderboards)))
+		return
+	}
+
+	usage := "Usage: !pref timezone <Area/City> | language <code> | replies <chat|whisper> | pronouns <pronouns> | leaderboard <on|off>, or \"clear\" as the value to unset"
+	if len(command.Args) < 2 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	value := strings.Join(command.Args[1:], " ")
+	clear := strings.EqualFold(value, "clear")
+
+	switch strings.ToLower(command.Args[0]) {
+	case "timezone", "tz":
+		if clear {
+			preferences.Timezone = ""
+			break
+		}
+
+		_, err := time.LoadLocation(value)
+		if err != nil || (!strings.Contains(value, "/") && value != "UTC") {
+			bot.Reply(command.Message, fmt.Sprintf("@%s %q isn't a timezone like Europe/Berlin.", command.Username, value))
+			return
+		}
+		preferences.Timezone = value
+	case "language", "lang":
+		if clear {
+			preferences.Language = ""
+			break
+		}
+		preferences.Language = strings.ToLower(value)
+	case "replies":
+		switch strings.ToLower(value) {
+		case "whisper", "whispers":
+			preferences.WhisperReplies = true
+		case "chat", "clear":
+			preferences.WhisperReplies = false
+		default:
+			bot.Reply(command.Message, usage)
+			return
+		}
+	case "leaderboard", "leaderboards":
+		switch strings.ToLower(value) {
+		case "off", "hide":
+			preferences.HideFromLeaderboards = true
+		case "on", "show", "clear":
+			preferences.HideFromLeaderboards = false
+		default:
+			bot.Reply(command.Message, usage)
+			return
+		}
+	case "pronouns":
+		if clear {
+			preferences.Pronouns = ""
+			break
+		}
+		preferences.Pronouns = value
+	default:
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	err := bot.SetUserPreferences(command.Message, preferences)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s saved your %s preference.", command.Username, strings.ToLower(command.Args[0])))
+}
+
+func orUnset(value string) string {
+	if value == "" {
+		return "not set"
+	}
+
+	return value
+}
@@ -0,0 +1,97 @@
+package twitchbot
+
+import (
+	"strings"
+)
+
+// PreFilter inspects a raw line from Twitch before it is parsed. Returning false drops the line.
+type PreFilter func(line string) bool
+
+// Lookup tables for the cheap filters, built once from the Bot's config
+type preFilterSet struct {
+	users    map[string]bool
+	commands map[string]bool
+	custom   []PreFilter
+}
+
+func newPreFilterSet(users, commands []string, custom []PreFilter) *preFilterSet {
+	set := &preFilterSet{
+		users:    map[string]bool{},
+		commands: map[string]bool{},
+		custom:   custom,
+	}
+
+	for _, user := range users {
+		set.users[strings.ToLower(strings.TrimPrefix(user, "@"))] = true
+	}
+
+	for _, command := range commands {
+		set.commands[strings.ToUpper(command)] = true
+	}
+
+	return set
+}
+
+// Splits "@tags :nick!user@host COMMAND ..." into nick and COMMAND without allocating
+func linePrefixAndCommand(line string) (nick, command string) {
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		end := strings.IndexByte(rest, ' ')
+		if end == -1 {
+			return "", ""
+		}
+		rest = rest[end+1:]
+	}
+
+	if strings.HasPrefix(rest, ":") {
+		end := strings.IndexByte(rest, ' ')
+		if end == -1 {
+			return "", ""
+		}
+
+		prefix := rest[1:end]
+		if bang := strings.IndexByte(prefix, '!'); bang != -1 {
+			nick = prefix[:bang]
+		}
+		rest = rest[end+1:]
+	}
+
+	if end := strings.IndexByte(rest, ' '); end != -1 {
+		command = rest[:end]
+	} else {
+		command = rest
+	}
+
+	return nick, command
+}
+
+// Reports whether a raw line should go on to be parsed
+func (bot *Bot) acceptLine(line string) bool {
+	set, _ := bot.filters()
+	if set == nil {
+		return true
+	}
+
+	nick, command := linePrefixAndCommand(line)
+
+	// Never drop the bot's own echoes or keepalives, the connection depends on them
+	if command == "PING" || strings.EqualFold(nick, bot.BotName) {
+		return true
+	}
+
+	if nick != "" && set.users[strings.ToLower(nick)] {
+		return false
+	}
+
+	if set.commands[command] {
+		return false
+	}
+
+	for _, filter := range set.custom {
+		if !filter(line) {
+			return false
+		}
+	}
+
+	return true
+}
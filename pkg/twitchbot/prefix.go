@@ -0,0 +1,63 @@
+package twitchbot
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const defaultCommandPrefix = "!"
+
+// Compiled command patterns, keyed by their prefixes joined with spaces
+var commandPatterns sync.Map
+
+// Builds a pattern that finds a command starting a word anywhere in a message
+// 1: (prefix) 2: (command)
+func commandPattern(prefixes []string) *regexp.Regexp {
+	key := strings.Join(prefixes, " ")
+	if pattern, ok := commandPatterns.Load(key); ok {
+		return pattern.(*regexp.Regexp)
+	}
+
+	quoted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		quoted[i] = regexp.QuoteMeta(prefix)
+	}
+
+	pattern := regexp.MustCompile(`(?:^|\s)(` + strings.Join(quoted, "|") + `)(\w+)`)
+	commandPatterns.Store(key, pattern)
+
+	return pattern
+}
+
+// The prefixes that start commands in a channel
+func (bot *Bot) commandPrefixes(channel string) []string {
+	if settings, ok := bot.channelSettings(channel); ok && len(settings.CommandPrefixes) > 0 {
+		return settings.CommandPrefixes
+	}
+
+	if prefixes, ok := bot.ChannelCommandPrefixes[channel]; ok && len(prefixes) > 0 {
+		return prefixes
+	}
+
+	if len(bot.CommandPrefixes) > 0 {
+		return bot.CommandPrefixes
+	}
+
+	return []string{defaultCommandPrefix}
+}
+
+// Finds the first command in a message, resolves aliases and splits out its arguments
+func (bot *Bot) parseCommand(message *Message) *Command {
+	location := commandPattern(bot.commandPrefixes(message.Channel)).FindStringSubmatchIndex(message.Text)
+	if location == nil {
+		return nil
+	}
+
+	return &Command{
+		Message: message,
+		Prefix:  message.Text[location[2]:location[3]],
+		Name:    bot.resolveCommandAlias(message.Channel, message.Text[location[4]:location[5]]),
+		Args:    strings.Fields(message.Text[location[1]:]),
+	}
+}
@@ -0,0 +1,140 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pronounsAPI             = "https://pronouns.alejo.io/api"
+	defaultPronounsCacheTTL = time.Hour
+)
+
+type pronounUser struct {
+	PronounID string `json:"pronoun_id"`
+}
+
+type pronounName struct {
+	Name    string `json:"name"`
+	Display string `json:"display"`
+}
+
+// Display names for pronoun IDs like "theythem", fetched once
+var (
+	pronounNamesMutex sync.Mutex
+	pronounNames      map[string]string
+)
+
+func getPronounsJSON(client *http.Client, path string, value interface{}) error {
+	resp, err := client.Get(pronounsAPI + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+// FetchPronouns looks up the pronouns a user set on pronouns.alejo.io, e.g. "They/Them".
+// Users who haven't set any get an empty string.
+func FetchPronouns(login string) (string, error) {
+	return fetchPronouns(&http.Client{Timeout: 5 * time.Second}, login)
+}
+
+func fetchPronouns(client *http.Client, login string) (string, error) {
+	names, err := fetchPronounNames(client)
+	if err != nil {
+		return "", errors.New("FetchPronouns: " + err.Error())
+	}
+
+	users := []pronounUser{}
+	err = getPronounsJSON(client, "/users/"+url.PathEscape(strings.ToLower(login)), &users)
+	if err != nil {
+		return "", errors.New("FetchPronouns: " + err.Error())
+	}
+
+	if len(users) == 0 {
+		return "", nil
+	}
+
+	display, ok := names[users[0].PronounID]
+	if !ok {
+		return users[0].PronounID, nil
+	}
+
+	return display, nil
+}
+
+func fetchPronounNames(client *http.Client) (map[string]string, error) {
+	pronounNamesMutex.Lock()
+	defer pronounNamesMutex.Unlock()
+
+	if pronounNames != nil {
+		return pronounNames, nil
+	}
+
+	names := []pronounName{}
+	err := getPronounsJSON(client, "/pronouns", &names)
+	if err != nil {
+		return nil, err
+	}
+
+	pronounNames = map[string]string{}
+	for _, name := range names {
+		pronounNames[name.Name] = name.Display
+	}
+
+	return pronounNames, nil
+}
+
+// Pronouns returns the sender's pronouns, preferring what they set with !pref over pronouns.alejo.io.
+// Lookups are cached for PronounsCacheTTL, and failures give an empty string.
+func (bot *Bot) Pronouns(message *Message) string {
+	pronouns := bot.UserPreferences(message).Pronouns
+	if pronouns != "" {
+		return pronouns
+	}
+
+	login := strings.ToLower(message.Username)
+	if cached, ok := bot.pronouns.get(login); ok {
+		return cached
+	}
+
+	pronouns, err := fetchPronouns(bot.httpClient(5*time.Second), login)
+	if err != nil {
+		logger.Warn(err.Error())
+		return ""
+	}
+
+	ttl := bot.PronounsCacheTTL
+	if ttl == 0 {
+		ttl = defaultPronounsCacheTTL
+	}
+	bot.pronouns.set(login, pronouns, ttl)
+
+	return pronouns
+}
+
+// Formats a username for the console, with pronouns when ShowPronouns is set
+func (bot *Bot) consoleName(message *Message) string {
+	if !bot.ShowPronouns {
+		return message.Username
+	}
+
+	pronouns := bot.Pronouns(message)
+	if pronouns == "" {
+		return message.Username
+	}
+
+	return message.Username + " (" + pronouns + ")"
+}
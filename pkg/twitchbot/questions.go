@@ -0,0 +1,204 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const defaultMaxQuestionsPerUser = 2
+
+// Question is a viewer's question waiting to be answered on stream
+type Question struct {
+	Username string    `json:"username"`
+	Text     string    `json:"text"`
+	AskedAt  time.Time `json:"asked_at"`
+
+	normalized string
+}
+
+type questionQueue struct {
+	mutex   sync.Mutex
+	pending map[string][]*Question
+
+	// The question currently being answered per channel, shown on the overlay
+	current map[string]*Question
+}
+
+// Reduces a question to lowercase letters and digits so rephrasings in case or punctuation match
+func normalizeQuestion(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, " ")
+}
+
+// Adds a question, returning a reason if it was refused
+func (bot *Bot) askQuestion(channel, username, text string) (string, bool) {
+	question := &Question{
+		Username:   username,
+		Text:       text,
+		AskedAt:    time.Now(),
+		normalized: normalizeQuestion(text),
+	}
+
+	if question.normalized == "" {
+		return "that isn't a question", false
+	}
+
+	limit := bot.MaxQuestionsPerUser
+	if limit == 0 {
+		limit = defaultMaxQuestionsPerUser
+	}
+
+	queue := &bot.questions
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if queue.pending == nil {
+		queue.pending = map[string][]*Question{}
+		queue.current = map[string]*Question{}
+	}
+
+	asked := 0
+	for _, pending := range queue.pending[channel] {
+		if pending.normalized == question.normalized {
+			return "that question has already been asked", false
+		}
+
+		if strings.EqualFold(pending.Username, username) {
+			asked++
+		}
+	}
+
+	if asked >= limit {
+		return fmt.Sprintf("you already have %d questions waiting", asked), false
+	}
+
+	queue.pending[channel] = append(queue.pending[channel], question)
+
+	return "", true
+}
+
+// NextQuestion takes the oldest question off a channel's queue and puts it on the overlay
+func (bot *Bot) NextQuestion(channel string) (*Question, bool) {
+	queue := &bot.questions
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	pending := queue.pending[channel]
+	if len(pending) == 0 {
+		delete(queue.current, channel)
+		return nil, false
+	}
+
+	next := pending[0]
+	queue.pending[channel] = pending[1:]
+	queue.current[channel] = next
+
+	return next, true
+}
+
+// PendingQuestions lists a channel's questions oldest first
+func (bot *Bot) PendingQuestions(channel string) []Question {
+	queue := &bot.questions
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	questions := make([]Question, 0, len(queue.pending[channel]))
+	for _, question := range queue.pending[channel] {
+		questions = append(questions, *question)
+	}
+
+	return questions
+}
+
+// Removes every pending question from a user, e.g. after a moderator rejects them
+func (bot *Bot) removeQuestions(channel, username string) int {
+	queue := &bot.questions
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	kept := []*Question{}
+	for _, question := range queue.pending[channel] {
+		if !strings.EqualFold(question.Username, username) {
+			kept = append(kept, question)
+		}
+	}
+
+	removed := len(queue.pending[channel]) - len(kept)
+	if queue.pending != nil {
+		queue.pending[channel] = kept
+	}
+
+	return removed
+}
+
+// !ask <question>
+func askCommand(bot *Bot, command *Command) {
+	text := strings.Join(command.Args, " ")
+	if text == "" {
+		bot.Reply(command.Message, "Usage: !ask <question>")
+		return
+	}
+
+	reason, ok := bot.askQuestion(command.Channel, command.Username, text)
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("@%s %s.", command.Username, reason))
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s your question is #%d in the queue.", command.Username, len(bot.PendingQuestions(command.Channel))))
+}
+
+// !nextq
+func nextQuestionCommand(bot *Bot, command *Command) {
+	question, ok := bot.NextQuestion(command.Channel)
+	if !ok {
+		bot.Reply(command.Message, "No questions waiting.")
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Question from @%s: %s", question.Username, question.Text))
+}
+
+// !removeq <user>
+func removeQuestionsCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !removeq <user>")
+		return
+	}
+
+	username := strings.TrimPrefix(command.Args[0], "@")
+	removed := bot.removeQuestions(command.Channel, username)
+	bot.Reply(command.Message, fmt.Sprintf("Removed %d question(s) from @%s.", removed, username))
+}
+
+// Serves the question a moderator picked with !nextq, e.g. /overlay/questions?channel=mikkeever.
+// Nobody has looked at the waiting questions yet, so they're only included for requests
+// authorizedRequest lets through.
+func (bot *Bot) serveQuestions(w http.ResponseWriter, r *http.Request) {
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	bot.questions.mutex.Lock()
+	response := struct {
+		Current *Question  `json:"current"`
+		Pending []Question `json:"pending,omitempty"`
+	}{Current: bot.questions.current[channel]}
+	bot.questions.mutex.Unlock()
+	if bot.authorizedRequest(r) {
+		response.Pending = bot.PendingQuestions(channel)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
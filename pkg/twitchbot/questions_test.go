@@ -0,0 +1,50 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+func TestServeQuestionsHidesPending(t *testing.T) {
+	bot := newClaimingBots(t, store.NewMemory(), 1)[0]
+	bot.AdminToken = "secret"
+	bot.askQuestion("mikkeever", "alice", "What's your favourite Chuck Norris fact?")
+	bot.NextQuestion("mikkeever")
+	bot.askQuestion("mikkeever", "troll", "something nobody has checked")
+
+	serve := func(token string) map[string]json.RawMessage {
+		request := httptest.NewRequest(http.MethodGet, "/overlay/questions?channel=mikkeever", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		bot.serveQuestions(recorder, request)
+
+		response := map[string]json.RawMessage{}
+		err := json.Unmarshal(recorder.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	overlay := serve("")
+	if _, ok := overlay["pending"]; ok {
+		t.Errorf("the overlay got the unchecked questions: %s", overlay["pending"])
+	}
+	current := &Question{}
+	json.Unmarshal(overlay["current"], current)
+	if current.Username != "alice" {
+		t.Errorf("current question = %+v, want alice's", current)
+	}
+
+	pending := []Question{}
+	json.Unmarshal(serve("secret")["pending"], &pending)
+	if len(pending) != 1 || pending[0].Username != "troll" {
+		t.Errorf("pending with the admin token = %+v, want the troll's question", pending)
+	}
+}
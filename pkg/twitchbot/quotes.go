@@ -0,0 +1,242 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	quotesBucket   = "quotes"
+	quoteIDsBucket = "quote_ids"
+)
+
+// Quote is something memorable said on stream
+type Quote struct {
+	ID      int       `json:"id"`
+	Channel string    `json:"channel"`
+	Text    string    `json:"text"`
+	Quoted  string    `json:"quoted"`
+	Game    string    `json:"game,omitempty"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+
+	// Keeps the quote attributed to the right user after they change their name
+	QuotedID string `json:"quoted_id,omitempty"`
+}
+
+func quoteKey(channel string, id int) string {
+	return channel + "/" + strconv.Itoa(id)
+}
+
+// The IDs of a channel's quotes, smallest first
+func (bot *Bot) quoteIDs(channel string) ([]int, error) {
+	keys, err := bot.Store.Keys(quotesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimPrefix(key, channel+"/"))
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	// Keys sort as strings, so 10 comes before 9
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// AddQuote saves a quote under the channel's next ID. IDs of deleted quotes are not reused.
+func (bot *Bot) AddQuote(quote Quote) (*Quote, error) {
+	bot.quoteMutex.Lock()
+	defer bot.quoteMutex.Unlock()
+
+	ids, err := bot.quoteIDs(quote.Channel)
+	if err != nil {
+		return nil, errors.New("Bot.AddQuote: " + err.Error())
+	}
+
+	// The last ID is kept separately so deleting the newest quote doesn't free its number
+	lastID := 0
+	_, err = bot.Store.Get(quoteIDsBucket, quote.Channel, &lastID)
+	if err != nil {
+		return nil, errors.New("Bot.AddQuote: " + err.Error())
+	}
+
+	quote.ID = lastID + 1
+	if len(ids) > 0 && ids[len(ids)-1] >= quote.ID {
+		quote.ID = ids[len(ids)-1] + 1
+	}
+
+	err = bot.Store.Put(quoteIDsBucket, quote.Channel, quote.ID)
+	if err != nil {
+		return nil, errors.New("Bot.AddQuote: " + err.Error())
+	}
+
+	if quote.AddedAt.IsZero() {
+		quote.AddedAt = time.Now()
+	}
+
+	err = bot.Store.Put(quotesBucket, quoteKey(quote.Channel, quote.ID), quote)
+	if err != nil {
+		return nil, errors.New("Bot.AddQuote: " + err.Error())
+	}
+
+	return &quote, nil
+}
+
+// Quote looks up a channel's quote by ID
+func (bot *Bot) Quote(channel string, id int) (*Quote, bool) {
+	quote := &Quote{}
+	ok, err := bot.Store.Get(quotesBucket, quoteKey(channel, id), quote)
+	if err != nil {
+		logger.Warn("Bot.Quote: %s", err.Error())
+		return nil, false
+	}
+
+	return quote, ok
+}
+
+// RandomQuote picks one of a channel's quotes
+func (bot *Bot) RandomQuote(channel string) (*Quote, bool) {
+	ids, err := bot.quoteIDs(channel)
+	if err != nil {
+		logger.Warn("Bot.RandomQuote: %s", err.Error())
+		return nil, false
+	}
+
+	if len(ids) == 0 {
+		return nil, false
+	}
+
+	return bot.Quote(channel, ids[bot.Random.Intn(len(ids))])
+}
+
+// DeleteQuote removes a quote. It can be brought back with Restore for TrashRetention.
+func (bot *Bot) DeleteQuote(channel string, id int, deletedBy string) error {
+	quote, ok := bot.Quote(channel, id)
+	if !ok {
+		return nil
+	}
+
+	err := bot.trash(trashQuote, channel, strconv.Itoa(id), deletedBy, quote)
+	if err != nil {
+		return errors.New("Bot.DeleteQuote: " + err.Error())
+	}
+
+	err = bot.Store.Delete(quotesBucket, quoteKey(channel, id))
+	if err != nil {
+		return errors.New("Bot.DeleteQuote: " + err.Error())
+	}
+
+	return nil
+}
+
+func formatQuote(quote *Quote) string {
+	text := fmt.Sprintf("Quote #%d: \"%s\" - %s", quote.ID, quote.Text, quote.Quoted)
+	if quote.Game != "" {
+		text += " playing " + quote.Game
+	}
+
+	return text + ", " + quote.AddedAt.Format("Jan 2 2006")
+}
+
+// !addquote [@user] <text>. Quotes the broadcaster unless a user is given.
+func addQuoteCommand(bot *Bot, command *Command) {
+	args := command.Args
+	quoted := command.Channel
+	quotedID := command.Tags["room-id"]
+	if len(args) > 1 && strings.HasPrefix(args[0], "@") {
+		quoted = strings.TrimPrefix(args[0], "@")
+		quotedID, _ = bot.UserIDForLogin(quoted)
+		args = args[1:]
+	}
+
+	text := strings.Trim(strings.Join(args, " "), "\"")
+	if text == "" {
+		bot.Reply(command.Message, "Usage: !addquote [@user] <text>")
+		return
+	}
+
+	quote, err := bot.AddQuote(Quote{
+		Channel:  command.Channel,
+		Text:     text,
+		Quoted:   quoted,
+		QuotedID: quotedID,
+		Game:     bot.Game(command.Channel),
+		AddedBy:  command.Username,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Added quote #%d.", quote.ID))
+}
+
+// !quote [id]
+func quoteCommand(bot *Bot, command *Command) {
+	var quote *Quote
+	var ok bool
+
+	if len(command.Args) > 0 {
+		id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#"))
+		if err != nil {
+			bot.Reply(command.Message, "Usage: !quote [number]")
+			return
+		}
+
+		quote, ok = bot.Quote(command.Channel, id)
+		if !ok {
+			bot.Reply(command.Message, fmt.Sprintf("There is no quote #%d.", id))
+			return
+		}
+	} else {
+		quote, ok = bot.RandomQuote(command.Channel)
+		if !ok {
+			bot.Reply(command.Message, "There are no quotes yet. Add one with !addquote.")
+			return
+		}
+	}
+
+	quote.Quoted = bot.CurrentLogin(quote.QuotedID, quote.Quoted)
+	bot.Reply(command.Message, formatQuote(quote))
+}
+
+// !delquote <id>
+func deleteQuoteCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !delquote <number>")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#"))
+	if err != nil {
+		bot.Reply(command.Message, "Usage: !delquote <number>")
+		return
+	}
+
+	if _, ok := bot.Quote(command.Channel, id); !ok {
+		bot.Reply(command.Message, fmt.Sprintf("There is no quote #%d.", id))
+		return
+	}
+
+	err = bot.DeleteQuote(command.Channel, id, command.Username)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Deleted quote #%d. Undo with !restore quote %d", id, id))
+}
@@ -0,0 +1,278 @@
+package twitchbot
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+const raffleReceiptsBucket = "raffle_receipts"
+
+// Describes how a winner is chosen so anyone holding a receipt can repeat the draw
+const raffleAlgorithm = "entrants sorted and joined by \\n; digest = sha256(seed bytes + sha256(entrants)); winner = entrants[uint64(digest[0:8]) % count]"
+
+// RaffleReceipt records everything needed to audit a raffle draw
+type RaffleReceipt struct {
+	ID           int       `json:"id"`
+	Channel      string    `json:"channel"`
+	DrawnAt      time.Time `json:"drawn_at"`
+	Seed         string    `json:"seed"`
+	EntrantsHash string    `json:"entrants_sha256"`
+	Entrants     []string  `json:"entrants"`
+	Algorithm    string    `json:"algorithm"`
+	Winner       string    `json:"winner"`
+}
+
+type raffle struct {
+	entrants map[string]bool
+}
+
+type raffles struct {
+	mutex sync.Mutex
+	open  map[string]*raffle
+
+	// Held while a receipt ID is picked and stored, for Stores that aren't a store.Claimer
+	receipts sync.Mutex
+}
+
+// Hashes the sorted entrant list so the receipt pins down exactly who was in the draw
+func hashEntrants(entrants []string) []byte {
+	sum := sha256.Sum256([]byte(strings.Join(entrants, "\n")))
+	return sum[:]
+}
+
+// DrawRaffleWinner repeats the selection described by a receipt's algorithm.
+// entrants must already be sorted.
+func DrawRaffleWinner(seed []byte, entrants []string) string {
+	if len(entrants) == 0 {
+		return ""
+	}
+
+	digest := sha256.Sum256(append(append([]byte{}, seed...), hashEntrants(entrants)...))
+	index := binary.BigEndian.Uint64(digest[:8]) % uint64(len(entrants))
+
+	return entrants[index]
+}
+
+// VerifyRaffleReceipt checks that a receipt's entrants, seed and winner are consistent
+func VerifyRaffleReceipt(receipt *RaffleReceipt) bool {
+	seed, err := hex.DecodeString(receipt.Seed)
+	if err != nil {
+		return false
+	}
+
+	entrants := append([]string{}, receipt.Entrants...)
+	sort.Strings(entrants)
+
+	return hex.EncodeToString(hashEntrants(entrants)) == receipt.EntrantsHash &&
+		DrawRaffleWinner(seed, entrants) == receipt.Winner
+}
+
+// Picks a winner for the channel's raffle, stores the receipt and closes the raffle. Returns
+// false when nobody entered. The raffle is taken out of the open ones before the draw, so it
+// can't be drawn twice, and put back if the receipt can't be stored.
+func (bot *Bot) drawRaffle(channel string) (*RaffleReceipt, bool, error) {
+	bot.raffles.mutex.Lock()
+	current := bot.raffles.open[channel]
+	if current == nil || len(current.entrants) == 0 {
+		bot.raffles.mutex.Unlock()
+		return nil, false, nil
+	}
+	delete(bot.raffles.open, channel)
+
+	entrants := make([]string, 0, len(current.entrants))
+	for entrant := range current.entrants {
+		entrants = append(entrants, entrant)
+	}
+	bot.raffles.mutex.Unlock()
+	sort.Strings(entrants)
+
+	seed := make([]byte, 16)
+	for i := range seed {
+		seed[i] = byte(bot.Random.Intn(256))
+	}
+
+	receipt := &RaffleReceipt{
+		Channel:      channel,
+		DrawnAt:      time.Now().UTC(),
+		Seed:         hex.EncodeToString(seed),
+		EntrantsHash: hex.EncodeToString(hashEntrants(entrants)),
+		Entrants:     entrants,
+		Algorithm:    raffleAlgorithm,
+		Winner:       DrawRaffleWinner(seed, entrants),
+	}
+
+	err := bot.storeRaffleReceipt(receipt)
+	if err != nil {
+		// Unless a new raffle was opened in the meantime
+		bot.raffles.mutex.Lock()
+		if bot.raffles.open[channel] == nil {
+			bot.raffles.open[channel] = current
+		}
+		bot.raffles.mutex.Unlock()
+
+		return nil, true, fmt.Errorf("Bot.drawRaffle: %s", err.Error())
+	}
+
+	return receipt, true, nil
+}
+
+// The highest receipt ID in the Store
+func (bot *Bot) lastRaffleID() (int, error) {
+	keys, err := bot.Store.Keys(raffleReceiptsBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	last := 0
+	for _, key := range keys {
+		id, err := strconv.Atoi(key)
+		if err == nil && id > last {
+			last = id
+		}
+	}
+
+	return last, nil
+}
+
+// Stores a receipt under the next free ID. With a store.Claimer the ID is claimed, so instances
+// sharing the Store never overwrite each other's receipts.
+func (bot *Bot) storeRaffleReceipt(receipt *RaffleReceipt) error {
+	claimer, ok := bot.Store.(store.Claimer)
+	if !ok {
+		bot.raffles.receipts.Lock()
+		defer bot.raffles.receipts.Unlock()
+	}
+
+	last, err := bot.lastRaffleID()
+	if err != nil {
+		return err
+	}
+	receipt.ID = last + 1
+
+	if !ok {
+		return bot.Store.Put(raffleReceiptsBucket, strconv.Itoa(receipt.ID), receipt)
+	}
+
+	for {
+		claimed, err := claimer.PutIfAbsent(raffleReceiptsBucket, strconv.Itoa(receipt.ID), receipt)
+		if err != nil || claimed {
+			return err
+		}
+		receipt.ID++
+	}
+}
+
+// RaffleReceipt looks up the receipt for a past draw
+func (bot *Bot) RaffleReceipt(id int) (*RaffleReceipt, bool, error) {
+	receipt := &RaffleReceipt{}
+	ok, err := bot.Store.Get(raffleReceiptsBucket, strconv.Itoa(id), receipt)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return receipt, true, nil
+}
+
+// !raffle open | !raffle draw
+func raffleCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !raffle open | !raffle draw")
+		return
+	}
+
+	switch strings.ToLower(command.Args[0]) {
+	case "open":
+		bot.raffles.mutex.Lock()
+		bot.raffles.open[command.Channel] = &raffle{entrants: map[string]bool{}}
+		bot.raffles.mutex.Unlock()
+
+		bot.Reply(command.Message, "A raffle is open! Type !join to enter.")
+	case "draw":
+		receipt, entered, err := bot.drawRaffle(command.Channel)
+		if err != nil {
+			logger.Error(err.Error())
+			bot.Reply(command.Message, "Couldn't draw the raffle, try again in a bit.")
+			return
+		}
+		if !entered {
+			bot.Reply(command.Message, "Nobody entered the raffle.")
+			return
+		}
+
+		logger.Success("< Raffle #%d in #%s won by %s", receipt.ID, receipt.Channel, receipt.Winner)
+		bot.Reply(command.Message, fmt.Sprintf("@%s wins raffle #%d out of %d entrants! Type !rafflereceipt %d to audit the draw.", receipt.Winner, receipt.ID, len(receipt.Entrants), receipt.ID))
+	}
+}
+
+// !join enters the open raffle
+func joinRaffleCommand(bot *Bot, command *Command) {
+	bot.raffles.mutex.Lock()
+	defer bot.raffles.mutex.Unlock()
+
+	current := bot.raffles.open[command.Channel]
+	if current == nil {
+		return
+	}
+
+	current.entrants[strings.ToLower(command.Username)] = true
+}
+
+// !rafflereceipt <id>
+func raffleReceiptCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !rafflereceipt <id>")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#"))
+	if err != nil {
+		bot.Reply(command.Message, "Usage: !rafflereceipt <id>")
+		return
+	}
+
+	receipt, ok, err := bot.RaffleReceipt(id)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if !ok {
+		bot.Reply(command.Message, fmt.Sprintf("There is no raffle #%d.", id))
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("Raffle #%d: winner %s of %d entrants, seed %s, entrants sha256 %s, drawn %s",
+		receipt.ID, receipt.Winner, len(receipt.Entrants), receipt.Seed, receipt.EntrantsHash, receipt.DrawnAt.Format(time.RFC3339)))
+}
+
+// Serves a receipt as JSON, e.g. /raffles?id=3
+func (bot *Bot) serveRaffleReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	receipt, ok, err := bot.RaffleReceipt(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
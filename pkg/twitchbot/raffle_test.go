@@ -0,0 +1,147 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// A Store whose writes fail, like a full disk
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) Put(bucket, key string, value interface{}) error {
+	return errors.New("disk full")
+}
+
+// A Store that takes a while to write, so draws overlap
+type slowStore struct {
+	store.Store
+}
+
+func (slow slowStore) Put(bucket, key string, value interface{}) error {
+	time.Sleep(5 * time.Millisecond)
+	return slow.Store.Put(bucket, key, value)
+}
+
+func (slow slowStore) PutIfAbsent(bucket, key string, value interface{}) (bool, error) {
+	time.Sleep(5 * time.Millisecond)
+	return slow.Store.(store.Claimer).PutIfAbsent(bucket, key, value)
+}
+
+func openRaffle(bot *Bot, channel string, entrants ...string) {
+	bot.raffles.mutex.Lock()
+	defer bot.raffles.mutex.Unlock()
+
+	if bot.raffles.open == nil {
+		bot.raffles.open = map[string]*raffle{}
+	}
+	current := &raffle{entrants: map[string]bool{}}
+	for _, entrant := range entrants {
+		current.entrants[entrant] = true
+	}
+	bot.raffles.open[channel] = current
+}
+
+func TestDrawRaffleOnce(t *testing.T) {
+	bot := newClaimingBots(t, slowStore{store.NewMemory()}, 1)[0]
+	openRaffle(bot, "mikkeever", "alice", "bob", "carol")
+
+	var wait sync.WaitGroup
+	var mutex sync.Mutex
+	receipts := []*RaffleReceipt{}
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			receipt, entered, err := bot.drawRaffle("mikkeever")
+			if err != nil {
+				t.Error(err)
+			}
+			if entered {
+				mutex.Lock()
+				receipts = append(receipts, receipt)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wait.Wait()
+
+	if len(receipts) != 1 {
+		t.Fatalf("the raffle was drawn %d times, want 1", len(receipts))
+	}
+	if !VerifyRaffleReceipt(receipts[0]) {
+		t.Errorf("receipt %+v doesn't verify", receipts[0])
+	}
+}
+
+func TestDrawRaffleKeepsItOpenOnStoreError(t *testing.T) {
+	bot := newClaimingBots(t, store.NewMemory(), 1)[0]
+	openRaffle(bot, "mikkeever", "alice")
+	working := bot.Store
+
+	bot.Store = failingStore{working}
+	_, entered, err := bot.drawRaffle("mikkeever")
+	if !entered || err == nil {
+		t.Fatalf("drawRaffle = %v, %v, want an error", entered, err)
+	}
+
+	bot.Store = working
+	receipt, entered, err := bot.drawRaffle("mikkeever")
+	if !entered || err != nil {
+		t.Fatalf("retrying drawRaffle = %v, %v", entered, err)
+	}
+	if receipt.Winner != "alice" {
+		t.Errorf("Winner = %q, want alice", receipt.Winner)
+	}
+
+	_, entered, _ = bot.drawRaffle("mikkeever")
+	if entered {
+		t.Error("the raffle is still open after it was drawn")
+	}
+}
+
+// Instances sharing a Store each draw raffles at the same time. No receipt may be overwritten.
+func TestRaffleReceiptIDsAcrossBots(t *testing.T) {
+	shared := slowStore{store.NewMemory()}
+	bots := newClaimingBots(t, shared, 4)
+	draws := 5
+
+	var wait sync.WaitGroup
+	for b, bot := range bots {
+		wait.Add(1)
+		go func(b int, bot *Bot) {
+			defer wait.Done()
+
+			for i := 0; i < draws; i++ {
+				channel := fmt.Sprintf("channel%d", b)
+				openRaffle(bot, channel, "alice", "bob")
+				_, _, err := bot.drawRaffle(channel)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(b, bot)
+	}
+	wait.Wait()
+
+	keys, err := shared.Keys(raffleReceiptsBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(bots)*draws {
+		t.Fatalf("%d receipts were stored, want %d", len(keys), len(bots)*draws)
+	}
+
+	for id := 1; id <= len(keys); id++ {
+		if _, ok, _ := bots[0].RaffleReceipt(id); !ok {
+			t.Errorf("there is no receipt #%d", id)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package twitchbot
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+// Thanks a raider in chat with RaidShoutout and, with RaidHelixShoutout, Twitch's own shoutout
+func (bot *Bot) shoutoutRaider(ircMessage *irc.Message) {
+	channel := ircMessage.Channel()
+	template := bot.channelResponse(channel, func(settings ChannelSettings) string { return settings.RaidShoutout }, &bot.RaidShoutout)
+	if bot.Anonymous || (template == "" && !bot.RaidHelixShoutout) {
+		return
+	}
+
+	raider := &Message{
+		Type:        "USERNOTICE",
+		Channel:     channel,
+		Username:    strings.ToLower(ircMessage.Tags["msg-param-login"]),
+		DisplayName: ircMessage.Tags["msg-param-displayName"],
+		UserID:      ircMessage.Tags["user-id"],
+		Tags:        ircMessage.Tags,
+		IRC:         ircMessage,
+	}
+	if raider.DisplayName == "" {
+		raider.DisplayName = raider.Username
+	}
+	viewers, _ := strconv.Atoi(ircMessage.Tags["msg-param-viewerCount"])
+
+	// Looking up the game and clip can take a moment, so leave the reader to it
+	go func() {
+		if template != "" {
+			values := map[string]string{
+				"raider":  raider.Username,
+				"viewers": strconv.Itoa(viewers),
+				"game":    "",
+				"clip":    "",
+			}
+			if strings.Contains(template, "$(game") {
+				values["game"] = bot.lastGame(raider.UserID)
+			}
+			if strings.Contains(template, "$(clip") {
+				values["clip"] = bot.shoutoutClip(raider.Username)
+			}
+
+			text := strings.TrimSpace(bot.RenderTemplate(template, &Command{Message: raider}, values))
+			bot.ChatWithPriority(channel, text, PrioritySystem)
+		}
+
+		if bot.RaidHelixShoutout && bot.isLeader() {
+			err := bot.sendShoutout(channel, raider)
+			if err != nil {
+				logger.Warn(err.Error())
+			}
+		}
+	}()
+}
+
+// The category a channel is set to, which is what it last streamed. Empty without a ClientID
+// or if Helix doesn't answer.
+func (bot *Bot) lastGame(broadcasterID string) string {
+	if bot.ClientID == "" || broadcasterID == "" {
+		return ""
+	}
+
+	channels, err := bot.helix().GetChannelInformation(bot.context(), []string{broadcasterID})
+	if err != nil {
+		logger.Warn("Bot.lastGame: %s", err.Error())
+		return ""
+	}
+	if len(channels) == 0 {
+		return ""
+	}
+
+	return channels[0].GameName
+}
+
+func (bot *Bot) sendShoutout(channel string, raider *Message) error {
+	if bot.ClientID == "" {
+		return errors.New("Bot.sendShoutout: raid_helix_shoutout needs a client_id")
+	}
+	if raider.UserID == "" {
+		return errors.New("Bot.sendShoutout: Twitch didn't say who raided")
+	}
+
+	moderatorID, err := bot.botUserID()
+	if err != nil {
+		return errors.New("Bot.sendShoutout: " + err.Error())
+	}
+
+	broadcasterID, err := bot.channelUserID(channel)
+	if err != nil {
+		return errors.New("Bot.sendShoutout: " + err.Error())
+	}
+
+	err = bot.helix().SendShoutout(bot.context(), broadcasterID, raider.UserID, moderatorID)
+	if err != nil {
+		return errors.New("Bot.sendShoutout: " + err.Error())
+	}
+
+	logger.Quiet("Gave @%s a shoutout in #%s", raider.Username, channel)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package twitchbot
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RandomSource supplies all of the randomness used by commands and games
+type RandomSource interface {
+	// Intn returns a number in [0, n). It panics if n <= 0.
+	Intn(n int) int
+}
+
+type seededRandom struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewSeededRandom creates a deterministic RandomSource. The same seed always produces the
+// same sequence, which keeps tests and replays predictable.
+func NewSeededRandom(seed int64) RandomSource {
+	return &seededRandom{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (random *seededRandom) Intn(n int) int {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+
+	return random.rand.Intn(n)
+}
+
+// CryptoRandom is a RandomSource backed by crypto/rand for giveaways where fairness matters
+type CryptoRandom struct{}
+
+// Intn returns an unbiased number in [0, n) from the operating system's secure generator
+func (CryptoRandom) Intn(n int) int {
+	if n <= 0 {
+		panic("CryptoRandom.Intn: invalid argument")
+	}
+
+	// Reject values from the incomplete final range so every result is equally likely
+	max := ^uint64(0) - (^uint64(0) % uint64(n))
+	buffer := make([]byte, 8)
+	for {
+		_, err := cryptorand.Read(buffer)
+		if err != nil {
+			panic("CryptoRandom.Intn: " + err.Error())
+		}
+
+		value := binary.BigEndian.Uint64(buffer)
+		if value < max {
+			return int(value % uint64(n))
+		}
+	}
+}
+
+// Picks a random element of options using the Bot's RandomSource
+func (bot *Bot) pickRandom(options []string) string {
+	if len(options) == 0 {
+		return ""
+	}
+
+	return options[bot.Random.Intn(len(options))]
+}
+
+func defaultRandomSource() RandomSource {
+	return NewSeededRandom(time.Now().UnixNano())
+}
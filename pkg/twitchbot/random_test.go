@@ -0,0 +1,104 @@
+package twitchbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mike1104/chuckbot/pkg/store"
+)
+
+// A RandomSource that returns the given numbers in turn
+type scriptedRandom struct {
+	values []int
+}
+
+func (random *scriptedRandom) Intn(n int) int {
+	value := random.values[0] % n
+	random.values = random.values[1:]
+	return value
+}
+
+// A Bot that can't reach the Chuck Norris API, so facts come from fallbackChuckFacts
+func offlineBot(t *testing.T, random RandomSource) *Bot {
+	bot := newClaimingBots(t, store.NewMemory(), 1)[0]
+	bot.Random = random
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bot.ctx = ctx
+
+	return bot
+}
+
+func TestSeededRandomRepeats(t *testing.T) {
+	first, second := NewSeededRandom(42), NewSeededRandom(42)
+	for i := 0; i < 100; i++ {
+		if a, b := first.Intn(1000), second.Intn(1000); a != b {
+			t.Fatalf("draw %d: %d and %d from the same seed", i, a, b)
+		}
+	}
+}
+
+func TestFallbackFactChoice(t *testing.T) {
+	bot := offlineBot(t, &scriptedRandom{values: []int{2, 0}})
+	if fact := bot.fetchChuckFact(); fact != fallbackChuckFacts[2] {
+		t.Errorf("fetchChuckFact() = %q, want %q", fact, fallbackChuckFacts[2])
+	}
+	if fact := bot.fetchChuckFact(); fact != fallbackChuckFacts[0] {
+		t.Errorf("fetchChuckFact() = %q, want %q", fact, fallbackChuckFacts[0])
+	}
+
+	first, second := offlineBot(t, NewSeededRandom(7)), offlineBot(t, NewSeededRandom(7))
+	for i := 0; i < 10; i++ {
+		if a, b := first.fetchChuckFact(), second.fetchChuckFact(); a != b {
+			t.Fatalf("fact %d: %q and %q from the same seed", i, a, b)
+		}
+	}
+}
+
+func TestDrawRaffleWinner(t *testing.T) {
+	entrants := []string{"alice", "bob", "carol", "dave"}
+
+	// Worked out by hand from raffleAlgorithm
+	if winner := DrawRaffleWinner(make([]byte, 16), entrants); winner != "bob" {
+		t.Errorf("winner with a zero seed = %q, want bob", winner)
+	}
+	if winner := DrawRaffleWinner([]byte("chuckbot"), entrants); winner != "carol" {
+		t.Errorf("winner with seed \"chuckbot\" = %q, want carol", winner)
+	}
+	if winner := DrawRaffleWinner(make([]byte, 16), nil); winner != "" {
+		t.Errorf("winner of an empty raffle = %q, want none", winner)
+	}
+}
+
+func TestDrawRaffleWithSeededRandom(t *testing.T) {
+	receipts := []*RaffleReceipt{}
+	for i := 0; i < 2; i++ {
+		bot := offlineBot(t, NewSeededRandom(1234))
+		openRaffle(bot, "mikkeever", "dave", "carol", "bob", "alice")
+
+		receipt, _, err := bot.drawRaffle("mikkeever")
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	if receipts[0].Seed != receipts[1].Seed || receipts[0].Winner != receipts[1].Winner {
+		t.Errorf("draws from the same seed differ: %+v and %+v", receipts[0], receipts[1])
+	}
+
+	bot := offlineBot(t, &scriptedRandom{values: make([]int, 16)})
+	openRaffle(bot, "mikkeever", "dave", "carol", "bob", "alice")
+	receipt, _, err := bot.drawRaffle("mikkeever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Seed != strings.Repeat("00", 16) || receipt.Winner != "bob" {
+		t.Errorf("receipt = %+v, want a zero seed won by bob", receipt)
+	}
+	if !VerifyRaffleReceipt(receipt) {
+		t.Errorf("receipt %+v doesn't verify", receipt)
+	}
+}
@@ -0,0 +1,91 @@
+package twitchbot
+
+import (
+	"sync"
+	"time"
+)
+
+// Twitch allows 20 messages per 30 seconds, or 100 in channels where the bot is a moderator or
+// the broadcaster. Going over gets the account locked out of chat for a while.
+const (
+	chatRateWindow      = 30 * time.Second
+	chatRateLimitNormal = 20
+	chatRateLimitMod    = 100
+
+	// Messages that may go out back to back before the rate applies
+	chatBurstNormal = 5
+	chatBurstMod    = 20
+)
+
+// A token bucket. Its refill rate leaves room for a full burst, so no window ever holds more
+// than the limit.
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	perToken time.Duration
+	updated  time.Time
+}
+
+// A bucket allowing limit per window, burst of them back to back
+func newTokenBucket(window time.Duration, limit, burst int) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(burst),
+		capacity: float64(burst),
+		perToken: window / time.Duration(limit-burst),
+		updated:  time.Now(),
+	}
+}
+
+// How long until a token is available
+func (bucket *tokenBucket) wait(now time.Time) time.Duration {
+	bucket.tokens += float64(now.Sub(bucket.updated)) / float64(bucket.perToken)
+	if bucket.tokens > bucket.capacity {
+		bucket.tokens = bucket.capacity
+	}
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - bucket.tokens) * float64(bucket.perToken))
+}
+
+// Keeps outgoing chat within Twitch's limits. Every message takes from the moderator bucket;
+// messages to channels where the bot isn't privileged also take from the normal one.
+type chatRateLimiter struct {
+	mutex  sync.Mutex
+	all    *tokenBucket
+	normal *tokenBucket
+}
+
+// How long until a message may be sent. A zero wait takes the tokens, so the
+// message should be sent right away.
+func (limiter *chatRateLimiter) delay(privileged bool) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.all == nil {
+		limiter.all = newTokenBucket(chatRateWindow, chatRateLimitMod, chatBurstMod)
+		limiter.normal = newTokenBucket(chatRateWindow, chatRateLimitNormal, chatBurstNormal)
+	}
+
+	now := time.Now()
+	wait := limiter.all.wait(now)
+	if !privileged {
+		if normal := limiter.normal.wait(now); normal > wait {
+			wait = normal
+		}
+	}
+
+	if wait > 0 {
+		return wait
+	}
+
+	limiter.all.tokens--
+	if !privileged {
+		limiter.normal.tokens--
+	}
+
+	return 0
+}
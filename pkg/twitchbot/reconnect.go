@@ -0,0 +1,56 @@
+package twitchbot
+
+import (
+	"time"
+)
+
+const (
+	defaultMaxReconnectWait = 2 * time.Minute
+	defaultReconnectJitter  = 0.5
+)
+
+// Reduce the rate of reconnection attempts exponentially (first attempt is immediate), up to
+// MaxReconnectWait
+func (bot *Bot) backoffConnectionRate() {
+	bot.reconnectAttempts++
+
+	maxWait := bot.MaxReconnectWait
+	if maxWait <= 0 {
+		maxWait = defaultMaxReconnectWait
+	}
+
+	if bot.reconnectWaitTime == 0 {
+		bot.reconnectWaitTime = time.Second
+	} else {
+		bot.reconnectWaitTime *= 2
+	}
+
+	if bot.reconnectWaitTime > maxWait {
+		bot.reconnectWaitTime = maxWait
+	}
+}
+
+// Forgets failed attempts once Twitch has welcomed the Bot, so the next drop reconnects right away
+func (bot *Bot) resetConnectionRate() {
+	bot.reconnectAttempts = 0
+	bot.reconnectWaitTime = 0
+}
+
+// The wait before the next attempt, shortened by a random part of up to ReconnectJitter so that
+// many bots dropped at once don't all come back at the same moment
+func (bot *Bot) reconnectWait() time.Duration {
+	jitter := bot.ReconnectJitter
+	if jitter == 0 {
+		jitter = defaultReconnectJitter
+	}
+	if jitter < 0 || jitter > 1 {
+		jitter = 0
+	}
+
+	spread := int(float64(bot.reconnectWaitTime) * jitter / float64(time.Millisecond))
+	if spread <= 0 {
+		return bot.reconnectWaitTime
+	}
+
+	return bot.reconnectWaitTime - time.Duration(bot.Random.Intn(spread+1))*time.Millisecond
+}
@@ -0,0 +1,187 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mike1104/chuckbot/pkg/eventsub"
+)
+
+// Actions a RedemptionAction can take
+const (
+	RedemptionChuckNorris = "chucknorris"
+	RedemptionCommand     = "command"
+	RedemptionWebhook     = "webhook"
+)
+
+var redemptionActions = []string{RedemptionChuckNorris, RedemptionCommand, RedemptionWebhook}
+
+// RedemptionAction is what the bot does when a channel point reward is redeemed, as set in
+// redemption_actions
+type RedemptionAction struct {
+	// chucknorris, command or webhook
+	Action string `json:"action"`
+
+	// For command: the built-in or custom command to run, with what the viewer typed as its args
+	Command string `json:"command,omitempty"`
+
+	// For webhook: where the redemption is POSTed as JSON
+	Webhook string `json:"webhook,omitempty"`
+
+	// Sent in chat after the action, e.g. "$(user) spent $(cost) points on $(reward)!". $(reward),
+	// $(cost) and $(input) are filled in. Empty sends nothing.
+	Response string `json:"response,omitempty"`
+}
+
+// Redemption is someone spending channel points on a custom reward, as received over EventSub.
+// Message.Text is what they typed, if the reward asks for anything.
+type Redemption struct {
+	*Message
+
+	ID     string
+	Reward eventsub.Reward
+}
+
+// RedemptionHandler responds to the redemptions of one reward
+type RedemptionHandler func(bot *Bot, redemption *Redemption)
+
+// Handlers registered from code, by reward ID
+type redemptionHandlers struct {
+	mutex    sync.RWMutex
+	handlers map[string]RedemptionHandler
+}
+
+// RegisterRedemption runs handler whenever the reward with this ID is redeemed, instead of its
+// redemption_actions entry. Redemptions arrive over EventSub, so EventSubEvents needs
+// "redemptions".
+func (bot *Bot) RegisterRedemption(rewardID string, handler RedemptionHandler) {
+	bot.redemptionHandlers.mutex.Lock()
+	defer bot.redemptionHandlers.mutex.Unlock()
+
+	if bot.redemptionHandlers.handlers == nil {
+		bot.redemptionHandlers.handlers = map[string]RedemptionHandler{}
+	}
+	bot.redemptionHandlers.handlers[rewardID] = handler
+}
+
+// UnregisterRedemption stops handling a reward from code
+func (bot *Bot) UnregisterRedemption(rewardID string) {
+	bot.redemptionHandlers.mutex.Lock()
+	defer bot.redemptionHandlers.mutex.Unlock()
+
+	delete(bot.redemptionHandlers.handlers, rewardID)
+}
+
+// Checks a config's redemption_actions
+func validateRedemptionActions(actions map[string]RedemptionAction) error {
+	for reward, action := range actions {
+		switch strings.ToLower(action.Action) {
+		case RedemptionChuckNorris:
+		case RedemptionCommand:
+			if strings.TrimLeft(action.Command, "!?~") == "" {
+				return fmt.Errorf("redemption_actions.%s: command needs a command to run", reward)
+			}
+		case RedemptionWebhook:
+			if action.Webhook == "" {
+				return fmt.Errorf("redemption_actions.%s: webhook needs a webhook URL", reward)
+			}
+		default:
+			return fmt.Errorf("redemption_actions.%s: unknown action %q, use %s", reward, action.Action, strings.Join(redemptionActions, ", "))
+		}
+	}
+
+	return nil
+}
+
+// The RedemptionAction for a reward, looked up by its ID and then by its title
+func (bot *Bot) redemptionAction(reward eventsub.Reward) (RedemptionAction, bool) {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if action, ok := bot.RedemptionActions[reward.ID]; ok {
+		return action, true
+	}
+	for name, action := range bot.RedemptionActions {
+		if strings.EqualFold(name, reward.Title) {
+			return action, true
+		}
+	}
+
+	return RedemptionAction{}, false
+}
+
+// Runs the registered handler or the redemption_actions entry for a redemption
+func (bot *Bot) handleRedemption(message *Message, event eventsub.RedemptionEvent) {
+	redemption := &Redemption{Message: message, ID: event.ID, Reward: event.Reward}
+
+	bot.redemptionHandlers.mutex.RLock()
+	handler, registered := bot.redemptionHandlers.handlers[event.Reward.ID]
+	bot.redemptionHandlers.mutex.RUnlock()
+
+	action, configured := bot.redemptionAction(event.Reward)
+	if (!registered && !configured) || bot.Anonymous || !bot.claimMessage(message) {
+		return
+	}
+
+	if registered {
+		handler(bot, redemption)
+		return
+	}
+
+	bot.runRedemptionAction(redemption, action)
+}
+
+func (bot *Bot) runRedemptionAction(redemption *Redemption, action RedemptionAction) {
+	message := redemption.Message
+
+	switch strings.ToLower(action.Action) {
+	case RedemptionChuckNorris:
+		chuckNorrisCommand(bot, &Command{Message: message, Prefix: "!", Name: "chucknorris"})
+	case RedemptionCommand:
+		name := strings.ToLower(strings.TrimLeft(action.Command, "!?~"))
+		command := &Command{Message: message, Prefix: "!", Name: name, Args: strings.Fields(message.Text)}
+
+		// The viewer paid for it, so permissions and cooldowns don't apply
+		if registered, ok := bot.resolveCommand(message.Channel, name); ok {
+			bot.recordCommand(message, registered.Name)
+			registered.Handler(bot, command)
+		} else if !bot.runCustomCommand(command) && !bot.runCounterCommand(command) {
+			logger.Warn("Bot.runRedemptionAction: %q redeems !%s, which doesn't exist in #%s", redemption.Reward.Title, name, message.Channel)
+		}
+	case RedemptionWebhook:
+		body, err := json.Marshal(map[string]interface{}{
+			"channel":       message.Channel,
+			"user":          message.Username,
+			"user_id":       message.UserID,
+			"reward_id":     redemption.Reward.ID,
+			"reward":        redemption.Reward.Title,
+			"cost":          redemption.Reward.Cost,
+			"input":         message.Text,
+			"redemption_id": redemption.ID,
+		})
+		if err == nil {
+			var effect Effect
+			effect, err = NewEffect("webhook", WebhookEffect{URL: action.Webhook, Body: body})
+			if err == nil {
+				err = bot.RecordEffects(effect)
+			}
+		}
+		if err != nil {
+			logger.Error("Bot.runRedemptionAction: %s", err.Error())
+		}
+	}
+
+	if action.Response == "" {
+		return
+	}
+
+	values := map[string]string{
+		"reward": redemption.Reward.Title,
+		"cost":   strconv.Itoa(redemption.Reward.Cost),
+		"input":  sanitizeTemplateInput(message.Text),
+	}
+	bot.ChatWithPriority(message.Channel, strings.TrimSpace(bot.RenderTemplate(action.Response, &Command{Message: message}, values)), PriorityReply)
+}
@@ -0,0 +1,204 @@
+package twitchbot
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/printpretty"
+)
+
+// CommandConfig sets up a command from the config file. With a Response it defines a text
+// command; without one it changes the permission and cooldowns of an existing command.
+type CommandConfig struct {
+	Response     string   `json:"response"`
+	Description  string   `json:"description"`
+	Permission   string   `json:"permission"`
+	Cooldown     Duration `json:"cooldown"`
+	UserCooldown Duration `json:"user_cooldown"`
+}
+
+// Reads a setting that Reload may change while chat is being handled
+func (bot *Bot) setting(value *string) string {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	return *value
+}
+
+// The filters Reload may swap out
+func (bot *Bot) filters() (*preFilterSet, *contentFilter) {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	return bot.preFilters, bot.contentFilter
+}
+
+// Reload reads the config again and applies it without reconnecting: commands, cooldowns,
+// responses, ignored users and commands, banned phrases and the token. Changing the bot name,
+// channel, server or store needs a restart.
+func (bot *Bot) Reload() error {
+	load := bot.ConfigLoader
+	if load == nil {
+		if bot.ConfigPath == "" {
+			return errors.New("Bot.Reload: no ConfigPath or ConfigLoader to reload from")
+		}
+
+		load = func() (*Config, error) { return LoadConfig(bot.ConfigPath) }
+	}
+
+	config, err := load()
+	if err != nil {
+		return errors.New("Bot.Reload: " + err.Error())
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return errors.New("Bot.Reload: " + err.Error())
+	}
+
+	if (!bot.Anonymous && !strings.EqualFold(config.BotName, bot.BotName)) || config.Anonymous != bot.Anonymous || !strings.EqualFold(config.Channel, bot.ChannelName) ||
+		config.Server != bot.Server || config.Port != bot.Port {
+		logger.Warn("Bot.Reload: bot name, channel and server changes apply after a restart")
+	}
+
+	bot.settingsMutex.Lock()
+	bot.WhisperAutoResponse = orDefault(config.WhisperAutoResponse, defaultWhisperAutoResponse)
+	bot.ChuckNorrisResponse = orDefault(config.ChuckNorrisResponse, defaultChuckNorrisResponse)
+	bot.ShoutoutResponse = orDefault(config.ShoutoutResponse, defaultShoutoutResponse)
+	bot.EmoteOnlyResponse = config.EmoteOnlyResponse
+	bot.FirstChatterGreeting = config.FirstChatterGreeting
+	bot.FollowGreeting = config.FollowGreeting
+	bot.FollowGreetingWindow = time.Duration(config.FollowGreetingWindow)
+	bot.RaidShoutout = config.RaidShoutout
+	bot.SubThanks = config.SubThanks
+	bot.ResubThanks = config.ResubThanks
+	bot.GiftThanks = config.GiftThanks
+	bot.GiftBombThanks = config.GiftBombThanks
+	bot.BatchGiftBombs = config.BatchGiftBombs
+	bot.CheerResponses = config.CheerResponses
+	bot.TrashRetention = time.Duration(config.TrashRetention)
+	bot.GoLiveAnnouncement = config.GoLiveAnnouncement
+	bot.OfflineAnnouncement = config.OfflineAnnouncement
+	bot.TimersOnlyWhenLive = config.TimersOnlyWhenLive
+	bot.CommandPacks = config.CommandPacks
+	bot.GameCounters = config.GameCounters
+	bot.CategoryAnnouncement = config.CategoryAnnouncement
+	bot.TitleAnnouncement = config.TitleAnnouncement
+	bot.ModerationReasons = config.ModerationReasons
+	bot.ModerationWhisper = config.ModerationWhisper
+	bot.AppealKeyword = config.AppealKeyword
+	bot.AppealWebhook = config.AppealWebhook
+	bot.IgnoredUsers = config.IgnoredUsers
+	bot.IgnoredCommands = config.IgnoredCommands
+	bot.BannedPhrases = config.BannedPhrases
+	bot.ChannelSettings = config.ChannelSettings
+	bot.preFilters = newPreFilterSet(bot.IgnoredUsers, bot.IgnoredCommands, bot.PreFilters)
+	bot.contentFilter = newContentFilter(bot.BannedPhrases, bot.ContentFilters)
+	bot.ConsoleCues = config.ConsoleCues
+	bot.ConsoleGroupWindow = time.Duration(config.ConsoleGroupWindow)
+	bot.ConsoleTimestamps = config.ConsoleTimestamps
+	bot.ConsoleTimezone = config.ConsoleTimezone
+	bot.LogLevels = config.LogLevels
+	bot.Hooks = config.Hooks
+	bot.RedemptionActions = config.RedemptionActions
+	bot.settingsMutex.Unlock()
+
+	printpretty.SetCues(config.ConsoleCues)
+	bot.applyConsoleSettings()
+
+	bot.applyCommandConfig(config.Commands)
+
+	bot.secretsMutex.Lock()
+	bot.Token = config.Token
+	bot.SecretsPath = config.SecretsPath
+	bot.SecretsPassphrase = config.SecretsPassphrase
+	bot.SecretsKeyFile = config.SecretsKeyFile
+	bot.secretsMutex.Unlock()
+
+	err = bot.getOAuthToken()
+	if err != nil {
+		logger.Warn("Bot.Reload: keeping the old token: %s", err.Error())
+	}
+
+	logger.Success("Reloaded the config")
+	return nil
+}
+
+// Registers the config's text commands and applies its overrides, removing text commands
+// an earlier config defined that are gone now
+func (bot *Bot) applyCommandConfig(commands map[string]CommandConfig) {
+	bot.configCommandsMutex.Lock()
+	defer bot.configCommandsMutex.Unlock()
+
+	defined := map[string]bool{}
+
+	for name, command := range commands {
+		name = strings.ToLower(strings.TrimPrefix(name, "!"))
+		permission, err := ParsePermission(orDefault(command.Permission, "everyone"))
+		if err != nil {
+			logger.Warn("Bot.applyCommandConfig: !%s: %s", name, err.Error())
+			continue
+		}
+
+		options := []CommandOption{WithPermission(permission)}
+		if command.Cooldown != 0 || command.UserCooldown != 0 {
+			options = append(options, WithCooldown(time.Duration(command.Cooldown), time.Duration(command.UserCooldown)))
+		}
+		if command.Description != "" {
+			options = append(options, WithDescription(command.Description))
+		}
+
+		if command.Response == "" {
+			if !bot.Commands.update(name, options...) {
+				logger.Warn("Bot.applyCommandConfig: no command !%s to configure", name)
+			}
+			continue
+		}
+
+		response := command.Response
+		bot.RegisterCommand(name, func(bot *Bot, command *Command) {
+			bot.Reply(command.Message, bot.renderTemplate(response, command))
+		}, options...)
+		defined[name] = true
+	}
+
+	for name := range bot.configCommands {
+		if !defined[name] {
+			bot.Commands.Unregister(name)
+		}
+	}
+	bot.configCommands = defined
+}
+
+// Reloads the config whenever the process gets SIGHUP
+func (bot *Bot) reloadOnHangup() {
+	if bot.DisableSignalHandling || (bot.ConfigPath == "" && bot.ConfigLoader == nil) {
+		return
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	go func() {
+		for range hangups {
+			logger.Notice("Got SIGHUP, reloading the config")
+
+			err := bot.Reload()
+			if err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}()
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,113 @@
+package twitchbot
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+// RoomState holds a channel's chat settings from ROOMSTATE and the bot's own badges there from USERSTATE
+type RoomState struct {
+	EmoteOnly     bool
+	SubsOnly      bool
+	FollowersOnly int // Minutes a user must have followed, -1 when off
+	Slow          int // Seconds between messages, 0 when off
+
+	// The broadcaster's user-id
+	RoomID string
+
+	// The bot is a moderator or the broadcaster in the channel, so room modes don't apply to it
+	Privileged bool
+}
+
+type roomStates struct {
+	mutex  sync.RWMutex
+	states map[string]*RoomState
+}
+
+// RoomState returns the last known chat settings of a channel
+func (bot *Bot) RoomState(channel string) RoomState {
+	bot.rooms.mutex.RLock()
+	defer bot.rooms.mutex.RUnlock()
+
+	if state, ok := bot.rooms.states[channel]; ok {
+		return *state
+	}
+
+	return RoomState{FollowersOnly: -1}
+}
+
+// Twitch sends every setting on join and only the changed ones afterwards, so tags are merged in
+func (bot *Bot) updateRoomState(ircMessage *irc.Message) {
+	channel := ircMessage.Channel()
+
+	bot.rooms.mutex.Lock()
+	defer bot.rooms.mutex.Unlock()
+
+	if bot.rooms.states == nil {
+		bot.rooms.states = map[string]*RoomState{}
+	}
+
+	state, ok := bot.rooms.states[channel]
+	if !ok {
+		state = &RoomState{FollowersOnly: -1}
+		bot.rooms.states[channel] = state
+	}
+
+	switch ircMessage.Command {
+	case "ROOMSTATE":
+		if value := ircMessage.Tags["room-id"]; value != "" {
+			state.RoomID = value
+		}
+		if value, ok := ircMessage.Tags["emote-only"]; ok {
+			emoteOnly := value == "1"
+			if emoteOnly != state.EmoteOnly {
+				logger.Notice("Emote-only mode is %s in #%s", onOff(emoteOnly), channel)
+			}
+			state.EmoteOnly = emoteOnly
+		}
+		if value, ok := ircMessage.Tags["subs-only"]; ok {
+			state.SubsOnly = value == "1"
+		}
+		if value, ok := ircMessage.Tags["followers-only"]; ok {
+			if minutes, err := strconv.Atoi(value); err == nil {
+				state.FollowersOnly = minutes
+			}
+		}
+		if value, ok := ircMessage.Tags["slow"]; ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				state.Slow = seconds
+			}
+		}
+	case "USERSTATE":
+		badges := parseBadges(ircMessage.Tags["badges"])
+		_, broadcaster := badges["broadcaster"]
+		state.Privileged = ircMessage.Tags["mod"] == "1" || broadcaster
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+
+	return "off"
+}
+
+// Swaps text for EmoteOnlyResponse while a channel is in emote-only mode. Returns "" to suppress the message.
+func (bot *Bot) emoteOnlyMessage(channel, message string) string {
+	state := bot.RoomState(channel)
+	if !state.EmoteOnly || state.Privileged {
+		return message
+	}
+
+	response := bot.channelResponse(channel, func(settings ChannelSettings) string { return settings.EmoteOnlyResponse }, &bot.EmoteOnlyResponse)
+	if strings.TrimSpace(response) == "" {
+		logger.Info("#%s is in emote-only mode. Not sending: %s", channel, message)
+		return ""
+	}
+
+	return response
+}
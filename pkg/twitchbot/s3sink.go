@@ -0,0 +1,131 @@
+package twitchbot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/s3"
+)
+
+// How often S3Sink looks for exports past their retention
+const retentionSweepInterval = time.Hour
+
+// S3Sink saves exports and backups to S3-compatible object storage such as AWS S3, MinIO or
+// Google Cloud Storage. Large files are uploaded in parts.
+type S3Sink struct {
+	Client *s3.Client
+
+	// Prepended to every object key, e.g. "chuckbot/"
+	Prefix string
+
+	// How long objects under Prefix are kept. 0 keeps them forever.
+	Retention time.Duration
+
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+// Save uploads the file, then deletes objects older than Retention
+func (sink *S3Sink) Save(name string, data []byte) error {
+	err := sink.Client.Upload(context.Background(), sink.Prefix+name, data)
+	if err != nil {
+		return errors.New("S3Sink.Save: " + err.Error())
+	}
+
+	sink.sweep()
+	return nil
+}
+
+// Deletes expired objects, at most once per retentionSweepInterval
+func (sink *S3Sink) sweep() {
+	if sink.Retention <= 0 {
+		return
+	}
+
+	sink.mutex.Lock()
+	if time.Since(sink.lastSweep) < retentionSweepInterval {
+		sink.mutex.Unlock()
+		return
+	}
+	sink.lastSweep = time.Now()
+	sink.mutex.Unlock()
+
+	ctx := context.Background()
+	objects, err := sink.Client.List(ctx, sink.Prefix)
+	if err != nil {
+		logger.Warn("S3Sink.sweep: %s", err.Error())
+		return
+	}
+
+	deleted := 0
+	for _, object := range objects {
+		if time.Since(object.LastModified) < sink.Retention {
+			continue
+		}
+
+		err := sink.Client.Delete(ctx, object.Key)
+		if err != nil {
+			logger.Warn("S3Sink.sweep: %s", err.Error())
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		logger.Info("Deleted %d export(s) older than %s from bucket %s", deleted, sink.Retention, sink.Client.Bucket)
+	}
+}
+
+// S3Config describes an S3-compatible bucket to save exports and backups to
+type S3Config struct {
+	Endpoint  string   `json:"endpoint"`
+	Region    string   `json:"region"`
+	Bucket    string   `json:"bucket"`
+	Prefix    string   `json:"prefix"`
+	PathStyle bool     `json:"path_style"`
+	Retention Duration `json:"retention"`
+
+	// Usually left out in favour of the secrets file or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// Builds the sink, taking credentials from the config, then the secrets file, then the
+// standard AWS environment variables
+func (config *S3Config) sink(provider SecretsProvider) (*S3Sink, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3: endpoint and bucket are required")
+	}
+
+	client := &s3.Client{
+		Endpoint:        strings.TrimSuffix(config.Endpoint, "/"),
+		Region:          orDefault(config.Region, "us-east-1"),
+		Bucket:          config.Bucket,
+		PathStyle:       config.PathStyle,
+		AccessKeyID:     config.AccessKeyID,
+		SecretAccessKey: config.SecretAccessKey,
+	}
+
+	if client.AccessKeyID == "" {
+		stored, err := provider.Secrets()
+		if err == nil {
+			client.AccessKeyID = stored.S3AccessKeyID
+			client.SecretAccessKey = stored.S3SecretAccessKey
+		}
+	}
+
+	if client.AccessKeyID == "" {
+		client.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		client.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	if client.AccessKeyID == "" || client.SecretAccessKey == "" {
+		return nil, errors.New("s3: no credentials in the config, secrets file or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	return &S3Sink{Client: client, Prefix: config.Prefix, Retention: time.Duration(config.Retention)}, nil
+}
@@ -0,0 +1,299 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/timeparse"
+)
+
+const scheduledMessagesBucket = "scheduled_messages"
+
+// Scheduled messages that came due while the Bot was offline are still sent if they are at most this late
+const scheduledMessageGracePeriod = 10 * time.Minute
+
+const scheduledMessageStartupDelay = 30 * time.Second
+
+// ScheduledMessage is a chat message waiting to be sent at a specific time
+type ScheduledMessage struct {
+	ID      string    `json:"id"`
+	Channel string    `json:"channel"`
+	Text    string    `json:"text"`
+	At      time.Time `json:"at"`
+
+	// The IANA timezone the time was given in, used when showing it back to users
+	Timezone string `json:"timezone"`
+
+	// How the message repeats, or nil if it is only sent once
+	Repeat *timeparse.Schedule `json:"repeat,omitempty"`
+}
+
+type scheduler struct {
+	mutex  sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// ScheduleMessage persists a message and sends it to channel at the given time. It can be called once
+// the Bot has started, e.g. from a command handler. The time keeps its location so it can be shown back in the timezone it was given in.
+func (bot *Bot) ScheduleMessage(channel, text string, at time.Time) (*ScheduledMessage, error) {
+	return bot.scheduleMessage(channel, text, &timeparse.Schedule{At: at})
+}
+
+// ScheduleMessageIn schedules a message for a wall clock time in an IANA timezone, e.g. "2006-01-02 20:00" or
+// just "20:00" for the next time the clock reads 8pm there
+func (bot *Bot) ScheduleMessageIn(channel, text, clock, timezone string) (*ScheduledMessage, error) {
+	at, err := ParseScheduleTime(clock, timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return bot.ScheduleMessage(channel, text, at)
+}
+
+// ScheduleMessageWhen schedules a message from a time typed the way people write it, such as
+// "in 20 minutes", "at 8pm CET" or "every friday 19:00". Times without a zone use the Bot's Timezone.
+func (bot *Bot) ScheduleMessageWhen(channel, text, when string) (*ScheduledMessage, error) {
+	schedule, err := timeparse.Parse(when, time.Now(), bot.location())
+	if err != nil {
+		return nil, fmt.Errorf("Bot.ScheduleMessageWhen: %s", err.Error())
+	}
+
+	return bot.scheduleMessage(channel, text, schedule)
+}
+
+func (bot *Bot) scheduleMessage(channel, text string, schedule *timeparse.Schedule) (*ScheduledMessage, error) {
+	if text == "" {
+		return nil, errors.New("Bot.ScheduleMessage: text is empty")
+	}
+
+	if schedule.At.Before(time.Now()) {
+		return nil, fmt.Errorf("Bot.ScheduleMessage: %s is in the past", schedule.At.Format(time.RFC3339))
+	}
+
+	scheduled := &ScheduledMessage{
+		ID:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		Channel:  channel,
+		Text:     text,
+		At:       schedule.At,
+		Timezone: schedule.At.Location().String(),
+	}
+
+	if schedule.Recurring() {
+		scheduled.Repeat = schedule
+	}
+
+	err := bot.Store.Put(scheduledMessagesBucket, scheduled.ID, scheduled)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.ScheduleMessage: %s", err.Error())
+	}
+
+	bot.armScheduledMessage(scheduled, time.Until(scheduled.At))
+
+	return scheduled, nil
+}
+
+// ParseScheduleTime reads "2006-01-02 15:04" or "15:04" in an IANA timezone. A bare clock time
+// means its next occurrence after now.
+func ParseScheduleTime(clock, timezone string, now time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ParseScheduleTime: unknown timezone %q", timezone)
+	}
+
+	at, err := time.ParseInLocation("2006-01-02 15:04", clock, location)
+	if err == nil {
+		return at, nil
+	}
+
+	clockTime, err := time.ParseInLocation("15:04", clock, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ParseScheduleTime: %q is not a time like \"20:00\" or \"2006-01-02 20:00\"", clock)
+	}
+
+	local := now.In(location)
+	at = time.Date(local.Year(), local.Month(), local.Day(), clockTime.Hour(), clockTime.Minute(), 0, 0, location)
+	if !at.After(local) {
+		at = at.AddDate(0, 0, 1)
+	}
+
+	return at, nil
+}
+
+// CancelScheduledMessage stops a scheduled message from being sent. It can be brought back with
+// Restore for TrashRetention.
+func (bot *Bot) CancelScheduledMessage(id string) error {
+	bot.scheduler.mutex.Lock()
+	timer, ok := bot.scheduler.timers[id]
+	delete(bot.scheduler.timers, id)
+	bot.scheduler.mutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Bot.CancelScheduledMessage: no scheduled message %q", id)
+	}
+
+	timer.Stop()
+
+	scheduled := &ScheduledMessage{}
+	found, err := bot.Store.Get(scheduledMessagesBucket, id, scheduled)
+	if err == nil && found {
+		err = bot.trash(trashTimer, scheduled.Channel, id, "", scheduled)
+	}
+	if err != nil {
+		logger.Warn("Bot.CancelScheduledMessage: %s", err.Error())
+	}
+
+	return bot.Store.Delete(scheduledMessagesBucket, id)
+}
+
+// ScheduledMessages lists the messages still waiting to be sent, soonest first
+func (bot *Bot) ScheduledMessages() ([]*ScheduledMessage, error) {
+	keys, err := bot.Store.Keys(scheduledMessagesBucket)
+	if err != nil {
+		return nil, fmt.Errorf("Bot.ScheduledMessages: %s", err.Error())
+	}
+
+	messages := make([]*ScheduledMessage, 0, len(keys))
+	for _, key := range keys {
+		scheduled := &ScheduledMessage{}
+		ok, err := bot.Store.Get(scheduledMessagesBucket, key, scheduled)
+		if err != nil {
+			return nil, fmt.Errorf("Bot.ScheduledMessages: %s", err.Error())
+		}
+		if ok {
+			messages = append(messages, scheduled)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].At.Before(messages[j].At) })
+
+	return messages, nil
+}
+
+// Starts timers for the messages persisted by a previous run
+func (bot *Bot) restoreScheduledMessages() {
+	messages, err := bot.ScheduledMessages()
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	for _, scheduled := range messages {
+		// A recurring message that was missed picks up at its next time instead
+		if scheduled.Repeat != nil && time.Since(scheduled.At) > scheduledMessageGracePeriod {
+			next := scheduled.Repeat.Next(time.Now())
+			if !next.IsZero() {
+				logger.Warn("Skipping scheduled message %s for #%s due at %s, next at %s", scheduled.ID, scheduled.Channel, scheduled.At.Format(time.RFC3339), next.Format(time.RFC3339))
+				scheduled.At = next
+				err := bot.Store.Put(scheduledMessagesBucket, scheduled.ID, scheduled)
+				if err != nil {
+					logger.Warn("Bot.restoreScheduledMessages: %s", err.Error())
+				}
+			}
+		}
+
+		if time.Since(scheduled.At) > scheduledMessageGracePeriod {
+			logger.Warn("Dropping scheduled message %s for #%s, it was due at %s", scheduled.ID, scheduled.Channel, scheduled.At.Format(time.RFC3339))
+			bot.Store.Delete(scheduledMessagesBucket, scheduled.ID)
+			continue
+		}
+
+		// Give the Bot time to connect before sending anything that is already due
+		delay := time.Until(scheduled.At)
+		if delay < scheduledMessageStartupDelay {
+			delay = scheduledMessageStartupDelay
+		}
+
+		bot.armScheduledMessage(scheduled, delay)
+	}
+}
+
+func (bot *Bot) armScheduledMessage(scheduled *ScheduledMessage, delay time.Duration) {
+	bot.scheduler.mutex.Lock()
+	defer bot.scheduler.mutex.Unlock()
+
+	if bot.scheduler.timers == nil {
+		bot.scheduler.timers = map[string]*time.Timer{}
+	}
+
+	bot.scheduler.timers[scheduled.ID] = time.AfterFunc(delay, func() {
+		bot.scheduler.mutex.Lock()
+		_, ok := bot.scheduler.timers[scheduled.ID]
+		delete(bot.scheduler.timers, scheduled.ID)
+		bot.scheduler.mutex.Unlock()
+
+		// Cancelled while the timer was firing
+		if !ok {
+			return
+		}
+
+		if scheduled.Repeat != nil && bot.pausedWhileOffline(scheduled.Channel) {
+			logger.Quiet("Skipping scheduled message %s, #%s is offline", scheduled.ID, scheduled.Channel)
+		} else {
+			logger.Info("Sending scheduled message %s to #%s", scheduled.ID, scheduled.Channel)
+			bot.ChatWithPriority(scheduled.Channel, scheduled.Text, PriorityTimer)
+		}
+
+		if scheduled.Repeat != nil {
+			next := scheduled.Repeat.Next(time.Now())
+			if !next.IsZero() {
+				scheduled.At = next
+				err := bot.Store.Put(scheduledMessagesBucket, scheduled.ID, scheduled)
+				if err != nil {
+					logger.Warn("Bot.armScheduledMessage: %s", err.Error())
+				}
+
+				bot.armScheduledMessage(scheduled, time.Until(next))
+				return
+			}
+		}
+
+		err := bot.Store.Delete(scheduledMessagesBucket, scheduled.ID)
+		if err != nil {
+			logger.Warn("Bot.armScheduledMessage: %s", err.Error())
+		}
+	})
+}
+
+// The timezone for times typed in chat without one
+func (bot *Bot) location() *time.Location {
+	if bot.Timezone == "" {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(bot.Timezone)
+	if err != nil {
+		logger.Warn("Bot.location: unknown timezone %q, using local time", bot.Timezone)
+		return time.Local
+	}
+
+	return location
+}
+
+// !remindme in 20 minutes stretch your legs
+func remindMeCommand(bot *Bot, command *Command) {
+	location := bot.userLocation(command.Message)
+	schedule, text, err := timeparse.ParsePrefix(strings.Join(command.Args, " "), time.Now(), location)
+	if err != nil || text == "" {
+		bot.Reply(command.Message, "Usage: !remindme <when> <reminder>, e.g. !remindme in 20 minutes stretch")
+		return
+	}
+
+	if schedule.Recurring() {
+		bot.Reply(command.Message, "Reminders can only go off once.")
+		return
+	}
+
+	_, err = bot.ScheduleMessage(command.Channel, fmt.Sprintf("@%s reminder: %s", command.Username, text), schedule.At)
+	if err != nil {
+		logger.Warn(err.Error())
+		bot.Reply(command.Message, "That time has already passed.")
+		return
+	}
+
+	bot.Reply(command.Message, fmt.Sprintf("@%s I'll remind you at %s.", command.Username, schedule.At.In(location).Format("Mon 15:04 MST")))
+}
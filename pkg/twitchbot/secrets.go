@@ -0,0 +1,175 @@
+package twitchbot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Secrets are the bot account's token and other credentials
+type Secrets struct {
+	// The bot account's OAuth token.
+	OAuthToken string `json:"token,omitempty"`
+
+	// With these, the token is refreshed before it expires. The client secret is only needed for
+	// confidential applications.
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+
+	// Keys for S3Sink
+	S3AccessKeyID     string `json:"s3_access_key_id,omitempty"`
+	S3SecretAccessKey string `json:"s3_secret_access_key,omitempty"`
+}
+
+// SecretsProvider is where the Bot gets its Secrets. It is asked again when the secrets are
+// reloaded.
+type SecretsProvider interface {
+	Secrets() (*Secrets, error)
+}
+
+// TokenSaver is a SecretsProvider that can keep a refreshed token. Tokens from other providers
+// are refreshed in memory only.
+type TokenSaver interface {
+	SaveToken(accessToken, refreshToken string) error
+}
+
+// NewSecretsProvider picks the provider named by a config's secrets_provider: "file" (the default)
+// for FileSecrets at secrets_path, "env" for EnvSecrets, "prompt" for PromptSecrets on stdin or
+// "keychain" for KeychainSecrets under bot_name, or "vault", "aws" or "gcp" for CloudSecrets at
+// cloud_secrets
+func NewSecretsProvider(config *Config) (SecretsProvider, error) {
+	switch strings.ToLower(config.SecretsProvider) {
+	case "", "file":
+		return &FileSecrets{Path: config.SecretsPath, Passphrase: config.SecretsPassphrase, KeyFile: config.SecretsKeyFile}, nil
+	case "env":
+		return &EnvSecrets{}, nil
+	case "prompt":
+		return &PromptSecrets{}, nil
+	case "keychain":
+		return &KeychainSecrets{Service: config.KeychainService, Account: strings.ToLower(config.BotName)}, nil
+	case "vault", "aws", "gcp":
+		backend, err := config.CloudSecrets.backend(strings.ToLower(config.SecretsProvider))
+		if err != nil {
+			return nil, errors.New("NewSecretsProvider: " + err.Error())
+		}
+		return &CloudSecrets{Backend: backend}, nil
+	}
+
+	return nil, fmt.Errorf("NewSecretsProvider: unknown provider %q, use file, env, prompt, keychain, vault, aws or gcp", config.SecretsProvider)
+}
+
+// FileSecrets reads a JSON file, such as secrets.json. It can be encrypted with
+// EncryptSecretsFile, and is then opened with Passphrase or KeyFile.
+type FileSecrets struct {
+	Path string
+
+	Passphrase string
+	KeyFile    string
+}
+
+// Secrets reads the file
+func (file *FileSecrets) Secrets() (*Secrets, error) {
+	data, err := ioutil.ReadFile(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err = decryptSecrets(data, file.key())
+	if err != nil {
+		return nil, err
+	}
+
+	var stored Secrets
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// SaveToken writes the tokens into the file, keeping everything else in it
+func (file *FileSecrets) SaveToken(accessToken, refreshToken string) error {
+	return saveSecrets(file.Path, file.key(), map[string]string{"token": accessToken, "refresh_token": refreshToken})
+}
+
+func (file *FileSecrets) key() secretsKey {
+	return secretsKey{Passphrase: file.Passphrase, KeyFile: file.KeyFile}
+}
+
+// EnvSecrets reads environment variables named after the secrets file's keys, e.g.
+// CHUCKBOT_SECRET_TOKEN and CHUCKBOT_SECRET_REFRESH_TOKEN
+type EnvSecrets struct {
+	// Defaults to CHUCKBOT_SECRET_
+	Prefix string
+}
+
+// Secrets reads the environment
+func (env *EnvSecrets) Secrets() (*Secrets, error) {
+	prefix := env.Prefix
+	if prefix == "" {
+		prefix = "CHUCKBOT_SECRET_"
+	}
+
+	stored := &Secrets{}
+	value := reflect.ValueOf(stored).Elem()
+	secretsType := value.Type()
+	for i := 0; i < secretsType.NumField(); i++ {
+		name := strings.Split(secretsType.Field(i).Tag.Get("json"), ",")[0]
+		value.Field(i).SetString(os.Getenv(prefix + strings.ToUpper(name)))
+	}
+
+	return stored, nil
+}
+
+// PromptSecrets asks for the token once and remembers it for reloads. What's typed is shown,
+// so use it at a terminal nobody else can see.
+type PromptSecrets struct {
+	// Default to stdin and stderr
+	In  io.Reader
+	Out io.Writer
+
+	mutex  sync.Mutex
+	stored *Secrets
+}
+
+// Secrets asks for the token the first time it's called
+func (prompt *PromptSecrets) Secrets() (*Secrets, error) {
+	prompt.mutex.Lock()
+	defer prompt.mutex.Unlock()
+
+	if prompt.stored != nil {
+		copied := *prompt.stored
+		return &copied, nil
+	}
+
+	in, out := prompt.In, prompt.Out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stderr
+	}
+
+	fmt.Fprint(out, "OAuth token: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	token := strings.TrimSpace(line)
+	if err != nil && !(err == io.EOF && token != "") {
+		return nil, errors.New("PromptSecrets: " + err.Error())
+	}
+	if token != "" && !strings.HasPrefix(token, "oauth:") {
+		token = "oauth:" + token
+	}
+
+	prompt.stored = &Secrets{OAuthToken: token}
+	copied := *prompt.stored
+	return &copied, nil
+}
@@ -0,0 +1,240 @@
+package twitchbot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+)
+
+const (
+	// Marks a secrets file as encrypted, and which format it's in
+	encryptedSecretsVersion = 1
+
+	// OWASP's recommendation for PBKDF2-HMAC-SHA256
+	pbkdf2Iterations = 600000
+
+	kdfPBKDF2  = "pbkdf2-sha256"
+	kdfKeyFile = "keyfile"
+)
+
+// An encrypted secrets file: the JSON secrets sealed with AES-256-GCM. The key comes from a
+// passphrase through PBKDF2, or is the SHA-256 of a key file.
+type encryptedSecrets struct {
+	Version    int    `json:"chuckbot_encrypted"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Where the key for an encrypted secrets file comes from. Empty means it isn't encrypted.
+type secretsKey struct {
+	Passphrase string
+	KeyFile    string
+}
+
+func (key secretsKey) empty() bool {
+	return key.Passphrase == "" && key.KeyFile == ""
+}
+
+// Seals the secrets. A passphrase gets a new salt every time.
+func encryptSecrets(plaintext []byte, key secretsKey) ([]byte, error) {
+	envelope := &encryptedSecrets{Version: encryptedSecretsVersion}
+
+	var aesKey []byte
+	var err error
+	if key.KeyFile != "" {
+		envelope.KDF = kdfKeyFile
+		aesKey, err = keyFromFile(key.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		envelope.KDF = kdfPBKDF2
+		envelope.Iterations = pbkdf2Iterations
+		envelope.Salt = make([]byte, 16)
+		_, err = rand.Read(envelope.Salt)
+		if err != nil {
+			return nil, err
+		}
+		aesKey = pbkdf2SHA256([]byte(key.Passphrase), envelope.Salt, envelope.Iterations, 32)
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.Nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(envelope.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Ciphertext = gcm.Seal(nil, envelope.Nonce, plaintext, nil)
+
+	return json.MarshalIndent(envelope, "", "    ")
+}
+
+// Opens a secrets file if it's encrypted, and returns it as it is if it isn't
+func decryptSecrets(data []byte, key secretsKey) ([]byte, bool, error) {
+	envelope := &encryptedSecrets{}
+	if json.Unmarshal(data, envelope) != nil || envelope.Version == 0 {
+		return data, false, nil
+	}
+	if envelope.Version != encryptedSecretsVersion {
+		return nil, true, errors.New("the secrets file was encrypted by a newer version")
+	}
+
+	var aesKey []byte
+	var err error
+	switch envelope.KDF {
+	case kdfKeyFile:
+		if key.KeyFile == "" {
+			return nil, true, errors.New("the secrets file is encrypted with a key file, set secrets_key_file")
+		}
+		aesKey, err = keyFromFile(key.KeyFile)
+		if err != nil {
+			return nil, true, err
+		}
+	case kdfPBKDF2:
+		if key.Passphrase == "" {
+			return nil, true, errors.New("the secrets file is encrypted with a passphrase, set secrets_passphrase")
+		}
+		// Zero would quietly derive a key from a single round
+		if envelope.Iterations < 1 {
+			return nil, true, errors.New("the secrets file has a bad iteration count")
+		}
+		aesKey = pbkdf2SHA256([]byte(key.Passphrase), envelope.Salt, envelope.Iterations, 32)
+	default:
+		return nil, true, errors.New("the secrets file uses an unknown kdf " + envelope.KDF)
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return nil, true, errors.New("the secrets file has a bad nonce")
+	}
+
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, true, errors.New("couldn't decrypt the secrets file, check the passphrase or key file")
+	}
+
+	return plaintext, true, nil
+}
+
+func keyFromFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("the key file " + path + " is empty")
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2 from RFC 8018 with HMAC-SHA256, as the standard library has no password hashing
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLength)
+	block := make([]byte, 4)
+
+	for index := uint32(1); len(key) < keyLength; index++ {
+		binary.BigEndian.PutUint32(block, index)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
+
+// EncryptSecretsFile encrypts a plaintext secrets file in place with a passphrase, or a key file
+// when keyFile is set. A file that's already encrypted is left alone.
+func EncryptSecretsFile(path, passphrase, keyFile string) error {
+	key := secretsKey{Passphrase: passphrase, KeyFile: keyFile}
+	if key.empty() {
+		return errors.New("EncryptSecretsFile: a passphrase or key file is required")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New("EncryptSecretsFile: " + err.Error())
+	}
+	if _, encrypted, _ := decryptSecrets(data, secretsKey{}); encrypted {
+		return errors.New("EncryptSecretsFile: " + path + " is already encrypted")
+	}
+	if !json.Valid(data) {
+		return errors.New("EncryptSecretsFile: " + path + " isn't JSON")
+	}
+
+	sealed, err := encryptSecrets(data, key)
+	if err != nil {
+		return errors.New("EncryptSecretsFile: " + err.Error())
+	}
+
+	err = writeFileAtomic(path, sealed)
+	if err != nil {
+		return errors.New("EncryptSecretsFile: " + err.Error())
+	}
+
+	return nil
+}
+
+// DecryptSecretsFile turns an encrypted secrets file back into plaintext JSON
+func DecryptSecretsFile(path, passphrase, keyFile string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New("DecryptSecretsFile: " + err.Error())
+	}
+
+	plaintext, encrypted, err := decryptSecrets(data, secretsKey{Passphrase: passphrase, KeyFile: keyFile})
+	if err != nil {
+		return errors.New("DecryptSecretsFile: " + err.Error())
+	}
+	if !encrypted {
+		return errors.New("DecryptSecretsFile: " + path + " isn't encrypted")
+	}
+
+	err = writeFileAtomic(path, plaintext)
+	if err != nil {
+		return errors.New("DecryptSecretsFile: " + err.Error())
+	}
+
+	return nil
+}
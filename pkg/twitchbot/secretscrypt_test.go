@@ -0,0 +1,117 @@
+package twitchbot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// PBKDF2-HMAC-SHA256 test vectors from RFC 7914 section 11
+func TestPBKDF2SHA256(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+
+	for _, test := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iterations, 64))
+		if got != test.want {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %s, want %s", test.password, test.salt, test.iterations, got, test.want)
+		}
+	}
+
+	// Shorter keys are a prefix of longer ones
+	short := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 32))
+	if short != tests[0].want[:64] {
+		t.Errorf("32 byte key = %s, want %s", short, tests[0].want[:64])
+	}
+}
+
+var testSecrets = []byte(`{"token": "oauth:test", "refresh_token": "refresh"}`)
+
+func TestEncryptSecretsWithPassphrase(t *testing.T) {
+	sealed, err := encryptSecrets(testSecrets, secretsKey{Passphrase: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("oauth:test")) {
+		t.Fatal("the sealed file still has the token in it")
+	}
+
+	opened, encrypted, err := decryptSecrets(sealed, secretsKey{Passphrase: "hunter2"})
+	if err != nil || !encrypted {
+		t.Fatalf("decryptSecrets = %v, %v", encrypted, err)
+	}
+	if !bytes.Equal(opened, testSecrets) {
+		t.Errorf("decrypted %s, want %s", opened, testSecrets)
+	}
+
+	_, encrypted, err = decryptSecrets(sealed, secretsKey{Passphrase: "hunter3"})
+	if err == nil || !encrypted {
+		t.Errorf("decrypting with the wrong passphrase = %v, %v, want an error", encrypted, err)
+	}
+
+	_, _, err = decryptSecrets(sealed, secretsKey{})
+	if err == nil {
+		t.Error("decrypting without a passphrase succeeded")
+	}
+}
+
+func TestEncryptSecretsWithKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chuckbot-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile, otherKeyFile := filepath.Join(dir, "key"), filepath.Join(dir, "other")
+	ioutil.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600)
+	ioutil.WriteFile(otherKeyFile, []byte("fedcba9876543210fedcba9876543210"), 0600)
+
+	sealed, err := encryptSecrets(testSecrets, secretsKey{KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, _, err := decryptSecrets(sealed, secretsKey{KeyFile: keyFile})
+	if err != nil || !bytes.Equal(opened, testSecrets) {
+		t.Errorf("decryptSecrets = %s, %v", opened, err)
+	}
+
+	_, _, err = decryptSecrets(sealed, secretsKey{KeyFile: otherKeyFile})
+	if err == nil {
+		t.Error("decrypting with the wrong key file succeeded")
+	}
+}
+
+func TestDecryptPlainSecrets(t *testing.T) {
+	opened, encrypted, err := decryptSecrets(testSecrets, secretsKey{Passphrase: "hunter2"})
+	if err != nil || encrypted || !bytes.Equal(opened, testSecrets) {
+		t.Errorf("decryptSecrets of a plain file = %s, %v, %v", opened, encrypted, err)
+	}
+}
+
+func TestDecryptSecretsRejectsNoIterations(t *testing.T) {
+	sealed, err := encryptSecrets(testSecrets, secretsKey{Passphrase: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := &encryptedSecrets{}
+	json.Unmarshal(sealed, envelope)
+	envelope.Iterations = 0
+	tampered, _ := json.Marshal(envelope)
+
+	_, encrypted, err := decryptSecrets(tampered, secretsKey{Passphrase: "hunter2"})
+	if err == nil || !encrypted {
+		t.Errorf("decryptSecrets with no iterations = %v, %v, want an error", encrypted, err)
+	}
+}
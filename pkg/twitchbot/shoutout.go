@@ -0,0 +1,79 @@
+package twitchbot
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const defaultShoutoutResponse = "Go check out @$(touser) at twitch.tv/$(touser)! $(clip)"
+
+const (
+	// How far back to look for a clip to share
+	shoutoutClipWindow = 30 * 24 * time.Hour
+
+	// How long a target's clip, or the lack of one, is remembered
+	shoutoutClipCacheTTL = time.Hour
+)
+
+// Give another streamer a shoutout: !so @user
+func shoutoutCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !so @user")
+		return
+	}
+
+	template := bot.channelResponse(command.Channel, func(settings ChannelSettings) string { return settings.ShoutoutResponse }, &bot.ShoutoutResponse)
+
+	clip := ""
+	if strings.Contains(template, "$(clip") {
+		clip = bot.shoutoutClip(strings.TrimPrefix(command.Args[0], "@"))
+	}
+
+	bot.Reply(command.Message, strings.TrimSpace(bot.RenderTemplate(template, command, map[string]string{"clip": clip})))
+}
+
+// The URL of the most viewed clip from a channel over the last 30 days, or "" when there is
+// none or no ClientID to ask Helix with
+func (bot *Bot) shoutoutClip(login string) string {
+	if bot.ClientID == "" {
+		return ""
+	}
+
+	login = strings.ToLower(login)
+	if cached, ok := bot.shoutoutClips.get(login); ok {
+		return cached
+	}
+
+	clip, err := bot.fetchTopClip(login)
+	if err != nil {
+		logger.Warn(err.Error())
+		return ""
+	}
+
+	bot.shoutoutClips.set(login, clip, shoutoutClipCacheTTL)
+
+	return clip
+}
+
+// Asks Helix for a channel's clips from the last 30 days. They come back most viewed first.
+func (bot *Bot) fetchTopClip(login string) (string, error) {
+	broadcasterID, found, err := bot.lookupUserID(login)
+	if err != nil {
+		return "", errors.New("Bot.fetchTopClip: " + err.Error())
+	}
+	if !found {
+		return "", nil
+	}
+
+	clips, err := bot.helix().GetClips(bot.context(), broadcasterID, time.Now().Add(-shoutoutClipWindow), 1)
+	if err != nil {
+		return "", errors.New("Bot.fetchTopClip: " + err.Error())
+	}
+
+	if len(clips) == 0 {
+		return "", nil
+	}
+
+	return clips[0].URL, nil
+}
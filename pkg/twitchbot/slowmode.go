@@ -0,0 +1,161 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessagePriority orders outgoing messages when they can't all be sent right away
+type MessagePriority int
+
+// Message priorities from lowest to highest
+const (
+	// Game announcements like boss health and duel results
+	PriorityGame MessagePriority = iota
+	PriorityTimer
+	PriorityReply
+	// Messages from the bot itself, like its greeting on joining or end of stream leaderboards
+	PrioritySystem
+	PriorityModeration
+)
+
+func (priority MessagePriority) String() string {
+	switch priority {
+	case PriorityGame:
+		return "game"
+	case PriorityTimer:
+		return "timer"
+	case PriorityReply:
+		return "reply"
+	case PrioritySystem:
+		return "system"
+	case PriorityModeration:
+		return "moderation"
+	case priorityControl:
+		return "control"
+	}
+
+	return fmt.Sprintf("priority %d", int(priority))
+}
+
+// ParsePriority reads a priority name such as "reply" or "game"
+func ParsePriority(name string) (MessagePriority, error) {
+	for priority := PriorityGame; priority <= PriorityModeration; priority++ {
+		if strings.EqualFold(name, priority.String()) {
+			return priority, nil
+		}
+	}
+
+	return PriorityReply, fmt.Errorf("ParsePriority: unknown priority %q", name)
+}
+
+// How many messages may wait per channel in slow mode before the lowest priority one is dropped
+const maxSlowModePending = 5
+
+// Twitch measures slow mode on its side, so leave a little room for network delay
+const slowModeMargin = 250 * time.Millisecond
+
+type pendingMessage struct {
+	text     string
+	priority MessagePriority
+}
+
+type slowModeChannel struct {
+	lastSent time.Time
+	pending  []pendingMessage
+	timer    *time.Timer
+}
+
+type slowModePacer struct {
+	mutex    sync.Mutex
+	channels map[string]*slowModeChannel
+}
+
+// Sends a message now, or holds it until slow mode lets the bot speak again.
+// Waiting messages are kept highest priority first, oldest first within a priority.
+func (bot *Bot) sendPaced(channel, message string, priority MessagePriority) {
+	bot.slowMode.mutex.Lock()
+	defer bot.slowMode.mutex.Unlock()
+
+	if bot.slowMode.channels == nil {
+		bot.slowMode.channels = map[string]*slowModeChannel{}
+	}
+
+	paced, ok := bot.slowMode.channels[channel]
+	if !ok {
+		paced = &slowModeChannel{}
+		bot.slowMode.channels[channel] = paced
+	}
+
+	wait := bot.slowModeWait(channel, paced)
+	if wait <= 0 && len(paced.pending) == 0 {
+		paced.lastSent = time.Now()
+		bot.queueMessage(channel, message, priority)
+		return
+	}
+
+	index := len(paced.pending)
+	for i, pending := range paced.pending {
+		if priority > pending.priority {
+			index = i
+			break
+		}
+	}
+
+	paced.pending = append(paced.pending, pendingMessage{})
+	copy(paced.pending[index+1:], paced.pending[index:])
+	paced.pending[index] = pendingMessage{text: message, priority: priority}
+
+	if len(paced.pending) > maxSlowModePending {
+		dropped := paced.pending[len(paced.pending)-1]
+		paced.pending = paced.pending[:len(paced.pending)-1]
+		logger.Notice("Slow mode in #%s, dropping %s message: %s", channel, dropped.priority, dropped.text)
+	}
+
+	if paced.timer == nil {
+		logger.Info("Slow mode in #%s, holding messages for %s", channel, wait.Round(time.Second))
+		paced.timer = time.AfterFunc(wait, func() { bot.flushPaced(channel) })
+	}
+}
+
+// Sends the highest priority waiting message and waits again for the rest
+func (bot *Bot) flushPaced(channel string) {
+	bot.slowMode.mutex.Lock()
+	defer bot.slowMode.mutex.Unlock()
+
+	paced := bot.slowMode.channels[channel]
+	paced.timer = nil
+
+	if len(paced.pending) == 0 {
+		return
+	}
+
+	wait := bot.slowModeWait(channel, paced)
+	if wait <= 0 {
+		next := paced.pending[0]
+		paced.pending = paced.pending[1:]
+		paced.lastSent = time.Now()
+		bot.queueMessage(channel, next.text, next.priority)
+
+		wait = bot.slowModeWait(channel, paced)
+	}
+
+	if len(paced.pending) > 0 {
+		if wait < 0 {
+			wait = 0
+		}
+		paced.timer = time.AfterFunc(wait, func() { bot.flushPaced(channel) })
+	}
+}
+
+// How long until the bot may speak in a channel. Slow mode doesn't apply to moderators.
+func (bot *Bot) slowModeWait(channel string, paced *slowModeChannel) time.Duration {
+	state := bot.RoomState(channel)
+	if state.Slow == 0 || state.Privileged {
+		return 0
+	}
+
+	return time.Until(paced.lastSent.Add(time.Duration(state.Slow)*time.Second + slowModeMargin))
+}
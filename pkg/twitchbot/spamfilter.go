@@ -0,0 +1,194 @@
+package twitchbot
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// More combining marks than this on one letter is zalgo rather than accents
+	maxCombiningMarks = 2
+
+	// A message with at least this many art characters, making up most of it, is ASCII art
+	minArtCharacters = 30
+
+	// Strikes older than this are forgotten
+	spamStrikeWindow = 10 * time.Minute
+
+	defaultSpamTimeout = time.Minute
+)
+
+// Characters that take up no space, used to dodge filters or break up words
+func isZeroWidth(char rune) bool {
+	switch {
+	case char >= 0x200B && char <= 0x200F, char >= 0x2060 && char <= 0x2064:
+		return true
+	case char == 0xFEFF, char == 0x034F, char == 0x180E, char == 0x00AD:
+		return true
+	case char >= 0xE0000 && char <= 0xE007F:
+		return true
+	}
+
+	return false
+}
+
+// Braille, box drawing and block characters, the building blocks of chat art
+func isArtCharacter(char rune) bool {
+	return (char >= 0x2800 && char <= 0x28FF) || (char >= 0x2500 && char <= 0x259F)
+}
+
+type cleanResult struct {
+	text  string
+	zalgo bool
+	art   bool
+}
+
+// Strips zero-width characters and piled up combining marks, and spots ASCII art
+func cleanChatText(text string) cleanResult {
+	result := cleanResult{}
+
+	var builder strings.Builder
+	marks, art, visible := 0, 0, 0
+
+	for _, char := range text {
+		if isZeroWidth(char) {
+			continue
+		}
+
+		if unicode.Is(unicode.Mn, char) {
+			marks++
+			if marks > maxCombiningMarks {
+				result.zalgo = true
+				continue
+			}
+		} else {
+			marks = 0
+		}
+
+		if isArtCharacter(char) {
+			art++
+		}
+		if !unicode.IsSpace(char) {
+			visible++
+		}
+
+		builder.WriteRune(char)
+	}
+
+	result.text = builder.String()
+	result.art = art >= minArtCharacters && art*2 >= visible
+
+	return result
+}
+
+// Keeps everything after the tags and prefix of a raw line readable in the console
+func consoleSafeLine(line string) string {
+	result := cleanChatText(line)
+	if !result.art {
+		return result.text
+	}
+
+	// Skip the tags and prefix, which also contain " :", to find where the message starts
+	start := 0
+	for _, marker := range []byte{'@', ':'} {
+		if start < len(result.text) && result.text[start] == marker {
+			if space := strings.IndexByte(result.text[start:], ' '); space != -1 {
+				start += space + 1
+			}
+		}
+	}
+
+	if colon := strings.Index(result.text[start:], " :"); colon != -1 {
+		return result.text[:start+colon] + " :[ascii art]"
+	}
+
+	return result.text
+}
+
+type spamStrikes struct {
+	mutex   sync.Mutex
+	strikes map[string][]time.Time
+}
+
+// Counts a strike against a user and reports how many they have in the window
+func (spam *spamStrikes) add(user string, now time.Time) int {
+	spam.mutex.Lock()
+	defer spam.mutex.Unlock()
+
+	if spam.strikes == nil {
+		spam.strikes = map[string][]time.Time{}
+	}
+
+	// Newest strike first
+	kept := []time.Time{now}
+	for _, strike := range spam.strikes[user] {
+		if now.Sub(strike) < spamStrikeWindow {
+			kept = append(kept, strike)
+		}
+	}
+	spam.strikes[user] = kept
+
+	// Forget users whose strikes have all run out once the map gets big
+	if len(spam.strikes) > 1000 {
+		for other, strikes := range spam.strikes {
+			if now.Sub(strikes[0]) >= spamStrikeWindow {
+				delete(spam.strikes, other)
+			}
+		}
+	}
+
+	return len(kept)
+}
+
+// How many strikes a user has in the window
+func (spam *spamStrikes) count(user string, now time.Time) int {
+	spam.mutex.Lock()
+	defer spam.mutex.Unlock()
+
+	count := 0
+	for _, strike := range spam.strikes[user] {
+		if now.Sub(strike) < spamStrikeWindow {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Cleans a chat message when CleanChat is on. Returns false if the message should be ignored.
+func (bot *Bot) cleanMessage(message *Message) bool {
+	if !bot.CleanChat {
+		return true
+	}
+
+	result := cleanChatText(message.Text)
+	message.Text = result.text
+
+	if !result.zalgo && !result.art {
+		return true
+	}
+
+	if message.Can(Moderator) {
+		return !result.art
+	}
+
+	bot.noteFiltered(message.Channel)
+
+	rule := RuleZalgo
+	if result.art {
+		rule = RuleASCIIArt
+	}
+
+	if bot.SpamStrikes > 0 && bot.spamStrikes.add(message.Username, time.Now()) >= bot.SpamStrikes {
+		timeout := bot.SpamTimeout
+		if timeout == 0 {
+			timeout = defaultSpamTimeout
+		}
+
+		bot.moderate(message, rule, timeout)
+	}
+
+	return !result.art
+}
@@ -0,0 +1,99 @@
+package twitchbot
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// This many filtered messages within spamWaveWindow is a spam wave
+	defaultSpamWaveThreshold = 5
+	spamWaveWindow           = 30 * time.Second
+
+	// A wave is over once this long passes without it picking up again
+	spamWaveCooldown = time.Minute
+)
+
+type channelSpamWave struct {
+	filtered []time.Time
+	until    time.Time
+	paused   bool
+}
+
+type spamWaves struct {
+	mutex    sync.Mutex
+	channels map[string]*channelSpamWave
+}
+
+func (waves *spamWaves) channel(channel string) *channelSpamWave {
+	if waves.channels == nil {
+		waves.channels = map[string]*channelSpamWave{}
+	}
+
+	wave, ok := waves.channels[channel]
+	if !ok {
+		wave = &channelSpamWave{}
+		waves.channels[channel] = wave
+	}
+
+	return wave
+}
+
+// SpamWave reports whether the filters are busy with a spam wave in a channel. While one is on,
+// timer messages, game announcements and greetings are skipped and only moderators can use commands, leaving the message
+// queue for moderation.
+func (bot *Bot) SpamWave(channel string) bool {
+	bot.spamWaves.mutex.Lock()
+	defer bot.spamWaves.mutex.Unlock()
+
+	return bot.spamWave(channel, time.Now())
+}
+
+// Counts a message the filters caught, which can start a spam wave
+func (bot *Bot) noteFiltered(channel string) {
+	if bot.SpamWaveThreshold < 0 {
+		return
+	}
+
+	bot.spamWaves.mutex.Lock()
+	defer bot.spamWaves.mutex.Unlock()
+
+	now := time.Now()
+	wave := bot.spamWaves.channel(channel)
+	wave.filtered = append(wave.filtered, now)
+	bot.spamWave(channel, now)
+}
+
+// Called with spamWaves.mutex held
+func (bot *Bot) spamWave(channel string, now time.Time) bool {
+	wave := bot.spamWaves.channel(channel)
+
+	recent := wave.filtered[:0]
+	for _, filtered := range wave.filtered {
+		if now.Sub(filtered) < spamWaveWindow {
+			recent = append(recent, filtered)
+		}
+	}
+	wave.filtered = recent
+
+	threshold := bot.SpamWaveThreshold
+	if threshold == 0 {
+		threshold = defaultSpamWaveThreshold
+	}
+
+	if threshold > 0 && len(wave.filtered) >= threshold {
+		wave.until = now.Add(spamWaveCooldown)
+	}
+
+	paused := now.Before(wave.until)
+	if paused != wave.paused {
+		if paused {
+			filterLogger.Notice("Spam wave in #%s, pausing timers, greetings and commands", channel)
+		} else {
+			filterLogger.Notice("Spam wave in #%s is over, resuming timers, greetings and commands", channel)
+		}
+		wave.paused = paused
+	}
+
+	return paused
+}
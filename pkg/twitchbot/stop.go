@@ -0,0 +1,114 @@
+package twitchbot
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// How long Stop waits for queued messages to go out before quitting anyway
+const stopFlushTimeout = 10 * time.Second
+
+// Stop sends any queued messages, says QUIT to Twitch and closes the connection, then waits
+// for Start to return. It is safe to call more than once and from any goroutine.
+func (bot *Bot) Stop() {
+	if bot.stopping == nil {
+		return
+	}
+
+	bot.stopOnce.Do(func() {
+		logger.Notice("Stopping...")
+		bot.runHooks(HookShuttingDown, "")
+		close(bot.stopping)
+		if bot.cancel != nil {
+			bot.cancel()
+		}
+
+		// The writer drains its queue once it notices the Bot is stopping
+		if _, flushed := bot.outboxAndFlushed(); flushed != nil {
+			select {
+			case <-flushed:
+			case <-time.After(stopFlushTimeout):
+				logger.Warn("Bot.Stop: gave up waiting for queued messages to send")
+			}
+		}
+
+		if bot.DataExportInterval > 0 {
+			err := bot.ExportData()
+			if err != nil {
+				logger.Warn(err.Error())
+			}
+		}
+
+		bot.flushMetrics()
+
+		if bot.connection != nil {
+			bot.writeLine("QUIT\r\n")
+			bot.connection.Close()
+		}
+
+		if bot.LeaderLock != nil {
+			// Renewing after the release would keep the lock from the standby
+			if renewal := bot.leaderRenewalStopped; renewal != nil {
+				<-renewal
+			}
+
+			err := bot.LeaderLock.Release(bot.InstanceID)
+			if err != nil {
+				logger.Warn("Bot.Stop: %s", err.Error())
+			}
+		}
+	})
+
+	<-bot.stopped
+}
+
+// The context of the running Bot, cancelled when it stops
+func (bot *Bot) context() context.Context {
+	if bot.ctx == nil {
+		return context.Background()
+	}
+
+	return bot.ctx
+}
+
+func (bot *Bot) isStopping() bool {
+	select {
+	case <-bot.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Sleeps for the duration, waking early to report true if the Bot is stopped meanwhile
+func (bot *Bot) sleepUnlessStopped(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return false
+	case <-bot.stopping:
+		return true
+	}
+}
+
+// Stops the Bot cleanly on SIGINT or SIGTERM
+func (bot *Bot) stopOnSignals() {
+	if bot.DisableSignalHandling {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		received := <-signals
+		logger.Notice("Got %s", received)
+		signal.Stop(signals)
+		bot.Stop()
+	}()
+}
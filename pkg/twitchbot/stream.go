@@ -0,0 +1,49 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetGame records what a channel is playing, for features like quotes that note the game.
+// Changing it switches CommandPacks and GameCounters over and starts a new game in today's stats.
+func (bot *Bot) SetGame(channel, game string) {
+	bot.gamesMutex.Lock()
+	if bot.games == nil {
+		bot.games = map[string]string{}
+	}
+
+	previous := bot.games[channel]
+	bot.games[channel] = game
+	bot.gamesMutex.Unlock()
+
+	if !strings.EqualFold(previous, game) {
+		bot.switchCommandPacks(channel, previous, game)
+		bot.startGameSegment(channel, game)
+	}
+}
+
+// Game returns what a channel is playing, or "" if it isn't known
+func (bot *Bot) Game(channel string) string {
+	bot.gamesMutex.RLock()
+	defer bot.gamesMutex.RUnlock()
+
+	return bot.games[channel]
+}
+
+// !game [name]
+func gameCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 || !command.Can(Moderator) {
+		game := bot.Game(command.Channel)
+		if game == "" {
+			game = "not set"
+		}
+
+		bot.Reply(command.Message, "Game: "+game)
+		return
+	}
+
+	game := strings.Join(command.Args, " ")
+	bot.SetGame(command.Channel, game)
+	bot.Reply(command.Message, fmt.Sprintf("Game set to %s.", game))
+}
@@ -0,0 +1,213 @@
+package twitchbot
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+// The login Twitch gives gifts from people who didn't want their name shown
+const anonymousGifterLogin = "ananonymousgifter"
+
+// The single gift notices that follow a gift bomb usually arrive within seconds. Ones later than
+// this are thanked on their own.
+const giftBombWindow = time.Minute
+
+// SubNotice is a sub, resub or gifted sub announced in chat through a USERNOTICE
+type SubNotice struct {
+	// sub, resub, subgift, anonsubgift, submysterygift or anonsubmysterygift
+	Kind string
+
+	Channel string
+
+	// Who subscribed, or who gifted
+	Login       string
+	DisplayName string
+	UserID      string
+
+	// Months subscribed in total, and in a row if they chose to share it
+	Months int
+	Streak int
+
+	// "Prime", "Tier 1", "Tier 2" or "Tier 3"
+	Tier string
+
+	// For single gifts, who got the sub
+	Recipient string
+
+	// For gift bombs, how many subs were given out
+	Count int
+
+	Anonymous bool
+
+	// What a resubscriber wrote
+	Text string
+}
+
+// Reads a USERNOTICE's sub tags
+func parseSubNotice(ircMessage *irc.Message) SubNotice {
+	tags := ircMessage.Tags
+	notice := SubNotice{
+		Kind:        tags["msg-id"],
+		Channel:     ircMessage.Channel(),
+		Login:       strings.ToLower(tags["login"]),
+		DisplayName: tags["display-name"],
+		UserID:      tags["user-id"],
+		Tier:        subTier(tags["msg-param-sub-plan"]),
+		Recipient:   tags["msg-param-recipient-display-name"],
+		Text:        ircMessage.Trailing,
+	}
+	if notice.DisplayName == "" {
+		notice.DisplayName = notice.Login
+	}
+
+	notice.Months, _ = strconv.Atoi(tags["msg-param-cumulative-months"])
+	if notice.Months == 0 {
+		notice.Months, _ = strconv.Atoi(tags["msg-param-months"])
+	}
+	if notice.Months == 0 {
+		notice.Months = 1
+	}
+	if tags["msg-param-should-share-streak"] == "1" {
+		notice.Streak, _ = strconv.Atoi(tags["msg-param-streak-months"])
+	}
+	notice.Count, _ = strconv.Atoi(tags["msg-param-mass-gift-count"])
+
+	if strings.HasPrefix(notice.Kind, "anon") || notice.Login == anonymousGifterLogin {
+		notice.Anonymous = true
+		notice.DisplayName = "An anonymous gifter"
+	}
+
+	return notice
+}
+
+func subTier(plan string) string {
+	switch plan {
+	case "Prime":
+		return "Prime"
+	case "2000":
+		return "Tier 2"
+	case "3000":
+		return "Tier 3"
+	}
+
+	return "Tier 1"
+}
+
+// Gift bombs being thanked as one, so their single gifts aren't thanked again
+type giftBombs struct {
+	mutex sync.Mutex
+
+	// Keyed by channel and gifter
+	pending map[string]*giftBomb
+}
+
+type giftBomb struct {
+	remaining int
+	until     time.Time
+}
+
+// Notes a gift bomb whose count single gifts should be left out
+func (bombs *giftBombs) start(channel, gifter string, count int) {
+	bombs.mutex.Lock()
+	defer bombs.mutex.Unlock()
+
+	if bombs.pending == nil {
+		bombs.pending = map[string]*giftBomb{}
+	}
+
+	key := channel + "/" + gifter
+	bomb, ok := bombs.pending[key]
+	if !ok || time.Now().After(bomb.until) {
+		bomb = &giftBomb{}
+		bombs.pending[key] = bomb
+	}
+	bomb.remaining += count
+	bomb.until = time.Now().Add(giftBombWindow)
+}
+
+// Whether a single gift belongs to a gift bomb that was already thanked
+func (bombs *giftBombs) claim(channel, gifter string) bool {
+	bombs.mutex.Lock()
+	defer bombs.mutex.Unlock()
+
+	key := channel + "/" + gifter
+	bomb, ok := bombs.pending[key]
+	if !ok {
+		return false
+	}
+	if bomb.remaining <= 0 || time.Now().After(bomb.until) {
+		delete(bombs.pending, key)
+		return false
+	}
+
+	bomb.remaining--
+	return true
+}
+
+// Thanks a subscriber or gifter with SubThanks, ResubThanks, GiftThanks or GiftBombThanks.
+// With BatchGiftBombs a gift bomb is thanked once; otherwise each of its gifts is.
+func (bot *Bot) thankSubscriber(ircMessage *irc.Message) {
+	if bot.Anonymous {
+		return
+	}
+
+	notice := parseSubNotice(ircMessage)
+	gifter := notice.Login
+	if notice.Anonymous {
+		gifter = anonymousGifterLogin
+	}
+
+	var template string
+	switch notice.Kind {
+	case "sub":
+		template = bot.channelResponse(notice.Channel, func(settings ChannelSettings) string { return settings.SubThanks }, &bot.SubThanks)
+	case "resub":
+		template = bot.channelResponse(notice.Channel, func(settings ChannelSettings) string { return settings.ResubThanks }, &bot.ResubThanks)
+	case "subgift", "anonsubgift":
+		if bot.batchGiftBombs() && bot.giftBombs.claim(notice.Channel, gifter) {
+			return
+		}
+		template = bot.channelResponse(notice.Channel, func(settings ChannelSettings) string { return settings.GiftThanks }, &bot.GiftThanks)
+	case "submysterygift", "anonsubmysterygift":
+		if !bot.batchGiftBombs() {
+			return
+		}
+		bot.giftBombs.start(notice.Channel, gifter, notice.Count)
+		template = bot.channelResponse(notice.Channel, func(settings ChannelSettings) string { return settings.GiftBombThanks }, &bot.GiftBombThanks)
+	}
+	if template == "" {
+		return
+	}
+
+	message := &Message{
+		Type:        "USERNOTICE",
+		Channel:     notice.Channel,
+		Username:    notice.Login,
+		DisplayName: notice.DisplayName,
+		UserID:      notice.UserID,
+		Tags:        ircMessage.Tags,
+		IRC:         ircMessage,
+	}
+	values := map[string]string{
+		"months":    strconv.Itoa(notice.Months),
+		"streak":    strconv.Itoa(notice.Streak),
+		"tier":      notice.Tier,
+		"gifter":    notice.DisplayName,
+		"recipient": notice.Recipient,
+		"count":     strconv.Itoa(notice.Count),
+		"message":   sanitizeTemplateInput(notice.Text),
+	}
+
+	bot.ChatWithPriority(notice.Channel, strings.TrimSpace(bot.RenderTemplate(template, &Command{Message: message}, values)), PriorityReply)
+}
+
+func (bot *Bot) batchGiftBombs() bool {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	return bot.BatchGiftBombs
+}
@@ -0,0 +1,108 @@
+package twitchbot
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+// Emote is one use of an emote in a message's text. Start and End are inclusive rune offsets.
+type Emote struct {
+	ID    string
+	Start int
+	End   int
+}
+
+// Parses the badges tag, e.g. "broadcaster/1,subscriber/12" into badge name to version
+func parseBadges(value string) map[string]string {
+	badges := map[string]string{}
+
+	for _, badge := range strings.Split(value, ",") {
+		if badge == "" {
+			continue
+		}
+
+		name, version := badge, ""
+		if slash := strings.IndexByte(badge, '/'); slash != -1 {
+			name, version = badge[:slash], badge[slash+1:]
+		}
+
+		badges[name] = version
+	}
+
+	return badges
+}
+
+// Parses the emotes tag, e.g. "25:0-4,12-16/1902:6-10"
+func parseEmotes(value string) []Emote {
+	emotes := []Emote{}
+
+	for _, emote := range strings.Split(value, "/") {
+		colon := strings.IndexByte(emote, ':')
+		if colon == -1 {
+			continue
+		}
+
+		id := emote[:colon]
+		for _, span := range strings.Split(emote[colon+1:], ",") {
+			dash := strings.IndexByte(span, '-')
+			if dash == -1 {
+				continue
+			}
+
+			start, startErr := strconv.Atoi(span[:dash])
+			end, endErr := strconv.Atoi(span[dash+1:])
+			if startErr != nil || endErr != nil {
+				continue
+			}
+
+			emotes = append(emotes, Emote{ID: id, Start: start, End: end})
+		}
+	}
+
+	return emotes
+}
+
+// Builds a Message from a PRIVMSG or WHISPER, pulling out the Twitch tags handlers care about
+func newMessage(ircMessage *irc.Message) *Message {
+	tags := ircMessage.Tags
+
+	message := &Message{
+		Type:        ircMessage.Command,
+		Username:    ircMessage.Prefix.Name,
+		DisplayName: tags["display-name"],
+		UserID:      tags["user-id"],
+		MessageID:   tags["id"],
+		Color:       tags["color"],
+		Badges:      parseBadges(tags["badges"]),
+		Emotes:      parseEmotes(tags["emotes"]),
+		Mod:         tags["mod"] == "1",
+		Subscriber:  tags["subscriber"] == "1",
+		Tags:        tags,
+		Text:        ircMessage.Trailing,
+		Raw:         ircMessage.Raw,
+		IRC:         ircMessage,
+	}
+
+	if message.DisplayName == "" {
+		message.DisplayName = message.Username
+	}
+
+	// Whispers carry their ID as message-id instead
+	if message.MessageID == "" {
+		message.MessageID = tags["message-id"]
+	}
+
+	if message.Type == "PRIVMSG" {
+		message.Channel = ircMessage.Channel()
+	}
+
+	return message
+}
+
+// HasBadge reports whether the sender displays a badge, such as "broadcaster", "moderator", "vip" or "subscriber"
+func (message *Message) HasBadge(name string) bool {
+	_, ok := message.Badges[name]
+	return ok
+}
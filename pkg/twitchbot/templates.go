@@ -0,0 +1,248 @@
+package twitchbot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const commandCountsBucket = "command_counts"
+
+const defaultChuckNorrisResponse = "$(user): $(fact)"
+
+// The longest text a user's arguments can put into a response
+const maxTemplateInputLength = 200
+
+// How far from 0 the ends of $(random low high) can be
+const maxRandomBound = 1000000000
+
+// TemplateContext is what a template variable can draw on while a response is rendered
+type TemplateContext struct {
+	Bot *Bot
+
+	// The command being answered
+	Command *Command
+
+	// Values supplied by the handler rendering the template, such as "fact" for !chucknorris
+	Values map[string]string
+}
+
+// TemplateVariable produces the text for a "$(name args...)" variable
+type TemplateVariable func(context *TemplateContext, args []string) string
+
+var (
+	templateVariablesMutex sync.RWMutex
+	templateVariables      = map[string]TemplateVariable{}
+)
+
+// RegisterTemplateVariable adds a variable that can be used as "$(name)" or "$(name arg...)" in any response
+func RegisterTemplateVariable(name string, variable TemplateVariable) {
+	templateVariablesMutex.Lock()
+	defer templateVariablesMutex.Unlock()
+
+	templateVariables[strings.ToLower(name)] = variable
+}
+
+func init() {
+	RegisterTemplateVariable("user", func(context *TemplateContext, args []string) string {
+		return context.Command.DisplayName
+	})
+
+	// The first argument without its "@", or the user if there are no arguments
+	RegisterTemplateVariable("touser", func(context *TemplateContext, args []string) string {
+		if len(context.Command.Args) == 0 {
+			return context.Command.DisplayName
+		}
+
+		return sanitizeTemplateInput(strings.TrimPrefix(context.Command.Args[0], "@"))
+	})
+
+	RegisterTemplateVariable("channel", func(context *TemplateContext, args []string) string {
+		return context.Command.Channel
+	})
+
+	RegisterTemplateVariable("args", func(context *TemplateContext, args []string) string {
+		return sanitizeTemplateInput(strings.Join(context.Command.Args, " "))
+	})
+
+	RegisterTemplateVariable("pronouns", func(context *TemplateContext, args []string) string {
+		return context.Bot.Pronouns(context.Command.Message)
+	})
+
+	// $(random 1 100) for a number in the range, or $(random) for 1 to 100. Both ends have to be
+	// within ±1e9, so the range always fits in an int.
+	RegisterTemplateVariable("random", func(context *TemplateContext, args []string) string {
+		low, high := 1, 100
+		if len(args) == 2 {
+			var lowErr, highErr error
+			low, lowErr = strconv.Atoi(args[0])
+			high, highErr = strconv.Atoi(args[1])
+			if lowErr != nil || highErr != nil || high < low || low < -maxRandomBound || high > maxRandomBound {
+				return "?"
+			}
+		}
+
+		return strconv.Itoa(low + context.Bot.Random.Intn(high-low+1))
+	})
+
+	// $(pick heads|tails)
+	RegisterTemplateVariable("pick", func(context *TemplateContext, args []string) string {
+		return context.Bot.pickRandom(strings.Split(strings.Join(args, " "), "|"))
+	})
+
+	// How many times the command has been used in the channel, including this time
+	RegisterTemplateVariable("count", func(context *TemplateContext, args []string) string {
+		count, err := context.Bot.incrementCommandCount(context.Command.Channel, context.Command.Name)
+		if err != nil {
+			logger.Warn(err.Error())
+			return "?"
+		}
+
+		return strconv.Itoa(count)
+	})
+}
+
+func (bot *Bot) incrementCommandCount(channel, name string) (int, error) {
+	bot.templateMutex.Lock()
+	defer bot.templateMutex.Unlock()
+
+	key := channel + "/" + strings.ToLower(name)
+	count := 0
+	_, err := bot.Store.Get(commandCountsBucket, key, &count)
+	if err != nil {
+		return 0, fmt.Errorf("Bot.incrementCommandCount: %s", err.Error())
+	}
+
+	count++
+	err = bot.Store.Put(commandCountsBucket, key, count)
+	if err != nil {
+		return 0, fmt.Errorf("Bot.incrementCommandCount: %s", err.Error())
+	}
+
+	return count, nil
+}
+
+func lookupTemplateVariable(name string) (TemplateVariable, bool) {
+	templateVariablesMutex.RLock()
+	defer templateVariablesMutex.RUnlock()
+
+	variable, ok := templateVariables[name]
+	return variable, ok
+}
+
+// RenderTemplate fills in the "$(...)" variables of a response. values adds variables only this
+// response knows about, and also allows "$(1)" through "$(9)" for single arguments. The
+// channel's variables can be used as "$(var.name)" or "{var.name}". Unknown variables are left
+// as they are.
+func (bot *Bot) RenderTemplate(template string, command *Command, values map[string]string) string {
+	context := &TemplateContext{Bot: bot, Command: command, Values: values}
+
+	var builder strings.Builder
+	rest := template
+
+	for {
+		start := strings.Index(rest, "$(")
+		closing := byte(')')
+		if braced := strings.Index(rest, "{var."); braced != -1 && (start == -1 || braced < start) {
+			start = braced
+			closing = '}'
+		}
+		if start == -1 {
+			builder.WriteString(rest)
+			break
+		}
+
+		end := strings.IndexByte(rest[start:], closing)
+		if end == -1 && closing == '}' {
+			// Not a variable after all, but $(...) may still follow
+			builder.WriteString(rest[:start+1])
+			rest = rest[start+1:]
+			continue
+		}
+		if end == -1 {
+			builder.WriteString(rest)
+			break
+		}
+		end += start
+
+		builder.WriteString(rest[:start])
+		if closing == '}' {
+			builder.WriteString(bot.renderChannelVariable(context, rest[start+len("{var."):end], rest[start:end+1]))
+		} else {
+			builder.WriteString(bot.renderVariable(context, rest[start:end+1]))
+		}
+		rest = rest[end+1:]
+	}
+
+	// A response made to start with "/" or "." by its variables would run as a chat command
+	rendered := builder.String()
+	if !isChatCommand(template) && isChatCommand(rendered) {
+		rendered = strings.TrimLeft(rendered, "/. \t")
+	}
+
+	return rendered
+}
+
+func isChatCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "/") || strings.HasPrefix(text, ".")
+}
+
+// Makes text from chat safe to put in a response: no control characters that could end the IRC
+// line, no leading "/" or "." that Twitch would run as a command, and no more than
+// maxTemplateInputLength characters
+func sanitizeTemplateInput(text string) string {
+	text = strings.Map(func(char rune) rune {
+		if unicode.IsControl(char) {
+			return -1
+		}
+		return char
+	}, text)
+
+	text = strings.TrimLeft(text, "/. ")
+
+	if runes := []rune(text); len(runes) > maxTemplateInputLength {
+		text = string(runes[:maxTemplateInputLength])
+	}
+
+	return text
+}
+
+// Renders a single "$(name args...)"
+func (bot *Bot) renderVariable(context *TemplateContext, variable string) string {
+	fields := strings.Fields(variable[2 : len(variable)-1])
+	if len(fields) == 0 {
+		return variable
+	}
+
+	name := strings.ToLower(fields[0])
+
+	if value, ok := context.Values[name]; ok {
+		return value
+	}
+
+	if strings.HasPrefix(name, "var.") {
+		return bot.renderChannelVariable(context, strings.TrimPrefix(name, "var."), variable)
+	}
+
+	if index, err := strconv.Atoi(name); err == nil && index >= 1 && index <= 9 {
+		if index <= len(context.Command.Args) {
+			return sanitizeTemplateInput(context.Command.Args[index-1])
+		}
+		return ""
+	}
+
+	handler, ok := lookupTemplateVariable(name)
+	if !ok {
+		return variable
+	}
+
+	return handler(context, fields[1:])
+}
+
+// Fills in the variables of a custom command response
+func (bot *Bot) renderTemplate(template string, command *Command) string {
+	return bot.RenderTemplate(template, command, nil)
+}
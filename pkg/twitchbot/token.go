@@ -0,0 +1,97 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// Scopes the bot can't chat without
+var requiredScopes = []string{"chat:read", "chat:edit"}
+
+// Scopes features that go through Helix need, when there's a ClientID to call it with
+var helixScopes = []string{"user:manage:whispers", "moderator:manage:announcements", "moderator:read:followers"}
+
+// An Auth client for Twitch's OAuth server, going through Proxy like other API calls
+func (bot *Bot) auth() *helix.Auth {
+	return &helix.Auth{ClientID: bot.ClientID, HTTPClient: bot.httpClient(10 * time.Second)}
+}
+
+// Checks the token with Twitch before connecting, so a bad one fails with a clear error instead of
+// a NOTICE after logging in. A token that's invalid, lacks the chat scopes or belongs to another
+// account is an error. If Twitch can't be reached the bot connects anyway.
+func (bot *Bot) validateToken() error {
+	bot.secretsMutex.Lock()
+	token := bot.oAuthToken
+	bot.secretsMutex.Unlock()
+
+	info, err := bot.auth().Validate(bot.context(), token)
+	if helixError, ok := err.(*helix.Error); ok && helixError.StatusCode == http.StatusUnauthorized {
+		if !bot.canRefreshToken() {
+			return errors.New("The OAuth token is invalid or expired. Generate a new one for " + bot.BotName)
+		}
+
+		logger.Warn("The OAuth token expired, refreshing it")
+		err = bot.refreshAccessToken()
+		if err != nil {
+			return errors.New("The OAuth token expired and couldn't be refreshed: " + err.Error())
+		}
+
+		bot.secretsMutex.Lock()
+		token = bot.oAuthToken
+		bot.secretsMutex.Unlock()
+		info, err = bot.auth().Validate(bot.context(), token)
+		if helixError, ok := err.(*helix.Error); ok && helixError.StatusCode == http.StatusUnauthorized {
+			return errors.New("Twitch rejected the refreshed OAuth token too. Generate a new one for " + bot.BotName)
+		}
+	}
+	if err != nil {
+		logger.Warn("Couldn't validate the OAuth token, connecting anyway: %s", err.Error())
+		return nil
+	}
+
+	if !strings.EqualFold(info.Login, bot.BotName) {
+		return fmt.Errorf("The OAuth token belongs to %s, not %s. Change bot_name or use a token for %s", info.Login, bot.BotName, bot.BotName)
+	}
+
+	missing := missingScopes(info, requiredScopes)
+	if len(missing) > 0 {
+		return fmt.Errorf("The OAuth token is missing the %s scope(s) the bot needs to chat", strings.Join(missing, ", "))
+	}
+
+	if bot.ClientID != "" {
+		if info.ClientID != bot.ClientID {
+			logger.Warn("The OAuth token was made for client ID %s, not client_id %s. Twitch API calls will fail", info.ClientID, bot.ClientID)
+		}
+
+		missing = missingScopes(info, helixScopes)
+		if len(missing) > 0 {
+			logger.Warn("The OAuth token is missing the %s scope(s). Whispers, announcements or follow dates won't work", strings.Join(missing, ", "))
+		}
+	}
+
+	if expires := info.Expires(); expires > 0 && expires < time.Hour {
+		logger.Warn("The OAuth token expires in %s", expires.Round(time.Minute))
+	}
+
+	bot.setBotUserID(info.UserID)
+	bot.setTokenExpiry(info.Expires())
+	logger.Success("OAuth token is valid for %s", info.Login)
+
+	return nil
+}
+
+func missingScopes(info *helix.TokenInfo, scopes []string) []string {
+	missing := []string{}
+	for _, scope := range scopes {
+		if !info.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,226 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Refresh this long before the token expires
+	tokenRefreshMargin = 10 * time.Minute
+
+	// How often to check on a token whose expiry is unknown
+	tokenCheckInterval = time.Hour
+
+	// Wait after a failed refresh before trying again
+	tokenRefreshRetry = time.Minute
+
+	// Don't refresh again this soon after the last refresh, whether Twitch rejected the token or
+	// handed back one that expires within tokenRefreshMargin
+	minTokenRefreshInterval = time.Minute
+)
+
+// Whether the secrets had what's needed to refresh the token. Apps set up as public
+// clients, like ones used for DeviceLogin, have no client secret.
+func (bot *Bot) canRefreshToken() bool {
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	return bot.refreshToken != "" && bot.ClientID != ""
+}
+
+func (bot *Bot) setTokenExpiry(expires time.Duration) {
+	bot.secretsMutex.Lock()
+	defer bot.secretsMutex.Unlock()
+
+	bot.tokenExpiry = time.Time{}
+	if expires > 0 {
+		bot.tokenExpiry = time.Now().Add(expires)
+	}
+}
+
+// Trades the refresh token for a new access token, used on the next authentication, and saves
+// both if the secrets provider is a TokenSaver
+func (bot *Bot) refreshAccessToken() error {
+	bot.latestRefreshToken()
+
+	bot.secretsMutex.Lock()
+	refreshToken := bot.refreshToken
+	auth := bot.auth()
+	auth.ClientSecret = bot.clientSecret
+	bot.lastTokenRefresh = time.Now()
+	bot.secretsMutex.Unlock()
+
+	token, err := auth.Refresh(bot.context(), refreshToken)
+	if err != nil {
+		return errors.New("Bot.refreshAccessToken: " + err.Error())
+	}
+
+	bot.secretsMutex.Lock()
+	bot.oAuthToken = "oauth:" + token.AccessToken
+	if token.RefreshToken != "" {
+		bot.refreshToken = token.RefreshToken
+	}
+	refreshToken = bot.refreshToken
+	bot.secretsMutex.Unlock()
+	bot.setTokenExpiry(token.Expires())
+
+	logger.Success("Refreshed the OAuth token, it expires in %s", token.Expires().Round(time.Minute))
+
+	if bot.configToken() != "" {
+		logger.Warn("Bot.refreshAccessToken: the token came from the config, so the new one isn't saved")
+		return nil
+	}
+
+	saver, ok := bot.secretsProvider().(TokenSaver)
+	if !ok {
+		logger.Warn("Bot.refreshAccessToken: the secrets provider can't keep tokens, so the new one isn't saved")
+		return nil
+	}
+
+	err = saver.SaveToken("oauth:"+token.AccessToken, refreshToken)
+	if err != nil {
+		logger.Error("Bot.refreshAccessToken: couldn't save the new token: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Refreshes the token shortly before it expires, for as long as the Bot runs
+func (bot *Bot) keepTokenFresh() {
+	refreshed := false
+	for {
+		bot.secretsMutex.Lock()
+		expiry := bot.tokenExpiry
+		bot.secretsMutex.Unlock()
+
+		wait := tokenCheckInterval
+		if !expiry.IsZero() {
+			wait = time.Until(expiry) - tokenRefreshMargin
+		}
+		// A token that expires within the margin would otherwise be refreshed over and over
+		if refreshed && wait < minTokenRefreshInterval {
+			wait = minTokenRefreshInterval
+		}
+
+		if wait > 0 && bot.sleepUnlessStopped(wait) {
+			return
+		}
+		if bot.isStopping() {
+			return
+		}
+
+		if expiry.IsZero() {
+			// Ask Twitch how long the token has left before deciding
+			err := bot.validateToken()
+			if err == nil {
+				bot.secretsMutex.Lock()
+				known := !bot.tokenExpiry.IsZero()
+				bot.secretsMutex.Unlock()
+				if known {
+					continue
+				}
+			}
+		}
+
+		err := bot.refreshAccessToken()
+		refreshed = err == nil
+		if err != nil {
+			logger.Error(err.Error())
+			if bot.sleepUnlessStopped(tokenRefreshRetry) {
+				return
+			}
+		}
+	}
+}
+
+// Called when Twitch rejects the token mid-session. Returns true if a new token was fetched, so
+// the Bot can reconnect with it instead of stopping.
+func (bot *Bot) recoverFromRejectedToken() bool {
+	if !bot.canRefreshToken() {
+		return false
+	}
+
+	bot.secretsMutex.Lock()
+	recent := time.Since(bot.lastTokenRefresh) < minTokenRefreshInterval
+	bot.secretsMutex.Unlock()
+	if recent {
+		return false
+	}
+
+	err := bot.refreshAccessToken()
+	if err != nil {
+		logger.Error(err.Error())
+		return false
+	}
+
+	return true
+}
+
+// Writes keys into the secrets file, keeping everything else in it. With a key the file is
+// encrypted.
+func saveSecrets(path string, key secretsKey, secrets map[string]string) error {
+	values := map[string]interface{}{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		data, _, err = decryptSecrets(data, key)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(data, &values)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, value := range secrets {
+		values[name] = value
+	}
+
+	data, err = json.MarshalIndent(values, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if !key.empty() {
+		data, err = encryptSecrets(data, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// Writes a temporary file readable only by its owner and renames it, so a crash never leaves
+// half a secrets file
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), ".secrets-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(temp.Name(), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
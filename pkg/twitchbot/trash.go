@@ -0,0 +1,372 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trashBucket = "trash"
+
+	defaultTrashRetention = 7 * 24 * time.Hour
+)
+
+// Kinds of things that go to the trash when deleted
+const (
+	trashCommand = "command"
+	trashQuote   = "quote"
+	trashTimer   = "timer"
+)
+
+// DeletedItem is a custom command, quote or timer that was deleted and can still be restored
+type DeletedItem struct {
+	// command, quote or timer
+	Kind    string `json:"kind"`
+	Channel string `json:"channel"`
+
+	// The command's name, the quote's number or the timer's ID
+	Name string `json:"name"`
+
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+
+	// The CustomCommand, Quote or ScheduledMessage as it was
+	Data json.RawMessage `json:"data"`
+}
+
+// Shows the item the way it's restored, e.g. "!hello", "quote #5" or "timer k2x1"
+func (item DeletedItem) String() string {
+	switch item.Kind {
+	case trashCommand:
+		return "!" + item.Name
+	case trashQuote:
+		return "quote #" + item.Name
+	}
+
+	return item.Kind + " " + item.Name
+}
+
+func trashKey(channel, kind, name string) string {
+	return channel + "/" + kind + "/" + strings.ToLower(name)
+}
+
+// How long deleted things can be restored, from TrashRetention or the default
+func (bot *Bot) trashRetention() time.Duration {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	if bot.TrashRetention <= 0 {
+		return defaultTrashRetention
+	}
+
+	return bot.TrashRetention
+}
+
+// Keeps something that's being deleted so it can be restored for TrashRetention
+func (bot *Bot) trash(kind, channel, name, deletedBy string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	item := DeletedItem{
+		Kind:      kind,
+		Channel:   channel,
+		Name:      strings.ToLower(name),
+		DeletedBy: deletedBy,
+		DeletedAt: time.Now(),
+		Data:      data,
+	}
+
+	err = bot.Store.Put(trashBucket, trashKey(channel, kind, name), item)
+	if err != nil {
+		return err
+	}
+
+	bot.publish(FeedModeration, channel, deletedBy, "deleted "+item.String())
+	bot.purgeTrash()
+
+	return nil
+}
+
+// Forgets deleted things older than TrashRetention, in every channel
+func (bot *Bot) purgeTrash() {
+	keys, err := bot.Store.Keys(trashBucket)
+	if err != nil {
+		logger.Warn("Bot.purgeTrash: %s", err.Error())
+		return
+	}
+
+	retention := bot.trashRetention()
+	for _, key := range keys {
+		item := DeletedItem{}
+		found, err := bot.Store.Get(trashBucket, key, &item)
+		if err != nil || !found || time.Since(item.DeletedAt) <= retention {
+			continue
+		}
+
+		err = bot.Store.Delete(trashBucket, key)
+		if err != nil {
+			logger.Warn("Bot.purgeTrash: %s", err.Error())
+		}
+	}
+}
+
+// Trash lists what was deleted in a channel and can still be restored, newest first
+func (bot *Bot) Trash(channel string) ([]DeletedItem, error) {
+	bot.purgeTrash()
+
+	keys, err := bot.Store.Keys(trashBucket)
+	if err != nil {
+		return nil, errors.New("Bot.Trash: " + err.Error())
+	}
+
+	items := []DeletedItem{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		item := DeletedItem{}
+		found, err := bot.Store.Get(trashBucket, key, &item)
+		if err != nil {
+			return nil, errors.New("Bot.Trash: " + err.Error())
+		}
+		if found {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+
+	return items, nil
+}
+
+// A deleted item that's still within TrashRetention
+func (bot *Bot) deletedItem(channel, kind, name string) (*DeletedItem, bool) {
+	item := &DeletedItem{}
+	found, err := bot.Store.Get(trashBucket, trashKey(channel, kind, name), item)
+	if err != nil {
+		logger.Warn("Bot.deletedItem: %s", err.Error())
+		return nil, false
+	}
+	if !found || time.Since(item.DeletedAt) > bot.trashRetention() {
+		return nil, false
+	}
+
+	return item, true
+}
+
+// Restore brings back a deleted custom command, quote or timer. Returns false if there's
+// nothing to restore. It fails if something new took the command's name or the quote's number,
+// or a timer is past due and doesn't repeat.
+func (bot *Bot) Restore(channel, kind, name string) (bool, error) {
+	item, found := bot.deletedItem(channel, kind, name)
+	if !found {
+		return false, nil
+	}
+
+	if reason := bot.restoreBlocked(item); reason != "" {
+		return false, errors.New("Bot.Restore: " + reason)
+	}
+
+	var err error
+	switch kind {
+	case trashCommand:
+		err = bot.restoreCustomCommand(item)
+	case trashQuote:
+		err = bot.restoreQuote(item)
+	case trashTimer:
+		err = bot.restoreScheduledMessage(item)
+	default:
+		err = fmt.Errorf("can't restore a %s", kind)
+	}
+	if err != nil {
+		return false, errors.New("Bot.Restore: " + err.Error())
+	}
+
+	err = bot.Store.Delete(trashBucket, trashKey(channel, kind, name))
+	if err != nil {
+		return true, errors.New("Bot.Restore: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// Why an item can't be restored, or "" if it can
+func (bot *Bot) restoreBlocked(item *DeletedItem) string {
+	switch item.Kind {
+	case trashCommand:
+		if _, ok := bot.CustomCommand(item.Channel, item.Name); ok {
+			return fmt.Sprintf("!%s was added again", item.Name)
+		}
+	case trashQuote:
+		id, _ := strconv.Atoi(item.Name)
+		if _, ok := bot.Quote(item.Channel, id); ok {
+			return fmt.Sprintf("quote #%d is back already", id)
+		}
+	case trashTimer:
+		scheduled := &ScheduledMessage{}
+		err := json.Unmarshal(item.Data, scheduled)
+		if err != nil {
+			return err.Error()
+		}
+		if restoredTime(scheduled).IsZero() {
+			return "it was due at " + scheduled.At.Format(time.RFC3339) + " and doesn't repeat"
+		}
+	}
+
+	return ""
+}
+
+// When a restored timer goes off: when it was due, or its next time if that's past and it
+// repeats. Zero if it's too late.
+func restoredTime(scheduled *ScheduledMessage) time.Time {
+	now := time.Now()
+	if scheduled.At.After(now) {
+		return scheduled.At
+	}
+	if scheduled.Repeat != nil {
+		return scheduled.Repeat.Next(now)
+	}
+
+	return time.Time{}
+}
+
+func (bot *Bot) restoreCustomCommand(item *DeletedItem) error {
+	custom := &CustomCommand{}
+	err := json.Unmarshal(item.Data, custom)
+	if err != nil {
+		return err
+	}
+
+	return bot.SaveCustomCommand(custom)
+}
+
+func (bot *Bot) restoreQuote(item *DeletedItem) error {
+	quote := Quote{}
+	err := json.Unmarshal(item.Data, &quote)
+	if err != nil {
+		return err
+	}
+
+	return bot.Store.Put(quotesBucket, quoteKey(quote.Channel, quote.ID), quote)
+}
+
+// Arms a timer again. One that repeats picks up at its next time.
+func (bot *Bot) restoreScheduledMessage(item *DeletedItem) error {
+	scheduled := &ScheduledMessage{}
+	err := json.Unmarshal(item.Data, scheduled)
+	if err != nil {
+		return err
+	}
+
+	scheduled.At = restoredTime(scheduled)
+	if scheduled.At.IsZero() {
+		return fmt.Errorf("timer %s is past due", scheduled.ID)
+	}
+
+	err = bot.Store.Put(scheduledMessagesBucket, scheduled.ID, scheduled)
+	if err != nil {
+		return err
+	}
+
+	bot.armScheduledMessage(scheduled, time.Until(scheduled.At))
+
+	return nil
+}
+
+// Reads "!name", "command name", "quote 5", "#5" or "timer id"
+func parseRestoreArgs(args []string) (kind, name string, ok bool) {
+	if len(args) == 1 {
+		switch {
+		case strings.HasPrefix(args[0], "!"):
+			return trashCommand, strings.ToLower(strings.TrimLeft(args[0], "!?~")), true
+		case strings.HasPrefix(args[0], "#"):
+			args = []string{trashQuote, args[0]}
+		default:
+			return "", "", false
+		}
+	}
+	if len(args) != 2 {
+		return "", "", false
+	}
+
+	kind = strings.ToLower(args[0])
+	switch kind {
+	case trashCommand, "com":
+		return trashCommand, strings.ToLower(strings.TrimLeft(args[1], "!?~")), true
+	case trashQuote:
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return "", "", false
+		}
+		return trashQuote, strconv.Itoa(id), true
+	case trashTimer:
+		return trashTimer, args[1], true
+	}
+
+	return "", "", false
+}
+
+// Lists what can be restored, or restores it: !restore [!name | quote <number> | timer <id>]
+func restoreCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		items, err := bot.Trash(command.Channel)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		if len(items) == 0 {
+			bot.Reply(command.Message, "Nothing was deleted recently.")
+			return
+		}
+
+		listed := make([]string, 0, len(items))
+		for _, item := range items {
+			entry := item.String()
+			if item.DeletedBy != "" {
+				entry += " by " + item.DeletedBy
+			}
+			listed = append(listed, entry)
+		}
+
+		for _, message := range packMessages("Deleted recently: ", ", ", listed) {
+			bot.Reply(command.Message, message)
+		}
+		return
+	}
+
+	kind, name, ok := parseRestoreArgs(command.Args)
+	if !ok {
+		bot.Reply(command.Message, "Usage: !restore [!name | quote <number> | timer <id>]")
+		return
+	}
+
+	item, found := bot.deletedItem(command.Channel, kind, name)
+	if !found {
+		bot.Reply(command.Message, fmt.Sprintf("There's no deleted %s to restore.", DeletedItem{Kind: kind, Name: name}))
+		return
+	}
+
+	if reason := bot.restoreBlocked(item); reason != "" {
+		bot.Reply(command.Message, fmt.Sprintf("Can't restore %s, %s.", item, reason))
+		return
+	}
+
+	restored, err := bot.Restore(command.Channel, kind, name)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	if !restored {
+		return
+	}
+
+	bot.publish(FeedModeration, command.Channel, command.Username, "restored "+item.String())
+	bot.Reply(command.Message, fmt.Sprintf("Restored %s.", item))
+}
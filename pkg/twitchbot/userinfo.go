@@ -0,0 +1,285 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+)
+
+const (
+	firstSeenBucket      = "first_seen"
+	moderationLogsBucket = "moderation_logs"
+)
+
+// ModerationLog counts the timeouts and bans a user got in a channel, from anyone
+type ModerationLog struct {
+	Timeouts   int       `json:"timeouts"`
+	Bans       int       `json:"bans"`
+	LastAction time.Time `json:"last_action"`
+}
+
+// UserReport is everything the bot knows about a user in a channel, for !userinfo and /users
+type UserReport struct {
+	UserID      string         `json:"user_id"`
+	Login       string         `json:"login"`
+	DisplayName string         `json:"display_name,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	FirstSeen   time.Time      `json:"first_seen"`
+	Messages    int            `json:"messages"`
+	Commands    int            `json:"commands"`
+	Strikes     int            `json:"strikes"`
+	Previous    []PreviousName `json:"previous,omitempty"`
+	FollowedAt  time.Time      `json:"followed_at"`
+	Following   bool           `json:"following"`
+	Moderation  ModerationLog  `json:"moderation"`
+}
+
+// Channel and user pairs whose first message is already stored, so the Store is only read once
+type firstSeenTracker struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+// Keeps when a user first chatted in a channel
+func (bot *Bot) noteFirstSeen(message *Message) {
+	if message.UserID == "" || message.Channel == "" {
+		return
+	}
+
+	key := leaderboardKey(message.Channel, message.UserID)
+
+	bot.firstSeen.mutex.Lock()
+	defer bot.firstSeen.mutex.Unlock()
+
+	if bot.firstSeen.seen == nil {
+		bot.firstSeen.seen = map[string]bool{}
+	}
+	if bot.firstSeen.seen[key] {
+		return
+	}
+	bot.firstSeen.seen[key] = true
+
+	var seen time.Time
+	found, err := bot.Store.Get(firstSeenBucket, key, &seen)
+	if err != nil {
+		logger.Warn("Bot.noteFirstSeen: %s", err.Error())
+		return
+	}
+	if found {
+		return
+	}
+
+	err = bot.Store.Put(firstSeenBucket, key, time.Now())
+	if err != nil {
+		logger.Error("Bot.noteFirstSeen: %s", err.Error())
+	}
+}
+
+// Counts a timeout or ban from CLEARCHAT. Clearing the whole chat has no target and is ignored.
+func (bot *Bot) recordModeration(ircMessage *irc.Message) {
+	userID := ircMessage.Tags["target-user-id"]
+	if userID == "" {
+		return
+	}
+
+	key := leaderboardKey(ircMessage.Channel(), userID)
+	log := ModerationLog{}
+	_, err := bot.Store.Get(moderationLogsBucket, key, &log)
+	if err != nil {
+		logger.Warn("Bot.recordModeration: %s", err.Error())
+		return
+	}
+
+	if _, timeout := ircMessage.Tags["ban-duration"]; timeout {
+		log.Timeouts++
+	} else {
+		log.Bans++
+	}
+	log.LastAction = time.Now()
+
+	err = bot.Store.Put(moderationLogsBucket, key, log)
+	if err != nil {
+		logger.Error("Bot.recordModeration: %s", err.Error())
+	}
+}
+
+// UserReport gathers what the bot knows about a user in a channel: when they first chatted,
+// how much, their strikes, timeouts and bans, previous names and, with a ClientID, their account
+// age and follow date. Returns false if the user was never seen and Helix doesn't know them.
+func (bot *Bot) UserReport(channel, login string) (*UserReport, bool, error) {
+	login = strings.ToLower(strings.TrimPrefix(login, "@"))
+
+	userID, found := bot.UserIDForLogin(login)
+	var err error
+	if !found && bot.ClientID != "" && !bot.Anonymous {
+		userID, found, err = bot.lookupUserID(login)
+	}
+	if err != nil {
+		return nil, false, errors.New("Bot.UserReport: " + err.Error())
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	report := &UserReport{UserID: userID, Login: login}
+	key := leaderboardKey(channel, userID)
+
+	if names, found, err := bot.UserNames(userID); err == nil && found {
+		report.Login = names.Login
+		report.Previous = names.Previous
+	}
+
+	if user, found, err := bot.User(userID); err == nil && found {
+		report.DisplayName = user.DisplayName
+		report.CreatedAt = user.CreatedAt
+	}
+
+	_, err = bot.Store.Get(firstSeenBucket, key, &report.FirstSeen)
+	if err != nil {
+		logger.Warn("Bot.UserReport: %s", err.Error())
+	}
+
+	bot.flushLeaderboards()
+	entry := LeaderboardEntry{}
+	_, err = bot.Store.Get(leaderboardBucket, key, &entry)
+	if err != nil {
+		logger.Warn("Bot.UserReport: %s", err.Error())
+	}
+	report.Messages = entry.Messages
+	report.Commands = entry.Commands
+
+	_, err = bot.Store.Get(moderationLogsBucket, key, &report.Moderation)
+	if err != nil {
+		logger.Warn("Bot.UserReport: %s", err.Error())
+	}
+
+	report.Strikes = bot.spamStrikes.count(report.Login, time.Now())
+
+	if bot.ClientID != "" && !bot.Anonymous {
+		bot.fillUserReportFromHelix(channel, report)
+	}
+
+	return report, true, nil
+}
+
+// Adds the account age and follow date. Seeing followers needs the bot to be a moderator with
+// the moderator:read:followers scope, so failures only leave them out.
+func (bot *Bot) fillUserReportFromHelix(channel string, report *UserReport) {
+	if report.CreatedAt.IsZero() {
+		users, err := bot.fetchUsers([]string{report.UserID})
+		if err != nil {
+			logger.Warn(err.Error())
+		} else if len(users) > 0 {
+			report.DisplayName = users[0].DisplayName
+			report.CreatedAt = users[0].CreatedAt
+		}
+	}
+
+	broadcasterID, err := bot.channelUserID(channel)
+	if err != nil {
+		logger.Warn(err.Error())
+		return
+	}
+
+	follower, following, err := bot.helix().GetChannelFollower(bot.context(), broadcasterID, report.UserID)
+	if err != nil {
+		logger.Warn("Bot.UserReport: %s", err.Error())
+		return
+	}
+
+	report.Following = following
+	if following {
+		report.FollowedAt = follower.FollowedAt
+	}
+}
+
+// One line summing up a report
+func (report *UserReport) String() string {
+	const day = "Jan 2 2006"
+
+	parts := []string{}
+	if !report.FirstSeen.IsZero() {
+		parts = append(parts, "first seen "+report.FirstSeen.Format(day))
+	}
+	parts = append(parts, fmt.Sprintf("%d messages", report.Messages))
+	if report.Strikes > 0 {
+		parts = append(parts, fmt.Sprintf("%d spam strikes", report.Strikes))
+	}
+	parts = append(parts, fmt.Sprintf("%d timeouts, %d bans", report.Moderation.Timeouts, report.Moderation.Bans))
+	if report.Following {
+		parts = append(parts, "following since "+report.FollowedAt.Format(day))
+	}
+	if !report.CreatedAt.IsZero() {
+		parts = append(parts, "account created "+report.CreatedAt.Format(day))
+	}
+	if len(report.Previous) > 0 {
+		previous := make([]string, 0, len(report.Previous))
+		for _, name := range report.Previous {
+			previous = append(previous, name.Login)
+		}
+		parts = append(parts, "previously "+strings.Join(previous, ", "))
+	}
+
+	return fmt.Sprintf("@%s: %s", report.Login, strings.Join(parts, ", "))
+}
+
+// Sums up a user for moderators: !userinfo @user. Whispered to the moderator who asked when
+// the bot can whisper, so it doesn't go out in chat.
+func userInfoCommand(bot *Bot, command *Command) {
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, "Usage: !userinfo @user")
+		return
+	}
+
+	login := strings.ToLower(strings.TrimPrefix(command.Args[0], "@"))
+	report, found, err := bot.UserReport(command.Channel, login)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	text := fmt.Sprintf("I don't know anything about @%s.", login)
+	if found {
+		text = report.String()
+	}
+
+	if !bot.whispersDisabled() {
+		bot.whisper(command.Username, text)
+		return
+	}
+
+	bot.Reply(command.Message, text)
+}
+
+// Serves a UserReport as JSON: /users?channel=name&user=login. It's for moderators, so it
+// needs AdminToken.
+func (bot *Bot) serveUserReport(w http.ResponseWriter, r *http.Request) {
+	if !bot.authorizedAdmin(r) {
+		bot.refuseUnauthorized(w)
+		return
+	}
+
+	channel := strings.ToLower(r.FormValue("channel"))
+	login := r.FormValue("user")
+	if channel == "" || login == "" {
+		http.Error(w, "channel and user are required", http.StatusBadRequest)
+		return
+	}
+
+	report, found, err := bot.UserReport(channel, login)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,187 @@
+package twitchbot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+const (
+	usersBucket = "users"
+
+	// Get Users takes at most this many IDs per request
+	helixUsersBatch = helix.MaxBatch
+
+	defaultUserBackfillInterval = 10 * time.Second
+
+	// The longest the backfill waits after Twitch says it is sending too many requests
+	maxUserBackfillInterval = 5 * time.Minute
+)
+
+// UserInfo is what the bot knows about a Twitch account
+type UserInfo struct {
+	ID          string    `json:"id"`
+	Login       string    `json:"login"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Users seen in chat whose details haven't been looked up yet
+type userBackfill struct {
+	mutex   sync.Mutex
+	pending map[string]bool
+	known   map[string]bool
+	started sync.Once
+}
+
+// User returns the stored details of an account, filled in by the backfill
+func (bot *Bot) User(userID string) (*UserInfo, bool, error) {
+	user := &UserInfo{}
+	found, err := bot.Store.Get(usersBucket, userID, user)
+	if err != nil {
+		return nil, false, errors.New("Bot.User: " + err.Error())
+	}
+
+	return user, found, nil
+}
+
+// Queues a chatter for the backfill, unless they were looked up before or there is no ClientID or
+// token to ask Helix with
+func (bot *Bot) noteUser(message *Message) {
+	if bot.ClientID == "" || bot.Anonymous || message.UserID == "" {
+		return
+	}
+
+	bot.users.started.Do(func() { go bot.backfillUsers() })
+
+	bot.users.mutex.Lock()
+	defer bot.users.mutex.Unlock()
+
+	if bot.users.known == nil {
+		bot.users.known = map[string]bool{}
+		bot.users.pending = map[string]bool{}
+	}
+
+	if bot.users.known[message.UserID] {
+		return
+	}
+	bot.users.known[message.UserID] = true
+
+	if _, found, err := bot.User(message.UserID); err == nil && found {
+		return
+	}
+
+	bot.users.pending[message.UserID] = true
+}
+
+// Takes up to one batch of pending user IDs
+func (bot *Bot) nextUserBatch() []string {
+	bot.users.mutex.Lock()
+	defer bot.users.mutex.Unlock()
+
+	batch := []string{}
+	for id := range bot.users.pending {
+		if len(batch) == helixUsersBatch {
+			break
+		}
+		batch = append(batch, id)
+		delete(bot.users.pending, id)
+	}
+
+	return batch
+}
+
+// Looks up pending users in batches, one request per UserBackfillInterval, backing off when
+// Twitch rate limits the bot
+func (bot *Bot) backfillUsers() {
+	base := bot.UserBackfillInterval
+	if base <= 0 {
+		base = defaultUserBackfillInterval
+	}
+	interval := base
+
+	for !bot.sleepUnlessStopped(interval) {
+		batch := bot.nextUserBatch()
+		if len(batch) == 0 {
+			continue
+		}
+
+		users, err := bot.fetchUsers(batch)
+		if err != nil {
+			logger.Warn(err.Error())
+
+			// Try them again later
+			bot.users.mutex.Lock()
+			for _, id := range batch {
+				bot.users.pending[id] = true
+			}
+			bot.users.mutex.Unlock()
+
+			interval *= 2
+			if interval > maxUserBackfillInterval {
+				interval = maxUserBackfillInterval
+			}
+			continue
+		}
+		interval = base
+
+		now := time.Now()
+		for _, user := range users {
+			user.UpdatedAt = now
+			err := bot.Store.Put(usersBucket, user.ID, user)
+			if err != nil {
+				logger.Error("Bot.backfillUsers: %s", err.Error())
+			}
+		}
+
+		logger.Quiet("Looked up %d user(s)", len(users))
+	}
+}
+
+// Calls Helix Get Users for up to 100 user IDs
+func (bot *Bot) fetchUsers(ids []string) ([]UserInfo, error) {
+	return bot.fetchUsersBy(ids, nil)
+}
+
+// Calls Helix Get Users for one login, for accounts the bot hasn't seen in chat
+func (bot *Bot) fetchUserByLogin(login string) (*UserInfo, bool, error) {
+	users, err := bot.fetchUsersBy(nil, []string{login})
+	if err != nil || len(users) == 0 {
+		return nil, false, err
+	}
+
+	return &users[0], true, nil
+}
+
+// Finds a login's user-id, from chat seen so far or else from Helix
+func (bot *Bot) lookupUserID(login string) (string, bool, error) {
+	login = strings.ToLower(strings.TrimPrefix(login, "@"))
+	if id, ok := bot.UserIDForLogin(login); ok {
+		return id, true, nil
+	}
+
+	user, found, err := bot.fetchUserByLogin(login)
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	return user.ID, true, nil
+}
+
+func (bot *Bot) fetchUsersBy(ids, logins []string) ([]UserInfo, error) {
+	users, err := bot.helix().GetUsers(bot.context(), ids, logins)
+	if err != nil {
+		return nil, errors.New("Bot.fetchUsers: " + err.Error())
+	}
+
+	infos := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, UserInfo{ID: user.ID, Login: user.Login, DisplayName: user.DisplayName, CreatedAt: user.CreatedAt})
+	}
+
+	return infos, nil
+}
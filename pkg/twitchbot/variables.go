@@ -0,0 +1,247 @@
+package twitchbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	channelVariablesBucket = "channel_variables"
+
+	maxVariableLength = 400
+)
+
+// Variable names are short words, e.g. discord, schedule or merch_store
+var variableName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ChannelVariable is a value moderators keep for a channel, like a social link or the stream
+// schedule, that any response can use as $(var.name) or {var.name}
+type ChannelVariable struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	SetBy     string    `json:"set_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func variableKey(channel, name string) string {
+	return channel + "/" + strings.ToLower(name)
+}
+
+// ChannelVariable looks up a channel's variable
+func (bot *Bot) ChannelVariable(channel, name string) (string, bool) {
+	variable := ChannelVariable{}
+	found, err := bot.Store.Get(channelVariablesBucket, variableKey(channel, name), &variable)
+	if err != nil {
+		logger.Warn("Bot.ChannelVariable: %s", err.Error())
+		return "", false
+	}
+
+	return variable.Value, found
+}
+
+// SetChannelVariable creates or changes a channel's variable. Names are up to 32 letters,
+// digits, "_" and "-".
+func (bot *Bot) SetChannelVariable(channel, name, value, setBy string) error {
+	name = strings.ToLower(name)
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("Bot.SetChannelVariable: %q isn't a variable name, use up to 32 letters, digits, _ and -", name)
+	}
+	if value == "" {
+		return errors.New("Bot.SetChannelVariable: value is empty")
+	}
+	if runes := []rune(value); len(runes) > maxVariableLength {
+		value = string(runes[:maxVariableLength])
+	}
+
+	variable := ChannelVariable{Name: name, Value: value, SetBy: setBy, UpdatedAt: time.Now()}
+	err := bot.Store.Put(channelVariablesBucket, variableKey(channel, name), variable)
+	if err != nil {
+		return errors.New("Bot.SetChannelVariable: " + err.Error())
+	}
+
+	return nil
+}
+
+// DeleteChannelVariable removes a channel's variable. Returns false if it had none by that name.
+func (bot *Bot) DeleteChannelVariable(channel, name string) (bool, error) {
+	if _, found := bot.ChannelVariable(channel, name); !found {
+		return false, nil
+	}
+
+	err := bot.Store.Delete(channelVariablesBucket, variableKey(channel, name))
+	if err != nil {
+		return false, errors.New("Bot.DeleteChannelVariable: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// ChannelVariables lists a channel's variables sorted by name
+func (bot *Bot) ChannelVariables(channel string) ([]ChannelVariable, error) {
+	keys, err := bot.Store.Keys(channelVariablesBucket)
+	if err != nil {
+		return nil, errors.New("Bot.ChannelVariables: " + err.Error())
+	}
+
+	variables := []ChannelVariable{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, channel+"/") {
+			continue
+		}
+
+		variable := ChannelVariable{}
+		found, err := bot.Store.Get(channelVariablesBucket, key, &variable)
+		if err != nil {
+			return nil, errors.New("Bot.ChannelVariables: " + err.Error())
+		}
+		if found {
+			variables = append(variables, variable)
+		}
+	}
+
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+
+	return variables, nil
+}
+
+// Renders "{var.name}" and "$(var.name)" from the channel's variables. A variable that isn't
+// set is left as it is, so it stands out.
+func (bot *Bot) renderChannelVariable(context *TemplateContext, name, original string) string {
+	if !variableName.MatchString(strings.ToLower(name)) {
+		return original
+	}
+
+	value, found := bot.ChannelVariable(context.Command.Channel, name)
+	if !found {
+		return original
+	}
+
+	return value
+}
+
+// !var set <name> <value> | !var del <name> | !var list | !var <name>
+func varCommand(bot *Bot, command *Command) {
+	usage := "Usage: !var set <name> <value> | del <name> | list | <name>"
+	if len(command.Args) == 0 {
+		bot.Reply(command.Message, usage)
+		return
+	}
+
+	switch strings.ToLower(command.Args[0]) {
+	case "set":
+		if len(command.Args) < 3 {
+			bot.Reply(command.Message, usage)
+			return
+		}
+
+		name := strings.ToLower(command.Args[1])
+		if !variableName.MatchString(name) {
+			bot.Reply(command.Message, "Variable names are up to 32 letters, digits, _ and -.")
+			return
+		}
+
+		err := bot.SetChannelVariable(command.Channel, name, strings.Join(command.Args[2:], " "), command.Username)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Set {var.%s}.", name))
+	case "del", "delete", "unset":
+		if len(command.Args) < 2 {
+			bot.Reply(command.Message, usage)
+			return
+		}
+
+		name := strings.ToLower(command.Args[1])
+		deleted, err := bot.DeleteChannelVariable(command.Channel, name)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		if !deleted {
+			bot.Reply(command.Message, fmt.Sprintf("There is no {var.%s}.", name))
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("Deleted {var.%s}.", name))
+	case "list":
+		variables, err := bot.ChannelVariables(command.Channel)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		if len(variables) == 0 {
+			bot.Reply(command.Message, "No variables yet. Add one with !var set <name> <value>.")
+			return
+		}
+
+		names := make([]string, 0, len(variables))
+		for _, variable := range variables {
+			names = append(names, variable.Name)
+		}
+
+		for _, message := range packMessages("Variables: ", ", ", names) {
+			bot.Reply(command.Message, message)
+		}
+	default:
+		name := strings.ToLower(command.Args[0])
+		value, found := bot.ChannelVariable(command.Channel, name)
+		if !found {
+			bot.Reply(command.Message, fmt.Sprintf("There is no {var.%s}.", name))
+			return
+		}
+
+		bot.Reply(command.Message, fmt.Sprintf("{var.%s} is %s", name, value))
+	}
+}
+
+// Serves a channel's variables: GET /variables?channel=name lists them, POST with name and
+// value sets one and DELETE with name removes it. POST and DELETE need AdminToken, and GET
+// does too when one is set.
+func (bot *Bot) serveChannelVariables(w http.ResponseWriter, r *http.Request) {
+	if !bot.authorizedRequest(r) {
+		bot.refuseUnauthorized(w)
+		return
+	}
+
+	channel := strings.ToLower(r.FormValue("channel"))
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		err := bot.SetChannelVariable(channel, r.FormValue("name"), r.FormValue("value"), "dashboard")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		deleted, err := bot.DeleteChannelVariable(channel, r.FormValue("name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	variables, err := bot.ChannelVariables(channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variables)
+}
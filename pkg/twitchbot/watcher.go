@@ -0,0 +1,60 @@
+package twitchbot
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+const defaultFileWatchInterval = 5 * time.Second
+
+// Identifies the current contents of a file. Kubernetes updates mounted ConfigMaps and Secrets by
+// swapping a "..data" symlink rather than writing the file, so the resolved path is included along
+// with a hash of the contents instead of relying on modification times.
+func fileSignature(path string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+
+	data, err := ioutil.ReadFile(resolved)
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(data)
+	return resolved + ":" + string(sum[:]), true
+}
+
+// Polls a file and calls onChange whenever its contents or symlink target change, until the Bot
+// stops. A file that briefly disappears mid-swap is ignored until it comes back.
+func (bot *Bot) watchFile(path string, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = defaultFileWatchInterval
+	}
+
+	last, _ := fileSignature(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bot.stopping:
+				return
+			case <-ticker.C:
+			}
+
+			current, ok := fileSignature(path)
+			if !ok || current == last {
+				continue
+			}
+
+			last = current
+			logger.Notice("Detected a change to %s", path)
+			onChange()
+		}
+	}()
+}
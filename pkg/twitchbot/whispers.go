@@ -0,0 +1,197 @@
+package twitchbot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/helix"
+)
+
+// Twitch allows 3 whispers a second and 100 a minute, to at most 40 people the bot hasn't
+// whispered before each day
+const (
+	whisperRateWindow    = time.Minute
+	whisperRateLimit     = 100
+	whisperBurst         = 3
+	whisperRecipientsDay = 40
+
+	// Whispers to someone new are cut to this length
+	whisperMaxLength = 500
+
+	// Whispers waiting beyond this are dropped
+	whisperQueueLength = 50
+)
+
+// Whether whispers are off, from the config or because Twitch refused one
+func (bot *Bot) whispersDisabled() bool {
+	bot.settingsMutex.RLock()
+	defer bot.settingsMutex.RUnlock()
+
+	return bot.WhispersDisabled
+}
+
+// Turns whispers off for the rest of the run
+func (bot *Bot) disableWhispers() {
+	bot.settingsMutex.Lock()
+	bot.WhispersDisabled = true
+	bot.settingsMutex.Unlock()
+}
+
+type pendingWhisper struct {
+	login   string
+	message string
+}
+
+// Sends whispers one at a time through Helix, within its limits
+type whisperSender struct {
+	mutex      sync.Mutex
+	queue      chan pendingWhisper
+	started    sync.Once
+	limiter    *tokenBucket
+	recipients map[string]bool
+	day        string
+}
+
+// Queues a whisper for Helix. Called from whisper after filtering.
+func (bot *Bot) queueWhisper(login, message string) {
+	bot.whispers.started.Do(func() {
+		bot.whispers.mutex.Lock()
+		bot.whispers.queue = make(chan pendingWhisper, whisperQueueLength)
+		bot.whispers.limiter = newTokenBucket(whisperRateWindow, whisperRateLimit, whisperBurst)
+		bot.whispers.mutex.Unlock()
+
+		go bot.sendWhispers()
+	})
+
+	select {
+	case bot.whispers.queue <- pendingWhisper{login: strings.ToLower(login), message: message}:
+	default:
+		logger.Warn("Bot.whisper: too many whispers waiting, dropping one to @%s", login)
+	}
+}
+
+func (bot *Bot) sendWhispers() {
+	for {
+		var whisper pendingWhisper
+		select {
+		case whisper = <-bot.whispers.queue:
+		case <-bot.stopping:
+			return
+		}
+
+		if !bot.allowWhisperRecipient(whisper.login) {
+			logger.Warn("Bot.whisper: already whispered %d new people today, not whispering @%s", whisperRecipientsDay, whisper.login)
+			continue
+		}
+
+		for {
+			wait := bot.whisperDelay()
+			if wait == 0 {
+				break
+			}
+			if bot.sleepUnlessStopped(wait) {
+				return
+			}
+		}
+
+		err := bot.sendWhisper(whisper)
+
+		helixError, ok := err.(*helix.Error)
+		if ok && helixError.StatusCode == http.StatusTooManyRequests {
+			// One more try once the bucket refills
+			wait := time.Until(helixError.RetryAt)
+			if wait <= 0 || wait > whisperRateWindow {
+				wait = time.Second
+			}
+			if bot.sleepUnlessStopped(wait) {
+				return
+			}
+			err = bot.sendWhisper(whisper)
+			helixError, ok = err.(*helix.Error)
+		}
+
+		switch {
+		case err == nil:
+		case ok && helixError.StatusCode == http.StatusUnauthorized:
+			logger.Error("Twitch won't let the bot whisper. Its token needs the user:manage:whispers scope. Whispers are off until restart: %s", err.Error())
+			bot.disableWhispers()
+		case ok && (helixError.StatusCode == http.StatusForbidden || helixError.StatusCode == http.StatusNotFound):
+			// The recipient blocks whispers from strangers, or the bot has no verified phone number
+			logger.Notice("Couldn't whisper @%s: %s", whisper.login, helixError.Message)
+		default:
+			logger.Error(err.Error())
+		}
+	}
+}
+
+// Sends one whisper, looking up both accounts' user IDs first
+func (bot *Bot) sendWhisper(whisper pendingWhisper) error {
+	fromID, err := bot.botUserID()
+	if err != nil {
+		return errors.New("Bot.sendWhisper: " + err.Error())
+	}
+
+	toID, found, err := bot.lookupUserID(whisper.login)
+	if err != nil {
+		return errors.New("Bot.sendWhisper: " + err.Error())
+	}
+	if !found {
+		return fmt.Errorf("Bot.sendWhisper: no Twitch account named %q", whisper.login)
+	}
+
+	message := whisper.message
+	if runes := []rune(message); len(runes) > whisperMaxLength {
+		message = string(runes[:whisperMaxLength])
+	}
+
+	err = bot.helix().SendWhisper(bot.context(), fromID, toID, message)
+	if err != nil {
+		// Keep it a *helix.Error so sendWhispers can tell rate limits and refusals apart
+		return err
+	}
+
+	logger.Quiet("Whispered @%s", whisper.login)
+
+	return nil
+}
+
+// How long until the next whisper may go out. A zero wait takes the token.
+func (bot *Bot) whisperDelay() time.Duration {
+	bot.whispers.mutex.Lock()
+	defer bot.whispers.mutex.Unlock()
+
+	wait := bot.whispers.limiter.wait(time.Now())
+	if wait == 0 {
+		bot.whispers.limiter.tokens--
+	}
+
+	return wait
+}
+
+// Counts a recipient against today's limit on new people, returning false once it's used up.
+// The bot can't see who it whispered before today, so everyone counts as new once a day.
+func (bot *Bot) allowWhisperRecipient(login string) bool {
+	bot.whispers.mutex.Lock()
+	defer bot.whispers.mutex.Unlock()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if bot.whispers.day != today {
+		bot.whispers.day = today
+		bot.whispers.recipients = map[string]bool{}
+	}
+
+	if bot.whispers.recipients[login] {
+		return true
+	}
+	if len(bot.whispers.recipients) >= whisperRecipientsDay {
+		return false
+	}
+
+	bot.whispers.recipients[login] = true
+
+	return true
+}
@@ -0,0 +1,423 @@
+// Package twitchtest runs a fake Twitch chat server in the same process, so a Bot can be tested
+// end to end: it authenticates, joins, answers PING and records what the Bot says, while the test
+// sends chat messages, notices and dropped connections its way.
+package twitchtest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/irc"
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+)
+
+const hostname = "tmi.twitch.tv"
+
+// Server is a fake Twitch chat server listening on a local port
+type Server struct {
+	// The OAuth token clients must send with PASS. Any token is accepted when empty, and justinfan
+	// logins need none.
+	Token string
+
+	listener net.Listener
+
+	mutex    sync.Mutex
+	clients  map[*client]bool
+	received []string
+	joined   map[string]bool
+	accepts  int
+	changed  chan struct{}
+}
+
+// One connected Bot
+type client struct {
+	conn   net.Conn
+	writer sync.Mutex
+	nick   string
+	token  string
+
+	// A hung client gets nothing and is ignored
+	hung bool
+}
+
+// NewServer starts a Server on a free local port. Close it when the test is done.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.New("twitchtest.NewServer: " + err.Error())
+	}
+
+	server := &Server{
+		listener: listener,
+		clients:  map[*client]bool{},
+		joined:   map[string]bool{},
+		changed:  make(chan struct{}),
+	}
+	go server.accept()
+
+	return server, nil
+}
+
+// Addr is the address the Server listens on, e.g. "127.0.0.1:51234"
+func (server *Server) Addr() string {
+	return server.listener.Addr().String()
+}
+
+// Dialer connects a Bot to this Server whatever its Server and Port settings are
+func (server *Server) Dialer() twitchbot.Dialer {
+	return twitchbot.DialerFunc(func(ctx context.Context, address string) (twitchbot.Conn, error) {
+		dialer := &net.Dialer{}
+		return dialer.DialContext(ctx, "tcp", server.Addr())
+	})
+}
+
+// Close stops listening and drops every connection
+func (server *Server) Close() error {
+	err := server.listener.Close()
+	server.Disconnect()
+	return err
+}
+
+// Disconnect drops every connection, as Twitch does during maintenance, so reconnection can be tested
+func (server *Server) Disconnect() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for client := range server.clients {
+		client.conn.Close()
+	}
+	server.clients = map[*client]bool{}
+	server.joined = map[string]bool{}
+	server.notify()
+}
+
+// Hang stops answering the current connections without closing them, like a connection that
+// silently died. New connections work normally.
+func (server *Server) Hang() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for client := range server.clients {
+		client.writer.Lock()
+		client.hung = true
+		client.writer.Unlock()
+	}
+}
+
+// Connections counts the connections accepted so far, including dropped ones
+func (server *Server) Connections() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.accepts
+}
+
+// Joined reports whether a connected client has joined the channel
+func (server *Server) Joined(channel string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.joined[strings.TrimPrefix(channel, "#")]
+}
+
+// Received lists every line clients sent, oldest first. Blank lines are kept, since Twitch
+// ignores them but a Bot shouldn't send any.
+func (server *Server) Received() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]string{}, server.received...)
+}
+
+// Messages lists the chat messages clients sent to a channel, oldest first
+func (server *Server) Messages(channel string) []string {
+	messages := []string{}
+	for _, line := range server.Received() {
+		message, err := irc.Parse(line)
+		if err == nil && message.Command == "PRIVMSG" && message.Channel() == strings.TrimPrefix(channel, "#") {
+			messages = append(messages, message.Trailing)
+		}
+	}
+
+	return messages
+}
+
+// WaitFor waits until a client has sent a line for which match returns true, and returns it
+func (server *Server) WaitFor(timeout time.Duration, match func(line string) bool) (string, error) {
+	deadline := time.After(timeout)
+	seen := 0
+
+	for {
+		server.mutex.Lock()
+		lines := server.received[seen:]
+		seen = len(server.received)
+		changed := server.changed
+		server.mutex.Unlock()
+
+		for _, line := range lines {
+			if match(line) {
+				return line, nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return "", fmt.Errorf("twitchtest.WaitFor: nothing matched within %s", timeout)
+		}
+	}
+}
+
+// WaitForMessage waits until a client says something in a channel containing text, and returns the message
+func (server *Server) WaitForMessage(timeout time.Duration, channel, text string) (string, error) {
+	line, err := server.WaitFor(timeout, func(line string) bool {
+		message, err := irc.Parse(line)
+		return err == nil && message.Command == "PRIVMSG" && message.Channel() == strings.TrimPrefix(channel, "#") && strings.Contains(message.Trailing, text)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	message, _ := irc.Parse(line)
+	return message.Trailing, nil
+}
+
+// WaitForJoin waits until a client has joined the channel
+func (server *Server) WaitForJoin(timeout time.Duration, channel string) error {
+	deadline := time.After(timeout)
+
+	for {
+		server.mutex.Lock()
+		joined := server.joined[strings.TrimPrefix(channel, "#")]
+		changed := server.changed
+		server.mutex.Unlock()
+
+		if joined {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return fmt.Errorf("twitchtest.WaitForJoin: #%s wasn't joined within %s", strings.TrimPrefix(channel, "#"), timeout)
+		}
+	}
+}
+
+// Send writes a raw line to every connected client
+func (server *Server) Send(line string) {
+	server.mutex.Lock()
+	clients := make([]*client, 0, len(server.clients))
+	for client := range server.clients {
+		clients = append(clients, client)
+	}
+	server.mutex.Unlock()
+
+	for _, client := range clients {
+		client.send(line)
+	}
+}
+
+// SendMessage sends a chat message from a user to a channel, with the tags Twitch would add.
+// Extra tags, such as "mod" or "badges", replace the defaults.
+func (server *Server) SendMessage(channel, username, text string, tags map[string]string) {
+	channel = strings.TrimPrefix(channel, "#")
+	username = strings.ToLower(username)
+
+	message := &irc.Message{
+		Tags: map[string]string{
+			"display-name": username,
+			"user-id":      fmt.Sprintf("%d", userID(username)),
+			"id":           fmt.Sprintf("%d-%d", time.Now().UnixNano(), userID(username)),
+			"tmi-sent-ts":  fmt.Sprintf("%d", time.Now().UnixNano()/int64(time.Millisecond)),
+			"mod":          "0",
+			"subscriber":   "0",
+		},
+		Prefix:      irc.Prefix{Name: username, User: username, Host: username + "." + hostname},
+		Command:     "PRIVMSG",
+		Params:      []string{"#" + channel},
+		Trailing:    text,
+		HasTrailing: true,
+	}
+	for key, value := range tags {
+		message.Tags[key] = value
+	}
+
+	server.Send(message.String())
+}
+
+// SendWhisper sends a whisper from a user to the bot. Like Twitch, it carries its ID as message-id.
+func (server *Server) SendWhisper(to, username, text string) {
+	username = strings.ToLower(username)
+	server.Send(fmt.Sprintf("@display-name=%s;message-id=%d;user-id=%d :%s!%s@%s.%s WHISPER %s :%s", username, time.Now().UnixNano(), userID(username), username, username, username, hostname, strings.ToLower(to), text))
+}
+
+// SendNotice sends a NOTICE to a channel, or to "*" for server notices
+func (server *Server) SendNotice(channel, msgID, text string) {
+	target := "*"
+	if channel != "*" && channel != "" {
+		target = "#" + strings.TrimPrefix(channel, "#")
+	}
+
+	server.Send(fmt.Sprintf("@msg-id=%s :%s NOTICE %s :%s", msgID, hostname, target, text))
+}
+
+// Reconnect tells clients the server is about to restart, as Twitch does before maintenance
+func (server *Server) Reconnect() {
+	server.Send(":" + hostname + " RECONNECT")
+}
+
+// Ping sends a PING, which the Bot should answer with a PONG
+func (server *Server) Ping() {
+	server.Send("PING :" + hostname)
+}
+
+// A stable made-up user-id for a login
+func userID(username string) uint32 {
+	var hash uint32 = 2166136261
+	for _, char := range []byte(username) {
+		hash ^= uint32(char)
+		hash *= 16777619
+	}
+
+	return hash % 1000000000
+}
+
+func (server *Server) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		client := &client{conn: conn}
+		server.mutex.Lock()
+		server.clients[client] = true
+		server.accepts++
+		server.notify()
+		server.mutex.Unlock()
+
+		go server.serve(client)
+	}
+}
+
+// Wakes everyone waiting on a change. Called with the mutex held.
+func (server *Server) notify() {
+	close(server.changed)
+	server.changed = make(chan struct{})
+}
+
+func (server *Server) serve(client *client) {
+	defer func() {
+		client.conn.Close()
+		server.mutex.Lock()
+		delete(server.clients, client)
+		if len(server.clients) == 0 {
+			server.joined = map[string]bool{}
+		}
+		server.notify()
+		server.mutex.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(client.conn)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		server.mutex.Lock()
+		server.received = append(server.received, line)
+		server.notify()
+		server.mutex.Unlock()
+		if line == "" {
+			continue
+		}
+
+		client.writer.Lock()
+		hung := client.hung
+		client.writer.Unlock()
+		if hung {
+			continue
+		}
+
+		message, err := irc.Parse(line)
+		if err != nil {
+			continue
+		}
+
+		if !server.handle(client, message) {
+			return
+		}
+	}
+}
+
+// Answers a line the way Twitch would. Returns false when the connection should be closed.
+func (server *Server) handle(client *client, message *irc.Message) bool {
+	switch message.Command {
+	case "PASS":
+		client.token = message.Param(0)
+	case "NICK":
+		client.nick = strings.ToLower(message.Param(0))
+		// Like Twitch, justinfan logins can read chat without a token
+		if server.Token != "" && client.token != server.Token && !strings.HasPrefix(client.nick, "justinfan") {
+			client.send(":" + hostname + " NOTICE * :Login authentication failed")
+			return false
+		}
+
+		for _, reply := range []string{
+			fmt.Sprintf(":%s 001 %s :Welcome, GLHF!", hostname, client.nick),
+			fmt.Sprintf(":%s 002 %s :Your host is %s", hostname, client.nick, hostname),
+			fmt.Sprintf(":%s 003 %s :This server is rather new", hostname, client.nick),
+			fmt.Sprintf(":%s 004 %s :-", hostname, client.nick),
+			fmt.Sprintf(":%s 375 %s :-", hostname, client.nick),
+			fmt.Sprintf(":%s 372 %s :You are in a maze of twisty passages, all alike.", hostname, client.nick),
+			fmt.Sprintf(":%s 376 %s :>", hostname, client.nick),
+		} {
+			client.send(reply)
+		}
+	case "CAP":
+		if message.Param(0) == "REQ" {
+			client.send(fmt.Sprintf(":%s CAP * ACK :%s", hostname, message.Param(1)))
+		}
+	case "JOIN":
+		for _, channel := range strings.Split(message.Param(0), ",") {
+			channel = strings.TrimPrefix(channel, "#")
+			client.send(fmt.Sprintf(":%s!%s@%s.%s JOIN #%s", client.nick, client.nick, client.nick, hostname, channel))
+			client.send(fmt.Sprintf(":%s.%s 353 %s = #%s :%s", client.nick, hostname, client.nick, channel, client.nick))
+			client.send(fmt.Sprintf(":%s.%s 366 %s #%s :End of /NAMES list", client.nick, hostname, client.nick, channel))
+			client.send(fmt.Sprintf("@emote-only=0;followers-only=-1;r9k=0;slow=0;subs-only=0 :%s ROOMSTATE #%s", hostname, channel))
+
+			server.mutex.Lock()
+			server.joined[channel] = true
+			server.notify()
+			server.mutex.Unlock()
+		}
+	case "PART":
+		channel := strings.TrimPrefix(message.Param(0), "#")
+		server.mutex.Lock()
+		delete(server.joined, channel)
+		server.notify()
+		server.mutex.Unlock()
+	case "PING":
+		client.send(fmt.Sprintf(":%s PONG %s :%s", hostname, hostname, message.Param(0)))
+	case "QUIT":
+		return false
+	}
+
+	return true
+}
+
+func (client *client) send(line string) {
+	client.writer.Lock()
+	defer client.writer.Unlock()
+
+	if client.hung {
+		return
+	}
+
+	client.conn.Write([]byte(line + "\r\n"))
+}
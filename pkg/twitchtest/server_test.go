@@ -0,0 +1,196 @@
+package twitchtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mike1104/chuckbot/pkg/twitchbot"
+	"github.com/mike1104/chuckbot/pkg/twitchtest"
+)
+
+const timeout = 5 * time.Second
+
+// Starts a Bot connected to a new Server. The Bot is stopped and the Server closed when the
+// test ends.
+func startBot(t *testing.T, channels ...string) (*twitchtest.Server, *twitchbot.Bot, <-chan error) {
+	server, err := twitchtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Token = "oauth:test"
+
+	bot, err := twitchbot.NewBot(&twitchbot.Config{
+		BotName:             "chuckbot",
+		Channel:             channels[0],
+		Channels:            channels[1:],
+		Server:              "irc.chat.twitch.tv",
+		Port:                "6697",
+		Token:               "oauth:test",
+		SkipTokenValidation: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.Dialer = server.Dialer()
+
+	done := make(chan error, 1)
+	go func() { done <- bot.StartContext(context.Background()) }()
+
+	t.Cleanup(func() {
+		bot.Stop()
+		server.Close()
+	})
+
+	for _, channel := range channels {
+		err = server.WaitForJoin(timeout, channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return server, bot, done
+}
+
+func TestJoinAndReply(t *testing.T) {
+	server, _, _ := startBot(t, "mikkeever")
+
+	if !strings.HasPrefix(server.Received()[0], "PASS oauth:test") {
+		t.Errorf("first line = %q, want PASS", server.Received()[0])
+	}
+
+	server.SendMessage("mikkeever", "viewer", "!help", nil)
+	reply, err := server.WaitForMessage(timeout, "mikkeever", "Commands:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "!help") {
+		t.Errorf("reply = %q, want it to list !help", reply)
+	}
+
+	// Every line ends in exactly one CRLF, so none comes through blank
+	for i, line := range server.Received() {
+		if line == "" {
+			t.Errorf("line %d is blank", i)
+		}
+	}
+}
+
+func TestDispatchAcrossChannels(t *testing.T) {
+	server, _, _ := startBot(t, "mikkeever", "otherchannel")
+
+	server.SendMessage("mikkeever", "viewer", "!help", nil)
+	server.SendMessage("otherchannel", "viewer", "!help", nil)
+
+	for _, channel := range []string{"mikkeever", "otherchannel"} {
+		_, err := server.WaitForMessage(timeout, channel, "Commands:")
+		if err != nil {
+			t.Fatalf("#%s: %s", channel, err.Error())
+		}
+	}
+}
+
+func TestRejectedToken(t *testing.T) {
+	server, err := twitchtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Token = "oauth:right"
+
+	bot, err := twitchbot.NewBot(&twitchbot.Config{
+		BotName:             "chuckbot",
+		Channel:             "mikkeever",
+		Server:              "irc.chat.twitch.tv",
+		Port:                "6697",
+		Token:               "oauth:wrong",
+		SkipTokenValidation: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.Dialer = server.Dialer()
+	defer bot.Stop()
+
+	go bot.StartContext(context.Background())
+
+	_, err = server.WaitFor(timeout, func(line string) bool { return strings.HasPrefix(line, "NICK") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.WaitForJoin(time.Second, "mikkeever") == nil {
+		t.Error("joined with a token the server rejected")
+	}
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	server, _, _ := startBot(t, "mikkeever")
+
+	server.Disconnect()
+	err := server.WaitForJoin(timeout, "mikkeever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connections := server.Connections(); connections != 2 {
+		t.Errorf("Connections() = %d, want 2", connections)
+	}
+
+	server.SendMessage("mikkeever", "viewer", "!help", nil)
+	_, err = server.WaitForMessage(timeout, "mikkeever", "Commands:")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconnectWhenAsked(t *testing.T) {
+	server, _, _ := startBot(t, "mikkeever")
+
+	server.Reconnect()
+	logins := 0
+	_, err := server.WaitFor(timeout, func(line string) bool {
+		if strings.HasPrefix(line, "PASS") {
+			logins++
+		}
+		return logins == 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SendMessage("mikkeever", "viewer", "!help", nil)
+	_, err = server.WaitForMessage(timeout, "mikkeever", "Commands:")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	server, _, _ := startBot(t, "mikkeever")
+
+	server.Ping()
+	_, err := server.WaitFor(timeout, func(line string) bool { return strings.HasPrefix(line, "PONG") })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	server, bot, done := startBot(t, "mikkeever")
+
+	bot.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("StartContext returned %v after Stop, want nil", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("StartContext didn't return after Stop")
+	}
+
+	_, err := server.WaitFor(timeout, func(line string) bool { return strings.HasPrefix(line, "QUIT") })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
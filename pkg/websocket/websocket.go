@@ -0,0 +1,359 @@
+// Package websocket is a small WebSocket (RFC 6455) server and client for text messages, enough
+// for the bot's admin event feed
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Added to the client's key to prove the server speaks WebSocket
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize is the largest message ReadMessage accepts
+const MaxMessageSize = 1 << 20
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// ErrClosed is returned by ReadMessage once the other side closes the connection
+var ErrClosed = errors.New("websocket: closed")
+
+// Conn is one end of a WebSocket connection. Writes may come from several goroutines, reads
+// from one.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Clients mask what they send, servers don't
+	client bool
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+}
+
+// Upgrade turns an HTTP request into a WebSocket connection. On failure it has already answered
+// the request with an error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerHas(r.Header, "Connection", "upgrade") ||
+		!headerHas(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket.Upgrade: not a WebSocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket.Upgrade: unsupported version")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't upgrade this connection", http.StatusInternalServerError)
+		return nil, errors.New("websocket.Upgrade: the server can't hijack connections")
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, errors.New("websocket.Upgrade: " + err.Error())
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	_, err = buffered.WriteString(response)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("websocket.Upgrade: " + err.Error())
+	}
+
+	return &Conn{conn: conn, reader: buffered.Reader}, nil
+}
+
+// Dial connects to a ws:// or wss:// address, sending header with the handshake, e.g. for an
+// Authorization header
+func Dial(ctx context.Context, address string, header http.Header) (*Conn, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.New("websocket.Dial: " + err.Error())
+	}
+
+	host := parsed.Host
+	secure := false
+	switch parsed.Scheme {
+	case "ws":
+		if parsed.Port() == "" {
+			host += ":80"
+		}
+	case "wss":
+		secure = true
+		if parsed.Port() == "" {
+			host += ":443"
+		}
+	default:
+		return nil, errors.New("websocket.Dial: address must start with ws:// or wss://")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, errors.New("websocket.Dial: " + err.Error())
+	}
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: parsed.Hostname()})
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return nil, errors.New("websocket.Dial: " + err.Error())
+		}
+		conn = tlsConn
+	}
+
+	nonce := make([]byte, 16)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("websocket.Dial: " + err.Error())
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        parsed,
+		Host:       parsed.Host,
+		Header:     http.Header{},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	err = request.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("websocket.Dial: " + err.Error())
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("websocket.Dial: " + err.Error())
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		conn.Close()
+		return nil, errors.New("websocket.Dial: " + response.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket.Dial: the server's handshake doesn't match")
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+// WriteText sends a text message
+func (ws *Conn) WriteText(text []byte) error {
+	return ws.writeFrame(opText, text)
+}
+
+// ReadMessage waits for the next text or binary message, answering pings along the way. Returns
+// ErrClosed once the other side closes.
+func (ws *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			err = ws.writeFrame(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			ws.writeFrame(opClose, payload)
+			ws.conn.Close()
+			return nil, ErrClosed
+		case opText, opBinary, opContinuation:
+		default:
+			ws.Close()
+			return nil, errors.New("websocket: unknown opcode")
+		}
+
+		message = append(message, payload...)
+		if len(message) > MaxMessageSize {
+			ws.Close()
+			return nil, errors.New("websocket: message too large")
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// Ping sends a ping, e.g. to keep a quiet connection from being closed by a proxy
+func (ws *Conn) Ping() error {
+	return ws.writeFrame(opPing, nil)
+}
+
+// Close says goodbye and closes the connection
+func (ws *Conn) Close() error {
+	var err error
+	ws.closeOnce.Do(func() {
+		ws.writeFrame(opClose, []byte{0x03, 0xE8}) // 1000, normal closure
+		err = ws.conn.Close()
+	})
+
+	return err
+}
+
+func (ws *Conn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(ws.reader, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(ws.reader, extended)
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(ws.reader, extended)
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if length > MaxMessageSize {
+		ws.Close()
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		_, err = io.ReadFull(ws.reader, mask)
+		if err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(ws.reader, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (ws *Conn) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if ws.client {
+		maskBit = 0x80
+	}
+
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	if ws.client {
+		mask := make([]byte, 4)
+		_, err := rand.Read(mask)
+		if err != nil {
+			return err
+		}
+		frame = append(frame, mask...)
+
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	_, err := ws.conn.Write(append(frame, payload...))
+	return err
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Whether a comma separated header has a token, ignoring case
+func headerHas(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
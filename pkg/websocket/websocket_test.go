@@ -0,0 +1,279 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Starts a server that upgrades every request and hands the connection to handle
+func newServer(t *testing.T, handle func(ws *Conn)) (*httptest.Server, string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		handle(ws)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, address string) *Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws, err := Dial(ctx, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	return ws
+}
+
+// An unmasked frame as a server would send it
+func rawFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	return append([]byte{first, byte(len(payload))}, payload...)
+}
+
+func TestEchoLengths(t *testing.T) {
+	_, address := newServer(t, func(ws *Conn) {
+		for {
+			message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			ws.WriteText(message)
+		}
+	})
+	ws := dial(t, address)
+
+	// A 7 bit length, the 16 bit extended length and the 64 bit one
+	for _, size := range []int{0, 5, 125, 126, 200, 0xFFFF, 0x10000, 70000} {
+		message := bytes.Repeat([]byte("chuck"), size/5+1)[:size]
+		err := ws.WriteText(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		echoed, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("echo of %d bytes: %s", size, err.Error())
+		}
+		if !bytes.Equal(echoed, message) {
+			t.Errorf("echo of %d bytes came back as %d bytes", size, len(echoed))
+		}
+	}
+}
+
+func TestMasking(t *testing.T) {
+	near, far := net.Pipe()
+	defer near.Close()
+	defer far.Close()
+
+	client := &Conn{conn: near, reader: bufio.NewReader(near), client: true}
+	message := []byte("Chuck Norris can unmask this")
+
+	go client.WriteText(message)
+	wire := make([]byte, 2+4+len(message))
+	_, err := io.ReadFull(far, wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wire[1]&0x80 == 0 {
+		t.Fatal("the client sent an unmasked frame")
+	}
+	if bytes.Contains(wire, message) {
+		t.Error("the client's frame has the message in the clear")
+	}
+	mask := wire[2:6]
+	for i := range message {
+		if wire[6+i]^mask[i%4] != message[i] {
+			t.Fatalf("byte %d doesn't unmask", i)
+		}
+	}
+
+	// Servers send theirs in the clear
+	server := &Conn{conn: far, reader: bufio.NewReader(far)}
+	go server.WriteText(message)
+	wire = make([]byte, 2+len(message))
+	_, err = io.ReadFull(near, wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wire, rawFrame(true, opText, message)) {
+		t.Errorf("server frame = %q", wire)
+	}
+}
+
+func TestFragmentsWithPing(t *testing.T) {
+	pong := make(chan []byte, 1)
+	_, address := newServer(t, func(ws *Conn) {
+		ws.conn.Write(rawFrame(false, opText, []byte("Chuck ")))
+		ws.conn.Write(rawFrame(false, opContinuation, []byte("Norris ")))
+		ws.conn.Write(rawFrame(true, opPing, []byte("still there?")))
+		ws.conn.Write(rawFrame(true, opContinuation, []byte("counted to infinity")))
+
+		_, opcode, payload, err := ws.readFrame()
+		if err != nil || opcode != opPong {
+			payload = nil
+		}
+		pong <- payload
+	})
+	ws := dial(t, address)
+
+	message, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message) != "Chuck Norris counted to infinity" {
+		t.Errorf("reassembled %q", message)
+	}
+
+	select {
+	case payload := <-pong:
+		if string(payload) != "still there?" {
+			t.Errorf("pong = %q, want the ping's payload", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	// One frame that says it's too big, before sending any of it
+	_, address := newServer(t, func(ws *Conn) {
+		header := []byte{0x80 | opText, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(header[2:], MaxMessageSize+1)
+		ws.conn.Write(header)
+		ws.ReadMessage()
+	})
+	_, err := dial(t, address).ReadMessage()
+	if err == nil || !strings.Contains(err.Error(), "frame too large") {
+		t.Errorf("ReadMessage of a huge frame = %v, want frame too large", err)
+	}
+
+	// Fragments that are each fine but too big together
+	_, address = newServer(t, func(ws *Conn) {
+		chunk := make([]byte, MaxMessageSize/2+1)
+		ws.writeMutex.Lock()
+		for _, opcode := range []byte{opText, opContinuation} {
+			header := []byte{opcode, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint64(header[2:], uint64(len(chunk)))
+			ws.conn.Write(append(header, chunk...))
+		}
+		ws.writeMutex.Unlock()
+		ws.ReadMessage()
+	})
+	_, err = dial(t, address).ReadMessage()
+	if err == nil || !strings.Contains(err.Error(), "message too large") {
+		t.Errorf("ReadMessage of huge fragments = %v, want message too large", err)
+	}
+
+	// Right at the limit is fine
+	_, address = newServer(t, func(ws *Conn) {
+		ws.WriteText(make([]byte, MaxMessageSize))
+		ws.ReadMessage()
+	})
+	message, err := dial(t, address).ReadMessage()
+	if err != nil || len(message) != MaxMessageSize {
+		t.Errorf("ReadMessage at the limit = %d bytes, %v", len(message), err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	_, address := newServer(t, func(ws *Conn) {
+		ws.WriteText([]byte("bye"))
+	})
+	ws := dial(t, address)
+
+	message, err := ws.ReadMessage()
+	if err != nil || string(message) != "bye" {
+		t.Fatalf("ReadMessage = %q, %v", message, err)
+	}
+
+	_, err = ws.ReadMessage()
+	if err != ErrClosed {
+		t.Errorf("ReadMessage after the server closed = %v, want ErrClosed", err)
+	}
+}
+
+func TestDialSendsHeader(t *testing.T) {
+	authorization := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		ws, err := Upgrade(w, r)
+		if err == nil {
+			ws.Close()
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Authorization": {"Bearer chuck"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+
+	if got := <-authorization; got != "Bearer chuck" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	server, address := newServer(t, func(ws *Conn) {})
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET = %d, want 400", response.StatusCode)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("Connection", "keep-alive, Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	request.Header.Set("Sec-WebSocket-Version", "8")
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUpgradeRequired || response.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("old version = %d, want 426 asking for 13", response.StatusCode)
+	}
+
+	_, err = Dial(context.Background(), strings.Replace(address, "ws://", "http://", 1), nil)
+	if err == nil {
+		t.Error("Dial of an http:// address succeeded")
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example handshake from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %s", got)
+	}
+}